@@ -1,6 +1,7 @@
 package at
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"slices"
@@ -32,7 +33,7 @@ func New(device string, options ...iso7816.Option) (*AT, error) {
 }
 
 // Connect opens the serial port and initializes the eUICC transport.
-func (a *AT) Connect() error {
+func (a *AT) Connect(ctx context.Context) error {
 	if a.closed {
 		return errors.New("AT channel is closed")
 	}
@@ -44,7 +45,7 @@ func (a *AT) Connect() error {
 		return fmt.Errorf("open serial port %s: %w", a.device, err)
 	}
 	channel := iso7816.NewChannel(reader, a.options...)
-	if err := channel.Connect(); err != nil {
+	if err := channel.Connect(ctx); err != nil {
 		return errors.Join(err, channel.Disconnect())
 	}
 	a.channel = channel
@@ -64,30 +65,30 @@ func (a *AT) Disconnect() error {
 }
 
 // Transmit exchanges one raw APDU with the modem.
-func (a *AT) Transmit(command []byte) ([]byte, error) {
+func (a *AT) Transmit(ctx context.Context, command []byte) ([]byte, error) {
 	channel, err := a.smartCardChannel()
 	if err != nil {
 		return nil, err
 	}
-	return channel.Transmit(command)
+	return channel.Transmit(ctx, command)
 }
 
 // OpenLogicalChannel opens a logical channel and selects aid.
-func (a *AT) OpenLogicalChannel(aid []byte) (byte, error) {
+func (a *AT) OpenLogicalChannel(ctx context.Context, aid []byte) (byte, error) {
 	channel, err := a.smartCardChannel()
 	if err != nil {
 		return 0, err
 	}
-	return channel.OpenLogicalChannel(aid)
+	return channel.OpenLogicalChannel(ctx, aid)
 }
 
 // CloseLogicalChannel closes a logical channel.
-func (a *AT) CloseLogicalChannel(logicalChannel byte) error {
+func (a *AT) CloseLogicalChannel(ctx context.Context, logicalChannel byte) error {
 	channel, err := a.smartCardChannel()
 	if err != nil {
 		return err
 	}
-	return channel.CloseLogicalChannel(logicalChannel)
+	return channel.CloseLogicalChannel(ctx, logicalChannel)
 }
 
 func (a *AT) smartCardChannel() (*iso7816.Channel, error) {
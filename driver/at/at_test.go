@@ -16,13 +16,13 @@ func TestNewDoesNotOpenDevice(t *testing.T) {
 	if channel.channel != nil {
 		t.Fatal("New() opened the serial port")
 	}
-	if _, err := channel.Transmit(nil); err == nil {
+	if _, err := channel.Transmit(t.Context(), nil); err == nil {
 		t.Fatal("Transmit() before Connect error = nil")
 	}
 	if err := channel.Disconnect(); err != nil {
 		t.Fatalf("Disconnect() before Connect error = %v", err)
 	}
-	if err := channel.Connect(); err == nil {
+	if err := channel.Connect(t.Context()); err == nil {
 		t.Fatal("Connect() after Disconnect error = nil")
 	}
 }
@@ -32,7 +32,7 @@ func TestConnectOpensDevice(t *testing.T) {
 	if err != nil {
 		t.Fatalf("New() error = %v", err)
 	}
-	if err := channel.Connect(); err == nil {
+	if err := channel.Connect(t.Context()); err == nil {
 		t.Fatal("Connect() error = nil for missing device")
 	}
 	if channel.channel != nil {
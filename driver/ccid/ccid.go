@@ -65,7 +65,7 @@ func (c *Reader) SetReader(reader string) error {
 	return nil
 }
 
-func (c *Reader) Connect() error {
+func (c *Reader) Connect(ctx context.Context) error {
 	if c.closed {
 		return errors.New("ccid reader is closed")
 	}
@@ -76,14 +76,14 @@ func (c *Reader) Connect() error {
 		return errors.New("ccid reader is required")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	openCtx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
-	reader, err := wwanccid.Open(ctx, c.reader)
+	reader, err := wwanccid.Open(openCtx, c.reader)
 	if err != nil {
 		return fmt.Errorf("open CCID reader %q: %w", c.reader, err)
 	}
 	channel := iso7816.NewChannel(reader, c.options...)
-	if err := channel.Connect(); err != nil {
+	if err := channel.Connect(ctx); err != nil {
 		return errors.Join(err, channel.Disconnect())
 	}
 	c.channel = channel
@@ -103,28 +103,28 @@ func (c *Reader) Disconnect() error {
 	return c.channel.Disconnect()
 }
 
-func (c *Reader) Transmit(command []byte) ([]byte, error) {
+func (c *Reader) Transmit(ctx context.Context, command []byte) ([]byte, error) {
 	channel, err := c.smartCardChannel()
 	if err != nil {
 		return nil, err
 	}
-	return channel.Transmit(command)
+	return channel.Transmit(ctx, command)
 }
 
-func (c *Reader) OpenLogicalChannel(aid []byte) (byte, error) {
+func (c *Reader) OpenLogicalChannel(ctx context.Context, aid []byte) (byte, error) {
 	channel, err := c.smartCardChannel()
 	if err != nil {
 		return 0, err
 	}
-	return channel.OpenLogicalChannel(aid)
+	return channel.OpenLogicalChannel(ctx, aid)
 }
 
-func (c *Reader) CloseLogicalChannel(logicalChannel byte) error {
+func (c *Reader) CloseLogicalChannel(ctx context.Context, logicalChannel byte) error {
 	channel, err := c.smartCardChannel()
 	if err != nil {
 		return err
 	}
-	return channel.CloseLogicalChannel(logicalChannel)
+	return channel.CloseLogicalChannel(ctx, logicalChannel)
 }
 
 func (c *Reader) smartCardChannel() (*iso7816.Channel, error) {
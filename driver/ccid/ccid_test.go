@@ -18,7 +18,7 @@ func TestSetReaderReturnsStateErrors(t *testing.T) {
 
 func TestConnectRequiresReader(t *testing.T) {
 	reader := New()
-	err := reader.Connect()
+	err := reader.Connect(t.Context())
 	if err == nil {
 		t.Fatal("Connect() error = nil, want reader required error")
 	}
@@ -25,14 +25,18 @@ type LoggingRoundTripper struct {
 // NewLoggingRoundTripper returns a transport that trusts rootCAs and logs raw
 // HTTP bodies when debug logging is enabled. Logger must not be nil.
 func NewLoggingRoundTripper(rootCAs *x509.CertPool, logger *slog.Logger) *LoggingRoundTripper {
-	transport := http.DefaultTransport.(*http.Transport).Clone()
-	transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
 	return &LoggingRoundTripper{
 		logger:    logger,
-		transport: transport,
+		transport: newTransport(rootCAs),
 	}
 }
 
+func newTransport(rootCAs *x509.CertPool) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	return transport
+}
+
 // RoundTrip implements http.RoundTripper.
 func (l *LoggingRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
 	transportRequest := request.Clone(request.Context())
@@ -85,14 +89,20 @@ func (l *LoggingRoundTripper) CloseIdleConnections() {
 }
 
 // NewHTTPClient creates an HTTP client configured with the trusted eSIM root
-// certificates and raw debug logging. Logger must not be nil.
-func NewHTTPClient(logger *slog.Logger, timeout time.Duration) (*http.Client, error) {
+// certificates and raw debug logging. Logger must not be nil. If wrap is not nil,
+// the requests logged are sent with the transport it returns for the one trusting
+// the root certificates, such as a recorder wrapping it or a replayer replacing it.
+func NewHTTPClient(logger *slog.Logger, timeout time.Duration, wrap func(http.RoundTripper) http.RoundTripper) (*http.Client, error) {
 	rootCAs, err := rootci.TrustedRootCAs()
 	if err != nil {
 		return nil, fmt.Errorf("load trusted root CAs: %w", err)
 	}
+	var transport http.RoundTripper = newTransport(rootCAs)
+	if wrap != nil {
+		transport = wrap(transport)
+	}
 	return &http.Client{
 		Timeout:   timeout,
-		Transport: NewLoggingRoundTripper(rootCAs, logger),
+		Transport: &LoggingRoundTripper{logger: logger, transport: transport},
 	}, nil
 }
@@ -10,6 +10,8 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+
+	"time"
 )
 
 type fakeHTTPTransport struct {
@@ -190,3 +192,22 @@ func TestLoggingRoundTripperUsesDefaultTransportAndClosesIdleConnections(t *test
 		t.Fatal("CloseIdleConnections() was not forwarded")
 	}
 }
+
+func TestNewHTTPClientWrapsTransport(t *testing.T) {
+	fake := new(fakeHTTPTransport)
+	var wrapped http.RoundTripper
+	client, err := NewHTTPClient(discardLogger(), time.Minute, func(transport http.RoundTripper) http.RoundTripper {
+		wrapped = transport
+		return fake
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	if _, ok := wrapped.(*http.Transport); !ok {
+		t.Fatalf("wrapped transport type = %T, want *http.Transport", wrapped)
+	}
+	roundTripper, ok := client.Transport.(*LoggingRoundTripper)
+	if !ok || roundTripper.transport != fake || client.Timeout != time.Minute {
+		t.Fatalf("NewHTTPClient() = %+v, want the wrapped transport logged with the timeout", client)
+	}
+}
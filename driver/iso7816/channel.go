@@ -26,8 +26,9 @@ type Transmitter interface {
 // Option configures a Channel.
 type Option func(*Channel)
 
-// WithTimeout sets the timeout for each transport operation. A non-positive
-// timeout makes operations expire immediately.
+// WithTimeout sets the timeout for each transport operation, which also ends
+// when the context of the operation is done. A non-positive timeout makes
+// operations expire immediately.
 func WithTimeout(timeout time.Duration) Option {
 	return func(channel *Channel) {
 		channel.timeout = timeout
@@ -53,11 +54,11 @@ func NewChannel(tx Transmitter, options ...Option) *Channel {
 }
 
 // Connect initializes the eUICC transport.
-func (c *Channel) Connect() error {
+func (c *Channel) Connect(ctx context.Context) error {
 	if c.closed {
 		return errors.New("smart card channel is closed")
 	}
-	ctx, cancel := c.newContext()
+	ctx, cancel := c.newContext(ctx)
 	defer cancel()
 	response, err := c.tx.Transmit(ctx, []byte(connectAPDU))
 	if err != nil {
@@ -76,7 +77,7 @@ func (c *Channel) Disconnect() error {
 	}
 	var channelErr error
 	if c.channel != 0 {
-		ctx, cancel := c.newContext()
+		ctx, cancel := c.newContext(context.Background())
 		channelErr = c.closeLogicalChannel(ctx, c.channel)
 		cancel()
 	}
@@ -89,11 +90,11 @@ func (c *Channel) Disconnect() error {
 }
 
 // Transmit exchanges one raw APDU with the underlying transport.
-func (c *Channel) Transmit(command []byte) ([]byte, error) {
+func (c *Channel) Transmit(ctx context.Context, command []byte) ([]byte, error) {
 	if c.closed {
 		return nil, errors.New("smart card channel is closed")
 	}
-	ctx, cancel := c.newContext()
+	ctx, cancel := c.newContext(ctx)
 	defer cancel()
 	response, err := c.tx.Transmit(ctx, command)
 	if err != nil {
@@ -103,7 +104,7 @@ func (c *Channel) Transmit(command []byte) ([]byte, error) {
 }
 
 // OpenLogicalChannel opens a channel and selects AID on it.
-func (c *Channel) OpenLogicalChannel(aid []byte) (byte, error) {
+func (c *Channel) OpenLogicalChannel(ctx context.Context, aid []byte) (byte, error) {
 	if c.closed {
 		return 0, errors.New("smart card channel is closed")
 	}
@@ -113,18 +114,19 @@ func (c *Channel) OpenLogicalChannel(aid []byte) (byte, error) {
 	if c.channel != 0 {
 		return 0, fmt.Errorf("logical channel %d is already open", c.channel)
 	}
-	ctx, cancel := c.newContext()
-	channel, err := c.openChannel(ctx)
+	operationCtx, cancel := c.newContext(ctx)
+	channel, err := c.openChannel(operationCtx)
 	cancel()
 	if err != nil {
 		return 0, err
 	}
 	c.channel = channel
-	ctx, cancel = c.newContext()
-	err = c.selectAID(ctx, channel, aid)
+	operationCtx, cancel = c.newContext(ctx)
+	err = c.selectAID(operationCtx, channel, aid)
 	cancel()
 	if err != nil {
-		cleanupCtx, cleanupCancel := c.newContext()
+		// The channel is closed even if ctx is done.
+		cleanupCtx, cleanupCancel := c.newContext(context.WithoutCancel(ctx))
 		cleanupErr := c.closeLogicalChannel(cleanupCtx, channel)
 		cleanupCancel()
 		return 0, errors.Join(err, cleanupErr)
@@ -133,17 +135,17 @@ func (c *Channel) OpenLogicalChannel(aid []byte) (byte, error) {
 }
 
 // CloseLogicalChannel closes channel.
-func (c *Channel) CloseLogicalChannel(channel byte) error {
+func (c *Channel) CloseLogicalChannel(ctx context.Context, channel byte) error {
 	if c.closed {
 		return errors.New("smart card channel is closed")
 	}
-	ctx, cancel := c.newContext()
+	ctx, cancel := c.newContext(ctx)
 	defer cancel()
 	return c.closeLogicalChannel(ctx, channel)
 }
 
-func (c *Channel) newContext() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), c.timeout)
+func (c *Channel) newContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, c.timeout)
 }
 
 func (c *Channel) openChannel(ctx context.Context) (byte, error) {
@@ -216,6 +218,18 @@ func selectAIDCommand(channel byte, aid []byte) ([]byte, error) {
 	return command, nil
 }
 
+// LogicalChannel returns the logical channel encoded in the class byte of a command APDU.
+func LogicalChannel(command []byte) byte {
+	if len(command) == 0 {
+		return 0
+	}
+	cla := command[0]
+	if cla&0x40 != 0 {
+		return cla&0x0F + 4
+	}
+	return cla & 0x03
+}
+
 func classByteForChannel(cla, channel byte) (byte, error) {
 	if channel < 4 {
 		return (cla & 0x9C) | channel, nil
@@ -37,7 +37,7 @@ func (f *fakeTransmitter) Close() error {
 func TestChannelConnectSendsInitializationAPDU(t *testing.T) {
 	fake := &fakeTransmitter{responses: [][]byte{{0x90, 0x00}}}
 	channel := NewChannel(fake)
-	if err := channel.Connect(); err != nil {
+	if err := channel.Connect(t.Context()); err != nil {
 		t.Fatalf("Connect() error = %v", err)
 	}
 	if want := []byte(connectAPDU); !bytes.Equal(fake.requests[0], want) {
@@ -52,7 +52,7 @@ func TestChannelOpenLogicalChannelSelectsAID(t *testing.T) {
 	}}
 	channel := NewChannel(fake)
 
-	got, err := channel.OpenLogicalChannel([]byte{0xA0, 0x00})
+	got, err := channel.OpenLogicalChannel(t.Context(), []byte{0xA0, 0x00})
 	if err != nil {
 		t.Fatalf("OpenLogicalChannel() error = %v", err)
 	}
@@ -71,10 +71,10 @@ func TestChannelOpenLogicalChannelRejectsSecondChannel(t *testing.T) {
 		{0x90, 0x00},
 	}}
 	channel := NewChannel(fake)
-	if _, err := channel.OpenLogicalChannel([]byte{0xA0}); err != nil {
+	if _, err := channel.OpenLogicalChannel(t.Context(), []byte{0xA0}); err != nil {
 		t.Fatalf("first OpenLogicalChannel() error = %v", err)
 	}
-	if _, err := channel.OpenLogicalChannel([]byte{0xA0}); err == nil {
+	if _, err := channel.OpenLogicalChannel(t.Context(), []byte{0xA0}); err == nil {
 		t.Fatal("second OpenLogicalChannel() error = nil")
 	}
 	if len(fake.requests) != 2 {
@@ -90,7 +90,7 @@ func TestChannelOpenLogicalChannelClosesWhenSelectFails(t *testing.T) {
 	}}
 	channel := NewChannel(fake)
 
-	_, err := channel.OpenLogicalChannel([]byte{0xA0, 0x00})
+	_, err := channel.OpenLogicalChannel(t.Context(), []byte{0xA0, 0x00})
 	if err == nil || !strings.Contains(err.Error(), "select AID: 6A82") {
 		t.Fatalf("OpenLogicalChannel() error = %v, want select failure", err)
 	}
@@ -108,7 +108,7 @@ func TestChannelDisconnectRetriesFailedSelectCleanup(t *testing.T) {
 		{0x90, 0x00},
 	}}
 	channel := NewChannel(fake)
-	if _, err := channel.OpenLogicalChannel([]byte{0xA0}); err == nil {
+	if _, err := channel.OpenLogicalChannel(t.Context(), []byte{0xA0}); err == nil {
 		t.Fatal("OpenLogicalChannel() error = nil")
 	}
 	if err := channel.Disconnect(); err != nil {
@@ -143,7 +143,7 @@ func TestChannelUsesFreshContextToCleanUpSelectTimeout(t *testing.T) {
 	}}
 	channel := NewChannel(fake, WithTimeout(20*time.Millisecond))
 
-	_, err := channel.OpenLogicalChannel([]byte{0xA0, 0x00})
+	_, err := channel.OpenLogicalChannel(t.Context(), []byte{0xA0, 0x00})
 	if !errors.Is(err, context.DeadlineExceeded) {
 		t.Fatalf("OpenLogicalChannel() error = %v, want deadline exceeded", err)
 	}
@@ -157,11 +157,24 @@ func TestChannelNonPositiveTimeoutExpiresContextImmediately(t *testing.T) {
 		return nil, ctx.Err()
 	}}
 	channel := NewChannel(fake, WithTimeout(0))
-	if err := channel.Connect(); !errors.Is(err, context.DeadlineExceeded) {
+	if err := channel.Connect(t.Context()); !errors.Is(err, context.DeadlineExceeded) {
 		t.Fatalf("Connect() error = %v, want deadline exceeded", err)
 	}
 }
 
+func TestChannelTransmitStopsWhenContextIsCanceled(t *testing.T) {
+	fake := &fakeTransmitter{transmit: func(ctx context.Context, _ []byte) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	channel := NewChannel(fake)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := channel.Transmit(ctx, []byte{0x80, 0xE2, 0x91, 0x00}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Transmit() error = %v, want canceled", err)
+	}
+}
+
 func TestChannelRejectsInvalidLogicalChannel(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -176,7 +189,7 @@ func TestChannelRejectsInvalidLogicalChannel(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			fake := &fakeTransmitter{responses: [][]byte{tt.response}}
 			channel := NewChannel(fake)
-			_, err := channel.OpenLogicalChannel([]byte{0xA0, 0x00})
+			_, err := channel.OpenLogicalChannel(t.Context(), []byte{0xA0, 0x00})
 			if err == nil || err.Error() != tt.want {
 				t.Fatalf("OpenLogicalChannel() error = %v, want %q", err, tt.want)
 			}
@@ -194,7 +207,7 @@ func TestChannelDisconnectClosesLogicalChannelAndTransport(t *testing.T) {
 		{0x90, 0x00},
 	}}
 	channel := NewChannel(fake)
-	if _, err := channel.OpenLogicalChannel([]byte{0xA0}); err != nil {
+	if _, err := channel.OpenLogicalChannel(t.Context(), []byte{0xA0}); err != nil {
 		t.Fatalf("OpenLogicalChannel() error = %v", err)
 	}
 	if err := channel.Disconnect(); err != nil {
@@ -212,14 +225,29 @@ func TestChannelDisconnectClosesLogicalChannelAndTransport(t *testing.T) {
 func TestChannelAPDUStatusHandling(t *testing.T) {
 	fake := &fakeTransmitter{responses: [][]byte{{0x6A, 0x82}}}
 	channel := NewChannel(fake)
-	got, err := channel.Transmit([]byte{0x00})
+	got, err := channel.Transmit(t.Context(), []byte{0x00})
 	if err != nil {
 		t.Fatalf("Transmit() error = %v", err)
 	}
 	if !bytes.Equal(got, []byte{0x6A, 0x82}) {
 		t.Fatalf("Transmit() = % X, want 6A 82", got)
 	}
-	if err := channel.CloseLogicalChannel(0); err == nil || err.Error() != "invalid logical channel 0" {
+	if err := channel.CloseLogicalChannel(t.Context(), 0); err == nil || err.Error() != "invalid logical channel 0" {
 		t.Fatalf("CloseLogicalChannel() error = %v, want invalid channel", err)
 	}
 }
+
+func TestLogicalChannel(t *testing.T) {
+	for channel := range byte(maxLogicalChannel + 1) {
+		cla, err := classByteForChannel(0x80, channel)
+		if err != nil {
+			t.Fatalf("classByteForChannel(%d) error = %v", channel, err)
+		}
+		if got := LogicalChannel([]byte{cla, 0xE2, 0x91, 0x00}); got != channel {
+			t.Errorf("LogicalChannel(%02X) = %d, want %d", cla, got, channel)
+		}
+	}
+	if got := LogicalChannel(nil); got != 0 {
+		t.Errorf("LogicalChannel(nil) = %d, want 0", got)
+	}
+}
@@ -56,14 +56,14 @@ func New(options ...Option) (*MBIM, error) {
 }
 
 // Connect establishes the MBIM session and opens the device.
-func (m *MBIM) Connect() error {
+func (m *MBIM) Connect(ctx context.Context) error {
 	if m.closed {
 		return errors.New("mbim reader is closed")
 	}
 	if m.reader != nil {
 		return nil
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 	options := []wwanmbim.Option{wwanmbim.WithSlot(int(m.slot))}
 	switch m.access {
@@ -83,23 +83,24 @@ func (m *MBIM) Connect() error {
 }
 
 // OpenLogicalChannel opens a logical channel for the specified Application ID.
-func (m *MBIM) OpenLogicalChannel(aid []byte) (byte, error) {
+func (m *MBIM) OpenLogicalChannel(ctx context.Context, aid []byte) (byte, error) {
 	if err := m.ensureOpen(); err != nil {
 		return 0, err
 	}
 	if m.channel != 0 {
 		return 0, fmt.Errorf("MBIM logical channel %d is already open", m.channel)
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	openCtx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
-	channel, err := m.reader.OpenChannel(ctx, aid)
+	channel, err := m.reader.OpenChannel(openCtx, aid)
 	if err != nil {
 		return 0, fmt.Errorf("open MBIM logical channel: %w", err)
 	}
 	if channel == 0 || channel > maxLogicalChannel {
 		var cleanupErr error
 		if channel != 0 {
-			cleanupErr = m.closeLogicalChannel(channel)
+			// The channel is closed even if ctx is done.
+			cleanupErr = m.closeLogicalChannel(context.WithoutCancel(ctx), channel)
 		}
 		return 0, errors.Join(
 			fmt.Errorf("MBIM returned invalid logical channel %d", channel),
@@ -111,11 +112,11 @@ func (m *MBIM) OpenLogicalChannel(aid []byte) (byte, error) {
 }
 
 // Transmit implements driver.SmartCardChannel.
-func (m *MBIM) Transmit(command []byte) ([]byte, error) {
+func (m *MBIM) Transmit(ctx context.Context, command []byte) ([]byte, error) {
 	if err := m.ensureOpen(); err != nil {
 		return nil, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 	response, status, err := m.reader.TransmitAPDU(ctx, m.channel, command)
 	if err != nil {
@@ -125,18 +126,18 @@ func (m *MBIM) Transmit(command []byte) ([]byte, error) {
 }
 
 // CloseLogicalChannel closes the specified logical channel.
-func (m *MBIM) CloseLogicalChannel(channel byte) error {
+func (m *MBIM) CloseLogicalChannel(ctx context.Context, channel byte) error {
 	if err := m.ensureOpen(); err != nil {
 		return err
 	}
 	if channel == 0 || channel > maxLogicalChannel {
 		return fmt.Errorf("invalid logical channel %d", channel)
 	}
-	return m.closeLogicalChannel(uint32(channel))
+	return m.closeLogicalChannel(ctx, uint32(channel))
 }
 
-func (m *MBIM) closeLogicalChannel(channel uint32) error {
-	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+func (m *MBIM) closeLogicalChannel(ctx context.Context, channel uint32) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 	if err := m.reader.CloseChannel(ctx, channel); err != nil {
 		return fmt.Errorf("close MBIM logical channel %d: %w", channel, err)
@@ -154,7 +155,7 @@ func (m *MBIM) Disconnect() error {
 	}
 	var channelErr error
 	if m.reader != nil && m.channel != 0 {
-		channelErr = m.closeLogicalChannel(m.channel)
+		channelErr = m.closeLogicalChannel(context.Background(), m.channel)
 	}
 	m.closed = true
 	if m.reader == nil {
@@ -102,7 +102,7 @@ func TestNewUsesClient(t *testing.T) {
 			if channel.timeout != time.Second {
 				t.Fatalf("New() timeout = %s, want %s", channel.timeout, time.Second)
 			}
-			if err := channel.Connect(); err != nil {
+			if err := channel.Connect(t.Context()); err != nil {
 				t.Fatalf("Connect() error = %v", err)
 			}
 		})
@@ -116,11 +116,11 @@ func TestTransmitAppendsAPDUStatusWord(t *testing.T) {
 		status:      0x0090,
 	}
 	m := &MBIM{reader: fake}
-	if _, err := m.OpenLogicalChannel([]byte{0xA0, 0x00}); err != nil {
+	if _, err := m.OpenLogicalChannel(t.Context(), []byte{0xA0, 0x00}); err != nil {
 		t.Fatalf("OpenLogicalChannel() error = %v", err)
 	}
 
-	got, err := m.Transmit([]byte{0x80, 0xE2})
+	got, err := m.Transmit(t.Context(), []byte{0x80, 0xE2})
 	if err != nil {
 		t.Fatalf("Transmit() error = %v", err)
 	}
@@ -135,7 +135,7 @@ func TestOpenLogicalChannelRejectsInvalidChannel(t *testing.T) {
 		fake := &fakeMBIMReader{openChannel: logicalChannel}
 		m := &MBIM{reader: fake}
 
-		if _, err := m.OpenLogicalChannel(nil); err == nil {
+		if _, err := m.OpenLogicalChannel(t.Context(), nil); err == nil {
 			t.Fatalf("OpenLogicalChannel() error = nil for channel %d", logicalChannel)
 		}
 		if logicalChannel == 0 && len(fake.closedChannel) != 0 {
@@ -150,10 +150,10 @@ func TestOpenLogicalChannelRejectsInvalidChannel(t *testing.T) {
 func TestOpenLogicalChannelRejectsSecondChannel(t *testing.T) {
 	fake := &fakeMBIMReader{openChannel: 3}
 	m := &MBIM{reader: fake, timeout: defaultTimeout}
-	if _, err := m.OpenLogicalChannel(nil); err != nil {
+	if _, err := m.OpenLogicalChannel(t.Context(), nil); err != nil {
 		t.Fatalf("first OpenLogicalChannel() error = %v", err)
 	}
-	if _, err := m.OpenLogicalChannel(nil); err == nil {
+	if _, err := m.OpenLogicalChannel(t.Context(), nil); err == nil {
 		t.Fatal("second OpenLogicalChannel() error = nil")
 	}
 	if fake.openCalls != 1 {
@@ -164,7 +164,7 @@ func TestOpenLogicalChannelRejectsSecondChannel(t *testing.T) {
 func TestNonPositiveTimeoutExpiresContextImmediately(t *testing.T) {
 	fake := &fakeMBIMReader{openChannel: 3}
 	m := &MBIM{reader: fake, timeout: 0}
-	if _, err := m.OpenLogicalChannel(nil); err != nil {
+	if _, err := m.OpenLogicalChannel(t.Context(), nil); err != nil {
 		t.Fatalf("OpenLogicalChannel() error = %v", err)
 	}
 	if !errors.Is(fake.openContextErr, context.DeadlineExceeded) {
@@ -40,7 +40,7 @@ func validateSlot(slot uint8) error {
 	return nil
 }
 
-func (c *channel) Connect() error {
+func (c *channel) Connect(ctx context.Context) error {
 	if c.closed {
 		return errors.New("smart card channel is closed")
 	}
@@ -50,7 +50,7 @@ func (c *channel) Connect() error {
 	if c.reader == nil {
 		return errors.New("QCOM reader is not connected")
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 	if err := c.reader.ActivateSlot(ctx); err != nil {
 		return fmt.Errorf("activate QCOM slot: %w", err)
@@ -65,30 +65,31 @@ func (c *channel) Disconnect() error {
 	}
 	var channelErr error
 	if c.reader != nil && c.channel != 0 {
-		channelErr = c.closeLogicalChannel(c.channel)
+		channelErr = c.closeLogicalChannel(context.Background(), c.channel)
 	}
 	c.closed = true
 	c.connected = false
 	return errors.Join(channelErr, c.releaseReader())
 }
 
-func (c *channel) OpenLogicalChannel(aid []byte) (byte, error) {
+func (c *channel) OpenLogicalChannel(ctx context.Context, aid []byte) (byte, error) {
 	if err := c.ensureConnected(); err != nil {
 		return 0, err
 	}
 	if c.channel != 0 {
 		return 0, fmt.Errorf("QCOM logical channel %d is already open", c.channel)
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	openCtx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
-	channel, err := c.reader.OpenLogicalChannel(ctx, aid)
+	channel, err := c.reader.OpenLogicalChannel(openCtx, aid)
 	if err != nil {
 		return 0, fmt.Errorf("open QCOM logical channel: %w", err)
 	}
 	if channel == 0 || channel > maxLogicalChannel {
 		var cleanupErr error
 		if channel != 0 {
-			cleanupErr = c.closeLogicalChannel(channel)
+			// The channel is closed even if ctx is done.
+			cleanupErr = c.closeLogicalChannel(context.WithoutCancel(ctx), channel)
 		}
 		return 0, errors.Join(
 			fmt.Errorf("QCOM returned invalid logical channel %d", channel),
@@ -99,11 +100,11 @@ func (c *channel) OpenLogicalChannel(aid []byte) (byte, error) {
 	return channel, nil
 }
 
-func (c *channel) Transmit(command []byte) ([]byte, error) {
+func (c *channel) Transmit(ctx context.Context, command []byte) ([]byte, error) {
 	if err := c.ensureConnected(); err != nil {
 		return nil, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 	response, err := c.reader.SendAPDU(ctx, c.channel, command)
 	if err != nil {
@@ -112,18 +113,18 @@ func (c *channel) Transmit(command []byte) ([]byte, error) {
 	return response, nil
 }
 
-func (c *channel) CloseLogicalChannel(channel byte) error {
+func (c *channel) CloseLogicalChannel(ctx context.Context, channel byte) error {
 	if err := c.ensureConnected(); err != nil {
 		return err
 	}
 	if channel == 0 || channel > maxLogicalChannel {
 		return fmt.Errorf("invalid logical channel %d", channel)
 	}
-	return c.closeLogicalChannel(channel)
+	return c.closeLogicalChannel(ctx, channel)
 }
 
-func (c *channel) closeLogicalChannel(channel byte) error {
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+func (c *channel) closeLogicalChannel(ctx context.Context, channel byte) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 	if err := c.reader.CloseLogicalChannel(ctx, channel); err != nil {
 		return fmt.Errorf("close QCOM logical channel %d: %w", channel, err)
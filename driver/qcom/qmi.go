@@ -40,15 +40,15 @@ func NewQMI(options ...Option) (*QMI, error) {
 }
 
 // Connect opens the QMI transport and activates the configured slot.
-func (q *QMI) Connect() error {
+func (q *QMI) Connect(ctx context.Context) error {
 	if q.closed {
 		return errors.New("smart card channel is closed")
 	}
 	if q.reader != nil {
-		return q.channel.Connect()
+		return q.channel.Connect(ctx)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+	openCtx, cancel := context.WithTimeout(ctx, q.timeout)
 	defer cancel()
 	var accessOption wwanqmi.Option
 	switch q.access {
@@ -59,7 +59,7 @@ func (q *QMI) Connect() error {
 	case accessProxy:
 		accessOption = wwanqmi.WithProxy(q.device)
 	}
-	transport, err := wwanqmi.Open(ctx, accessOption)
+	transport, err := wwanqmi.Open(openCtx, accessOption)
 	if err != nil {
 		return fmt.Errorf("open QMI transport: %w", err)
 	}
@@ -72,7 +72,7 @@ func (q *QMI) Connect() error {
 		return errors.Join(fmt.Errorf("create QMI client: %w", err), closeErr)
 	}
 	q.reader = reader
-	if err := q.channel.Connect(); err != nil {
+	if err := q.channel.Connect(ctx); err != nil {
 		return errors.Join(err, q.releaseReader())
 	}
 	return nil
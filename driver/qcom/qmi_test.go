@@ -75,7 +75,7 @@ func TestOpenLogicalChannelRejectsInvalidChannel(t *testing.T) {
 		channel := newChannel(reader, defaultTimeout)
 		channel.connected = true
 
-		if _, err := channel.OpenLogicalChannel(nil); err == nil {
+		if _, err := channel.OpenLogicalChannel(t.Context(), nil); err == nil {
 			t.Fatalf("OpenLogicalChannel() error = nil for channel %d", logicalChannel)
 		}
 		if logicalChannel == 0 && len(reader.closedChannel) != 0 {
@@ -91,10 +91,10 @@ func TestOpenLogicalChannelRejectsSecondChannel(t *testing.T) {
 	reader := &fakeUIMReader{openChannel: 2}
 	channel := newChannel(reader, defaultTimeout)
 	channel.connected = true
-	if _, err := channel.OpenLogicalChannel(nil); err != nil {
+	if _, err := channel.OpenLogicalChannel(t.Context(), nil); err != nil {
 		t.Fatalf("first OpenLogicalChannel() error = %v", err)
 	}
-	if _, err := channel.OpenLogicalChannel(nil); err == nil {
+	if _, err := channel.OpenLogicalChannel(t.Context(), nil); err == nil {
 		t.Fatal("second OpenLogicalChannel() error = nil")
 	}
 	if reader.openCalls != 1 {
@@ -214,10 +214,10 @@ func TestNewAcceptsNonPositiveTimeout(t *testing.T) {
 func TestQCOMChannelConnectIsIdempotent(t *testing.T) {
 	reader := new(fakeUIMReader)
 	channel := newChannel(reader, defaultTimeout)
-	if err := channel.Connect(); err != nil {
+	if err := channel.Connect(t.Context()); err != nil {
 		t.Fatalf("first Connect() error = %v", err)
 	}
-	if err := channel.Connect(); err != nil {
+	if err := channel.Connect(t.Context()); err != nil {
 		t.Fatalf("second Connect() error = %v", err)
 	}
 	if reader.activateCalls != 1 {
@@ -248,13 +248,13 @@ func TestConstructorsDoNotOpenTransports(t *testing.T) {
 	if qmi.reader != nil || qmi.connected {
 		t.Fatal("NewQMI() opened the transport")
 	}
-	if _, err := qmi.OpenLogicalChannel(nil); err == nil {
+	if _, err := qmi.OpenLogicalChannel(t.Context(), nil); err == nil {
 		t.Fatal("OpenLogicalChannel() before Connect error = nil")
 	}
 	if err := qmi.Disconnect(); err != nil {
 		t.Fatalf("Disconnect() before Connect error = %v", err)
 	}
-	if err := qmi.Connect(); err == nil {
+	if err := qmi.Connect(t.Context()); err == nil {
 		t.Fatal("Connect() after Disconnect error = nil")
 	}
 
@@ -270,7 +270,7 @@ func TestConstructorsDoNotOpenTransports(t *testing.T) {
 func TestNonPositiveTimeoutExpiresContextImmediately(t *testing.T) {
 	reader := new(fakeUIMReader)
 	channel := newChannel(reader, 0)
-	if err := channel.Connect(); err != nil {
+	if err := channel.Connect(t.Context()); err != nil {
 		t.Fatalf("Connect() error = %v", err)
 	}
 	if !errors.Is(reader.activateContextErr, context.DeadlineExceeded) {
@@ -33,17 +33,17 @@ func NewQRTR(options ...Option) (*QRTR, error) {
 }
 
 // Connect opens the QRTR transport and activates the configured slot.
-func (r *QRTR) Connect() error {
+func (r *QRTR) Connect(ctx context.Context) error {
 	if r.closed {
 		return errors.New("smart card channel is closed")
 	}
 	if r.reader != nil {
-		return r.channel.Connect()
+		return r.channel.Connect(ctx)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	openCtx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
-	transport, err := wwanqrtr.Open(ctx)
+	transport, err := wwanqrtr.Open(openCtx)
 	if err != nil {
 		return fmt.Errorf("open QRTR transport: %w", err)
 	}
@@ -56,7 +56,7 @@ func (r *QRTR) Connect() error {
 		return errors.Join(fmt.Errorf("create QRTR client: %w", err), closeErr)
 	}
 	r.reader = reader
-	if err := r.channel.Connect(); err != nil {
+	if err := r.channel.Connect(ctx); err != nil {
 		return errors.Join(err, r.releaseReader())
 	}
 	return nil
@@ -0,0 +1,181 @@
+package remote
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/damonto/euicc-go/driver"
+	"github.com/damonto/euicc-go/internal/netctx"
+)
+
+var _ driver.SmartCardChannel = (*Client)(nil)
+
+// Client is a smart card channel calling a [Server]. Like other channels it
+// is not safe for concurrent use, but its calls are serialized, so concurrent
+// callers cannot mix up the responses of each other.
+type Client struct {
+	address string
+	config  config
+
+	mu      sync.Mutex
+	conn    net.Conn
+	decoder *json.Decoder
+	encoder *json.Encoder
+	// err is set when the connection is broken.
+	err    error
+	closed bool
+}
+
+// Dial creates a channel to the server at address, which is a host and port
+// for TCP, or a ws or wss URL for WebSocket.
+func Dial(address string, options ...Option) *Client {
+	return &Client{address: address, config: applyOptions(options)}
+}
+
+// Connect connects to the server, authenticates and connects the channel of the server.
+// A broken connection is closed and dialled again.
+func (c *Client) Connect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errors.New("remote channel is closed")
+	}
+	if c.conn != nil && c.err == nil {
+		return nil
+	}
+	if c.conn != nil {
+		// The connection is broken, so closing it may fail.
+		c.close()
+	}
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", c.address, err)
+	}
+	c.conn, c.err = conn, nil
+	c.decoder = json.NewDecoder(conn)
+	c.encoder = json.NewEncoder(conn)
+	if _, err := c.call(ctx, &request{Op: opHello, Token: c.config.token}); err != nil {
+		return errors.Join(err, c.close())
+	}
+	if _, err := c.call(ctx, &request{Op: opConnect}); err != nil {
+		return errors.Join(err, c.close())
+	}
+	return nil
+}
+
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	if strings.HasPrefix(c.address, "ws://") || strings.HasPrefix(c.address, "wss://") {
+		u, err := url.Parse(c.address)
+		if err != nil {
+			return nil, err
+		}
+		return dialWebSocket(ctx, u, c.config.tlsConfig)
+	}
+	if c.config.tlsConfig != nil {
+		dialer := tls.Dialer{Config: c.config.tlsConfig}
+		return dialer.DialContext(ctx, "tcp", c.address)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", c.address)
+}
+
+// Disconnect releases the channel of the server, closing the logical channels
+// left open, and closes the connection.
+func (c *Client) Disconnect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	if c.err != nil {
+		return c.close()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	if _, err := c.call(ctx, &request{Op: opDisconnect}); err != nil {
+		return errors.Join(err, c.close())
+	}
+	// The server closes the connection once it answers, so closing it may fail.
+	c.close()
+	return nil
+}
+
+func (c *Client) OpenLogicalChannel(ctx context.Context, aid []byte) (byte, error) {
+	response, err := c.callConnected(ctx, &request{Op: opOpenLogicalChannel, AID: aid})
+	if err != nil {
+		return 0, err
+	}
+	return response.Channel, nil
+}
+
+func (c *Client) Transmit(ctx context.Context, command []byte) ([]byte, error) {
+	response, err := c.callConnected(ctx, &request{Op: opTransmit, Command: command})
+	if err != nil {
+		return nil, err
+	}
+	return response.Response, nil
+}
+
+func (c *Client) CloseLogicalChannel(ctx context.Context, channel byte) error {
+	_, err := c.callConnected(ctx, &request{Op: opCloseLogicalChannel, Channel: channel})
+	return err
+}
+
+func (c *Client) callConnected(ctx context.Context, request *request) (*response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, errors.New("remote channel is closed")
+	}
+	if c.conn == nil {
+		return nil, errors.New("remote channel is not connected")
+	}
+	return c.call(ctx, request)
+}
+
+// call sends a request and returns its response, or the error of the call made by the server.
+func (c *Client) call(ctx context.Context, request *request) (*response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		request.Timeout = max(time.Until(deadline).Milliseconds(), 1)
+	}
+	var response response
+	err := netctx.Do(ctx, c.conn, func() error {
+		if err := c.encoder.Encode(request); err != nil {
+			return err
+		}
+		return c.decoder.Decode(&response)
+	})
+	if err != nil {
+		// The response to an interrupted request would be taken for the response to the next one.
+		c.err = fmt.Errorf("remote channel connection is broken: %w", err)
+		return nil, fmt.Errorf("%s: %w", request.Op, err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%s: %s", request.Op, response.Error)
+	}
+	return &response, nil
+}
+
+func (c *Client) close() error {
+	conn := c.conn
+	c.conn = nil
+	return conn.Close()
+}
@@ -0,0 +1,112 @@
+// Package remote relays a [driver.SmartCardChannel] over the network, so an
+// eUICC attached to one machine can be managed from another.
+//
+// A [Server] exposes a local channel over TCP or WebSocket, and a [Client] is
+// a [driver.SmartCardChannel] calling it. Each call is a JSON request answered
+// by a JSON response, in order. A client first sends a hello request with its
+// token, and the server serves one client at a time: the others are refused
+// until the client holding the channel disconnects, since the channel is not
+// safe for concurrent use. Connections can be secured with TLS.
+package remote
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// Operations of the requests.
+const (
+	opHello               = "hello"
+	opConnect             = "connect"
+	opDisconnect          = "disconnect"
+	opOpenLogicalChannel  = "openLogicalChannel"
+	opTransmit            = "transmit"
+	opCloseLogicalChannel = "closeLogicalChannel"
+)
+
+type request struct {
+	Op    string `json:"op"`
+	Token string `json:"token,omitempty"`
+	// Timeout is the time left to the deadline of the call, in milliseconds.
+	Timeout int64  `json:"timeout,omitempty"`
+	Channel byte   `json:"channel,omitempty"`
+	AID     []byte `json:"aid,omitempty"`
+	Command []byte `json:"command,omitempty"`
+}
+
+type response struct {
+	Channel  byte   `json:"channel,omitempty"`
+	Response []byte `json:"response,omitempty"`
+	// Error is the message of the error returned by the call, if any.
+	Error string `json:"error,omitempty"`
+}
+
+type config struct {
+	token       string
+	tlsConfig   *tls.Config
+	logger      *slog.Logger
+	checkOrigin func(r *http.Request) bool
+}
+
+// Option configures a [Server] or a [Client].
+type Option func(*config)
+
+// WithToken sets the token a client authenticates with. A server refuses clients
+// sending a token other than its own, and so a server without a token refuses
+// clients sending one. The default is no token.
+func WithToken(token string) Option {
+	return func(config *config) {
+		config.token = token
+	}
+}
+
+// WithTLSConfig secures the connections with TLS. A server serving a listener
+// wraps it with the config, and a client dials TCP addresses and wss URLs with it.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(config *config) {
+		config.tlsConfig = tlsConfig
+	}
+}
+
+// WithLogger sets the logger a server reports the errors ending the connections
+// of its clients to. The default is slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(config *config) {
+		config.logger = logger
+	}
+}
+
+// WithOriginCheck sets the function a server accepts the Origin of a WebSocket
+// handshake with. Browsers send the Origin of the page opening the WebSocket, so the
+// check keeps other sites from reaching the channel through the browser of a user.
+// The default accepts handshakes without an Origin, and with an Origin of the host
+// of the request.
+func WithOriginCheck(check func(r *http.Request) bool) Option {
+	return func(config *config) {
+		config.checkOrigin = check
+	}
+}
+
+func applyOptions(options []Option) config {
+	config := config{logger: slog.Default(), checkOrigin: sameOrigin}
+	for _, option := range options {
+		option(&config)
+	}
+	return config
+}
+
+// sameOrigin reports whether the request has no Origin, or an Origin of its host.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
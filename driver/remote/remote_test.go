@@ -0,0 +1,325 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/damonto/euicc-go/driver"
+	"github.com/damonto/euicc-go/driver/virtual"
+	"github.com/damonto/euicc-go/driver/virtual/virtualtest"
+	"github.com/damonto/euicc-go/lpa"
+)
+
+func newServer(t *testing.T, options ...Option) *Server {
+	t.Helper()
+	options = append([]Option{WithLogger(slog.New(slog.DiscardHandler))}, options...)
+	server := NewServer(virtualtest.NewCard(t), options...)
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// serve serves server on a loopback TCP listener and returns its address.
+func serve(t *testing.T, server *Server) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ctx, listener) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-served; !errors.Is(err, context.Canceled) {
+			t.Errorf("Serve() error = %v, want %v", err, context.Canceled)
+		}
+	})
+	return listener.Addr().String()
+}
+
+// eid reads the EID of the card on channel with an LPA client.
+func eid(t *testing.T, channel driver.SmartCardChannel) (string, error) {
+	t.Helper()
+	client, err := lpa.New(t.Context(), &lpa.Options{
+		Channel: channel,
+		Logger:  slog.New(slog.DiscardHandler),
+	})
+	if err != nil {
+		return "", err
+	}
+	eid, err := client.EID(t.Context())
+	if err != nil {
+		return "", errors.Join(err, client.Close())
+	}
+	return eid.String(), client.Close()
+}
+
+func TestClientTCP(t *testing.T) {
+	address := serve(t, newServer(t, WithToken("secret")))
+	got, err := eid(t, Dial(address, WithToken("secret")))
+	if err != nil {
+		t.Fatalf("eid() error = %v", err)
+	}
+	if got != virtual.DefaultEID {
+		t.Errorf("eid() = %s, want %s", got, virtual.DefaultEID)
+	}
+
+	client := Dial(address, WithToken("wrong"))
+	if err := client.Connect(t.Context()); err == nil || !strings.Contains(err.Error(), "invalid token") {
+		t.Errorf("Connect() with a wrong token error = %v, want an invalid token", err)
+	}
+	if _, err := client.Transmit(t.Context(), []byte{0x00, 0xA4, 0x04, 0x00}); err == nil {
+		t.Error("Transmit() without a connection error = nil, want an error")
+	}
+	client = Dial(serve(t, newServer(t)), WithToken("secret"))
+	if err := client.Connect(t.Context()); err == nil || !strings.Contains(err.Error(), "invalid token") {
+		t.Errorf("Connect() with a token to a server without one error = %v, want an invalid token", err)
+	}
+}
+
+func TestClientTLS(t *testing.T) {
+	// The test certificate of httptest is valid for 127.0.0.1.
+	certificates := httptest.NewUnstartedServer(nil)
+	certificates.StartTLS()
+	certificates.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(certificates.Certificate())
+	address := serve(t, newServer(t, WithTLSConfig(certificates.TLS)))
+	if _, err := eid(t, Dial(address, WithTLSConfig(&tls.Config{RootCAs: roots}))); err != nil {
+		t.Errorf("eid() error = %v", err)
+	}
+	if err := Dial(address).Connect(t.Context()); err == nil {
+		t.Error("Connect() without TLS error = nil, want an error")
+	}
+}
+
+func TestClientSingleUser(t *testing.T) {
+	address := serve(t, newServer(t))
+	first := Dial(address)
+	if err := first.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if _, err := first.OpenLogicalChannel(t.Context(), lpa.GSMAISDRApplicationAID); err != nil {
+		t.Fatalf("OpenLogicalChannel() error = %v", err)
+	}
+	second := Dial(address)
+	if err := second.Connect(t.Context()); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("Connect() while in use error = %v, want the channel in use", err)
+	}
+
+	// The logical channel left open by the first client is closed when it disconnects.
+	if err := first.Disconnect(); err != nil {
+		t.Fatalf("Disconnect() error = %v", err)
+	}
+	if _, err := first.Transmit(t.Context(), []byte{0x00, 0xA4, 0x04, 0x00}); err == nil {
+		t.Error("Transmit() after Disconnect() error = nil, want an error")
+	}
+	if _, err := eid(t, Dial(address)); err != nil {
+		t.Errorf("eid() after the first client disconnected error = %v", err)
+	}
+
+	// So is the one of a client whose connection ends.
+	third := Dial(address)
+	if err := third.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if _, err := third.OpenLogicalChannel(t.Context(), lpa.GSMAISDRApplicationAID); err != nil {
+		t.Fatalf("OpenLogicalChannel() error = %v", err)
+	}
+	third.conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := eid(t, Dial(address))
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("eid() after the connection of a client ended error = %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClientWebSocket(t *testing.T) {
+	server := httptest.NewUnstartedServer(newServer(t, WithToken("secret")))
+	// The handshake of the client not trusting the server fails.
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	address := "wss" + strings.TrimPrefix(server.URL, "https") + "/apdu"
+	got, err := eid(t, Dial(address, WithToken("secret"), WithTLSConfig(&tls.Config{RootCAs: roots})))
+	if err != nil {
+		t.Fatalf("eid() error = %v", err)
+	}
+	if got != virtual.DefaultEID {
+		t.Errorf("eid() = %s, want %s", got, virtual.DefaultEID)
+	}
+	if err := Dial(address, WithToken("secret")).Connect(t.Context()); err == nil {
+		t.Error("Connect() without the root certificate error = nil, want an error")
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	address := serve(t, newServer(t))
+	client := Dial(address)
+	if err := client.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Disconnect()
+	client.conn.Close()
+	if _, err := client.OpenLogicalChannel(t.Context(), lpa.GSMAISDRApplicationAID); err == nil {
+		t.Fatal("OpenLogicalChannel() on a closed connection error = nil, want an error")
+	}
+
+	// The server releases the channel once it sees the connection end.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := client.Connect(t.Context())
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Connect() after the connection broke error = %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := client.OpenLogicalChannel(t.Context(), lpa.GSMAISDRApplicationAID); err != nil {
+		t.Errorf("OpenLogicalChannel() after Connect() error = %v", err)
+	}
+}
+
+func TestServerOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		options []Option
+		want    int
+	}{
+		{name: "no origin", want: http.StatusUpgradeRequired},
+		{name: "same origin", origin: "https://lab-pi", want: http.StatusUpgradeRequired},
+		{name: "other origin", origin: "https://example.com", want: http.StatusForbidden},
+		{
+			name:    "accepted origin",
+			origin:  "https://example.com",
+			options: []Option{WithOriginCheck(func(r *http.Request) bool { return r.Header.Get("Origin") == "https://example.com" })},
+			want:    http.StatusUpgradeRequired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "https://lab-pi/apdu", nil)
+			if tt.origin != "" {
+				request.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			// The request is not a handshake, so an accepted origin is answered with 426.
+			newServer(t, tt.options...).ServeHTTP(w, request)
+			if w.Code != tt.want {
+				t.Errorf("ServeHTTP() status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientContext(t *testing.T) {
+	address := serve(t, newServer(t))
+	client := Dial(address)
+	if err := client.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Disconnect()
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := client.OpenLogicalChannel(ctx, lpa.GSMAISDRApplicationAID); !errors.Is(err, context.Canceled) {
+		t.Errorf("OpenLogicalChannel() with a canceled context error = %v, want %v", err, context.Canceled)
+	}
+	ctx, cancel = context.WithTimeout(t.Context(), time.Minute)
+	defer cancel()
+	if _, err := client.OpenLogicalChannel(ctx, lpa.GSMAISDRApplicationAID); err != nil {
+		t.Errorf("OpenLogicalChannel() with a deadline error = %v", err)
+	}
+}
+
+// frame encodes a WebSocket frame with a short payload, masked with a fixed key if masked is true.
+func frame(fin bool, opcode byte, payload []byte, masked bool) []byte {
+	header := opcode
+	if fin {
+		header |= 0x80
+	}
+	if !masked {
+		return append([]byte{header, byte(len(payload))}, payload...)
+	}
+	key := [4]byte{0x01, 0x02, 0x03, 0x04}
+	data := append([]byte{header, 0x80 | byte(len(payload))}, key[:]...)
+	for i, b := range payload {
+		data = append(data, b^key[i%4])
+	}
+	return data
+}
+
+func TestWebSocketFrames(t *testing.T) {
+	tests := []struct {
+		name    string
+		frames  [][]byte
+		want    string
+		invalid bool
+	}{
+		{
+			name:   "fragments around a ping",
+			frames: [][]byte{frame(false, opcodeBinary, []byte("ab"), true), frame(true, opcodePing, nil, true), frame(true, opcodeContinuation, []byte("cd"), true)},
+			want:   "abcd",
+		},
+		{name: "unmasked", frames: [][]byte{frame(true, opcodeBinary, []byte("ab"), false)}, invalid: true},
+		{name: "continuation first", frames: [][]byte{frame(true, opcodeContinuation, []byte("ab"), true)}, invalid: true},
+		{
+			name:    "message inside a fragmented one",
+			frames:  [][]byte{frame(false, opcodeBinary, []byte("ab"), true), frame(true, opcodeBinary, []byte("cd"), true)},
+			invalid: true,
+		},
+		{name: "fragmented ping", frames: [][]byte{frame(false, opcodePing, nil, true)}, invalid: true},
+		{name: "reserved bits", frames: [][]byte{{0xC2, 0x80, 0x00, 0x00, 0x00, 0x00}}, invalid: true},
+		{name: "unknown opcode", frames: [][]byte{frame(true, 0x3, nil, true)}, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, peer := net.Pipe()
+			defer conn.Close()
+			defer peer.Close()
+			// The server answers pings and protocol errors, which the peer discards.
+			go io.Copy(io.Discard, peer)
+			go func() {
+				for _, frame := range tt.frames {
+					if _, err := peer.Write(frame); err != nil {
+						return
+					}
+				}
+			}()
+			ws := &websocketConn{Conn: conn, reader: bufio.NewReader(conn)}
+			if tt.invalid {
+				if _, err := io.ReadAll(ws); err == nil || !strings.Contains(err.Error(), "protocol error") {
+					t.Errorf("Read() error = %v, want a protocol error", err)
+				}
+				return
+			}
+			got := make([]byte, len(tt.want))
+			_, err := io.ReadFull(ws, got)
+			if err != nil || string(got) != tt.want {
+				t.Errorf("Read() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package remote
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/damonto/euicc-go/driver"
+	"github.com/damonto/euicc-go/internal/netctx"
+)
+
+// Server exposes a [driver.SmartCardChannel] to one [Client] at a time.
+// It is safe for concurrent use.
+//
+// The channel is connected when a client first connects, and stays connected
+// for the next clients until Close. The logical channels a client leaves open
+// are closed when it disconnects or its connection ends.
+type Server struct {
+	channel driver.SmartCardChannel
+	config  config
+
+	mu        sync.Mutex
+	busy      bool
+	connected bool
+}
+
+// NewServer returns a server exposing channel.
+func NewServer(channel driver.SmartCardChannel, options ...Option) *Server {
+	return &Server{channel: channel, config: applyOptions(options)}
+}
+
+// Serve accepts TCP connections on listener, with TLS if configured, and
+// serves them until ctx is done. It closes listener and returns once every
+// connection is closed.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	if s.config.tlsConfig != nil {
+		listener = tls.NewListener(listener, s.config.tlsConfig)
+	}
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			listener.Close()
+			return fmt.Errorf("accept connection: %w", err)
+		}
+		wg.Go(func() { s.serveConn(ctx, conn) })
+	}
+}
+
+// ServeHTTP serves a client connecting over WebSocket, so the server can be
+// mounted on an [http.ServeMux]. TLS is configured on the HTTP server.
+// Handshakes from an Origin the server does not accept are refused, see [WithOriginCheck].
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.config.checkOrigin(r) {
+		http.Error(w, "WebSocket origin not allowed", http.StatusForbidden)
+		s.config.logger.WarnContext(r.Context(), "[Remote] refused WebSocket handshake", "remote", r.RemoteAddr, "origin", r.Header.Get("Origin"))
+		return
+	}
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		s.config.logger.WarnContext(r.Context(), "[Remote] refused WebSocket handshake", "remote", r.RemoteAddr, "error", err)
+		return
+	}
+	s.serveConn(r.Context(), conn)
+}
+
+// ServeConn serves the client on conn until it disconnects, its connection
+// ends or ctx is done, and closes conn. It returns nil when the client disconnects.
+func (s *Server) ServeConn(ctx context.Context, conn net.Conn) error {
+	defer conn.Close()
+	session := &session{server: s, conn: conn, decoder: json.NewDecoder(conn), encoder: json.NewEncoder(conn)}
+	return session.serve(ctx)
+}
+
+// serveConn serves the client on conn and logs the error ending the connection, if any.
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	if err := s.ServeConn(ctx, conn); err != nil {
+		s.config.logger.WarnContext(ctx, "[Remote] connection ended", "remote", conn.RemoteAddr().String(), "error", err)
+	}
+}
+
+// Close disconnects the channel. The server must not be used after.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected = false
+	return s.channel.Disconnect()
+}
+
+func (s *Server) acquire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.busy {
+		return false
+	}
+	s.busy = true
+	return true
+}
+
+func (s *Server) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.busy = false
+}
+
+// session serves a client.
+type session struct {
+	server  *Server
+	conn    net.Conn
+	decoder *json.Decoder
+	encoder *json.Encoder
+	// channels holds the logical channels opened by the client.
+	channels map[byte]bool
+}
+
+func (s *session) serve(ctx context.Context) error {
+	var hello request
+	if err := s.read(ctx, &hello); err != nil {
+		return err
+	}
+	if err := s.authenticate(&hello); err != nil {
+		return errors.Join(err, s.write(ctx, &response{Error: err.Error()}))
+	}
+	if !s.server.acquire() {
+		err := errors.New("smart card channel is in use by another client")
+		return errors.Join(err, s.write(ctx, &response{Error: err.Error()}))
+	}
+	defer s.server.release()
+	s.channels = make(map[byte]bool)
+	defer func() {
+		// The channels are closed even if ctx is done.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), defaultTimeout)
+		defer cancel()
+		s.closeChannels(ctx)
+	}()
+	if err := s.write(ctx, &response{}); err != nil {
+		return err
+	}
+	for {
+		var request request
+		if err := s.read(ctx, &request); err != nil {
+			return err
+		}
+		response := s.handle(ctx, &request)
+		if err := s.write(ctx, response); err != nil {
+			return err
+		}
+		if request.Op == opDisconnect {
+			return nil
+		}
+	}
+}
+
+func (s *session) authenticate(hello *request) error {
+	if hello.Op != opHello {
+		return fmt.Errorf("unexpected %s request before hello", hello.Op)
+	}
+	if subtle.ConstantTimeCompare([]byte(hello.Token), []byte(s.server.config.token)) != 1 {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+func (s *session) handle(ctx context.Context, request *request) *response {
+	if request.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(request.Timeout)*time.Millisecond)
+		defer cancel()
+	}
+	var response response
+	var err error
+	channel := s.server.channel
+	switch request.Op {
+	case opConnect:
+		err = s.connect(ctx)
+	case opDisconnect:
+		err = s.closeChannels(ctx)
+	case opOpenLogicalChannel:
+		response.Channel, err = channel.OpenLogicalChannel(ctx, request.AID)
+		if err == nil {
+			s.channels[response.Channel] = true
+		}
+	case opTransmit:
+		response.Response, err = channel.Transmit(ctx, request.Command)
+	case opCloseLogicalChannel:
+		err = channel.CloseLogicalChannel(ctx, request.Channel)
+		if err == nil {
+			delete(s.channels, request.Channel)
+		}
+	default:
+		err = fmt.Errorf("unknown operation %q", request.Op)
+	}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	return &response
+}
+
+func (s *session) connect(ctx context.Context) error {
+	s.server.mu.Lock()
+	defer s.server.mu.Unlock()
+	if s.server.connected {
+		return nil
+	}
+	if err := s.server.channel.Connect(ctx); err != nil {
+		return err
+	}
+	s.server.connected = true
+	return nil
+}
+
+func (s *session) closeChannels(ctx context.Context) error {
+	var errs []error
+	for channel := range s.channels {
+		errs = append(errs, s.server.channel.CloseLogicalChannel(ctx, channel))
+	}
+	clear(s.channels)
+	return errors.Join(errs...)
+}
+
+func (s *session) read(ctx context.Context, request *request) error {
+	err := netctx.Do(ctx, s.conn, func() error { return s.decoder.Decode(request) })
+	if errors.Is(err, io.EOF) {
+		return errors.New("client closed the connection")
+	}
+	if err != nil {
+		return fmt.Errorf("read request: %w", err)
+	}
+	return nil
+}
+
+func (s *session) write(ctx context.Context, response *response) error {
+	err := netctx.Do(ctx, s.conn, func() error { return s.encoder.Encode(response) })
+	if err != nil {
+		return fmt.Errorf("write response: %w", err)
+	}
+	return nil
+}
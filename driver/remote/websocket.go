@@ -0,0 +1,304 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/damonto/euicc-go/internal/netctx"
+)
+
+// websocketGUID is appended to the key of a handshake, see RFC 6455 section 1.3.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opcodeContinuation = 0x0
+	opcodeText         = 0x1
+	opcodeBinary       = 0x2
+	opcodeClose        = 0x8
+	opcodePing         = 0x9
+	opcodePong         = 0xA
+)
+
+// closeProtocolError is the status code of a close frame ending a connection that broke the protocol.
+const closeProtocolError = 1002
+
+// websocketConn carries a stream over the binary messages of a WebSocket.
+// Each Write is sent as a message, and Read returns the payloads of the
+// messages received in order, answering pings on the way.
+type websocketConn struct {
+	net.Conn
+	reader *bufio.Reader
+	// masked is true for clients, which mask the frames they send.
+	masked bool
+
+	// remaining is the length of the payload left to read in the current frame.
+	remaining uint64
+	// fragmented is true between the first and the last fragment of a message.
+	fragmented bool
+	mask       [4]byte
+	offset     int
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func websocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// upgradeWebSocket answers the handshake of a WebSocket client and takes the connection over.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*websocketConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "WebSocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("not a WebSocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported WebSocket version")
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("hijack connection: %w", err)
+	}
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", websocketAccept(key))
+	if err := rw.Flush(); err != nil {
+		return nil, errors.Join(err, conn.Close())
+	}
+	return &websocketConn{Conn: conn, reader: rw.Reader}, nil
+}
+
+// dialWebSocket connects to a ws or wss URL.
+func dialWebSocket(ctx context.Context, u *url.URL, tlsConfig *tls.Config) (*websocketConn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), map[string]string{"ws": "80", "wss": "443"}[u.Scheme])
+	}
+	var conn net.Conn
+	var err error
+	if u.Scheme == "wss" {
+		dialer := tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ws, err := handshakeWebSocket(ctx, conn, u)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("WebSocket handshake: %w", err), conn.Close())
+	}
+	return ws, nil
+}
+
+func handshakeWebSocket(ctx context.Context, conn net.Conn, u *url.URL) (*websocketConn, error) {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	reader := bufio.NewReader(conn)
+	var response *http.Response
+	err = netctx.Do(ctx, conn, func() error {
+		if err := request.Write(conn); err != nil {
+			return err
+		}
+		response, err = http.ReadResponse(reader, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != websocketAccept(key) {
+		return nil, errors.New("invalid Sec-WebSocket-Accept")
+	}
+	return &websocketConn{Conn: conn, reader: reader, masked: true}, nil
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for field := range strings.SplitSeq(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *websocketConn) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if err := c.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+	if uint64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.reader.Read(p)
+	c.unmask(p[:n])
+	c.remaining -= uint64(n)
+	return n, err
+}
+
+// nextFrame reads the header of the next data frame, handling the control frames before it.
+// The frames must follow RFC 6455 section 5: masked from the client only, without extensions,
+// and with unfragmented control frames between the fragments of a message.
+func (c *websocketConn) nextFrame() error {
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+			return err
+		}
+		fin, opcode := header[0]&0x80 != 0, header[0]&0x0F
+		masked, length := header[1]&0x80 != 0, uint64(header[1]&0x7F)
+		if header[0]&0x70 != 0 {
+			return c.fail("reserved bits set without an extension")
+		}
+		// Clients mask the frames they send, and servers do not.
+		if masked == c.masked {
+			return c.fail("frame masked by the server or unmasked by the client")
+		}
+		switch length {
+		case 126:
+			var extended [2]byte
+			if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+				return err
+			}
+			length = uint64(binary.BigEndian.Uint16(extended[:]))
+		case 127:
+			var extended [8]byte
+			if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+				return err
+			}
+			length = binary.BigEndian.Uint64(extended[:])
+		}
+		c.mask, c.offset = [4]byte{}, 0
+		if masked {
+			if _, err := io.ReadFull(c.reader, c.mask[:]); err != nil {
+				return err
+			}
+		}
+		switch opcode {
+		case opcodeContinuation, opcodeText, opcodeBinary:
+			if (opcode == opcodeContinuation) != c.fragmented {
+				return c.fail("unexpected fragment")
+			}
+			// The payloads of the fragments of a message are read in order like the ones of messages.
+			c.fragmented = !fin
+			c.remaining = length
+			return nil
+		case opcodeClose, opcodePing, opcodePong:
+		default:
+			return c.fail(fmt.Sprintf("unknown opcode %#x", opcode))
+		}
+		if !fin || length > 125 {
+			return c.fail("fragmented or too long control frame")
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.reader, payload); err != nil {
+			return err
+		}
+		c.unmask(payload)
+		switch opcode {
+		case opcodeClose:
+			c.writeFrame(opcodeClose, payload)
+			return io.EOF
+		case opcodePing:
+			if err := c.writeFrame(opcodePong, payload); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// fail closes the WebSocket with a protocol error, see RFC 6455 section 7.4.1.
+func (c *websocketConn) fail(reason string) error {
+	c.writeFrame(opcodeClose, binary.BigEndian.AppendUint16(nil, closeProtocolError))
+	return fmt.Errorf("WebSocket protocol error: %s", reason)
+}
+
+func (c *websocketConn) unmask(p []byte) {
+	for i := range p {
+		p[i] ^= c.mask[c.offset%4]
+		c.offset++
+	}
+}
+
+func (c *websocketConn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(opcodeBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *websocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	frame := []byte{0x80 | opcode}
+	var maskBit byte
+	if c.masked {
+		maskBit = 0x80
+	}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xFFFF:
+		frame = binary.BigEndian.AppendUint16(append(frame, maskBit|126), uint16(length))
+	default:
+		frame = binary.BigEndian.AppendUint64(append(frame, maskBit|127), uint64(length))
+	}
+	if c.masked {
+		var mask [4]byte
+		rand.Read(mask[:])
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := c.Conn.Write(frame)
+	if opcode == opcodeClose {
+		c.closed = true
+	}
+	return err
+}
+
+// Close sends a close frame, unless one was exchanged, and closes the connection.
+func (c *websocketConn) Close() error {
+	c.writeFrame(opcodeClose, nil)
+	return c.Conn.Close()
+}
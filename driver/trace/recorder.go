@@ -0,0 +1,100 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/damonto/euicc-go/driver"
+	"github.com/damonto/euicc-go/driver/iso7816"
+)
+
+var _ driver.SmartCardChannel = (*Recorder)(nil)
+
+// Recorder is a [driver.SmartCardChannel] that passes every call to a channel
+// and writes it to a trace. Like other channels, it is not safe for concurrent use.
+type Recorder struct {
+	channel driver.SmartCardChannel
+	now     func() time.Time
+
+	mu      sync.Mutex
+	encoder *json.Encoder
+	err     error
+}
+
+// NewRecorder returns a channel recording the calls made on channel to w.
+// Each entry is written as soon as its call returns, so a trace is complete
+// up to the last call even if the process dies. The recorder does not close w.
+func NewRecorder(channel driver.SmartCardChannel, w io.Writer) *Recorder {
+	return &Recorder{
+		channel: channel,
+		now:     time.Now,
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// Err returns the first error writing the trace. Calls on the channel are not
+// affected by it, but the entries after the failed one may be missing.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) Connect(ctx context.Context) error {
+	start := r.now()
+	err := r.channel.Connect(ctx)
+	r.record(Entry{Operation: OperationConnect}, start, err)
+	return err
+}
+
+func (r *Recorder) Disconnect() error {
+	start := r.now()
+	err := r.channel.Disconnect()
+	r.record(Entry{Operation: OperationDisconnect}, start, err)
+	return err
+}
+
+func (r *Recorder) OpenLogicalChannel(ctx context.Context, aid []byte) (byte, error) {
+	start := r.now()
+	channel, err := r.channel.OpenLogicalChannel(ctx, aid)
+	r.record(Entry{Operation: OperationOpenLogicalChannel, Channel: channel, AID: slices.Clone(aid)}, start, err)
+	return channel, err
+}
+
+func (r *Recorder) Transmit(ctx context.Context, command []byte) ([]byte, error) {
+	start := r.now()
+	response, err := r.channel.Transmit(ctx, command)
+	r.record(Entry{
+		Operation: OperationTransmit,
+		Channel:   iso7816.LogicalChannel(command),
+		Command:   bytes.Clone(command),
+		Response:  bytes.Clone(response),
+	}, start, err)
+	return response, err
+}
+
+func (r *Recorder) CloseLogicalChannel(ctx context.Context, channel byte) error {
+	start := r.now()
+	err := r.channel.CloseLogicalChannel(ctx, channel)
+	r.record(Entry{Operation: OperationCloseLogicalChannel, Channel: channel}, start, err)
+	return err
+}
+
+func (r *Recorder) record(entry Entry, start time.Time, err error) {
+	entry.Time = start
+	entry.Duration = r.now().Sub(start)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = r.encoder.Encode(&entry)
+}
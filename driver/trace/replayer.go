@@ -0,0 +1,143 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/damonto/euicc-go/driver"
+	"github.com/damonto/euicc-go/driver/iso7816"
+)
+
+var _ driver.SmartCardChannel = (*Replayer)(nil)
+
+// DivergenceError reports a call that differs from the next entry of the trace.
+type DivergenceError struct {
+	// Index is the index of the entry in the trace.
+	Index int
+	// Want is the entry of the trace, or nil if the trace has no entries left.
+	Want *Entry
+	// Got is the call made on the replayer.
+	Got Entry
+}
+
+func (e *DivergenceError) Error() string {
+	if e.Want == nil {
+		return fmt.Sprintf("trace diverged after %d entries: unexpected %s", e.Index, describe(&e.Got))
+	}
+	return fmt.Sprintf("trace diverged at entry %d: got %s, want %s", e.Index, describe(&e.Got), describe(e.Want))
+}
+
+func describe(entry *Entry) string {
+	switch entry.Operation {
+	case OperationOpenLogicalChannel:
+		return fmt.Sprintf("%s %X", entry.Operation, []byte(entry.AID))
+	case OperationTransmit:
+		return fmt.Sprintf("%s %X", entry.Operation, []byte(entry.Command))
+	case OperationCloseLogicalChannel:
+		return fmt.Sprintf("%s %d", entry.Operation, entry.Channel)
+	}
+	return string(entry.Operation)
+}
+
+// Replayer is a [driver.SmartCardChannel] answering calls with the entries of a trace, in order.
+// Each call must match its entry: the operation, the AID of an opened channel, the command APDU
+// or the closed channel. The first call that differs fails with a [*DivergenceError], and so does
+// every call after it. Recorded errors are returned as errors with the recorded message.
+// Like other channels, it is not safe for concurrent use.
+type Replayer struct {
+	entries []Entry
+	next    int
+	err     error
+}
+
+// NewReplayer returns a channel replaying the entries of a trace, see [Read].
+func NewReplayer(entries []Entry) *Replayer {
+	return &Replayer{entries: slices.Clone(entries)}
+}
+
+// Done reports whether every entry of the trace was replayed. It returns the
+// divergence if a call differed from the trace.
+func (r *Replayer) Done() error {
+	if r.err != nil {
+		return r.err
+	}
+	if remaining := len(r.entries) - r.next; remaining > 0 {
+		return fmt.Errorf("%d of %d trace entries were not replayed, the next is %s",
+			remaining, len(r.entries), describe(&r.entries[r.next]))
+	}
+	return nil
+}
+
+func (r *Replayer) Connect(ctx context.Context) error {
+	_, err := r.replay(ctx, Entry{Operation: OperationConnect})
+	return err
+}
+
+func (r *Replayer) Disconnect() error {
+	_, err := r.replay(context.Background(), Entry{Operation: OperationDisconnect})
+	return err
+}
+
+func (r *Replayer) OpenLogicalChannel(ctx context.Context, aid []byte) (byte, error) {
+	entry, err := r.replay(ctx, Entry{Operation: OperationOpenLogicalChannel, AID: aid})
+	if entry == nil {
+		return 0, err
+	}
+	return entry.Channel, err
+}
+
+func (r *Replayer) Transmit(ctx context.Context, command []byte) ([]byte, error) {
+	entry, err := r.replay(ctx, Entry{Operation: OperationTransmit, Channel: iso7816.LogicalChannel(command), Command: command})
+	if entry == nil {
+		return nil, err
+	}
+	return bytes.Clone(entry.Response), err
+}
+
+func (r *Replayer) CloseLogicalChannel(ctx context.Context, channel byte) error {
+	_, err := r.replay(ctx, Entry{Operation: OperationCloseLogicalChannel, Channel: channel})
+	return err
+}
+
+// replay matches a call against the next entry, and returns the entry with its recorded error.
+// The entry is nil if the call diverged from the trace.
+func (r *Replayer) replay(ctx context.Context, call Entry) (*Entry, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if r.next == len(r.entries) {
+		r.err = &DivergenceError{Index: r.next, Got: call}
+		return nil, r.err
+	}
+	entry := &r.entries[r.next]
+	if !matches(entry, &call) {
+		r.err = &DivergenceError{Index: r.next, Want: entry, Got: call}
+		return nil, r.err
+	}
+	r.next++
+	if entry.Error != "" {
+		return entry, errors.New(entry.Error)
+	}
+	return entry, nil
+}
+
+func matches(entry, call *Entry) bool {
+	if entry.Operation != call.Operation {
+		return false
+	}
+	switch call.Operation {
+	case OperationOpenLogicalChannel:
+		return bytes.Equal(entry.AID, call.AID)
+	case OperationTransmit:
+		return bytes.Equal(entry.Command, call.Command)
+	case OperationCloseLogicalChannel:
+		return entry.Channel == call.Channel
+	}
+	return true
+}
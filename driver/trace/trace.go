@@ -0,0 +1,79 @@
+// Package trace records the calls made on a [driver.SmartCardChannel] to a trace,
+// and replays a trace in place of the smart card.
+//
+// A trace is a JSON Lines file with one [Entry] per call: the APDUs, the logical
+// channel, the time and duration of the call and the error it returned. A trace
+// recorded with a [Recorder] in the field can be replayed locally with a [Replayer],
+// which answers the same calls with the recorded responses and reports the first
+// call that differs from the trace.
+package trace
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Operation is the [driver.SmartCardChannel] method recorded by an entry.
+type Operation string
+
+const (
+	OperationConnect             Operation = "connect"
+	OperationDisconnect          Operation = "disconnect"
+	OperationOpenLogicalChannel  Operation = "openLogicalChannel"
+	OperationTransmit            Operation = "transmit"
+	OperationCloseLogicalChannel Operation = "closeLogicalChannel"
+)
+
+// Bytes is binary data encoded as an uppercase hexadecimal string.
+type Bytes []byte
+
+func (b Bytes) MarshalText() ([]byte, error) {
+	return fmt.Appendf(nil, "%X", []byte(b)), nil
+}
+
+func (b *Bytes) UnmarshalText(text []byte) error {
+	data := make([]byte, hex.DecodedLen(len(text)))
+	if _, err := hex.Decode(data, text); err != nil {
+		return err
+	}
+	*b = data
+	return nil
+}
+
+// Entry is a call recorded in a trace.
+type Entry struct {
+	Operation Operation `json:"op"`
+	Time      time.Time `json:"time"`
+	// Duration is the time the call took, in nanoseconds.
+	Duration time.Duration `json:"duration"`
+	// Channel is the logical channel opened or closed, or the one encoded in the class byte of the command.
+	Channel  byte  `json:"channel,omitempty"`
+	AID      Bytes `json:"aid,omitempty"`
+	Command  Bytes `json:"command,omitempty"`
+	Response Bytes `json:"response,omitempty"`
+	// Error is the message of the error returned by the call, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Read reads the entries of a trace.
+func Read(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	// Extended APDUs take longer lines than the default buffer of the scanner.
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("trace line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
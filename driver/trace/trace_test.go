@@ -0,0 +1,162 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/damonto/euicc-go/driver"
+	"github.com/damonto/euicc-go/driver/virtual"
+	"github.com/damonto/euicc-go/driver/virtual/virtualtest"
+	"github.com/damonto/euicc-go/lpa"
+)
+
+// session runs an LPA session on channel, returning the EID and the ICCIDs of the profiles.
+func session(t *testing.T, channel driver.SmartCardChannel) (string, []string, error) {
+	t.Helper()
+	client, err := lpa.New(t.Context(), &lpa.Options{
+		Channel: channel,
+		Logger:  slog.New(slog.DiscardHandler),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	eid, err := client.EID(t.Context())
+	if err != nil {
+		return "", nil, errors.Join(err, client.Close())
+	}
+	profiles, err := client.ListProfile(t.Context(), nil, nil)
+	if err != nil {
+		return "", nil, errors.Join(err, client.Close())
+	}
+	var iccids []string
+	for _, profile := range profiles {
+		iccids = append(iccids, profile.ICCID.String())
+	}
+	return eid.String(), iccids, client.Close()
+}
+
+// record runs a session on a virtual card holding one profile, and returns the trace.
+func record(t *testing.T) []byte {
+	t.Helper()
+	card := virtualtest.NewCard(t)
+	var trace bytes.Buffer
+	recorder := NewRecorder(card, &trace)
+	if _, _, err := session(t, recorder); err != nil {
+		t.Fatalf("session() error = %v", err)
+	}
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("Recorder.Err() = %v", err)
+	}
+	return trace.Bytes()
+}
+
+func TestRecordReplay(t *testing.T) {
+	data := record(t)
+	entries, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if n := strings.Count(string(data), "\n"); len(entries) != n {
+		t.Errorf("Read() = %d entries, want %d", len(entries), n)
+	}
+	first, last := entries[0], entries[len(entries)-1]
+	if first.Operation != OperationConnect || last.Operation != OperationDisconnect {
+		t.Errorf("trace operations = %s...%s, want connect...disconnect", first.Operation, last.Operation)
+	}
+	var opened byte
+	for _, entry := range entries {
+		switch entry.Operation {
+		case OperationOpenLogicalChannel:
+			opened = entry.Channel
+		case OperationTransmit:
+			if entry.Channel != opened || len(entry.Response) < 2 || entry.Time.IsZero() {
+				t.Errorf("transmit entry = %+v, want a response on channel %d", entry, opened)
+			}
+		}
+	}
+
+	replayer := NewReplayer(entries)
+	eid, iccids, err := session(t, replayer)
+	if err != nil {
+		t.Fatalf("session() on the replayer error = %v", err)
+	}
+	if eid != virtual.DefaultEID || len(iccids) != 1 || iccids[0] != virtualtest.ICCID {
+		t.Errorf("session() = %s, %v, want the recorded EID and profile", eid, iccids)
+	}
+	if err := replayer.Done(); err != nil {
+		t.Errorf("Replayer.Done() = %v", err)
+	}
+}
+
+func TestReplayDivergence(t *testing.T) {
+	entries, err := Read(bytes.NewReader(record(t)))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	replayer := NewReplayer(entries)
+	if err := replayer.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if _, err := replayer.Transmit(t.Context(), []byte{0x00, 0xA4, 0x04, 0x00}); err == nil {
+		t.Fatal("Transmit() out of trace error = nil, want a divergence")
+	}
+	var divergence *DivergenceError
+	if _, err := replayer.OpenLogicalChannel(t.Context(), entries[1].AID); !errors.As(err, &divergence) ||
+		divergence.Index != 1 || divergence.Want.Operation != OperationOpenLogicalChannel || divergence.Got.Operation != OperationTransmit {
+		t.Errorf("OpenLogicalChannel() after a divergence error = %v, want the first divergence", err)
+	}
+	if err := replayer.Done(); !errors.As(err, &divergence) {
+		t.Errorf("Replayer.Done() = %v, want the divergence", err)
+	}
+
+	replayer = NewReplayer(entries[:2])
+	if _, _, err := session(t, replayer); !errors.As(err, &divergence) || divergence.Want != nil || divergence.Index != 2 {
+		t.Errorf("session() on a truncated trace error = %v, want a divergence after 2 entries", err)
+	}
+}
+
+func TestReplayRecordedError(t *testing.T) {
+	replayer := NewReplayer([]Entry{
+		{Operation: OperationConnect, Error: "no card present"},
+		{Operation: OperationConnect},
+		{Operation: OperationDisconnect},
+	})
+	if err := replayer.Connect(t.Context()); err == nil || err.Error() != "no card present" {
+		t.Errorf("Connect() error = %v, want the recorded error", err)
+	}
+	if err := replayer.Done(); err == nil {
+		t.Error("Done() with entries left = nil, want an error")
+	}
+	if err := replayer.Connect(t.Context()); err != nil {
+		t.Errorf("Connect() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if err := replayer.CloseLogicalChannel(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("CloseLogicalChannel() with a canceled context error = %v, want %v", err, context.Canceled)
+	}
+	if err := replayer.Disconnect(); err != nil {
+		t.Errorf("Disconnect() error = %v", err)
+	}
+	if err := replayer.Done(); err != nil {
+		t.Errorf("Done() = %v", err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	entries, err := Read(strings.NewReader(`{"op":"transmit","time":"2024-01-02T03:04:05Z","duration":1500000,"channel":1,"command":"81E2910003BF3E00","response":"6112"}` + "\n\n"))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Channel != 1 || !bytes.Equal(entries[0].Response, []byte{0x61, 0x12}) ||
+		entries[0].Duration.Milliseconds() != 1 {
+		t.Errorf("Read() = %+v", entries)
+	}
+	if _, err := Read(strings.NewReader(`{"op":"transmit","command":"XYZ"}`)); err == nil {
+		t.Error("Read() of invalid hex error = nil, want an error")
+	}
+}
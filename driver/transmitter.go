@@ -21,15 +21,16 @@ const (
 
 // SmartCardChannel provides serialized access to a smart card. Driver
 // constructors configure channels without opening their transports; Connect
-// performs the I/O needed to establish a session. SmartCardChannel is not safe
-// for concurrent use; callers must serialize all operations, including
-// Disconnect.
+// performs the I/O needed to establish a session. Operations give up when ctx
+// is done, in addition to the per-operation timeout of the driver.
+// SmartCardChannel is not safe for concurrent use; callers must serialize all
+// operations, including Disconnect.
 type SmartCardChannel interface {
-	Connect() error
+	Connect(ctx context.Context) error
 	Disconnect() error
-	OpenLogicalChannel(aid []byte) (byte, error)
-	Transmit(command []byte) ([]byte, error)
-	CloseLogicalChannel(channel byte) error
+	OpenLogicalChannel(ctx context.Context, aid []byte) (byte, error)
+	Transmit(ctx context.Context, command []byte) ([]byte, error)
+	CloseLogicalChannel(ctx context.Context, channel byte) error
 }
 
 // Transmitter exchanges BER-TLV commands with an eUICC. It is not safe for
@@ -46,15 +47,15 @@ type transmitter struct {
 // NewTransmitter connects to channel and opens a logical channel for AID.
 // The transmitter takes ownership of channel and disconnects it on failure or
 // when Close is called. Logger must not be nil.
-func NewTransmitter(logger *slog.Logger, channel SmartCardChannel, aid []byte, mss int) (Transmitter, error) {
-	t, err := newCardTransmitter(logger, channel, aid, mss)
+func NewTransmitter(ctx context.Context, logger *slog.Logger, channel SmartCardChannel, aid []byte, mss int) (Transmitter, error) {
+	t, err := newCardTransmitter(ctx, logger, channel, aid, mss)
 	if err != nil {
 		return nil, err
 	}
 	return &transmitter{card: t}, nil
 }
 
-func (t *transmitter) Transmit(request bertlv.Marshaler, response bertlv.Unmarshaler) error {
+func (t *transmitter) Transmit(ctx context.Context, request bertlv.Marshaler, response bertlv.Unmarshaler) error {
 	req, err := request.MarshalBERTLV()
 	if err != nil {
 		return err
@@ -63,7 +64,7 @@ func (t *transmitter) Transmit(request bertlv.Marshaler, response bertlv.Unmarsh
 	if err != nil {
 		return err
 	}
-	bs, err = t.TransmitRaw(bs)
+	bs, err = t.TransmitRaw(ctx, bs)
 	if err != nil {
 		return err
 	}
@@ -74,8 +75,8 @@ func (t *transmitter) Transmit(request bertlv.Marshaler, response bertlv.Unmarsh
 	return response.UnmarshalBERTLV(&tlv)
 }
 
-func (t *transmitter) TransmitRaw(command []byte) ([]byte, error) {
-	return t.card.exchange(command)
+func (t *transmitter) TransmitRaw(ctx context.Context, command []byte) ([]byte, error) {
+	return t.card.exchange(ctx, command)
 }
 
 func (t *transmitter) Close() error {
@@ -89,21 +90,21 @@ type cardTransmitter struct {
 	logger         *slog.Logger
 }
 
-func newCardTransmitter(logger *slog.Logger, channel SmartCardChannel, aid []byte, mss int) (*cardTransmitter, error) {
+func newCardTransmitter(ctx context.Context, logger *slog.Logger, channel SmartCardChannel, aid []byte, mss int) (*cardTransmitter, error) {
 	if channel == nil {
 		return nil, errors.New("smart card channel is nil")
 	}
 	if mss < 1 || mss > maxMSS {
 		return nil, fmt.Errorf("MSS must be between 1 and %d: got %d", maxMSS, mss)
 	}
-	if err := channel.Connect(); err != nil {
+	if err := channel.Connect(ctx); err != nil {
 		return nil, errors.Join(
 			fmt.Errorf("connect smart card channel: %w", err),
 			disconnectChannel(channel),
 		)
 	}
 
-	logicalChannel, err := channel.OpenLogicalChannel(aid)
+	logicalChannel, err := channel.OpenLogicalChannel(ctx, aid)
 	if err != nil {
 		return nil, errors.Join(
 			fmt.Errorf("open logical channel: %w", err),
@@ -113,7 +114,7 @@ func newCardTransmitter(logger *slog.Logger, channel SmartCardChannel, aid []byt
 	if logicalChannel == 0 || logicalChannel > maxLogicalChannel {
 		return nil, errors.Join(
 			fmt.Errorf("logical channel %d is outside 1..%d", logicalChannel, maxLogicalChannel),
-			closeChannel(channel, logicalChannel),
+			closeChannel(ctx, channel, logicalChannel),
 			disconnectChannel(channel),
 		)
 	}
@@ -126,11 +127,11 @@ func newCardTransmitter(logger *slog.Logger, channel SmartCardChannel, aid []byt
 	}, nil
 }
 
-func closeChannel(channel SmartCardChannel, logicalChannel byte) error {
+func closeChannel(ctx context.Context, channel SmartCardChannel, logicalChannel byte) error {
 	if logicalChannel == 0 {
 		return nil
 	}
-	if err := channel.CloseLogicalChannel(logicalChannel); err != nil {
+	if err := channel.CloseLogicalChannel(ctx, logicalChannel); err != nil {
 		return fmt.Errorf("close logical channel %d: %w", logicalChannel, err)
 	}
 	return nil
@@ -143,7 +144,7 @@ func disconnectChannel(channel SmartCardChannel) error {
 	return nil
 }
 
-func (t *cardTransmitter) exchange(command []byte) ([]byte, error) {
+func (t *cardTransmitter) exchange(ctx context.Context, command []byte) ([]byte, error) {
 	var responseData bytes.Buffer
 	request := wwanapdu.Request{CLA: 0x80, INS: 0xE2}
 	var response wwanapdu.Response
@@ -163,7 +164,7 @@ func (t *cardTransmitter) exchange(command []byte) ([]byte, error) {
 			request.P1 = 0x91
 		}
 		var err error
-		if response, err = t.transmitAPDU(&request); err != nil {
+		if response, err = t.transmitAPDU(ctx, &request); err != nil {
 			return nil, err
 		}
 		block++
@@ -171,25 +172,24 @@ func (t *cardTransmitter) exchange(command []byte) ([]byte, error) {
 			responseData.Write(response.Data()) // bytes.Buffer.Write always returns a nil error.
 			continue
 		}
-		if err := t.readCommandResponse(&responseData, response.SW2()); err != nil {
+		if err := t.readCommandResponse(ctx, &responseData, response.SW2()); err != nil {
 			return nil, err
 		}
 	}
 	return responseData.Bytes(), nil
 }
 
-func (t *cardTransmitter) transmitAPDU(request *wwanapdu.Request) (wwanapdu.Response, error) {
+func (t *cardTransmitter) transmitAPDU(ctx context.Context, request *wwanapdu.Request) (wwanapdu.Response, error) {
 	t.setChannelToCLA(request, t.logicalChannel)
 	command, err := request.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
-	ctx := context.Background()
 	debug := t.logger.Enabled(ctx, slog.LevelDebug)
 	if debug {
 		t.logger.DebugContext(ctx, "[APDU] sending", "command", fmt.Sprintf("%X", command))
 	}
-	b, err := t.channel.Transmit(command)
+	b, err := t.channel.Transmit(ctx, command)
 	if debug {
 		if err != nil {
 			t.logger.DebugContext(ctx, "[APDU] received", "response", fmt.Sprintf("%X", b), "error", err)
@@ -215,7 +215,7 @@ func (t *cardTransmitter) setChannelToCLA(request *wwanapdu.Request, channel byt
 	}
 }
 
-func (t *cardTransmitter) readCommandResponse(responseData *bytes.Buffer, le byte) error {
+func (t *cardTransmitter) readCommandResponse(ctx context.Context, responseData *bytes.Buffer, le byte) error {
 	var err error
 	var request wwanapdu.Request
 	var response wwanapdu.Response
@@ -223,7 +223,7 @@ func (t *cardTransmitter) readCommandResponse(responseData *bytes.Buffer, le byt
 	request.INS = 0xC0
 	request.Le = &le
 	for {
-		if response, err = t.transmitAPDU(&request); err != nil {
+		if response, err = t.transmitAPDU(ctx, &request); err != nil {
 			return err
 		}
 		responseData.Write(response.Data()) // bytes.Buffer.Write always returns a nil error.
@@ -237,7 +237,7 @@ func (t *cardTransmitter) readCommandResponse(responseData *bytes.Buffer, le byt
 
 func (t *cardTransmitter) Close() error {
 	return errors.Join(
-		closeChannel(t.channel, t.logicalChannel),
+		closeChannel(context.Background(), t.channel, t.logicalChannel),
 		disconnectChannel(t.channel),
 	)
 }
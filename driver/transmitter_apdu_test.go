@@ -210,9 +210,9 @@ func TestTransmitterBuildsRecordedES10APDUs(t *testing.T) {
 				logicalChannel: 1,
 				responses:      [][]byte{{0x90, 0x00}},
 			}
-			tx, err := NewTransmitter(discardLogger(), channel, []byte{0xA0}, 254)
+			tx, err := NewTransmitter(t.Context(), discardLogger(), channel, []byte{0xA0}, 254)
 			if err != nil {
-				t.Fatalf("NewTransmitter() error = %v", err)
+				t.Fatalf("NewTransmitter(t.Context(), ) error = %v", err)
 			}
 			tlv, err := tt.request.MarshalBERTLV()
 			if err != nil {
@@ -222,7 +222,7 @@ func TestTransmitterBuildsRecordedES10APDUs(t *testing.T) {
 			if err != nil {
 				t.Fatalf("TLV.Bytes() error = %v", err)
 			}
-			if _, err := tx.TransmitRaw(encoded); err != nil {
+			if _, err := tx.TransmitRaw(t.Context(), encoded); err != nil {
 				t.Fatalf("TransmitRaw() error = %v", err)
 			}
 			if got := hex.EncodeToString(channel.requests[0]); got != lowerHex(tt.apdu) {
@@ -2,6 +2,7 @@ package driver
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"log/slog"
@@ -23,7 +24,7 @@ type fakeSmartCardChannel struct {
 	closedChannel  byte
 }
 
-func (f *fakeSmartCardChannel) Connect() error {
+func (f *fakeSmartCardChannel) Connect(context.Context) error {
 	f.connected = true
 	return f.connectErr
 }
@@ -33,12 +34,12 @@ func (f *fakeSmartCardChannel) Disconnect() error {
 	return f.disconnectErr
 }
 
-func (f *fakeSmartCardChannel) OpenLogicalChannel(AID []byte) (byte, error) {
+func (f *fakeSmartCardChannel) OpenLogicalChannel(_ context.Context, AID []byte) (byte, error) {
 	f.openedAID = append([]byte(nil), AID...)
 	return f.logicalChannel, f.openErr
 }
 
-func (f *fakeSmartCardChannel) Transmit(command []byte) ([]byte, error) {
+func (f *fakeSmartCardChannel) Transmit(_ context.Context, command []byte) ([]byte, error) {
 	f.requests = append(f.requests, append([]byte(nil), command...))
 	if len(f.responses) == 0 {
 		return nil, errors.New("unexpected transmit")
@@ -48,7 +49,7 @@ func (f *fakeSmartCardChannel) Transmit(command []byte) ([]byte, error) {
 	return response, nil
 }
 
-func (f *fakeSmartCardChannel) CloseLogicalChannel(channel byte) error {
+func (f *fakeSmartCardChannel) CloseLogicalChannel(_ context.Context, channel byte) error {
 	f.closedChannel = channel
 	return f.closeErr
 }
@@ -61,12 +62,12 @@ func TestNewTransmitterConnectsAndClosesChannel(t *testing.T) {
 	channel := &fakeSmartCardChannel{logicalChannel: 4}
 	aid := []byte{0xA0, 0x00}
 
-	tx, err := NewTransmitter(discardLogger(), channel, aid, 254)
+	tx, err := NewTransmitter(t.Context(), discardLogger(), channel, aid, 254)
 	if err != nil {
-		t.Fatalf("NewTransmitter() error = %v", err)
+		t.Fatalf("NewTransmitter(t.Context(), ) error = %v", err)
 	}
 	if !channel.connected {
-		t.Fatal("NewTransmitter() did not connect channel")
+		t.Fatal("NewTransmitter(t.Context(), ) did not connect channel")
 	}
 	if !bytes.Equal(channel.openedAID, aid) {
 		t.Fatalf("OpenLogicalChannel() AID = % X, want % X", channel.openedAID, aid)
@@ -84,17 +85,17 @@ func TestNewTransmitterConnectsAndClosesChannel(t *testing.T) {
 }
 
 func TestNewTransmitterValidatesBeforeConnecting(t *testing.T) {
-	if _, err := NewTransmitter(discardLogger(), nil, nil, 254); err == nil {
-		t.Fatal("NewTransmitter() error = nil for nil channel")
+	if _, err := NewTransmitter(t.Context(), discardLogger(), nil, nil, 254); err == nil {
+		t.Fatal("NewTransmitter(t.Context(), ) error = nil for nil channel")
 	}
 
 	for _, MSS := range []int{-1, 0, 255} {
 		channel := &fakeSmartCardChannel{logicalChannel: 1}
-		if _, err := NewTransmitter(discardLogger(), channel, nil, MSS); err == nil {
-			t.Fatalf("NewTransmitter() error = nil for MSS %d", MSS)
+		if _, err := NewTransmitter(t.Context(), discardLogger(), channel, nil, MSS); err == nil {
+			t.Fatalf("NewTransmitter(t.Context(), ) error = nil for MSS %d", MSS)
 		}
 		if channel.connected {
-			t.Fatalf("NewTransmitter() connected channel for invalid MSS %d", MSS)
+			t.Fatalf("NewTransmitter(t.Context(), ) connected channel for invalid MSS %d", MSS)
 		}
 	}
 }
@@ -102,31 +103,31 @@ func TestNewTransmitterValidatesBeforeConnecting(t *testing.T) {
 func TestNewTransmitterCleansUpFailedInitialization(t *testing.T) {
 	connectErr := errors.New("connect")
 	channel := &fakeSmartCardChannel{connectErr: connectErr}
-	if _, err := NewTransmitter(discardLogger(), channel, nil, 254); !errors.Is(err, connectErr) {
-		t.Fatalf("NewTransmitter() error = %v, want connect error", err)
+	if _, err := NewTransmitter(t.Context(), discardLogger(), channel, nil, 254); !errors.Is(err, connectErr) {
+		t.Fatalf("NewTransmitter(t.Context(), ) error = %v, want connect error", err)
 	}
 	if !channel.disconnected {
-		t.Fatal("NewTransmitter() did not disconnect after Connect failure")
+		t.Fatal("NewTransmitter(t.Context(), ) did not disconnect after Connect failure")
 	}
 
 	openErr := errors.New("open")
 	channel = &fakeSmartCardChannel{logicalChannel: 3, openErr: openErr}
-	if _, err := NewTransmitter(discardLogger(), channel, nil, 254); !errors.Is(err, openErr) {
-		t.Fatalf("NewTransmitter() error = %v, want open error", err)
+	if _, err := NewTransmitter(t.Context(), discardLogger(), channel, nil, 254); !errors.Is(err, openErr) {
+		t.Fatalf("NewTransmitter(t.Context(), ) error = %v, want open error", err)
 	}
 	if channel.closedChannel != 0 || !channel.disconnected {
-		t.Fatalf("NewTransmitter() cleanup = close %d, disconnect %t; want disconnect only", channel.closedChannel, channel.disconnected)
+		t.Fatalf("NewTransmitter(t.Context(), ) cleanup = close %d, disconnect %t; want disconnect only", channel.closedChannel, channel.disconnected)
 	}
 }
 
 func TestNewTransmitterRejectsInvalidLogicalChannelAndDisconnects(t *testing.T) {
 	channel := &fakeSmartCardChannel{logicalChannel: 20}
-	_, err := NewTransmitter(discardLogger(), channel, nil, 254)
+	_, err := NewTransmitter(t.Context(), discardLogger(), channel, nil, 254)
 	if err == nil || !strings.Contains(err.Error(), "outside 1..19") {
-		t.Fatalf("NewTransmitter() error = %v, want invalid logical channel", err)
+		t.Fatalf("NewTransmitter(t.Context(), ) error = %v, want invalid logical channel", err)
 	}
 	if channel.closedChannel != 20 || !channel.disconnected {
-		t.Fatalf("NewTransmitter() cleanup = close %d, disconnect %t; want close 20 and disconnect", channel.closedChannel, channel.disconnected)
+		t.Fatalf("NewTransmitter(t.Context(), ) cleanup = close %d, disconnect %t; want close 20 and disconnect", channel.closedChannel, channel.disconnected)
 	}
 }
 
@@ -138,9 +139,9 @@ func TestTransmitterCloseAlwaysDisconnects(t *testing.T) {
 		closeErr:       closeErr,
 		disconnectErr:  disconnectErr,
 	}
-	tx, err := NewTransmitter(discardLogger(), channel, nil, 254)
+	tx, err := NewTransmitter(t.Context(), discardLogger(), channel, nil, 254)
 	if err != nil {
-		t.Fatalf("NewTransmitter() error = %v", err)
+		t.Fatalf("NewTransmitter(t.Context(), ) error = %v", err)
 	}
 	err = tx.Close()
 	if !errors.Is(err, closeErr) || !errors.Is(err, disconnectErr) {
@@ -159,12 +160,12 @@ func TestTransmitterSplitsStoreDataAPDUByMSS(t *testing.T) {
 			{0x90, 0x00},
 		},
 	}
-	tx, err := NewTransmitter(discardLogger(), channel, []byte{0xA0}, 3)
+	tx, err := NewTransmitter(t.Context(), discardLogger(), channel, []byte{0xA0}, 3)
 	if err != nil {
-		t.Fatalf("NewTransmitter() error = %v", err)
+		t.Fatalf("NewTransmitter(t.Context(), ) error = %v", err)
 	}
 
-	got, err := tx.TransmitRaw([]byte{0x01, 0x02, 0x03, 0x04, 0x05})
+	got, err := tx.TransmitRaw(t.Context(), []byte{0x01, 0x02, 0x03, 0x04, 0x05})
 	if err != nil {
 		t.Fatalf("TransmitRaw() error = %v", err)
 	}
@@ -191,12 +192,12 @@ func TestTransmitterMarksFinalBlockWhenCommandIsExactMultipleOfMSS(t *testing.T)
 		logicalChannel: 1,
 		responses:      [][]byte{{0x90, 0x00}, {0x90, 0x00}},
 	}
-	tx, err := NewTransmitter(discardLogger(), channel, nil, 3)
+	tx, err := NewTransmitter(t.Context(), discardLogger(), channel, nil, 3)
 	if err != nil {
-		t.Fatalf("NewTransmitter() error = %v", err)
+		t.Fatalf("NewTransmitter(t.Context(), ) error = %v", err)
 	}
 
-	if _, err := tx.TransmitRaw([]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}); err != nil {
+	if _, err := tx.TransmitRaw(t.Context(), []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}); err != nil {
 		t.Fatalf("TransmitRaw() error = %v", err)
 	}
 	want := [][]byte{
@@ -215,12 +216,12 @@ func TestTransmitterMarksFinalBlockWhenCommandIsExactMultipleOfMSS(t *testing.T)
 
 func TestTransmitterRejectsMoreThan256StoreDataBlocks(t *testing.T) {
 	channel := &fakeSmartCardChannel{logicalChannel: 1}
-	tx, err := NewTransmitter(discardLogger(), channel, nil, 1)
+	tx, err := NewTransmitter(t.Context(), discardLogger(), channel, nil, 1)
 	if err != nil {
-		t.Fatalf("NewTransmitter() error = %v", err)
+		t.Fatalf("NewTransmitter(t.Context(), ) error = %v", err)
 	}
 
-	_, err = tx.TransmitRaw(make([]byte, maxStoreDataBlocks+1))
+	_, err = tx.TransmitRaw(t.Context(), make([]byte, maxStoreDataBlocks+1))
 	if err == nil || !strings.Contains(err.Error(), "maximum is 256") {
 		t.Fatalf("TransmitRaw() error = %v, want block count error", err)
 	}
@@ -238,11 +239,11 @@ func TestTransmitterLogsRawAPDUBodiesAtDebugLevel(t *testing.T) {
 			{0xBE, 0xEF, 0x90, 0x00},
 		},
 	}
-	tx, err := NewTransmitter(debugLogger(&logs), channel, nil, 254)
+	tx, err := NewTransmitter(t.Context(), debugLogger(&logs), channel, nil, 254)
 	if err != nil {
-		t.Fatalf("NewTransmitter() error = %v", err)
+		t.Fatalf("NewTransmitter(t.Context(), ) error = %v", err)
 	}
-	if _, err := tx.TransmitRaw([]byte{0xDE, 0xAD}); err != nil {
+	if _, err := tx.TransmitRaw(t.Context(), []byte{0xDE, 0xAD}); err != nil {
 		t.Fatalf("TransmitRaw() error = %v", err)
 	}
 	output := logs.String()
@@ -266,12 +267,12 @@ func TestTransmitterReadsCommandResponseWhenStatusHasMore(t *testing.T) {
 			{0xDE, 0xAD, 0x90, 0x00},
 		},
 	}
-	tx, err := NewTransmitter(discardLogger(), channel, []byte{0xA0}, 254)
+	tx, err := NewTransmitter(t.Context(), discardLogger(), channel, []byte{0xA0}, 254)
 	if err != nil {
-		t.Fatalf("NewTransmitter() error = %v", err)
+		t.Fatalf("NewTransmitter(t.Context(), ) error = %v", err)
 	}
 
-	got, err := tx.TransmitRaw([]byte{0x01, 0x02})
+	got, err := tx.TransmitRaw(t.Context(), []byte{0x01, 0x02})
 	if err != nil {
 		t.Fatalf("TransmitRaw() error = %v", err)
 	}
@@ -299,12 +300,12 @@ func TestTransmitterReturnsErrorForUnexpectedStatus(t *testing.T) {
 		logicalChannel: 1,
 		responses:      [][]byte{{0x6A, 0x82}},
 	}
-	tx, err := NewTransmitter(debugLogger(&logs), channel, []byte{0xA0}, 254)
+	tx, err := NewTransmitter(t.Context(), debugLogger(&logs), channel, []byte{0xA0}, 254)
 	if err != nil {
-		t.Fatalf("NewTransmitter() error = %v", err)
+		t.Fatalf("NewTransmitter(t.Context(), ) error = %v", err)
 	}
 
-	_, err = tx.TransmitRaw([]byte{0x01})
+	_, err = tx.TransmitRaw(t.Context(), []byte{0x01})
 	if err == nil {
 		t.Fatal("TransmitRaw() error = nil, want unexpected status error")
 	}
@@ -0,0 +1,325 @@
+package virtual
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"errors"
+	"slices"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/internal/scp03t"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// installation is the state of a bound profile package being loaded segment by segment.
+type installation struct {
+	transactionID []byte
+	channel       *scp03t.Channel
+	// command is the BPP command of the last sequence, and remaining the length of its contents still to come.
+	command   sgp22.BPPCommandID
+	remaining int
+	// data collects the unprotected segments of the command.
+	data    bytes.Buffer
+	isdpAID sgp22.ISDPAID
+	profile *sgp22.ProfileInfo
+}
+
+// sequenceCommands are the BPP commands carried by the sequences of a bound profile package,
+// with the tag of their protected segments and the commands that must precede them.
+var sequenceCommands = map[uint64]struct {
+	command  sgp22.BPPCommandID
+	segment  bertlv.Tag
+	previous []sgp22.BPPCommandID
+}{
+	0: {sgp22.BPPCommandIDConfigureISDP, bertlv.ContextSpecific.Primitive(7), []sgp22.BPPCommandID{sgp22.BPPCommandIDInitialiseSecureChannel}},
+	1: {sgp22.BPPCommandIDStoreMetadata, bertlv.ContextSpecific.Primitive(8), []sgp22.BPPCommandID{sgp22.BPPCommandIDConfigureISDP}},
+	2: {sgp22.BPPCommandIDReplaceSessionKeys, bertlv.ContextSpecific.Primitive(7), []sgp22.BPPCommandID{sgp22.BPPCommandIDStoreMetadata}},
+	3: {sgp22.BPPCommandIDLoadProfileElements, bertlv.ContextSpecific.Primitive(6), []sgp22.BPPCommandID{sgp22.BPPCommandIDStoreMetadata, sgp22.BPPCommandIDReplaceSessionKeys}},
+}
+
+// boundProfilePackageSegment reports whether the command is a segment of a bound profile package,
+// which starts with the tag of the BoundProfilePackage, of one of its sequences or of a protected segment.
+func boundProfilePackageSegment(command []byte) bool {
+	var tag bertlv.Tag
+	if _, err := tag.ReadFrom(bytes.NewReader(command)); err != nil {
+		return false
+	}
+	if tag.Constructed() {
+		_, ok := sequenceCommands[tag.Value()]
+		return tag.Equal(bertlv.ContextSpecific.Constructed(54)) || tag.ContextSpecific() && ok
+	}
+	return tag.ContextSpecific() && tag.Value() >= 6 && tag.Value() <= 8
+}
+
+// readHeader reads the tag and length fields at the start of data, and returns the length and the size of the fields.
+func readHeader(data []byte) (tag bertlv.Tag, length int, n int, err error) {
+	reader := bytes.NewReader(data)
+	if _, err = tag.ReadFrom(reader); err != nil {
+		return nil, 0, 0, err
+	}
+	first, err := reader.ReadByte()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if length = int(first); first&0x80 != 0 {
+		size := int(first & 0x7F)
+		if size == 0 || size > 3 {
+			return nil, 0, 0, errors.New("unsupported length")
+		}
+		length = 0
+		for range size {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	return tag, length, len(data) - reader.Len(), nil
+}
+
+// loadBoundProfilePackage processes a segment of a bound profile package, which holds the tag and
+// length fields of the package or of its sequences and the TLVs that follow them. The response is nil
+// until the last segment is loaded or a command fails, when it is the signed ProfileInstallationResult.
+//
+// See https://aka.pw/sgp22/v2.5#page=186 (Section 5.7.6, ES10b.LoadBoundProfilePackage)
+func (c *Card) loadBoundProfilePackage(segment []byte) (*bertlv.TLV, error) {
+	for len(segment) > 0 {
+		tag, length, n, err := readHeader(segment)
+		if err != nil {
+			return nil, err
+		}
+		if tag.Equal(bertlv.ContextSpecific.Constructed(54)) {
+			// A new package replaces the one being loaded.
+			c.installation = &installation{command: sgp22.BPPCommandIDInitialiseSecureChannel}
+			segment = segment[n:]
+			continue
+		}
+		install := c.installation
+		if install == nil {
+			return nil, errors.New("no bound profile package is being loaded")
+		}
+		if sequence, ok := sequenceCommands[tag.Value()]; ok && tag.ContextSpecific() && tag.Constructed() {
+			err = c.beginSequence(install, sequence.command, sequence.previous, length)
+			segment = segment[n:]
+		} else {
+			var tlv bertlv.TLV
+			reader := bytes.NewReader(segment)
+			if _, err := tlv.ReadFrom(reader); err != nil {
+				return nil, err
+			}
+			err = c.loadSegment(install, &tlv)
+			segment = segment[len(segment)-reader.Len():]
+		}
+		var bppError *sgp22.LoadBoundProfilePackageError
+		switch {
+		case errors.As(err, &bppError):
+			return c.profileInstallationResult(install, bertlv.NewChildren(
+				bertlv.ContextSpecific.Constructed(1),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{byte(bppError.BPPCommandID)}),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{byte(bppError.ErrorReason)}),
+			))
+		case err != nil:
+			return nil, err
+		case install.command == sgp22.BPPCommandIDLoadProfileElements && install.remaining == 0:
+			return c.installProfile(install)
+		}
+	}
+	return nil, nil
+}
+
+// bppError returns the error reported in the errorResult of the ProfileInstallationResult.
+func bppError(command sgp22.BPPCommandID, reason sgp22.BPPErrorReason) error {
+	return &sgp22.LoadBoundProfilePackageError{BPPCommandID: command, ErrorReason: reason}
+}
+
+// beginSequence starts the sequence of the command, once the commands before it are complete.
+func (c *Card) beginSequence(install *installation, command sgp22.BPPCommandID, previous []sgp22.BPPCommandID, length int) error {
+	if install.channel == nil || install.remaining != 0 || !slices.Contains(previous, install.command) {
+		return bppError(command, sgp22.BPPErrorReasonSCP03tStructureError)
+	}
+	install.command, install.remaining = command, length
+	install.data.Reset()
+	if length == 0 {
+		return c.completeCommand(install)
+	}
+	return nil
+}
+
+// loadSegment opens a protected segment of the sequence being loaded, or sets up the secure channel.
+func (c *Card) loadSegment(install *installation, tlv *bertlv.TLV) error {
+	if tlv.Tag.Equal(bertlv.ContextSpecific.Constructed(35)) && install.channel == nil {
+		return c.initialiseSecureChannel(install, tlv)
+	}
+	var segment bertlv.Tag
+	for _, sequence := range sequenceCommands {
+		if sequence.command == install.command {
+			segment = sequence.segment
+		}
+	}
+	if install.remaining == 0 || !tlv.Tag.Equal(segment) {
+		return bppError(install.command, sgp22.BPPErrorReasonSCP03tStructureError)
+	}
+	if install.remaining -= tlv.Len(); install.remaining < 0 {
+		return bppError(install.command, sgp22.BPPErrorReasonSCP03tStructureError)
+	}
+	// The '88' TLVs of StoreMetadata are only MAC protected.
+	data, err := install.channel.Unwrap(tlv, install.command != sgp22.BPPCommandIDStoreMetadata)
+	if err != nil {
+		return bppError(install.command, sgp22.BPPErrorReasonSCP03tSecurityError)
+	}
+	install.data.Write(data)
+	if install.remaining == 0 {
+		return c.completeCommand(install)
+	}
+	return nil
+}
+
+// initialiseSecureChannel verifies the InitialiseSecureChannelRequest of the session and derives
+// the session keys from the one-time keys of the SM-DP+ and of the eUICC.
+func (c *Card) initialiseSecureChannel(install *installation, request *bertlv.TLV) error {
+	var initialise sgp22.InitialiseSecureChannelRequest
+	if err := initialise.UnmarshalBERTLV(request); err != nil {
+		return bppError(sgp22.BPPCommandIDInitialiseSecureChannel, sgp22.BPPErrorReasonIncorrectInputValues)
+	}
+	install.transactionID = initialise.TransactionID
+	if initialise.RemoteOperationID != sgp22.RemoteOperationInstallBoundProfilePackage {
+		return bppError(sgp22.BPPCommandIDInitialiseSecureChannel, sgp22.BPPErrorReasonUnsupportedRemoteOperationType)
+	}
+	crt := initialise.ControlReferenceTemplate
+	keyType := crt.First(bertlv.ContextSpecific.Primitive(0))
+	keyLength := crt.First(bertlv.ContextSpecific.Primitive(1))
+	hostID := crt.First(bertlv.ContextSpecific.Primitive(4))
+	if keyType == nil || !bytes.Equal(keyType.Value, []byte{scp03t.KeyType}) ||
+		keyLength == nil || !bytes.Equal(keyLength.Value, []byte{scp03t.KeyLength}) || hostID == nil {
+		return bppError(sgp22.BPPCommandIDInitialiseSecureChannel, sgp22.BPPErrorReasonUnsupportedCRTValues)
+	}
+	if c.session == nil || c.session.otsk == nil || !bytes.Equal(initialise.TransactionID, c.session.transactionID) {
+		return bppError(sgp22.BPPCommandIDInitialiseSecureChannel, sgp22.BPPErrorReasonInvalidTransactionID)
+	}
+	// smdpSign is computed over the preceding data objects followed by the one-time key of the eUICC.
+	index := slices.IndexFunc(request.Children, func(child *bertlv.TLV) bool {
+		return child.Tag.Equal(bertlv.Application.Primitive(55))
+	})
+	euiccOtpk := bertlv.NewValue(bertlv.Application.Primitive(73), c.session.otsk.PublicKey().Bytes())
+	signed := append(slices.Clone(request.Children[:index]), euiccOtpk)
+	if err := sgp22.VerifySignature(c.session.smdpCertificate, initialise.SMDPSign, signed...); err != nil {
+		return bppError(sgp22.BPPCommandIDInitialiseSecureChannel, sgp22.BPPErrorReasonInvalidSignature)
+	}
+	publicKey, err := ecdh.P256().NewPublicKey(initialise.SMDPOtpk)
+	if err != nil {
+		return bppError(sgp22.BPPCommandIDInitialiseSecureChannel, sgp22.BPPErrorReasonIncorrectInputValues)
+	}
+	sharedSecret, err := c.session.otsk.ECDH(publicKey)
+	if err != nil {
+		return bppError(sgp22.BPPCommandIDInitialiseSecureChannel, sgp22.BPPErrorReasonIncorrectInputValues)
+	}
+	install.channel, err = scp03t.New(scp03t.DeriveKeys(sharedSecret, hostID.Value, c.eid))
+	return err
+}
+
+// completeCommand runs the command once all the segments of its sequence are loaded.
+func (c *Card) completeCommand(install *installation) error {
+	if install.command == sgp22.BPPCommandIDLoadProfileElements {
+		// The profile elements are not interpreted, so the profile is installed as it is.
+		return nil
+	}
+	var request bertlv.TLV
+	if err := request.UnmarshalBinary(install.data.Bytes()); err != nil {
+		return bppError(install.command, sgp22.BPPErrorReasonIncorrectInputValues)
+	}
+	switch install.command {
+	case sgp22.BPPCommandIDConfigureISDP:
+		if !request.Tag.Equal(bertlv.ContextSpecific.Constructed(36)) {
+			return bppError(install.command, sgp22.BPPErrorReasonIncorrectInputValues)
+		}
+		install.isdpAID = c.allocateISDPAID()
+	case sgp22.BPPCommandIDStoreMetadata:
+		profile := new(sgp22.ProfileInfo)
+		if !request.Tag.Equal(bertlv.ContextSpecific.Constructed(37)) || profile.UnmarshalBERTLV(&request) != nil || len(profile.ICCID) == 0 {
+			return bppError(install.command, sgp22.BPPErrorReasonIncorrectInputValues)
+		}
+		if c.lookup(bertlv.NewValue(sgp22.TagICCID, profile.ICCID)) != nil {
+			return bppError(install.command, sgp22.BPPErrorReasonInstallFailedDueToICCIDAlreadyExistsOnEUICC)
+		}
+		// ppr1 cannot be set while an operational profile is installed.
+		if profile.ProfilePolicyRules.DisablingNotAllowed && slices.ContainsFunc(c.profiles, func(profile *sgp22.ProfileInfo) bool {
+			return profile.ProfileClass == sgp22.ProfileClassOperational
+		}) {
+			return bppError(install.command, sgp22.BPPErrorReasonPPRNotAllowed)
+		}
+		install.profile = profile
+	case sgp22.BPPCommandIDReplaceSessionKeys:
+		keys := make([][]byte, 3)
+		for i := range keys {
+			key := request.First(bertlv.ContextSpecific.Primitive(uint64(i)))
+			if key == nil || len(key.Value) != scp03t.KeyLength {
+				return bppError(install.command, sgp22.BPPErrorReasonIncorrectInputValues)
+			}
+			keys[i] = key.Value
+		}
+		channel, err := scp03t.New(&scp03t.Keys{MACChainingValue: keys[0], Enc: keys[1], MAC: keys[2]})
+		if err != nil {
+			return err
+		}
+		install.channel = channel
+	}
+	return nil
+}
+
+// installProfile installs the loaded profile in the disabled state.
+func (c *Card) installProfile(install *installation) (*bertlv.TLV, error) {
+	profile := install.profile
+	profile.ISDPAID = install.isdpAID
+	profile.ProfileState = sgp22.ProfileDisabled
+	c.profiles = append(c.profiles, profile)
+	// The simaResponse is an EUICCResponse reporting the profile elements as processed.
+	simaResponse := []byte{0x30, 0x07, 0xA0, 0x05, 0x30, 0x03, 0x80, 0x01, 0x00}
+	return c.profileInstallationResult(install, bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(0),
+		bertlv.NewValue(bertlv.Application.Primitive(15), profile.ISDPAID),
+		bertlv.NewValue(bertlv.Universal.Primitive(4), simaResponse),
+	))
+}
+
+// profileInstallationResult ends the installation and the session, and returns the ProfileInstallationResult
+// signed by the eUICC. It is also queued as the install notification, which is sent to the SM-DP+.
+//
+// See https://aka.pw/sgp22/v2.5#page=35 (Section 2.5.6, ProfileInstallationResult)
+func (c *Card) profileInstallationResult(install *installation, finalResult *bertlv.TLV) (*bertlv.TLV, error) {
+	c.sequenceNumber++
+	metadata := sgp22.NotificationMetadata{
+		SequenceNumber:             c.sequenceNumber,
+		ProfileManagementOperation: sgp22.NotificationEventInstall,
+	}
+	if install.profile != nil {
+		metadata.ICCID = install.profile.ICCID
+	}
+	var smdpOID []byte
+	if c.session != nil {
+		metadata.Address = c.session.smdpAddress
+		smdpOID = c.session.smdpOID
+	}
+	tbs, err := notificationMetadata(&metadata)
+	if err != nil {
+		return nil, err
+	}
+	data := bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(39),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), install.transactionID),
+		tbs,
+	)
+	if smdpOID != nil {
+		data.Children = append(data.Children, bertlv.NewValue(bertlv.Universal.Primitive(6), smdpOID))
+	}
+	data.Children = append(data.Children, bertlv.NewChildren(bertlv.ContextSpecific.Constructed(2), finalResult))
+	signature, err := c.sign(data)
+	if err != nil {
+		return nil, err
+	}
+	result := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(55), data, signature)
+	c.notifications = append(c.notifications, &notification{metadata: metadata, tbs: tbs, pending: result})
+	c.installation = nil
+	c.session = nil
+	return result, nil
+}
@@ -0,0 +1,32 @@
+package virtual
+
+import (
+	"errors"
+
+	"github.com/damonto/euicc-go/bertlv"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// euiccConfiguredAddresses returns the default SM-DP+ address, if one is set, and the root SM-DS address.
+//
+// See https://aka.pw/sgp22/v2.5#page=183 (Section 5.7.3, ES10a.GetEuiccConfiguredAddresses)
+func (c *Card) euiccConfiguredAddresses(request *bertlv.TLV) (*bertlv.TLV, error) {
+	response := bertlv.NewChildren(request.Tag)
+	if c.defaultSMDPAddress != "" {
+		response.Children = append(response.Children, bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte(c.defaultSMDPAddress)))
+	}
+	response.Children = append(response.Children, bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte(c.rootSMDSAddress)))
+	return response, nil
+}
+
+// setDefaultDPAddress sets the default SM-DP+ address. An empty address removes it.
+//
+// See https://aka.pw/sgp22/v2.5#page=183 (Section 5.7.4, ES10a.SetDefaultDpAddress)
+func (c *Card) setDefaultDPAddress(request *bertlv.TLV) (*bertlv.TLV, error) {
+	address := request.First(bertlv.ContextSpecific.Primitive(0))
+	if address == nil {
+		return nil, errors.New("missing default SM-DP+ address")
+	}
+	c.defaultSMDPAddress = string(address.Value)
+	return result(request.Tag, sgp22.SetDefaultDPAddressResultOK), nil
+}
@@ -0,0 +1,526 @@
+package virtual
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding"
+	"encoding/asn1"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/bertlv/primitive"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// notification is a pending notification of the card.
+type notification struct {
+	metadata sgp22.NotificationMetadata
+	// tbs is the NotificationMetadata listed by ListNotification.
+	tbs *bertlv.TLV
+	// pending is the signed notification returned by RetrieveNotificationsList.
+	pending *bertlv.TLV
+}
+
+// session is the RSP session opened by AuthenticateServer.
+type session struct {
+	transactionID   []byte
+	smdpAddress     string
+	smdpOID         []byte
+	euiccSignature1 *bertlv.TLV
+	// smdpCertificate and otsk are set by PrepareDownload.
+	smdpCertificate *x509.Certificate
+	otsk            *ecdh.PrivateKey
+}
+
+// downloadErrorCode is the DownloadErrorCode of a PrepareDownloadResponse.
+type downloadErrorCode int8
+
+const (
+	downloadErrorInvalidCertificate   downloadErrorCode = 1
+	downloadErrorInvalidSignature     downloadErrorCode = 2
+	downloadErrorNoSessionContext     downloadErrorCode = 4
+	downloadErrorInvalidTransactionID downloadErrorCode = 5
+	downloadErrorUndefined            downloadErrorCode = 127
+)
+
+// Notifications returns the pending notifications of the card, oldest first.
+func (c *Card) Notifications() []*sgp22.NotificationMetadata {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	notifications := make([]*sgp22.NotificationMetadata, 0, len(c.notifications))
+	for _, notification := range c.notifications {
+		metadata := notification.metadata
+		notifications = append(notifications, &metadata)
+	}
+	return notifications
+}
+
+// notify queues a signed notification of the event, if the profile asks for one.
+func (c *Card) notify(event sgp22.NotificationEvent, profile *sgp22.ProfileInfo) error {
+	index := slices.IndexFunc(profile.NotificationConfigurationInfo, func(configuration *sgp22.NotificationConfiguration) bool {
+		return slices.Contains(configuration.ProfileManagementOperations, event)
+	})
+	if index < 0 {
+		return nil
+	}
+	c.sequenceNumber++
+	metadata := sgp22.NotificationMetadata{
+		SequenceNumber:             c.sequenceNumber,
+		ProfileManagementOperation: event,
+		Address:                    profile.NotificationConfigurationInfo[index].Address,
+		ICCID:                      profile.ICCID,
+	}
+	tbs, err := notificationMetadata(&metadata)
+	if err != nil {
+		return err
+	}
+	signature, err := c.sign(tbs)
+	if err != nil {
+		return err
+	}
+	c.notifications = append(c.notifications, &notification{
+		metadata: metadata,
+		tbs:      tbs,
+		pending:  bertlv.NewChildren(bertlv.Universal.Constructed(16), append([]*bertlv.TLV{tbs, signature}, c.certificates...)...),
+	})
+	return nil
+}
+
+// notificationMetadata encodes the NotificationMetadata of a notification.
+// The ICCID is left out if it is empty, as in a failed installation before the metadata is stored.
+func notificationMetadata(metadata *sgp22.NotificationMetadata) (*bertlv.TLV, error) {
+	sequenceNumber, err := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(0), metadata.SequenceNumber)
+	if err != nil {
+		return nil, err
+	}
+	event, err := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(1), &metadata.ProfileManagementOperation)
+	if err != nil {
+		return nil, err
+	}
+	tlv := bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(47),
+		sequenceNumber,
+		event,
+		bertlv.NewValue(bertlv.Universal.Primitive(12), []byte(metadata.Address)),
+	)
+	if len(metadata.ICCID) > 0 {
+		tlv.Children = append(tlv.Children, bertlv.NewValue(sgp22.TagICCID, metadata.ICCID))
+	}
+	return tlv, nil
+}
+
+// notificationEvents encodes the events as the NotificationEvent bit string.
+func notificationEvents(events []sgp22.NotificationEvent) encoding.BinaryMarshaler {
+	bits := make([]bool, sgp22.NotificationEventDelete+1)
+	for _, event := range events {
+		if event <= sgp22.NotificationEventDelete {
+			bits[event] = true
+		}
+	}
+	return primitive.MarshalBitString(bits)
+}
+
+// sign computes the ECDSA signature of the card over the concatenated encodings of the signed TLVs,
+// in the plain r || s format of SGP.22.
+func (c *Card) sign(signed ...*bertlv.TLV) (*bertlv.TLV, error) {
+	digest := sha256.New()
+	for _, tlv := range signed {
+		data, err := tlv.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		digest.Write(data)
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, c.key, digest.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return bertlv.NewValue(bertlv.Application.Primitive(55), signature), nil
+}
+
+// euiccChallenge returns a new random challenge for AuthenticateServer.
+//
+// See https://aka.pw/sgp22/v2.5#page=187 (Section 5.7.7, ES10b.GetEUICCChallenge)
+func (c *Card) euiccChallenge(request *bertlv.TLV) (*bertlv.TLV, error) {
+	c.challenge = make([]byte, 16)
+	rand.Read(c.challenge)
+	return bertlv.NewChildren(request.Tag, bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), c.challenge)), nil
+}
+
+// euiccInfo1 returns the SVN and the CI of the card, which is used both for verification and signing.
+//
+// See https://aka.pw/sgp22/v2.5#page=187 (Section 5.7.8, ES10b.GetEUICCInfo)
+func (c *Card) euiccInfo1(request *bertlv.TLV) (*bertlv.TLV, error) {
+	return bertlv.NewChildren(
+		request.Tag,
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), []byte{0x02, 0x05, 0x00}),
+		c.subjectKeyIdentifiers(bertlv.ContextSpecific.Constructed(9)),
+		c.subjectKeyIdentifiers(bertlv.ContextSpecific.Constructed(10)),
+	), nil
+}
+
+// euiccInfo2 returns the extended information of the card.
+//
+// See https://aka.pw/sgp22/v2.5#page=187 (Section 5.7.8, ES10b.GetEUICCInfo)
+func (c *Card) euiccInfo2(request *bertlv.TLV) (*bertlv.TLV, error) {
+	uiccCapability, err := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(5), primitive.MarshalBitString([]bool{
+		false, true, true, false, // usimSupport, isimSupport
+		true, false, false, false, // akaMilenage
+		false, false, false, false,
+		false, false, true, // javacard
+	}))
+	if err != nil {
+		return nil, err
+	}
+	rspCapability, err := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(8), primitive.MarshalBitString([]bool{
+		true, false, false, true, // additionalProfile, testProfileSupport
+	}))
+	if err != nil {
+		return nil, err
+	}
+	// The extCardResource is an OCTET STRING holding the installed applications and the free memory as TLVs.
+	var resource bytes.Buffer
+	for _, tlv := range []*bertlv.TLV{
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{byte(len(c.profiles))}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), []byte{0x07, 0xA1, 0x20}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(3), []byte{0x40, 0x00}),
+	} {
+		data, err := tlv.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		resource.Write(data)
+	}
+	return bertlv.NewChildren(
+		request.Tag,
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{0x02, 0x03, 0x01}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), []byte{0x02, 0x05, 0x00}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(3), []byte{0x01, 0x00, 0x00}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(4), resource.Bytes()),
+		uiccCapability,
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(6), []byte{0x09, 0x02, 0x00}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(7), []byte{0x02, 0x03, 0x00}),
+		rspCapability,
+		c.subjectKeyIdentifiers(bertlv.ContextSpecific.Constructed(9)),
+		c.subjectKeyIdentifiers(bertlv.ContextSpecific.Constructed(10)),
+		bertlv.NewValue(bertlv.Universal.Primitive(4), []byte{0x00, 0x01, 0x00}),
+		bertlv.NewValue(bertlv.Universal.Primitive(12), []byte("RSPTEST-SAS")),
+	), nil
+}
+
+// subjectKeyIdentifiers returns the list of CI public key identifiers, which holds only the CI of the card.
+func (c *Card) subjectKeyIdentifiers(tag bertlv.Tag) *bertlv.TLV {
+	return bertlv.NewChildren(tag, bertlv.NewValue(bertlv.Universal.Primitive(4), c.ci.Certificate.SubjectKeyId))
+}
+
+// listNotification returns the metadata of the pending notifications whose event is in the filter, or of all of them.
+//
+// See https://aka.pw/sgp22/v2.5#page=191 (Section 5.7.9, ES10b.ListNotification)
+func (c *Card) listNotification(request *bertlv.TLV) (*bertlv.TLV, error) {
+	match := func(*notification) bool { return true }
+	if filter := request.First(bertlv.ContextSpecific.Primitive(1)); filter != nil {
+		var events []bool
+		if err := filter.UnmarshalValue(primitive.UnmarshalBitString(&events)); err != nil {
+			return nil, err
+		}
+		match = func(notification *notification) bool {
+			event := int(notification.metadata.ProfileManagementOperation)
+			return event < len(events) && events[event]
+		}
+	}
+	list := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0))
+	for _, notification := range c.notifications {
+		if match(notification) {
+			list.Children = append(list.Children, notification.tbs)
+		}
+	}
+	return bertlv.NewChildren(request.Tag, list), nil
+}
+
+// retrieveNotificationsList returns the signed pending notifications matching the sequence number or event
+// of the search criteria, or all of them.
+//
+// See https://aka.pw/sgp22/v2.5#page=191 (Section 5.7.10, ES10b.RetrieveNotificationsList)
+func (c *Card) retrieveNotificationsList(request *bertlv.TLV) (*bertlv.TLV, error) {
+	match := func(*notification) bool { return true }
+	if criteria := request.First(bertlv.ContextSpecific.Constructed(0)); criteria != nil {
+		switch criterion := identifier(criteria); {
+		case criterion == nil:
+			return nil, errors.New("missing search criteria")
+		case criterion.Tag.Equal(bertlv.ContextSpecific.Primitive(0)):
+			var sequenceNumber sgp22.SequenceNumber
+			if err := criterion.UnmarshalValue(primitive.UnmarshalInt(&sequenceNumber)); err != nil {
+				return nil, err
+			}
+			match = func(notification *notification) bool { return notification.metadata.SequenceNumber == sequenceNumber }
+		case criterion.Tag.Equal(bertlv.ContextSpecific.Primitive(1)):
+			var event sgp22.NotificationEvent
+			if err := criterion.UnmarshalValue(&event); err != nil {
+				return nil, err
+			}
+			match = func(notification *notification) bool {
+				return notification.metadata.ProfileManagementOperation == event
+			}
+		default:
+			return nil, errors.New("unsupported search criteria")
+		}
+	}
+	list := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0))
+	for _, notification := range c.notifications {
+		if match(notification) {
+			list.Children = append(list.Children, notification.pending)
+		}
+	}
+	return bertlv.NewChildren(request.Tag, list), nil
+}
+
+// removeNotificationFromList removes the notification of the sequence number.
+//
+// See https://aka.pw/sgp22/v2.5#page=193 (Section 5.7.11, ES10b.RemoveNotificationFromList)
+func (c *Card) removeNotificationFromList(request *bertlv.TLV) (*bertlv.TLV, error) {
+	tlv := request.First(bertlv.ContextSpecific.Primitive(0))
+	if tlv == nil {
+		return nil, errors.New("missing sequence number")
+	}
+	var sequenceNumber sgp22.SequenceNumber
+	if err := tlv.UnmarshalValue(primitive.UnmarshalInt(&sequenceNumber)); err != nil {
+		return nil, err
+	}
+	n := len(c.notifications)
+	c.notifications = slices.DeleteFunc(c.notifications, func(notification *notification) bool {
+		return notification.metadata.SequenceNumber == sequenceNumber
+	})
+	if len(c.notifications) == n {
+		return result(request.Tag, sgp22.DeleteNotificationStatusNothingToDelete), nil
+	}
+	return result(request.Tag, sgp22.DeleteNotificationStatusOK), nil
+}
+
+// authenticateServer verifies the SM-DP+ or SM-DS and the challenge it signed, opens a session
+// and returns euiccSigned1 signed with the eUICC certificate.
+//
+// See https://aka.pw/sgp22/v2.5#page=195 (Section 5.7.13, ES10b.AuthenticateServer)
+func (c *Card) authenticateServer(request *bertlv.TLV) (*bertlv.TLV, error) {
+	var sequences []*bertlv.TLV
+	for _, child := range request.Children {
+		if child.Tag.If(bertlv.Universal, bertlv.Constructed, 16) {
+			sequences = append(sequences, child)
+		}
+	}
+	signature1 := request.First(bertlv.Application.Primitive(55))
+	usedIssuer := request.First(bertlv.Universal.Primitive(4))
+	ctxParams1 := request.First(bertlv.ContextSpecific.Constructed(0))
+	if len(sequences) != 2 || signature1 == nil || usedIssuer == nil || ctxParams1 == nil {
+		return nil, errors.New("malformed AuthenticateServerRequest")
+	}
+	var signed1 sgp22.ServerSigned1
+	if err := signed1.UnmarshalBERTLV(sequences[0]); err != nil {
+		return nil, err
+	}
+	// The challenge can only be used once.
+	challenge := c.challenge
+	c.challenge = nil
+	authenticateError := func(code sgp22.AuthenticateErrorCode) (*bertlv.TLV, error) {
+		errorCode, err := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(1), primitive.MarshalInt(code))
+		if err != nil {
+			return nil, err
+		}
+		return bertlv.NewChildren(request.Tag, bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(1),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), signed1.TransactionID),
+			errorCode,
+		)), nil
+	}
+	if !bytes.Equal(usedIssuer.Value, c.ci.Certificate.SubjectKeyId) {
+		return authenticateError(sgp22.AuthenticateErrorCodeCIPKUnknown)
+	}
+	certificate, err := sgp22.ParseCertificate(sequences[1])
+	if err != nil {
+		return authenticateError(sgp22.AuthenticateErrorCodeInvalidCertificate)
+	}
+	if err := sgp22.VerifyCertificate(certificate, c.ci.Roots(), time.Time{}); err != nil {
+		return authenticateError(sgp22.AuthenticateErrorCodeInvalidCertificate)
+	}
+	if err := sgp22.VerifySignature(certificate, signature1, sequences[0]); err != nil {
+		return authenticateError(sgp22.AuthenticateErrorCodeInvalidSignature)
+	}
+	if challenge == nil || !bytes.Equal(signed1.EUICCChallenge, challenge) {
+		return authenticateError(sgp22.AuthenticateErrorCodeEuiccChallengeMismatch)
+	}
+	info2, err := c.euiccInfo2(bertlv.NewChildren(bertlv.ContextSpecific.Constructed(34)))
+	if err != nil {
+		return nil, err
+	}
+	euiccSigned1 := bertlv.NewChildren(
+		bertlv.Universal.Constructed(16),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), signed1.TransactionID),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(3), []byte(signed1.ServerAddress)),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(4), signed1.ServerChallenge),
+		info2,
+		ctxParams1,
+	)
+	euiccSignature1, err := c.sign(euiccSigned1)
+	if err != nil {
+		return nil, err
+	}
+	c.session = &session{
+		transactionID:   signed1.TransactionID,
+		smdpAddress:     signed1.ServerAddress,
+		smdpOID:         registeredID(certificate),
+		euiccSignature1: euiccSignature1,
+	}
+	ok := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0), append([]*bertlv.TLV{euiccSigned1, euiccSignature1}, c.certificates...)...)
+	return bertlv.NewChildren(request.Tag, ok), nil
+}
+
+// registeredID returns the content octets of the registered ID in the subject alternative name
+// of the certificate, which is the OID of the SM-DP+, or nil if there is none.
+func registeredID(certificate *x509.Certificate) []byte {
+	for _, extension := range certificate.Extensions {
+		if !extension.Id.Equal(asn1.ObjectIdentifier{2, 5, 29, 17}) {
+			continue
+		}
+		var names []asn1.RawValue
+		if _, err := asn1.Unmarshal(extension.Value, &names); err != nil {
+			return nil
+		}
+		for _, name := range names {
+			if name.Class == asn1.ClassContextSpecific && name.Tag == 8 {
+				return name.Bytes
+			}
+		}
+	}
+	return nil
+}
+
+// prepareDownload verifies smdpSigned2 of the session, generates the one-time key of the eUICC
+// and returns it in euiccSigned2 with the hashed confirmation code.
+//
+// See https://aka.pw/sgp22/v2.5#page=184 (Section 5.7.5, ES10b.PrepareDownload)
+func (c *Card) prepareDownload(request *bertlv.TLV) (*bertlv.TLV, error) {
+	var sequences []*bertlv.TLV
+	for _, child := range request.Children {
+		if child.Tag.If(bertlv.Universal, bertlv.Constructed, 16) {
+			sequences = append(sequences, child)
+		}
+	}
+	signature2 := request.First(bertlv.Application.Primitive(55))
+	hashCc := request.First(bertlv.Universal.Primitive(4))
+	if len(sequences) != 2 || signature2 == nil {
+		return nil, errors.New("malformed PrepareDownloadRequest")
+	}
+	var signed2 sgp22.SMDPSigned2
+	if err := signed2.UnmarshalBERTLV(sequences[0]); err != nil {
+		return nil, err
+	}
+	downloadError := func(code downloadErrorCode) (*bertlv.TLV, error) {
+		errorCode, err := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(1), primitive.MarshalInt(code))
+		if err != nil {
+			return nil, err
+		}
+		return bertlv.NewChildren(request.Tag, bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(1),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), signed2.TransactionID),
+			errorCode,
+		)), nil
+	}
+	if c.session == nil {
+		return downloadError(downloadErrorNoSessionContext)
+	}
+	if !bytes.Equal(signed2.TransactionID, c.session.transactionID) {
+		return downloadError(downloadErrorInvalidTransactionID)
+	}
+	certificate, err := sgp22.ParseCertificate(sequences[1])
+	if err != nil {
+		return downloadError(downloadErrorInvalidCertificate)
+	}
+	if err := sgp22.VerifyCertificate(certificate, c.ci.Roots(), time.Time{}); err != nil {
+		return downloadError(downloadErrorInvalidCertificate)
+	}
+	if err := sgp22.VerifySignature(certificate, signature2, sequences[0], c.session.euiccSignature1); err != nil {
+		return downloadError(downloadErrorInvalidSignature)
+	}
+	if signed2.CCRequired && hashCc == nil {
+		return downloadError(downloadErrorUndefined)
+	}
+	otsk, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	euiccSigned2 := bertlv.NewChildren(
+		bertlv.Universal.Constructed(16),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), signed2.TransactionID),
+		bertlv.NewValue(bertlv.Application.Primitive(73), otsk.PublicKey().Bytes()),
+	)
+	if hashCc != nil {
+		euiccSigned2.Children = append(euiccSigned2.Children, hashCc)
+	}
+	euiccSignature2, err := c.sign(euiccSigned2, signature2)
+	if err != nil {
+		return nil, err
+	}
+	c.session.smdpCertificate = certificate
+	c.session.otsk = otsk
+	return bertlv.NewChildren(request.Tag, bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0), euiccSigned2, euiccSignature2)), nil
+}
+
+// cancelSession ends the session and returns the signed reason, which the LPA forwards to the server.
+//
+// See https://aka.pw/sgp22/v2.5#page=197 (Section 5.7.14, ES10b.CancelSession)
+func (c *Card) cancelSession(request *bertlv.TLV) (*bertlv.TLV, error) {
+	transactionID := request.First(bertlv.ContextSpecific.Primitive(0))
+	reason := request.First(bertlv.ContextSpecific.Primitive(1))
+	if transactionID == nil || reason == nil {
+		return nil, errors.New("malformed CancelSessionRequest")
+	}
+	if c.session == nil || !bytes.Equal(transactionID.Value, c.session.transactionID) {
+		return bertlv.NewChildren(request.Tag, bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{byte(downloadErrorInvalidTransactionID)})), nil
+	}
+	signed := bertlv.NewChildren(bertlv.Universal.Constructed(16), transactionID)
+	if c.session.smdpOID != nil {
+		signed.Children = append(signed.Children, bertlv.NewValue(bertlv.Universal.Primitive(6), c.session.smdpOID))
+	}
+	signed.Children = append(signed.Children, reason)
+	signature, err := c.sign(signed)
+	if err != nil {
+		return nil, err
+	}
+	c.session = nil
+	return bertlv.NewChildren(request.Tag, bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0), signed, signature)), nil
+}
+
+// rulesAuthorisationTable returns a RAT allowing any operator to set ppr1 and ppr2 with the consent of the end user.
+//
+// See https://aka.pw/sgp22/v2.5#page=210 (Section 5.7.22, ES10b.GetRAT)
+func (c *Card) rulesAuthorisationTable(request *bertlv.TLV) (*bertlv.TLV, error) {
+	rules, err := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(0), policyRules(sgp22.ProfilePolicyRules{
+		DisablingNotAllowed: true,
+		DeletionNotAllowed:  true,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	flags, err := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(2), primitive.MarshalBitString([]bool{true}))
+	if err != nil {
+		return nil, err
+	}
+	rule := bertlv.NewChildren(
+		bertlv.Universal.Constructed(16),
+		rules,
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(1), bertlv.NewChildren(
+			bertlv.Universal.Constructed(16),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{0xEE, 0xEE, 0xEE}),
+		)),
+		flags,
+	)
+	return bertlv.NewChildren(request.Tag, bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0), rule)), nil
+}
@@ -0,0 +1,345 @@
+package virtual
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/bertlv/primitive"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// AddProfile installs a profile as if it had been downloaded, without a notification.
+// The ICCID is required and must be unique. An ISD-P AID is allocated if the profile has none.
+// The profile class is kept as is, so the zero value is a test profile.
+// Only one profile can be enabled.
+func (c *Card) AddProfile(profile sgp22.ProfileInfo) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(profile.ICCID) == 0 {
+		return errors.New("ICCID is required")
+	}
+	if c.lookup(bertlv.NewValue(sgp22.TagICCID, profile.ICCID)) != nil {
+		return fmt.Errorf("profile %s already exists", profile.ICCID)
+	}
+	if profile.ProfileState == sgp22.ProfileEnabled && c.enabledProfile() != nil {
+		return errors.New("another profile is enabled")
+	}
+	if len(profile.ISDPAID) == 0 {
+		profile.ISDPAID = c.allocateISDPAID()
+	} else if c.lookup(bertlv.NewValue(sgp22.TagISDPAID, profile.ISDPAID)) != nil {
+		return fmt.Errorf("ISD-P %s already exists", profile.ISDPAID)
+	}
+	c.profiles = append(c.profiles, &profile)
+	return nil
+}
+
+// Profiles returns copies of the installed profiles in installation order.
+func (c *Card) Profiles() []*sgp22.ProfileInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	profiles := make([]*sgp22.ProfileInfo, 0, len(c.profiles))
+	for _, profile := range c.profiles {
+		profile := *profile
+		profiles = append(profiles, &profile)
+	}
+	return profiles
+}
+
+// allocateISDPAID returns the first free AID of the A0000005591010FFFFFFFF89000010xx form used by eUICCs,
+// whose 15th byte counts up from 10.
+func (c *Card) allocateISDPAID() sgp22.ISDPAID {
+	aid := slices.Clone(isdrAID)
+	for aid[14] = 0x10; c.lookup(bertlv.NewValue(sgp22.TagISDPAID, aid)) != nil; aid[14]++ {
+	}
+	return aid
+}
+
+// lookup returns the profile identified by an ICCID or ISD-P AID, or nil if there is none.
+func (c *Card) lookup(identifier *bertlv.TLV) *sgp22.ProfileInfo {
+	if identifier == nil {
+		return nil
+	}
+	for _, profile := range c.profiles {
+		switch {
+		case identifier.Tag.Equal(sgp22.TagICCID) && bytes.Equal(profile.ICCID, identifier.Value),
+			identifier.Tag.Equal(sgp22.TagISDPAID) && bytes.Equal(profile.ISDPAID, identifier.Value):
+			return profile
+		}
+	}
+	return nil
+}
+
+// identifier returns the ICCID or ISD-P AID identifying a profile, the first child of tlv, or nil if there is none.
+func identifier(tlv *bertlv.TLV) *bertlv.TLV {
+	if tlv == nil || len(tlv.Children) == 0 {
+		return nil
+	}
+	return tlv.Children[0]
+}
+
+func (c *Card) enabledProfile() *sgp22.ProfileInfo {
+	for _, profile := range c.profiles {
+		if profile.ProfileState == sgp22.ProfileEnabled {
+			return profile
+		}
+	}
+	return nil
+}
+
+// profileInfoList returns the profiles matching the search criteria, with the data objects of the tag list.
+//
+// See https://aka.pw/sgp22/v2.5#page=199 (Section 5.7.15, ES10c.GetProfilesInfo)
+func (c *Card) profileInfoList(request *bertlv.TLV) (*bertlv.TLV, error) {
+	incorrectInputValues := bertlv.NewChildren(request.Tag, bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{byte(sgp22.ProfileInfoListErrorIncorrectInputValues)}))
+	match := func(*sgp22.ProfileInfo) bool { return true }
+	if criteria := request.First(bertlv.ContextSpecific.Constructed(0)); criteria != nil {
+		if len(criteria.Children) != 1 {
+			return incorrectInputValues, nil
+		}
+		switch criterion := criteria.Children[0]; {
+		case criterion.Tag.Equal(sgp22.TagICCID), criterion.Tag.Equal(sgp22.TagISDPAID):
+			match = func(profile *sgp22.ProfileInfo) bool { return profile == c.lookup(criterion) }
+		case criterion.Tag.Equal(sgp22.TagProfileClass):
+			var class sgp22.ProfileClass
+			if err := criterion.UnmarshalValue(primitive.UnmarshalInt(&class)); err != nil {
+				return incorrectInputValues, nil
+			}
+			match = func(profile *sgp22.ProfileInfo) bool { return profile.ProfileClass == class }
+		default:
+			return incorrectInputValues, nil
+		}
+	}
+	var tags []bertlv.Tag
+	if tagList := request.First(bertlv.Application.Primitive(28)); tagList != nil {
+		reader := bytes.NewReader(tagList.Value)
+		for reader.Len() > 0 {
+			var tag bertlv.Tag
+			if _, err := tag.ReadFrom(reader); err != nil {
+				return incorrectInputValues, nil
+			}
+			tags = append(tags, tag)
+		}
+	}
+	list := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0))
+	for _, profile := range c.profiles {
+		if !match(profile) {
+			continue
+		}
+		info, err := profileInfo(profile, tags)
+		if err != nil {
+			return nil, err
+		}
+		list.Children = append(list.Children, info)
+	}
+	return bertlv.NewChildren(request.Tag, list), nil
+}
+
+// profileInfo encodes the data objects of the profile whose tag is in tags, or all of them if tags is empty.
+func profileInfo(profile *sgp22.ProfileInfo, tags []bertlv.Tag) (*bertlv.TLV, error) {
+	state, err := bertlv.MarshalValue(sgp22.TagProfileState, primitive.MarshalInt(profile.ProfileState))
+	if err != nil {
+		return nil, err
+	}
+	class, err := bertlv.MarshalValue(sgp22.TagProfileClass, profile.ProfileClass)
+	if err != nil {
+		return nil, err
+	}
+	fields := []*bertlv.TLV{
+		bertlv.NewValue(sgp22.TagICCID, profile.ICCID),
+		bertlv.NewValue(sgp22.TagISDPAID, profile.ISDPAID),
+		state,
+	}
+	for _, field := range []struct {
+		tag   bertlv.Tag
+		value string
+	}{
+		{sgp22.TagNickname, profile.ProfileNickname},
+		{sgp22.TagServiceProviderName, profile.ServiceProviderName},
+		{sgp22.TagProfileName, profile.ProfileName},
+	} {
+		if field.value != "" {
+			fields = append(fields, bertlv.NewValue(field.tag, []byte(field.value)))
+		}
+	}
+	if len(profile.Icon) > 0 {
+		iconType, err := bertlv.MarshalValue(sgp22.TagProfileIconType, primitive.MarshalInt(profile.IconType))
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, iconType, bertlv.NewValue(sgp22.TagProfileIcon, profile.Icon))
+	}
+	fields = append(fields, class)
+	if len(profile.NotificationConfigurationInfo) > 0 {
+		configurations := bertlv.NewChildren(sgp22.TagNotificationConfigurationInfo)
+		for _, configuration := range profile.NotificationConfigurationInfo {
+			operations, err := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(0), notificationEvents(configuration.ProfileManagementOperations))
+			if err != nil {
+				return nil, err
+			}
+			configurations.Children = append(configurations.Children, bertlv.NewChildren(
+				bertlv.Universal.Constructed(16),
+				operations,
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte(configuration.Address)),
+			))
+		}
+		fields = append(fields, configurations)
+	}
+	if owner := profile.ProfileOwner; len(owner.PLMN) > 0 {
+		tlv := bertlv.NewChildren(sgp22.TagProfileOwner, bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), owner.PLMN))
+		if owner.GID1 != nil {
+			tlv.Children = append(tlv.Children, bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), owner.GID1))
+		}
+		if owner.GID2 != nil {
+			tlv.Children = append(tlv.Children, bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), owner.GID2))
+		}
+		fields = append(fields, tlv)
+	}
+	if rules := profile.ProfilePolicyRules; rules != (sgp22.ProfilePolicyRules{}) {
+		tlv, err := bertlv.MarshalValue(sgp22.TagProfilePolicyRules, policyRules(rules))
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, tlv)
+	}
+	if len(tags) > 0 {
+		fields = slices.DeleteFunc(fields, func(field *bertlv.TLV) bool {
+			return !slices.ContainsFunc(tags, field.Tag.Equal)
+		})
+	}
+	return bertlv.NewChildren(bertlv.Private.Constructed(3), fields...), nil
+}
+
+// policyRules encodes the Profile Policy Rules as the PprIds bit string.
+func policyRules(rules sgp22.ProfilePolicyRules) encoding.BinaryMarshaler {
+	return primitive.MarshalBitString([]bool{rules.UpdateControl, rules.DisablingNotAllowed, rules.DeletionNotAllowed})
+}
+
+// enableProfile enables the profile and disables the profile enabled before it, queueing a notification for each.
+//
+// See https://aka.pw/sgp22/v2.5#page=201 (Section 5.7.16, ES10c.EnableProfile)
+func (c *Card) enableProfile(request *bertlv.TLV) (*bertlv.TLV, error) {
+	profile := c.lookup(identifier(request.First(bertlv.ContextSpecific.Constructed(0))))
+	if profile == nil {
+		return result(request.Tag, sgp22.ProfileOperationResultICCIDOrAIDNotFound), nil
+	}
+	if profile.ProfileState == sgp22.ProfileEnabled {
+		return result(request.Tag, sgp22.ProfileOperationResultProfileNotInDisabledState), nil
+	}
+	if enabled := c.enabledProfile(); enabled != nil {
+		if enabled.ProfilePolicyRules.DisablingNotAllowed {
+			return result(request.Tag, sgp22.ProfileOperationResultDisallowedByPolicy), nil
+		}
+		enabled.ProfileState = sgp22.ProfileDisabled
+		if err := c.notify(sgp22.NotificationEventDisable, enabled); err != nil {
+			return nil, err
+		}
+	}
+	profile.ProfileState = sgp22.ProfileEnabled
+	if err := c.notify(sgp22.NotificationEventEnable, profile); err != nil {
+		return nil, err
+	}
+	return result(request.Tag, sgp22.ProfileOperationResultOK), nil
+}
+
+// disableProfile disables the enabled profile, unless its Profile Policy Rules forbid it.
+//
+// See https://aka.pw/sgp22/v2.5#page=204 (Section 5.7.17, ES10c.DisableProfile)
+func (c *Card) disableProfile(request *bertlv.TLV) (*bertlv.TLV, error) {
+	profile := c.lookup(identifier(request.First(bertlv.ContextSpecific.Constructed(0))))
+	switch {
+	case profile == nil:
+		return result(request.Tag, sgp22.ProfileOperationResultICCIDOrAIDNotFound), nil
+	case profile.ProfileState != sgp22.ProfileEnabled:
+		return result(request.Tag, sgp22.ProfileOperationResultProfileNotInEnabledState), nil
+	case profile.ProfilePolicyRules.DisablingNotAllowed:
+		return result(request.Tag, sgp22.ProfileOperationResultDisallowedByPolicy), nil
+	}
+	profile.ProfileState = sgp22.ProfileDisabled
+	if err := c.notify(sgp22.NotificationEventDisable, profile); err != nil {
+		return nil, err
+	}
+	return result(request.Tag, sgp22.ProfileOperationResultOK), nil
+}
+
+// deleteProfile deletes a disabled profile, unless its Profile Policy Rules forbid it.
+//
+// See https://aka.pw/sgp22/v2.5#page=206 (Section 5.7.18, ES10c.DeleteProfile)
+func (c *Card) deleteProfile(request *bertlv.TLV) (*bertlv.TLV, error) {
+	profile := c.lookup(identifier(request))
+	switch {
+	case profile == nil:
+		return result(request.Tag, sgp22.ProfileOperationResultICCIDOrAIDNotFound), nil
+	case profile.ProfileState == sgp22.ProfileEnabled:
+		return result(request.Tag, sgp22.ProfileOperationResultProfileNotInDisabledState), nil
+	case profile.ProfilePolicyRules.DeletionNotAllowed:
+		return result(request.Tag, sgp22.ProfileOperationResultDisallowedByPolicy), nil
+	}
+	c.profiles = slices.DeleteFunc(c.profiles, func(p *sgp22.ProfileInfo) bool { return p == profile })
+	if err := c.notify(sgp22.NotificationEventDelete, profile); err != nil {
+		return nil, err
+	}
+	return result(request.Tag, sgp22.ProfileOperationResultOK), nil
+}
+
+// memoryReset deletes the operational or test profiles and resets the default SM-DP+ address
+// as the reset options ask for. No notification is queued.
+//
+// See https://aka.pw/sgp22/v2.5#page=207 (Section 5.7.19, ES10c.eUICCMemoryReset)
+func (c *Card) memoryReset(request *bertlv.TLV) (*bertlv.TLV, error) {
+	resetOptions := request.First(bertlv.ContextSpecific.Primitive(2))
+	if resetOptions == nil {
+		return nil, errors.New("missing reset options")
+	}
+	var options []bool
+	if err := resetOptions.UnmarshalValue(primitive.UnmarshalBitString(&options)); err != nil {
+		return nil, err
+	}
+	options = append(options, make([]bool, 3)...)
+	n := len(c.profiles)
+	c.profiles = slices.DeleteFunc(c.profiles, func(profile *sgp22.ProfileInfo) bool {
+		return options[0] && profile.ProfileClass == sgp22.ProfileClassOperational ||
+			options[1] && profile.ProfileClass == sgp22.ProfileClassTest
+	})
+	deleted := len(c.profiles) < n
+	if options[2] && c.defaultSMDPAddress != "" {
+		c.defaultSMDPAddress = ""
+		deleted = true
+	}
+	if !deleted {
+		return result(request.Tag, sgp22.EuiccMemoryResetResultNothingToDelete), nil
+	}
+	return result(request.Tag, sgp22.EuiccMemoryResetResultOK), nil
+}
+
+// euiccData returns the EID.
+//
+// See https://aka.pw/sgp22/v2.5#page=209 (Section 5.7.20, ES10c.GetEID)
+func (c *Card) euiccData(request *bertlv.TLV) (*bertlv.TLV, error) {
+	return bertlv.NewChildren(request.Tag, bertlv.NewValue(bertlv.Application.Primitive(26), c.eid)), nil
+}
+
+// setNickname sets the nickname of the profile.
+//
+// See https://aka.pw/sgp22/v2.5#page=209 (Section 5.7.21, ES10c.SetNickname)
+func (c *Card) setNickname(request *bertlv.TLV) (*bertlv.TLV, error) {
+	iccid := request.First(sgp22.TagICCID)
+	nickname := request.First(sgp22.TagNickname)
+	if iccid == nil || nickname == nil {
+		return nil, errors.New("missing ICCID or nickname")
+	}
+	profile := c.lookup(iccid)
+	if profile == nil {
+		return result(request.Tag, sgp22.SetNicknameResultICCIDNotFound), nil
+	}
+	profile.ProfileNickname = string(nickname.Value)
+	return result(request.Tag, sgp22.SetNicknameResultOK), nil
+}
+
+// result returns a response holding only the result code.
+func result[Result ~int8](tag bertlv.Tag, code Result) *bertlv.TLV {
+	return bertlv.NewChildren(tag, bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{byte(code)}))
+}
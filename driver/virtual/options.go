@@ -0,0 +1,42 @@
+package virtual
+
+// DefaultEID is the EID of a card created without WithEID.
+const DefaultEID = "89049032123451234512345678901235"
+
+type config struct {
+	eid                string
+	defaultSMDPAddress string
+	rootSMDSAddress    string
+}
+
+// Option configures a virtual card.
+type Option func(*config)
+
+// WithEID sets the EID of the card, which is bound to its eUICC certificate. The default is DefaultEID.
+func WithEID(eid string) Option {
+	return func(config *config) {
+		config.eid = eid
+	}
+}
+
+// WithDefaultSMDPAddress sets the default SM-DP+ address of the card. The default is empty.
+func WithDefaultSMDPAddress(address string) Option {
+	return func(config *config) {
+		config.defaultSMDPAddress = address
+	}
+}
+
+// WithRootSMDSAddress sets the root SM-DS address of the card. The default is lpa.ds.gsma.com.
+func WithRootSMDSAddress(address string) Option {
+	return func(config *config) {
+		config.rootSMDSAddress = address
+	}
+}
+
+func applyOptions(options []Option) config {
+	config := config{eid: DefaultEID, rootSMDSAddress: "lpa.ds.gsma.com"}
+	for _, option := range options {
+		option(&config)
+	}
+	return config
+}
@@ -0,0 +1,401 @@
+// Package virtual implements a software eUICC for tests that need no hardware.
+//
+// A [Card] is a [driver.SmartCardChannel] whose APDUs are answered in memory:
+// it opens logical channels with MANAGE CHANNEL, selects the ISD-R, and chains
+// ES10a, ES10b and ES10c functions over STORE DATA and GET RESPONSE like a real card.
+// Bound profile packages are loaded segment by segment over the SCP03t secure channel.
+// Its certificates are issued by an [rsptest.CI], so it can download profiles from an
+// [rsptest.SMDP] and discover events on an [rsptest.SMDS].
+package virtual
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/driver"
+	"github.com/damonto/euicc-go/driver/iso7816"
+	"github.com/damonto/euicc-go/rsptest"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+const maxLogicalChannel = 19
+
+// isdrAID is the AID of the ISD-R, see lpa.GSMAISDRApplicationAID.
+var isdrAID = []byte{0xA0, 0x00, 0x00, 0x05, 0x59, 0x10, 0x10, 0xFF, 0xFF, 0xFF, 0xFF, 0x89, 0x00, 0x00, 0x01, 0x00}
+
+// Status words returned by the card.
+var (
+	swOK                     = []byte{0x90, 0x00}
+	swWrongLength            = []byte{0x67, 0x00}
+	swChannelNotSupported    = []byte{0x68, 0x81}
+	swConditionsNotSatisfied = []byte{0x69, 0x85}
+	swWrongData              = []byte{0x6A, 0x80}
+	swFileNotFound           = []byte{0x6A, 0x82}
+	swNoChannelAvailable     = []byte{0x6A, 0x81}
+	swIncorrectP1P2          = []byte{0x6A, 0x86}
+	swINSNotSupported        = []byte{0x6D, 0x00}
+	swCLANotSupported        = []byte{0x6E, 0x00}
+)
+
+var _ driver.SmartCardChannel = (*Card)(nil)
+
+// Card is a software eUICC. Its profiles, notifications and addresses are kept
+// across Disconnect and Connect, while the logical channels and the RSP session
+// are lost as if the card was powered off.
+//
+// Like other channels, the [driver.SmartCardChannel] methods are not safe for
+// concurrent use, but the other methods can be called while the card is in use.
+type Card struct {
+	channel *iso7816.Channel
+
+	mu                 sync.Mutex
+	ci                 *rsptest.CI
+	eid                sgp22.EID
+	key                *ecdsa.PrivateKey
+	certificate        *x509.Certificate
+	certificates       []*bertlv.TLV
+	defaultSMDPAddress string
+	rootSMDSAddress    string
+	profiles           []*sgp22.ProfileInfo
+	notifications      []*notification
+	sequenceNumber     sgp22.SequenceNumber
+	challenge          []byte
+	session            *session
+	installation       *installation
+
+	// selected holds the logical channels the ISD-R is selected on.
+	selected [maxLogicalChannel + 1]bool
+	open     [maxLogicalChannel + 1]bool
+	command  bytes.Buffer
+	block    int
+	response []byte
+}
+
+// New creates a card whose CERT.EUM.ECDSA and CERT.EUICC.ECDSA are issued by ci.
+// The card has no profiles until they are added with [Card.AddProfile].
+func New(ci *rsptest.CI, options ...Option) (*Card, error) {
+	if ci == nil {
+		return nil, errors.New("CI is required")
+	}
+	config := applyOptions(options)
+	eid, err := sgp22.ParseEID(config.eid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EID: %w", err)
+	}
+	certificate, eum, key, err := ci.IssueEUICC(eid.String())
+	if err != nil {
+		return nil, fmt.Errorf("issue eUICC certificate: %w", err)
+	}
+	card := &Card{
+		ci:                 ci,
+		eid:                eid,
+		key:                key,
+		certificate:        certificate,
+		defaultSMDPAddress: config.defaultSMDPAddress,
+		rootSMDSAddress:    config.rootSMDSAddress,
+	}
+	for _, certificate := range []*x509.Certificate{certificate, eum} {
+		var tlv bertlv.TLV
+		if err := tlv.UnmarshalBinary(certificate.Raw); err != nil {
+			return nil, err
+		}
+		card.certificates = append(card.certificates, &tlv)
+	}
+	return card, nil
+}
+
+// EID returns the EID of the card.
+func (c *Card) EID() sgp22.EID {
+	return slices.Clone(c.eid)
+}
+
+// Certificate returns CERT.EUICC.ECDSA, which verifies the signatures of the card.
+func (c *Card) Certificate() *x509.Certificate {
+	return c.certificate
+}
+
+// Connect powers the card on.
+func (c *Card) Connect(ctx context.Context) error {
+	if c.channel != nil {
+		return nil
+	}
+	channel := iso7816.NewChannel(&transmitter{card: c})
+	if err := channel.Connect(ctx); err != nil {
+		return errors.Join(err, channel.Disconnect())
+	}
+	c.channel = channel
+	return nil
+}
+
+// Disconnect powers the card off. The card can be connected again.
+func (c *Card) Disconnect() error {
+	if c.channel == nil {
+		return nil
+	}
+	channel := c.channel
+	c.channel = nil
+	return channel.Disconnect()
+}
+
+func (c *Card) Transmit(ctx context.Context, command []byte) ([]byte, error) {
+	channel, err := c.smartCardChannel()
+	if err != nil {
+		return nil, err
+	}
+	return channel.Transmit(ctx, command)
+}
+
+func (c *Card) OpenLogicalChannel(ctx context.Context, aid []byte) (byte, error) {
+	channel, err := c.smartCardChannel()
+	if err != nil {
+		return 0, err
+	}
+	return channel.OpenLogicalChannel(ctx, aid)
+}
+
+func (c *Card) CloseLogicalChannel(ctx context.Context, channel byte) error {
+	smartCardChannel, err := c.smartCardChannel()
+	if err != nil {
+		return err
+	}
+	return smartCardChannel.CloseLogicalChannel(ctx, channel)
+}
+
+func (c *Card) smartCardChannel() (*iso7816.Channel, error) {
+	if c.channel == nil {
+		return nil, errors.New("virtual card is not connected")
+	}
+	return c.channel, nil
+}
+
+// transmitter passes APDUs to the card. Closing it powers the card off.
+type transmitter struct {
+	card *Card
+}
+
+func (t *transmitter) Transmit(ctx context.Context, command []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	t.card.mu.Lock()
+	defer t.card.mu.Unlock()
+	return t.card.process(command), nil
+}
+
+func (t *transmitter) Close() error {
+	t.card.mu.Lock()
+	defer t.card.mu.Unlock()
+	t.card.reset()
+	return nil
+}
+
+// reset closes every logical channel and drops the state kept in volatile memory.
+func (c *Card) reset() {
+	c.open = [maxLogicalChannel + 1]bool{}
+	c.selected = [maxLogicalChannel + 1]bool{}
+	c.command.Reset()
+	c.block = 0
+	c.response = nil
+	c.challenge = nil
+	c.session = nil
+	c.installation = nil
+}
+
+// process answers a command APDU with its response data followed by the status word.
+func (c *Card) process(command []byte) []byte {
+	if len(command) < 4 {
+		return swWrongLength
+	}
+	cla, ins, p1, p2 := command[0], command[1], command[2], command[3]
+	data, le, ok := commandBody(command[4:])
+	if !ok {
+		return swWrongLength
+	}
+	channel := cla & 0x03
+	if cla&0x40 != 0 {
+		channel = cla&0x0F + 4
+	}
+	// The logical channel bits aside, interindustry commands use CLA 00 and the GlobalPlatform commands CLA 80.
+	proprietary := cla&0x80 != 0
+	if channel != 0 && !c.open[channel] {
+		return swChannelNotSupported
+	}
+	switch {
+	case ins == 0xAA && proprietary:
+		// The terminal capabilities sent on connect are accepted as they are.
+		return swOK
+	case ins == 0x70 && !proprietary:
+		return c.manageChannel(p1, p2)
+	case ins == 0xA4 && !proprietary:
+		return c.selectApplication(channel, p1, data)
+	case ins == 0xE2 && proprietary:
+		if !c.selected[channel] {
+			return swConditionsNotSatisfied
+		}
+		return c.storeData(p1, p2, data)
+	case ins == 0xC0 && proprietary:
+		return c.getResponse(le)
+	case ins == 0xE2 || ins == 0xC0 || ins == 0x70 || ins == 0xA4 || ins == 0xAA:
+		return swCLANotSupported
+	}
+	return swINSNotSupported
+}
+
+// commandBody returns the command data and Le of the body of a short APDU. Le is 256 if it is absent or zero.
+func commandBody(body []byte) (data []byte, le int, ok bool) {
+	le = 256
+	switch {
+	case len(body) == 0:
+		return nil, le, true
+	case len(body) == 1:
+		if body[0] != 0 {
+			le = int(body[0])
+		}
+		return nil, le, true
+	}
+	lc := int(body[0])
+	if lc == 0 || len(body) < 1+lc || len(body) > 2+lc {
+		return nil, 0, false
+	}
+	if len(body) == 2+lc && body[1+lc] != 0 {
+		le = int(body[1+lc])
+	}
+	return body[1 : 1+lc], le, true
+}
+
+// manageChannel opens the first free logical channel if P1 is 00, or closes the channel in P2 if P1 is 80.
+func (c *Card) manageChannel(p1, p2 byte) []byte {
+	switch {
+	case p1 == 0x00 && p2 == 0x00:
+		for channel := byte(1); channel <= maxLogicalChannel; channel++ {
+			if !c.open[channel] {
+				c.open[channel] = true
+				return append([]byte{channel}, swOK...)
+			}
+		}
+		return swNoChannelAvailable
+	case p1 == 0x80 && p2 != 0 && p2 <= maxLogicalChannel:
+		if !c.open[p2] {
+			return swChannelNotSupported
+		}
+		c.open[p2] = false
+		c.selected[p2] = false
+		c.command.Reset()
+		c.block = 0
+		c.response = nil
+		return swOK
+	}
+	return swIncorrectP1P2
+}
+
+// selectApplication selects the ISD-R by its AID. No other application exists.
+func (c *Card) selectApplication(channel, p1 byte, aid []byte) []byte {
+	if p1 != 0x04 {
+		return swIncorrectP1P2
+	}
+	if !bytes.Equal(aid, isdrAID) {
+		return swFileNotFound
+	}
+	c.selected[channel] = true
+	return swOK
+}
+
+// storeData collects the blocks of an ES10 command. P1 marks the last block with bit 8, and P2 numbers the blocks from 0.
+// The command is run once its last block arrives, and its response is left for GET RESPONSE.
+// A segment of a bound profile package is loaded instead, as it is not a complete TLV.
+func (c *Card) storeData(p1, p2 byte, data []byte) []byte {
+	if int(p2) != c.block {
+		c.command.Reset()
+		c.block = 0
+		return swIncorrectP1P2
+	}
+	c.command.Write(data)
+	c.block++
+	if p1&0x80 == 0 {
+		return swOK
+	}
+	command := bytes.Clone(c.command.Bytes())
+	c.command.Reset()
+	c.block = 0
+	var response *bertlv.TLV
+	var err error
+	if boundProfilePackageSegment(command) {
+		response, err = c.loadBoundProfilePackage(command)
+	} else {
+		var request bertlv.TLV
+		if err := request.UnmarshalBinary(command); err != nil {
+			return swWrongData
+		}
+		response, err = c.handle(&request)
+	}
+	if err != nil {
+		return swWrongData
+	}
+	if response == nil {
+		c.response = nil
+		return swOK
+	}
+	if c.response, err = response.MarshalBinary(); err != nil {
+		return swWrongData
+	}
+	return moreData(len(c.response))
+}
+
+// getResponse returns up to le bytes of the pending response.
+func (c *Card) getResponse(le int) []byte {
+	if len(c.response) == 0 {
+		return swConditionsNotSatisfied
+	}
+	n := min(le, len(c.response))
+	response := slices.Clone(c.response[:n])
+	if c.response = c.response[n:]; len(c.response) > 0 {
+		return append(response, moreData(len(c.response))...)
+	}
+	c.response = nil
+	return append(response, swOK...)
+}
+
+// moreData returns the status word announcing n bytes of response data, 61 00 standing for 256 or more.
+func moreData(n int) []byte {
+	return []byte{0x61, byte(min(n, 256))}
+}
+
+// handle runs an ES10 function. An error means the command is malformed.
+func (c *Card) handle(request *bertlv.TLV) (*bertlv.TLV, error) {
+	if !request.Tag.ContextSpecific() || !request.Tag.Constructed() {
+		return nil, errors.New("unsupported command")
+	}
+	handlers := map[uint64]func(*bertlv.TLV) (*bertlv.TLV, error){
+		32: c.euiccInfo1,
+		33: c.prepareDownload,
+		34: c.euiccInfo2,
+		40: c.listNotification,
+		41: c.setNickname,
+		43: c.retrieveNotificationsList,
+		45: c.profileInfoList,
+		46: c.euiccChallenge,
+		48: c.removeNotificationFromList,
+		49: c.enableProfile,
+		50: c.disableProfile,
+		51: c.deleteProfile,
+		52: c.memoryReset,
+		56: c.authenticateServer,
+		60: c.euiccConfiguredAddresses,
+		62: c.euiccData,
+		63: c.setDefaultDPAddress,
+		65: c.cancelSession,
+		67: c.rulesAuthorisationTable,
+	}
+	handler, ok := handlers[request.Tag.Value()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported command %s", request.Tag.String())
+	}
+	return handler(request)
+}
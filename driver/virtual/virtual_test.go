@@ -0,0 +1,878 @@
+package virtual
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/driver"
+	"github.com/damonto/euicc-go/driver/trace"
+	"github.com/damonto/euicc-go/http"
+	"github.com/damonto/euicc-go/lpa"
+	"github.com/damonto/euicc-go/rsptest"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+const testIMEI = "356938035643809"
+
+func newTestCard(t *testing.T, options ...Option) (*rsptest.CI, *Card) {
+	t.Helper()
+	ci, err := rsptest.NewCI()
+	if err != nil {
+		t.Fatalf("NewCI() error = %v", err)
+	}
+	card, err := New(ci, options...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return ci, card
+}
+
+func newTestClient(t *testing.T, card *Card) *lpa.Client {
+	t.Helper()
+	client, err := lpa.New(t.Context(), &lpa.Options{
+		Channel: card,
+		Logger:  slog.New(slog.DiscardHandler),
+	})
+	if err != nil {
+		t.Fatalf("lpa.New() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func testProfile(t *testing.T, iccid string, state sgp22.ProfileState) sgp22.ProfileInfo {
+	t.Helper()
+	id, err := sgp22.NewICCID(iccid)
+	if err != nil {
+		t.Fatalf("NewICCID() error = %v", err)
+	}
+	return sgp22.ProfileInfo{
+		ICCID:               id,
+		ProfileState:        state,
+		ServiceProviderName: "RSPTEST",
+		ProfileName:         "Profile " + iccid[len(iccid)-2:],
+		ProfileClass:        sgp22.ProfileClassOperational,
+		NotificationConfigurationInfo: sgp22.NotificationConfigurationInfo{{
+			ProfileManagementOperations: []sgp22.NotificationEvent{sgp22.NotificationEventEnable, sgp22.NotificationEventDisable, sgp22.NotificationEventDelete},
+			Address:                     "smdp.example.com",
+		}},
+	}
+}
+
+// transmit sends a command APDU to the card and returns the response.
+func transmit(t *testing.T, card *Card, command []byte) []byte {
+	t.Helper()
+	response, err := card.Transmit(t.Context(), command)
+	if err != nil {
+		t.Fatalf("Transmit(% X) error = %v", command, err)
+	}
+	return response
+}
+
+func TestCardAPDU(t *testing.T) {
+	_, card := newTestCard(t)
+	if _, err := card.Transmit(t.Context(), []byte{0x80, 0xE2, 0x91, 0x00}); err == nil {
+		t.Fatalf("Transmit() before Connect error = nil, want an error")
+	}
+	if err := card.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer card.Disconnect()
+
+	if _, err := card.OpenLogicalChannel(t.Context(), []byte{0xA0, 0x00, 0x00, 0x00, 0x87}); err == nil {
+		t.Errorf("OpenLogicalChannel(USIM) error = nil, want file not found")
+	}
+	if got := transmit(t, card, []byte{0x81, 0xE2, 0x91, 0x00, 0x03, 0xBF, 0x3E, 0x00}); !bytes.Equal(got, swChannelNotSupported) {
+		t.Errorf("STORE DATA on a closed channel = % X, want % X", got, swChannelNotSupported)
+	}
+	channel, err := card.OpenLogicalChannel(t.Context(), isdrAID)
+	if err != nil {
+		t.Fatalf("OpenLogicalChannel() error = %v", err)
+	}
+	if channel != 1 {
+		t.Fatalf("OpenLogicalChannel() = %d, want 1", channel)
+	}
+	cla := 0x80 | channel
+
+	if got := transmit(t, card, []byte{0x80, 0xE2, 0x91, 0x00, 0x03, 0xBF, 0x3E, 0x00}); !bytes.Equal(got, swConditionsNotSatisfied) {
+		t.Errorf("STORE DATA without the ISD-R = % X, want % X", got, swConditionsNotSatisfied)
+	}
+	if got := transmit(t, card, []byte{cla, 0xCA, 0x00, 0x00, 0x00}); !bytes.Equal(got, swINSNotSupported) {
+		t.Errorf("GET DATA = % X, want % X", got, swINSNotSupported)
+	}
+	if got := transmit(t, card, []byte{cla, 0xE2, 0x91, 0x01, 0x03, 0xBF, 0x3E, 0x00}); !bytes.Equal(got, swIncorrectP1P2) {
+		t.Errorf("STORE DATA out of sequence = % X, want % X", got, swIncorrectP1P2)
+	}
+	if got := transmit(t, card, []byte{cla, 0xE2, 0x91, 0x00, 0x03, 0xBF, 0x7F, 0x00}); !bytes.Equal(got, swWrongData) {
+		t.Errorf("STORE DATA of an unknown function = % X, want % X", got, swWrongData)
+	}
+
+	// GetEUICCData is sent in two blocks and its response is read 8 bytes at a time.
+	if got := transmit(t, card, []byte{cla, 0xE2, 0x11, 0x00, 0x02, 0xBF, 0x3E}); !bytes.Equal(got, swOK) {
+		t.Fatalf("STORE DATA block 0 = % X, want % X", got, swOK)
+	}
+	response := transmit(t, card, []byte{cla, 0xE2, 0x91, 0x01, 0x01, 0x00})
+	want := append([]byte{0xBF, 0x3E, 0x12, 0x5A, 0x10}, card.EID()...)
+	if !bytes.Equal(response, []byte{0x61, byte(len(want))}) {
+		t.Fatalf("STORE DATA block 1 = % X, want 61 %02X", response, len(want))
+	}
+	var data []byte
+	for response[0] == 0x61 {
+		response = transmit(t, card, []byte{cla, 0xC0, 0x00, 0x00, 0x08})
+		data = append(data, response[:len(response)-2]...)
+		response = response[len(response)-2:]
+	}
+	if !bytes.Equal(response, swOK) || !bytes.Equal(data, want) {
+		t.Errorf("GET RESPONSE = % X % X, want % X 90 00", data, response, want)
+	}
+	if got := transmit(t, card, []byte{cla, 0xC0, 0x00, 0x00, 0x00}); !bytes.Equal(got, swConditionsNotSatisfied) {
+		t.Errorf("GET RESPONSE without data = % X, want % X", got, swConditionsNotSatisfied)
+	}
+
+	if err := card.CloseLogicalChannel(t.Context(), channel); err != nil {
+		t.Fatalf("CloseLogicalChannel() error = %v", err)
+	}
+	if got := transmit(t, card, []byte{cla, 0xE2, 0x91, 0x00, 0x03, 0xBF, 0x3E, 0x00}); !bytes.Equal(got, swChannelNotSupported) {
+		t.Errorf("STORE DATA after CloseLogicalChannel = % X, want % X", got, swChannelNotSupported)
+	}
+}
+
+// operationResult returns the result of a failed profile operation, or OK if err is not a ProfileOperationError.
+func operationResult(err error) sgp22.ProfileOperationResult {
+	var operationError *sgp22.ProfileOperationError
+	if errors.As(err, &operationError) {
+		return operationError.Result
+	}
+	return sgp22.ProfileOperationResultOK
+}
+
+func TestCardProfiles(t *testing.T) {
+	_, card := newTestCard(t)
+	first, second := testProfile(t, "8944476500001224101", sgp22.ProfileEnabled), testProfile(t, "8944476500001224102", sgp22.ProfileDisabled)
+	second.ProfilePolicyRules.DeletionNotAllowed = true
+	for _, profile := range []sgp22.ProfileInfo{first, second} {
+		if err := card.AddProfile(profile); err != nil {
+			t.Fatalf("AddProfile() error = %v", err)
+		}
+	}
+	if err := card.AddProfile(first); err == nil {
+		t.Errorf("AddProfile() of a duplicate ICCID error = nil, want an error")
+	}
+	client := newTestClient(t, card)
+
+	eid, err := client.EID(t.Context())
+	if err != nil {
+		t.Fatalf("EID() error = %v", err)
+	}
+	if eid.String() != DefaultEID {
+		t.Errorf("EID() = %s, want %s", eid, DefaultEID)
+	}
+
+	profiles, err := client.ListProfile(t.Context(), nil, nil)
+	if err != nil {
+		t.Fatalf("ListProfile() error = %v", err)
+	}
+	if len(profiles) != 2 {
+		t.Fatalf("ListProfile() returned %d profiles, want 2", len(profiles))
+	}
+	if got := profiles[0]; !bytes.Equal(got.ICCID, first.ICCID) || got.ProfileState != sgp22.ProfileEnabled || got.ProfileName != first.ProfileName {
+		t.Errorf("ListProfile()[0] = %+v, want %+v", got, first)
+	}
+	if got := profiles[1].ISDPAID.String(); got != "A0000005591010FFFFFFFF8900001100" {
+		t.Errorf("ListProfile()[1].ISDPAID = %s, want A0000005591010FFFFFFFF8900001100", got)
+	}
+	if profiles[1].ProfilePolicyRules.DeletionNotAllowed {
+		t.Errorf("ListProfile()[1].ProfilePolicyRules = %+v, want them left out of the tag list", profiles[1].ProfilePolicyRules)
+	}
+	profiles, err = client.ListProfile(t.Context(), second.ICCID, []bertlv.Tag{sgp22.TagProfilePolicyRules})
+	if err != nil {
+		t.Fatalf("ListProfile(ICCID) error = %v", err)
+	}
+	if len(profiles) != 1 || !bytes.Equal(profiles[0].ICCID, second.ICCID) {
+		t.Fatalf("ListProfile(ICCID) = %+v, want only %s", profiles, second.ICCID)
+	}
+	if !profiles[0].ProfilePolicyRules.DeletionNotAllowed {
+		t.Errorf("ListProfile(ICCID).ProfilePolicyRules = %+v, want pprDeletionNotAllowed", profiles[0].ProfilePolicyRules)
+	}
+
+	if err := client.EnableProfile(t.Context(), second.ICCID, false); err != nil {
+		t.Fatalf("EnableProfile() error = %v", err)
+	}
+	if err := client.EnableProfile(t.Context(), second.ICCID, false); operationResult(err) != sgp22.ProfileOperationResultProfileNotInDisabledState {
+		t.Errorf("EnableProfile() of the enabled profile error = %v, want profileNotInDisabledState", err)
+	}
+	if err := client.DeleteProfile(t.Context(), first.ICCID); err != nil {
+		t.Fatalf("DeleteProfile() error = %v", err)
+	}
+	if err := client.DisableProfile(t.Context(), second.ICCID, false); err != nil {
+		t.Fatalf("DisableProfile() error = %v", err)
+	}
+	if err := client.DeleteProfile(t.Context(), second.ICCID); operationResult(err) != sgp22.ProfileOperationResultDisallowedByPolicy {
+		t.Errorf("DeleteProfile() error = %v, want disallowedByPolicy", err)
+	}
+	if err := client.SetNickname(t.Context(), second.ICCID, "Work"); err != nil {
+		t.Fatalf("SetNickname() error = %v", err)
+	}
+	if err := client.SetNickname(t.Context(), first.ICCID, "Home"); !errors.Is(err, sgp22.ErrICCIDNotFound) {
+		t.Errorf("SetNickname() of a deleted profile error = %v, want %v", err, sgp22.ErrICCIDNotFound)
+	}
+
+	profiles = card.Profiles()
+	if len(profiles) != 1 || profiles[0].ProfileState != sgp22.ProfileDisabled || profiles[0].ProfileNickname != "Work" {
+		t.Errorf("Profiles() = %+v, want the disabled profile %s named Work", profiles, second.ICCID)
+	}
+	var events []sgp22.NotificationEvent
+	for _, notification := range card.Notifications() {
+		events = append(events, notification.ProfileManagementOperation)
+	}
+	want := []sgp22.NotificationEvent{
+		sgp22.NotificationEventDisable, sgp22.NotificationEventEnable,
+		sgp22.NotificationEventDelete, sgp22.NotificationEventDisable,
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("Notifications() events = %v, want %v", events, want)
+	}
+}
+
+func TestCardNotifications(t *testing.T) {
+	ci, card := newTestCard(t)
+	profile := testProfile(t, "8944476500001224101", sgp22.ProfileDisabled)
+	if err := card.AddProfile(profile); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	client := newTestClient(t, card)
+	if err := client.EnableProfile(t.Context(), profile.ICCID, false); err != nil {
+		t.Fatalf("EnableProfile() error = %v", err)
+	}
+	if err := client.DisableProfile(t.Context(), profile.ICCID, false); err != nil {
+		t.Fatalf("DisableProfile() error = %v", err)
+	}
+
+	notifications, err := client.ListNotification(t.Context(), sgp22.NotificationEventDisable)
+	if err != nil {
+		t.Fatalf("ListNotification() error = %v", err)
+	}
+	if len(notifications) != 1 || notifications[0].SequenceNumber != 2 || notifications[0].Address != "smdp.example.com" {
+		t.Fatalf("ListNotification(disable) = %+v, want the second notification", notifications)
+	}
+	pending, err := client.RetrieveNotificationList(t.Context(), sgp22.SequenceNumber(1))
+	if err != nil {
+		t.Fatalf("RetrieveNotificationList() error = %v", err)
+	}
+	if len(pending) != 1 || pending[0].Notification.ProfileManagementOperation != sgp22.NotificationEventEnable {
+		t.Fatalf("RetrieveNotificationList(1) = %+v, want the enable notification", pending)
+	}
+	if !bytes.Equal(pending[0].Notification.ICCID, profile.ICCID) {
+		t.Errorf("RetrieveNotificationList(1) ICCID = %s, want %s", pending[0].Notification.ICCID, profile.ICCID)
+	}
+	if err := pending[0].Verify(card.Certificate(), ci.Roots(), time.Time{}); err != nil {
+		t.Errorf("PendingNotification.Verify() error = %v", err)
+	}
+
+	if err := client.RemoveNotificationFromList(t.Context(), 1); err != nil {
+		t.Fatalf("RemoveNotificationFromList() error = %v", err)
+	}
+	if err := client.RemoveNotificationFromList(t.Context(), 1); !errors.Is(err, sgp22.ErrNothingToDelete) {
+		t.Errorf("RemoveNotificationFromList() of a removed notification error = %v, want %v", err, sgp22.ErrNothingToDelete)
+	}
+	if pending, err = client.RetrieveNotificationList(t.Context(), nil); err != nil || len(pending) != 1 {
+		t.Errorf("RetrieveNotificationList() = %d notifications, %v, want 1", len(pending), err)
+	}
+}
+
+func TestCardConfiguration(t *testing.T) {
+	ci, card := newTestCard(t, WithEID("89049032123451234512345678909965"), WithRootSMDSAddress("smds.example.com"))
+	if got := card.EID().String(); got != "89049032123451234512345678909965" {
+		t.Errorf("EID() = %s, want 89049032123451234512345678909965", got)
+	}
+	client := newTestClient(t, card)
+
+	addresses, err := client.EUICCConfiguredAddresses(t.Context())
+	if err != nil {
+		t.Fatalf("EUICCConfiguredAddresses() error = %v", err)
+	}
+	if addresses.DefaultSMDPAddress != "" || addresses.RootSMDSAddress != "smds.example.com" {
+		t.Errorf("EUICCConfiguredAddresses() = %+v, want only the root SM-DS address", addresses)
+	}
+	if err := client.SetDefaultDPAddress(t.Context(), "smdp.example.com"); err != nil {
+		t.Fatalf("SetDefaultDPAddress() error = %v", err)
+	}
+	if addresses, _ = client.EUICCConfiguredAddresses(t.Context()); addresses.DefaultSMDPAddress != "smdp.example.com" {
+		t.Errorf("EUICCConfiguredAddresses().DefaultSMDPAddress = %q, want smdp.example.com", addresses.DefaultSMDPAddress)
+	}
+
+	info1, err := client.EUICCInfo1(t.Context())
+	if err != nil {
+		t.Fatalf("EUICCInfo1() error = %v", err)
+	}
+	if len(info1.CIPKIDListForSigning) != 1 || !bytes.Equal(info1.CIPKIDListForSigning[0], ci.Certificate.SubjectKeyId) {
+		t.Errorf("EUICCInfo1().CIPKIDListForSigning = %X, want the CI", info1.CIPKIDListForSigning)
+	}
+	info2, err := client.EUICCInfo2(t.Context())
+	if err != nil {
+		t.Fatalf("EUICCInfo2() error = %v", err)
+	}
+	if info2.SVN.String() != "2.5.0" || !info2.RSPCapability.TestProfileSupport || info2.SASAccreditationNumber != "RSPTEST-SAS" {
+		t.Errorf("EUICCInfo2() = %+v, want SVN 2.5.0 supporting test profiles", info2)
+	}
+
+	if err := card.AddProfile(testProfile(t, "8944476500001224101", sgp22.ProfileEnabled)); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if err := client.MemoryReset(t.Context()); err != nil {
+		t.Fatalf("MemoryReset() error = %v", err)
+	}
+	if profiles := card.Profiles(); len(profiles) != 0 {
+		t.Errorf("Profiles() after MemoryReset() = %d profiles, want none", len(profiles))
+	}
+	if notifications := card.Notifications(); len(notifications) != 0 {
+		t.Errorf("Notifications() after MemoryReset() = %d notifications, want none", len(notifications))
+	}
+	if addresses, _ = client.EUICCConfiguredAddresses(t.Context()); addresses.DefaultSMDPAddress != "" {
+		t.Errorf("EUICCConfiguredAddresses().DefaultSMDPAddress after MemoryReset() = %q, want empty", addresses.DefaultSMDPAddress)
+	}
+}
+
+func TestCardReconnect(t *testing.T) {
+	_, card := newTestCard(t)
+	profile := testProfile(t, "8944476500001224101", sgp22.ProfileDisabled)
+	if err := card.AddProfile(profile); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	client := newTestClient(t, card)
+	if err := client.EnableProfile(t.Context(), profile.ICCID, true); err != nil {
+		t.Fatalf("EnableProfile() error = %v", err)
+	}
+	if _, err := client.EUICCChallenge(t.Context()); err != nil {
+		t.Fatalf("EUICCChallenge() error = %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if card.challenge != nil {
+		t.Errorf("challenge after Close() = % X, want nil", card.challenge)
+	}
+
+	client = newTestClient(t, card)
+	profiles, err := client.ListProfile(t.Context(), nil, nil)
+	if err != nil {
+		t.Fatalf("ListProfile() error = %v", err)
+	}
+	if len(profiles) != 1 || profiles[0].ProfileState != sgp22.ProfileEnabled {
+		t.Errorf("ListProfile() after reconnecting = %+v, want the enabled profile", profiles)
+	}
+}
+
+// newTestSMDP serves an SM-DP+ of the CI with one order, and returns its address.
+func newTestSMDP(t *testing.T, ci *rsptest.CI, client *lpa.Client, order *rsptest.Order) (*rsptest.SMDP, *url.URL) {
+	t.Helper()
+	smdp, err := rsptest.NewSMDP(ci)
+	if err != nil {
+		t.Fatalf("NewSMDP() error = %v", err)
+	}
+	smdp.AddOrder(order)
+	server := httptest.NewTLSServer(smdp)
+	t.Cleanup(server.Close)
+	client.HTTP = &http.Client{Client: server.Client(), AdminProtocolVersion: "2.5.0"}
+	address, _ := url.Parse(server.URL)
+	return smdp, address
+}
+
+func testOrder(t *testing.T) *rsptest.Order {
+	t.Helper()
+	iccid, _ := sgp22.NewICCID("8944476500001224199")
+	return &rsptest.Order{
+		MatchingID: "MATCHING-ID",
+		Profile: &rsptest.Profile{
+			ICCID:               iccid,
+			ServiceProviderName: "RSPTEST",
+			ProfileName:         "Downloaded",
+			ProfileClass:        sgp22.ProfileClassOperational,
+			Elements:            bytes.Repeat([]byte{0xA0, 0x03, 0x80, 0x01, 0x00}, 100),
+		},
+	}
+}
+
+func TestCardPrepareDownload(t *testing.T) {
+	ci, card := newTestCard(t)
+	client := newTestClient(t, card)
+	_, address := newTestSMDP(t, ci, client, testOrder(t))
+
+	initiateAuthenticationResponse, err := client.InitiateAuthentication(t.Context(), address)
+	if err != nil {
+		t.Fatalf("InitiateAuthentication() error = %v", err)
+	}
+	imei, _ := sgp22.NewIMEI(testIMEI)
+	authenticateServerRequest := initiateAuthenticationResponse.CardRequest()
+	authenticateServerRequest.IMEI = imei
+	authenticateServerRequest.MatchingID = []byte("MATCHING-ID")
+	response, err := client.AuthenticateClient(t.Context(), address, authenticateServerRequest)
+	if err != nil {
+		t.Fatalf("AuthenticateClient() error = %v", err)
+	}
+	var metadata sgp22.ProfileInfo
+	if err := metadata.UnmarshalBERTLV(response.ProfileMetadata); err != nil {
+		t.Fatalf("ProfileInfo.UnmarshalBERTLV() error = %v", err)
+	}
+	if metadata.ProfileName != "Downloaded" {
+		t.Errorf("AuthenticateClient() profile name = %q, want Downloaded", metadata.ProfileName)
+	}
+	bpp, err := client.PrepareDownload(t.Context(), address, &sgp22.PrepareDownloadRequest{
+		TransactionID:   response.TransactionID,
+		ProfileMetadata: response.ProfileMetadata,
+		Signed2:         response.Signed2,
+		Signature2:      response.Signature2,
+		Certificate:     response.Certificate,
+	})
+	if err != nil {
+		t.Fatalf("PrepareDownload() error = %v", err)
+	}
+	if err := sgp22.ValidBoundProfilePackage(bpp.BoundProfilePackage); err != nil {
+		t.Errorf("PrepareDownload() bound profile package is invalid: %v", err)
+	}
+	if card.session == nil || card.session.otsk == nil || card.session.smdpCertificate == nil {
+		t.Errorf("session after PrepareDownload() = %+v, want the one-time key and CERT.DPpb.ECDSA", card.session)
+	}
+}
+
+func TestCardAuthenticateServerChallengeMismatch(t *testing.T) {
+	ci, card := newTestCard(t)
+	client := newTestClient(t, card)
+	_, address := newTestSMDP(t, ci, client, testOrder(t))
+
+	initiateAuthenticationResponse, err := client.InitiateAuthentication(t.Context(), address)
+	if err != nil {
+		t.Fatalf("InitiateAuthentication() error = %v", err)
+	}
+	// A new challenge replaces the one signed by the SM-DP+.
+	if _, err := client.EUICCChallenge(t.Context()); err != nil {
+		t.Fatalf("EUICCChallenge() error = %v", err)
+	}
+	imei, _ := sgp22.NewIMEI(testIMEI)
+	request := initiateAuthenticationResponse.CardRequest()
+	request.IMEI = imei
+	var authenticateError *sgp22.AuthenticateResponseError
+	if _, err := client.AuthenticateClient(t.Context(), address, request); !errors.As(err, &authenticateError) ||
+		authenticateError.ErrorCode != sgp22.AuthenticateErrorCodeEuiccChallengeMismatch {
+		t.Errorf("AuthenticateClient() error = %v, want %v", err, sgp22.AuthenticateErrorCodeEuiccChallengeMismatch)
+	}
+}
+
+func TestCardCancelSession(t *testing.T) {
+	ci, card := newTestCard(t)
+	client := newTestClient(t, card)
+	smdp, address := newTestSMDP(t, ci, client, testOrder(t))
+
+	session := client.NewDownloadSession(&lpa.ActivationCode{SMDP: address, MatchingID: "MATCHING-ID", IMEI: testIMEI})
+	if _, err := session.Start(t.Context()); err != nil {
+		t.Fatalf("DownloadSession.Start() error = %v", err)
+	}
+	if err := session.Cancel(t.Context(), sgp22.CancelSessionReasonPostponed); err != nil {
+		t.Fatalf("DownloadSession.Cancel() error = %v", err)
+	}
+	canceled := smdp.CanceledSessions()
+	if len(canceled) != 1 || !bytes.Equal(canceled[0].TransactionID, session.TransactionID()) || canceled[0].Reason != sgp22.CancelSessionReasonPostponed {
+		t.Errorf("CanceledSessions() = %+v, want the postponed session", canceled)
+	}
+	if card.session != nil {
+		t.Errorf("session after CancelSession = %+v, want nil", card.session)
+	}
+}
+
+func TestCardDiscovery(t *testing.T) {
+	ci, card := newTestCard(t)
+	client := newTestClient(t, card)
+	smds, err := rsptest.NewSMDS(ci)
+	if err != nil {
+		t.Fatalf("NewSMDS() error = %v", err)
+	}
+	smds.RegisterEvent(DefaultEID, sgp22.EventEntry{EventID: "EVENT-1", Address: "smdp.example.com"})
+	server := httptest.NewTLSServer(smds)
+	defer server.Close()
+	client.HTTP = &http.Client{Client: server.Client(), AdminProtocolVersion: "2.5.0"}
+	address, _ := url.Parse(server.URL)
+
+	imei, _ := sgp22.NewIMEI(testIMEI)
+	entries, err := client.Discovery(t.Context(), address, imei)
+	if err != nil {
+		t.Fatalf("Discovery() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].EventID != "EVENT-1" || entries[0].Address != "smdp.example.com" {
+		t.Errorf("Discovery() = %+v, want EVENT-1", entries)
+	}
+}
+
+func TestCardDownloadProfile(t *testing.T) {
+	for _, protectionKeys := range []bool{false, true} {
+		ci, card := newTestCard(t)
+		client := newTestClient(t, card)
+		order := testOrder(t)
+		order.Profile.ProtectionKeys = protectionKeys
+		smdp, address := newTestSMDP(t, ci, client, order)
+
+		response, err := client.DownloadProfile(t.Context(), &lpa.ActivationCode{SMDP: address, MatchingID: order.MatchingID, IMEI: testIMEI}, nil)
+		if err != nil {
+			t.Fatalf("DownloadProfile() (protection keys %t) error = %v", protectionKeys, err)
+		}
+		profiles := card.Profiles()
+		if len(profiles) != 1 || !bytes.Equal(profiles[0].ICCID, order.Profile.ICCID) ||
+			profiles[0].ProfileName != "Downloaded" || profiles[0].ProfileState != sgp22.ProfileDisabled {
+			t.Fatalf("Profiles() = %+v, want the downloaded profile disabled", profiles)
+		}
+		if !bytes.Equal(response.ISDPAID(), profiles[0].ISDPAID) {
+			t.Errorf("DownloadProfile() ISD-P AID = %s, want %s", response.ISDPAID(), profiles[0].ISDPAID)
+		}
+		if card.session != nil || card.installation != nil {
+			t.Errorf("session after installation = %+v, want nil", card.session)
+		}
+
+		// The ProfileInstallationResult is kept as the install notification, and the SM-DP+ can verify it.
+		pending, err := client.RetrieveNotificationList(t.Context(), response.Notification.SequenceNumber)
+		if err != nil || len(pending) != 1 {
+			t.Fatalf("RetrieveNotificationList() = %v, %v, want the install notification", pending, err)
+		}
+		if err := client.HandleNotification(t.Context(), pending[0]); err != nil {
+			t.Fatalf("HandleNotification() error = %v", err)
+		}
+		notifications := smdp.Notifications()
+		if len(notifications) != 1 || notifications[0].ProfileInstallationResult == nil {
+			t.Fatalf("SM-DP+ notifications = %+v, want the ProfileInstallationResult", notifications)
+		}
+		result := notifications[0].ProfileInstallationResult
+		if !result.Succeeded() || !bytes.Equal(result.ISDPAID, profiles[0].ISDPAID) || !result.SMDPOID.Equal(rsptest.OID) {
+			t.Errorf("ProfileInstallationResult = %+v, want success", result)
+		}
+		if err := result.Verify(card.Certificate()); err != nil {
+			t.Errorf("ProfileInstallationResult.Verify() error = %v", err)
+		}
+	}
+}
+
+func TestCardDownloadProfileICCIDExists(t *testing.T) {
+	ci, card := newTestCard(t)
+	client := newTestClient(t, card)
+	order := testOrder(t)
+	_, address := newTestSMDP(t, ci, client, order)
+	if err := card.AddProfile(testProfile(t, order.Profile.ICCID.String(), sgp22.ProfileDisabled)); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+
+	_, err := client.DownloadProfile(t.Context(), &lpa.ActivationCode{SMDP: address, MatchingID: order.MatchingID, IMEI: testIMEI}, nil)
+	var bppError *sgp22.LoadBoundProfilePackageError
+	if !errors.As(err, &bppError) || bppError.BPPCommandID != sgp22.BPPCommandIDStoreMetadata ||
+		bppError.ErrorReason != sgp22.BPPErrorReasonInstallFailedDueToICCIDAlreadyExistsOnEUICC {
+		t.Errorf("DownloadProfile() error = %v, want %v", err, sgp22.BPPErrorReasonInstallFailedDueToICCIDAlreadyExistsOnEUICC)
+	}
+	if n := len(card.Profiles()); n != 1 {
+		t.Errorf("Profiles() = %d profiles, want 1", n)
+	}
+}
+
+// boundProfilePackage authenticates the SM-DP+ and returns the bound profile package of the order MATCHING-ID.
+func boundProfilePackage(t *testing.T, client *lpa.Client, address *url.URL) *bertlv.TLV {
+	t.Helper()
+	initiateAuthenticationResponse, err := client.InitiateAuthentication(t.Context(), address)
+	if err != nil {
+		t.Fatalf("InitiateAuthentication() error = %v", err)
+	}
+	imei, _ := sgp22.NewIMEI(testIMEI)
+	request := initiateAuthenticationResponse.CardRequest()
+	request.IMEI = imei
+	request.MatchingID = []byte("MATCHING-ID")
+	response, err := client.AuthenticateClient(t.Context(), address, request)
+	if err != nil {
+		t.Fatalf("AuthenticateClient() error = %v", err)
+	}
+	bpp, err := client.PrepareDownload(t.Context(), address, &sgp22.PrepareDownloadRequest{
+		TransactionID:   response.TransactionID,
+		ProfileMetadata: response.ProfileMetadata,
+		Signed2:         response.Signed2,
+		Signature2:      response.Signature2,
+		Certificate:     response.Certificate,
+	})
+	if err != nil {
+		t.Fatalf("PrepareDownload() error = %v", err)
+	}
+	return bpp.BoundProfilePackage
+}
+
+func TestCardLoadBoundProfilePackageTampered(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(bpp *bertlv.TLV)
+		command sgp22.BPPCommandID
+		reason  sgp22.BPPErrorReason
+	}{
+		{
+			name: "transaction ID",
+			tamper: func(bpp *bertlv.TLV) {
+				bpp.First(bertlv.ContextSpecific.Constructed(35)).First(bertlv.ContextSpecific.Primitive(0)).Value = make([]byte, 16)
+			},
+			command: sgp22.BPPCommandIDInitialiseSecureChannel,
+			reason:  sgp22.BPPErrorReasonInvalidTransactionID,
+		},
+		{
+			name: "key type",
+			tamper: func(bpp *bertlv.TLV) {
+				crt := bpp.First(bertlv.ContextSpecific.Constructed(35)).First(bertlv.ContextSpecific.Constructed(6))
+				crt.First(bertlv.ContextSpecific.Primitive(0)).Value = []byte{0x80}
+			},
+			command: sgp22.BPPCommandIDInitialiseSecureChannel,
+			reason:  sgp22.BPPErrorReasonUnsupportedCRTValues,
+		},
+		{
+			name: "smdpSign",
+			tamper: func(bpp *bertlv.TLV) {
+				bpp.First(bertlv.ContextSpecific.Constructed(35)).First(bertlv.Application.Primitive(55)).Value[0] ^= 0xFF
+			},
+			command: sgp22.BPPCommandIDInitialiseSecureChannel,
+			reason:  sgp22.BPPErrorReasonInvalidSignature,
+		},
+		{
+			name: "segment tag",
+			tamper: func(bpp *bertlv.TLV) {
+				bpp.First(bertlv.ContextSpecific.Constructed(0)).Children[0].Tag = bertlv.ContextSpecific.Primitive(8)
+			},
+			command: sgp22.BPPCommandIDConfigureISDP,
+			reason:  sgp22.BPPErrorReasonSCP03tStructureError,
+		},
+		{
+			name: "metadata MAC",
+			tamper: func(bpp *bertlv.TLV) {
+				bpp.First(bertlv.ContextSpecific.Constructed(1)).Children[0].Value[0] ^= 0xFF
+			},
+			command: sgp22.BPPCommandIDStoreMetadata,
+			reason:  sgp22.BPPErrorReasonSCP03tSecurityError,
+		},
+		{
+			name: "profile elements",
+			tamper: func(bpp *bertlv.TLV) {
+				bpp.First(bertlv.ContextSpecific.Constructed(3)).Children[0].Value[0] ^= 0xFF
+			},
+			command: sgp22.BPPCommandIDLoadProfileElements,
+			reason:  sgp22.BPPErrorReasonSCP03tSecurityError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ci, card := newTestCard(t)
+			client := newTestClient(t, card)
+			_, address := newTestSMDP(t, ci, client, testOrder(t))
+
+			bpp := boundProfilePackage(t, client, address)
+			tt.tamper(bpp)
+			segments, err := sgp22.BoundProfilePackageSegments(bpp)
+			if err != nil {
+				t.Fatalf("BoundProfilePackageSegments() error = %v", err)
+			}
+			var data []byte
+			for _, segment := range segments {
+				if data, err = sgp22.InvokeRawAPDU(t.Context(), client.APDU, segment.Data); err != nil {
+					t.Fatalf("LoadBoundProfilePackage segment error = %v", err)
+				}
+				if len(data) > 0 {
+					break
+				}
+			}
+			var tlv bertlv.TLV
+			if err := tlv.UnmarshalBinary(data); err != nil {
+				t.Fatalf("decode ProfileInstallationResult: %v", err)
+			}
+			var result sgp22.ProfileInstallationResult
+			if err := result.UnmarshalBERTLV(&tlv); err != nil {
+				t.Fatalf("ProfileInstallationResult.UnmarshalBERTLV() error = %v", err)
+			}
+			if result.Error == nil || result.Error.BPPCommandID != tt.command || result.Error.ErrorReason != tt.reason {
+				t.Errorf("ProfileInstallationResult error = %v, want %s,%s", result.Error, tt.command, tt.reason)
+			}
+			if err := result.Verify(card.Certificate()); err != nil {
+				t.Errorf("ProfileInstallationResult.Verify() error = %v", err)
+			}
+			if profiles := card.Profiles(); len(profiles) != 0 {
+				t.Errorf("Profiles() = %+v, want none", profiles)
+			}
+			if card.session != nil || card.installation != nil {
+				t.Errorf("session after failed installation = %+v, want nil", card.session)
+			}
+		})
+	}
+}
+
+func TestCardReplayDownload(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []http.Option
+	}{
+		{name: "plain"},
+		{name: "redacted EID", options: []http.Option{http.WithRedactedValues(DefaultEID)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ci, card := newTestCard(t)
+			smdp, err := rsptest.NewSMDP(ci)
+			if err != nil {
+				t.Fatalf("NewSMDP() error = %v", err)
+			}
+			order := testOrder(t)
+			smdp.AddOrder(order)
+			server := httptest.NewTLSServer(smdp)
+			defer server.Close()
+			address, _ := url.Parse(server.URL)
+			ac := &lpa.ActivationCode{SMDP: address, MatchingID: order.MatchingID, IMEI: testIMEI}
+
+			// download installs the order with the channel and transport, and returns the ISD-P AID.
+			download := func(channel driver.SmartCardChannel, transport nethttp.RoundTripper) (sgp22.ISDPAID, error) {
+				client, err := lpa.New(t.Context(), &lpa.Options{
+					Channel:       channel,
+					Logger:        slog.New(slog.DiscardHandler),
+					WrapTransport: func(nethttp.RoundTripper) nethttp.RoundTripper { return transport },
+				})
+				if err != nil {
+					return nil, err
+				}
+				defer client.Close()
+				response, err := client.DownloadProfile(t.Context(), ac, nil)
+				if err != nil {
+					return nil, err
+				}
+				return response.ISDPAID(), nil
+			}
+			var apdus, exchanges bytes.Buffer
+			aid, err := download(trace.NewRecorder(card, &apdus), http.NewRecorder(server.Client().Transport, &exchanges, tt.options...))
+			if err != nil {
+				t.Fatalf("DownloadProfile() error = %v", err)
+			}
+
+			// The download is replayed without the card and the SM-DP+.
+			server.Close()
+			entries, err := trace.Read(&apdus)
+			if err != nil {
+				t.Fatalf("trace.Read() error = %v", err)
+			}
+			recorded, err := http.ReadExchanges(&exchanges)
+			if err != nil {
+				t.Fatalf("ReadExchanges() error = %v", err)
+			}
+			eid, _ := hex.DecodeString(DefaultEID)
+			for _, exchange := range recorded {
+				if found := leaks(exchange.Request, eid); len(tt.options) > 0 && len(found) > 0 {
+					t.Errorf("%s #%d leaks %q", exchange.Endpoint, exchange.Sequence, found)
+				}
+			}
+			channel, transport := trace.NewReplayer(entries), http.NewReplayer(recorded, tt.options...)
+			replayed, err := download(channel, transport)
+			if err != nil {
+				t.Fatalf("replayed DownloadProfile() error = %v", err)
+			}
+			if !bytes.Equal(replayed, aid) {
+				t.Errorf("replayed DownloadProfile() ISD-P AID = %s, want %s", replayed, aid)
+			}
+			if err := errors.Join(channel.Done(), transport.Done()); err != nil {
+				t.Errorf("replay is incomplete: %v", err)
+			}
+		})
+	}
+}
+
+// leaks returns the strings of a recorded body, or the data they encode in base64, containing one of the secrets.
+func leaks(body []byte, secrets ...[]byte) []string {
+	var value any
+	if len(body) == 0 || json.Unmarshal(body, &value) != nil {
+		return nil
+	}
+	var found []string
+	var walk func(value any)
+	walk = func(value any) {
+		switch value := value.(type) {
+		case map[string]any:
+			for _, field := range value {
+				walk(field)
+			}
+		case []any:
+			for _, element := range value {
+				walk(element)
+			}
+		case string:
+			data, _ := base64.StdEncoding.DecodeString(value)
+			for _, secret := range secrets {
+				text := []byte(hex.EncodeToString(secret))
+				if bytes.Contains(data, secret) || bytes.Contains(bytes.ToLower(data), text) || strings.Contains(strings.ToLower(value), string(text)) {
+					found = append(found, value)
+				}
+			}
+		}
+	}
+	walk(value)
+	return found
+}
+
+func TestCardRedactDownload(t *testing.T) {
+	ci, card := newTestCard(t)
+	smdp, err := rsptest.NewSMDP(ci)
+	if err != nil {
+		t.Fatalf("NewSMDP() error = %v", err)
+	}
+	order := testOrder(t)
+	smdp.AddOrder(order)
+	server := httptest.NewTLSServer(smdp)
+	defer server.Close()
+	address, _ := url.Parse(server.URL)
+	var exchanges, redacted bytes.Buffer
+	client, err := lpa.New(t.Context(), &lpa.Options{
+		Channel: card,
+		Logger:  slog.New(slog.DiscardHandler),
+		WrapTransport: func(nethttp.RoundTripper) nethttp.RoundTripper {
+			return http.NewRecorder(
+				http.NewRecorder(server.Client().Transport, &exchanges),
+				&redacted, http.WithRedaction("transactionId"), http.WithRedactedValues(DefaultEID),
+			)
+		},
+	})
+	if err != nil {
+		t.Fatalf("lpa.New() error = %v", err)
+	}
+	defer client.Close()
+	if _, err := client.DownloadProfile(t.Context(), &lpa.ActivationCode{SMDP: address, MatchingID: order.MatchingID, IMEI: testIMEI}, nil); err != nil {
+		t.Fatalf("DownloadProfile() error = %v", err)
+	}
+
+	recorded, err := http.ReadExchanges(&exchanges)
+	if err != nil {
+		t.Fatalf("ReadExchanges() error = %v", err)
+	}
+	var initiated sgp22.ES9InitiateAuthenticationResponse
+	if err := json.Unmarshal(recorded[0].Response, &initiated); err != nil {
+		t.Fatalf("initiateAuthentication response: %v", err)
+	}
+	transactionID := []byte(initiated.TransactionID)
+	eid, _ := hex.DecodeString(DefaultEID)
+	if len(leaks(recorded[1].Request, transactionID)) == 0 || len(leaks(recorded[1].Request, eid)) == 0 {
+		t.Fatal("authenticateClient request without the transaction ID and EID in its base64 data")
+	}
+	exchangesRedacted, err := http.ReadExchanges(&redacted)
+	if err != nil {
+		t.Fatalf("ReadExchanges() error = %v", err)
+	}
+	if len(exchangesRedacted) != len(recorded) {
+		t.Fatalf("redacted exchanges = %d, want %d", len(exchangesRedacted), len(recorded))
+	}
+	for _, exchange := range exchangesRedacted {
+		for _, body := range [][]byte{exchange.Request, exchange.Response} {
+			if found := leaks(body, transactionID, eid); len(found) > 0 {
+				t.Errorf("%s #%d leaks %q", exchange.Endpoint, exchange.Sequence, found)
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Package virtualtest provides virtual eUICCs for the tests of the packages built on them.
+package virtualtest
+
+import (
+	"testing"
+
+	"github.com/damonto/euicc-go/driver/virtual"
+	"github.com/damonto/euicc-go/rsptest"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// ICCID is the ICCID of the profile on the cards returned by NewCard.
+const ICCID = "8944476500001224199"
+
+// NewCard returns a virtual card issued by a new test CI, holding one enabled
+// profile with [ICCID]. It stops the test if the card cannot be created.
+func NewCard(tb testing.TB, options ...virtual.Option) *virtual.Card {
+	tb.Helper()
+	ci, err := rsptest.NewCI()
+	if err != nil {
+		tb.Fatalf("NewCI() error = %v", err)
+	}
+	card, err := virtual.New(ci, options...)
+	if err != nil {
+		tb.Fatalf("virtual.New() error = %v", err)
+	}
+	iccid, _ := sgp22.NewICCID(ICCID)
+	if err := card.AddProfile(sgp22.ProfileInfo{ICCID: iccid, ProfileState: sgp22.ProfileEnabled}); err != nil {
+		tb.Fatalf("AddProfile() error = %v", err)
+	}
+	return card
+}
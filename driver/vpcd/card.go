@@ -0,0 +1,243 @@
+package vpcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+
+	"github.com/damonto/euicc-go/driver"
+	"github.com/damonto/euicc-go/driver/iso7816"
+	"github.com/damonto/euicc-go/internal/netctx"
+)
+
+const maxLogicalChannel = 19
+
+const (
+	insManageChannel byte = 0x70
+	insSelect        byte = 0xA4
+)
+
+// Status words returned for the commands emulated by a card.
+var (
+	swOK                  = []byte{0x90, 0x00}
+	swWrongLength         = []byte{0x67, 0x00}
+	swChannelNotSupported = []byte{0x68, 0x81}
+	swFileNotFound        = []byte{0x6A, 0x82}
+	swNoChannelAvailable  = []byte{0x6A, 0x81}
+	swIncorrectP1P2       = []byte{0x6A, 0x86}
+	swNoPreciseDiagnosis  = []byte{0x6F, 0x00}
+)
+
+// Option configures a Card.
+type Option func(*Card)
+
+// WithATR sets the ATR of the card. The default is DefaultATR.
+func WithATR(atr []byte) Option {
+	return func(card *Card) {
+		card.atr = slices.Clone(atr)
+	}
+}
+
+// Card serves a [driver.SmartCardChannel] to a vpcd reader as a virtual card.
+//
+// Modems open logical channels only to select an application, so the card
+// emulates MANAGE CHANNEL: a logical channel opened by the reader is opened on
+// the channel by the first SELECT by DF name on it, and the class byte of the
+// commands sent on it is rewritten for the opened channel. That SELECT answers
+// 9000 without the FCI of the application, or 6A82 if the channel cannot open
+// it. The other commands on the basic channel are passed unchanged, and a
+// command failing to reach the channel answers 6F00.
+//
+// The card is not safe for concurrent use; serve one reader at a time.
+type Card struct {
+	channel   driver.SmartCardChannel
+	atr       []byte
+	connected bool
+	// channels maps the logical channels opened by the reader to the ones
+	// opened on the channel, which are 0 until an application is selected.
+	channels map[byte]byte
+}
+
+// NewCard returns a virtual card passing APDUs to channel. The channel is
+// connected when the reader first powers the card on, and disconnected by Close.
+func NewCard(channel driver.SmartCardChannel, options ...Option) *Card {
+	card := &Card{
+		channel:  channel,
+		atr:      DefaultATR,
+		channels: make(map[byte]byte),
+	}
+	for _, option := range options {
+		option(card)
+	}
+	return card
+}
+
+// Serve answers the reader on conn until it closes the connection or ctx is
+// done, and closes conn. Powering the card off or resetting it closes the
+// logical channels opened by the reader. Serve returns nil when the reader
+// closes the connection, and the error that stopped it otherwise.
+//
+// vpcd listens on [DefaultPort] for a virtual card to connect, or connects to
+// the virtual card in its reversed mode, so conn can be dialed or accepted.
+func (c *Card) Serve(ctx context.Context, conn net.Conn) error {
+	defer conn.Close()
+	for {
+		var message []byte
+		err := netctx.Do(ctx, conn, func() (err error) {
+			message, err = readMessage(conn)
+			return err
+		})
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read vpcd message: %w", err)
+		}
+		response, err := c.handle(ctx, message)
+		if err != nil {
+			return err
+		}
+		if response == nil {
+			continue
+		}
+		if err := netctx.Do(ctx, conn, func() error { return writeMessage(conn, response) }); err != nil {
+			return fmt.Errorf("write vpcd message: %w", err)
+		}
+	}
+}
+
+// Close closes the logical channels opened by the reader and disconnects the channel.
+func (c *Card) Close() error {
+	return errors.Join(c.closeChannels(context.Background()), c.channel.Disconnect())
+}
+
+// handle returns the answer to a message of the reader, or nil if it has none.
+func (c *Card) handle(ctx context.Context, message []byte) ([]byte, error) {
+	if len(message) != 1 {
+		return c.transmit(ctx, message), nil
+	}
+	switch message[0] {
+	case powerOn:
+		if c.connected {
+			return nil, nil
+		}
+		if err := c.channel.Connect(ctx); err != nil {
+			return nil, fmt.Errorf("power on: %w", err)
+		}
+		c.connected = true
+	case powerOff, reset:
+		if err := c.closeChannels(ctx); err != nil {
+			return nil, fmt.Errorf("power off: %w", err)
+		}
+	case getATR:
+		return c.atr, nil
+	}
+	return nil, nil
+}
+
+func (c *Card) transmit(ctx context.Context, command []byte) []byte {
+	if len(command) < 4 {
+		return swWrongLength
+	}
+	channel := iso7816.LogicalChannel(command)
+	switch {
+	case command[1] == insManageChannel:
+		return c.manageChannel(ctx, command)
+	case channel == 0:
+		return c.transmitRaw(ctx, command)
+	case command[1] == insSelect && command[2] == 0x04:
+		return c.selectApplication(ctx, channel, command)
+	}
+	opened := c.channels[channel]
+	if opened == 0 {
+		return swChannelNotSupported
+	}
+	return c.transmitRaw(ctx, withChannel(command, opened))
+}
+
+func (c *Card) transmitRaw(ctx context.Context, command []byte) []byte {
+	response, err := c.channel.Transmit(ctx, command)
+	if err != nil || len(response) < 2 {
+		return swNoPreciseDiagnosis
+	}
+	return response
+}
+
+func (c *Card) manageChannel(ctx context.Context, command []byte) []byte {
+	channel := command[3]
+	switch command[2] {
+	case 0x00:
+		if channel == 0 {
+			if channel = c.freeChannel(); channel == 0 {
+				return swNoChannelAvailable
+			}
+			c.channels[channel] = 0
+			return append([]byte{channel}, swOK...)
+		}
+		if _, ok := c.channels[channel]; ok || channel > maxLogicalChannel {
+			return swIncorrectP1P2
+		}
+		c.channels[channel] = 0
+		return swOK
+	case 0x80:
+		if channel == 0 {
+			channel = iso7816.LogicalChannel(command)
+		}
+		opened, ok := c.channels[channel]
+		if !ok {
+			return swIncorrectP1P2
+		}
+		delete(c.channels, channel)
+		if opened != 0 && c.channel.CloseLogicalChannel(ctx, opened) != nil {
+			return swNoPreciseDiagnosis
+		}
+		return swOK
+	}
+	return swIncorrectP1P2
+}
+
+func (c *Card) freeChannel() byte {
+	for channel := byte(1); channel <= maxLogicalChannel; channel++ {
+		if _, ok := c.channels[channel]; !ok {
+			return channel
+		}
+	}
+	return 0
+}
+
+// selectApplication opens the application selected on a logical channel of the reader.
+func (c *Card) selectApplication(ctx context.Context, channel byte, command []byte) []byte {
+	opened, ok := c.channels[channel]
+	if !ok {
+		return swChannelNotSupported
+	}
+	if len(command) < 5 || len(command) < 5+int(command[4]) {
+		return swWrongLength
+	}
+	if opened != 0 {
+		c.channels[channel] = 0
+		if c.channel.CloseLogicalChannel(ctx, opened) != nil {
+			return swNoPreciseDiagnosis
+		}
+	}
+	opened, err := c.channel.OpenLogicalChannel(ctx, command[5:5+int(command[4])])
+	if err != nil {
+		return swFileNotFound
+	}
+	c.channels[channel] = opened
+	return swOK
+}
+
+func (c *Card) closeChannels(ctx context.Context) error {
+	var errs []error
+	for _, opened := range c.channels {
+		if opened != 0 {
+			errs = append(errs, c.channel.CloseLogicalChannel(ctx, opened))
+		}
+	}
+	clear(c.channels)
+	return errors.Join(errs...)
+}
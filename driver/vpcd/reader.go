@@ -0,0 +1,202 @@
+package vpcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+
+	"github.com/damonto/euicc-go/driver"
+	"github.com/damonto/euicc-go/driver/iso7816"
+	"github.com/damonto/euicc-go/internal/netctx"
+)
+
+var _ driver.SmartCardChannel = (*Reader)(nil)
+
+// Reader is a smart card channel to a virtual card speaking the vpcd
+// protocol. Create one with New, Dial or Accept. It is not safe for
+// concurrent use.
+type Reader struct {
+	connect func(ctx context.Context) (net.Conn, error)
+	options []iso7816.Option
+	channel *iso7816.Channel
+	atr     []byte
+	closed  bool
+}
+
+// New creates a channel to the virtual card on the connection returned by
+// connect, which Connect calls once. Options configure its ISO 7816 operations.
+func New(connect func(ctx context.Context) (net.Conn, error), options ...iso7816.Option) *Reader {
+	return &Reader{
+		connect: connect,
+		options: slices.Clone(options),
+	}
+}
+
+// Dial creates a channel to a virtual card listening on address, as vpcd
+// connects to it in its reversed mode. Options configure its ISO 7816 operations.
+func Dial(network, address string, options ...iso7816.Option) *Reader {
+	return New(func(ctx context.Context) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, address)
+	}, options...)
+}
+
+// Accept creates a channel to the first virtual card connecting to listener,
+// as vpcd waits for it on [DefaultPort]. The reader does not close listener.
+// If Connect gives up first, the next card connecting is turned away.
+// Options configure its ISO 7816 operations.
+func Accept(listener net.Listener, options ...iso7816.Option) *Reader {
+	return New(func(ctx context.Context) (net.Conn, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		type result struct {
+			conn net.Conn
+			err  error
+		}
+		accepted := make(chan result, 1)
+		go func() {
+			conn, err := listener.Accept()
+			accepted <- result{conn, err}
+		}()
+		select {
+		case result := <-accepted:
+			return result.conn, result.err
+		case <-ctx.Done():
+			go func() {
+				if result := <-accepted; result.conn != nil {
+					result.conn.Close()
+				}
+			}()
+			return nil, ctx.Err()
+		}
+	}, options...)
+}
+
+// ATR returns the ATR of the card, or nil before Connect.
+func (r *Reader) ATR() []byte {
+	return slices.Clone(r.atr)
+}
+
+// Connect connects to the virtual card and powers it on.
+func (r *Reader) Connect(ctx context.Context) error {
+	if r.closed {
+		return errors.New("vpcd reader is closed")
+	}
+	if r.channel != nil {
+		return nil
+	}
+	conn, err := r.connect(ctx)
+	if err != nil {
+		return fmt.Errorf("connect to virtual card: %w", err)
+	}
+	card := &virtualCard{conn: conn}
+	atr, err := card.powerOn(ctx)
+	if err != nil {
+		return errors.Join(fmt.Errorf("power on virtual card: %w", err), conn.Close())
+	}
+	channel := iso7816.NewChannel(card, r.options...)
+	if err := channel.Connect(ctx); err != nil {
+		return errors.Join(err, channel.Disconnect())
+	}
+	r.channel = channel
+	r.atr = atr
+	return nil
+}
+
+// Disconnect powers the virtual card off and closes the connection.
+func (r *Reader) Disconnect() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.channel == nil {
+		return nil
+	}
+	return r.channel.Disconnect()
+}
+
+func (r *Reader) Transmit(ctx context.Context, command []byte) ([]byte, error) {
+	channel, err := r.smartCardChannel()
+	if err != nil {
+		return nil, err
+	}
+	return channel.Transmit(ctx, command)
+}
+
+func (r *Reader) OpenLogicalChannel(ctx context.Context, aid []byte) (byte, error) {
+	channel, err := r.smartCardChannel()
+	if err != nil {
+		return 0, err
+	}
+	return channel.OpenLogicalChannel(ctx, aid)
+}
+
+func (r *Reader) CloseLogicalChannel(ctx context.Context, logicalChannel byte) error {
+	channel, err := r.smartCardChannel()
+	if err != nil {
+		return err
+	}
+	return channel.CloseLogicalChannel(ctx, logicalChannel)
+}
+
+func (r *Reader) smartCardChannel() (*iso7816.Channel, error) {
+	if r.closed {
+		return nil, errors.New("vpcd reader is closed")
+	}
+	if r.channel == nil {
+		return nil, errors.New("vpcd reader is not connected")
+	}
+	return r.channel, nil
+}
+
+// virtualCard exchanges messages with a virtual card. Closing it powers the card off.
+type virtualCard struct {
+	conn net.Conn
+	err  error
+}
+
+func (v *virtualCard) powerOn(ctx context.Context) ([]byte, error) {
+	if _, err := v.send(ctx, []byte{powerOn}, false); err != nil {
+		return nil, err
+	}
+	return v.send(ctx, []byte{getATR}, true)
+}
+
+func (v *virtualCard) Transmit(ctx context.Context, command []byte) ([]byte, error) {
+	return v.send(ctx, command, true)
+}
+
+func (v *virtualCard) Close() error {
+	var err error
+	if v.err == nil {
+		_, err = v.send(context.Background(), []byte{powerOff}, false)
+	}
+	return errors.Join(err, v.conn.Close())
+}
+
+// send writes a message to the card, and reads the answer if the message has one.
+func (v *virtualCard) send(ctx context.Context, message []byte, answered bool) ([]byte, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var response []byte
+	err := netctx.Do(ctx, v.conn, func() (err error) {
+		if err = writeMessage(v.conn, message); err != nil || !answered {
+			return err
+		}
+		response, err = readMessage(v.conn)
+		return err
+	})
+	if err != nil {
+		// The answer to an interrupted message would be taken for the answer to the next one.
+		v.err = fmt.Errorf("virtual card connection is broken: %w", err)
+		return nil, err
+	}
+	return response, nil
+}
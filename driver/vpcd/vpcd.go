@@ -0,0 +1,71 @@
+// Package vpcd bridges smart card channels over the protocol of vpcd, the
+// virtual smart card reader of vsmartcard.
+//
+// vpcd is a PC/SC reader driver that exchanges with a virtual card over TCP.
+// Every message is prefixed with its length as a big-endian 16-bit integer.
+// The reader sends a 1-byte control message to power the card off or on, to
+// reset it or to get its ATR, and any longer message is a command APDU the
+// card answers with its response APDU. Only the ATR request and the APDUs
+// are answered.
+//
+// A [Card] serves a [driver.SmartCardChannel] as a virtual card, so PC/SC
+// tools can reach an eUICC behind a modem. A [Reader] is a [driver.SmartCardChannel]
+// driving a virtual card, such as a phone running a smart card emulator.
+package vpcd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// DefaultPort is the TCP port vpcd listens on for virtual cards.
+const DefaultPort = 35963
+
+// Control messages sent by the reader.
+const (
+	powerOff byte = 0x00
+	powerOn  byte = 0x01
+	reset    byte = 0x02
+	getATR   byte = 0x04
+)
+
+// DefaultATR is the ATR of a [Card] created without WithATR. It announces
+// a card supporting the T=0 and T=1 protocols.
+var DefaultATR = []byte{0x3B, 0x80, 0x80, 0x01, 0x01}
+
+func readMessage(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	message := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func writeMessage(w io.Writer, message []byte) error {
+	if len(message) > 0xFFFF {
+		return fmt.Errorf("message length %d exceeds 65535", len(message))
+	}
+	_, err := w.Write(binary.BigEndian.AppendUint16(nil, uint16(len(message))))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(message)
+	return err
+}
+
+// withChannel returns command with its class byte encoding channel.
+func withChannel(command []byte, channel byte) []byte {
+	command = append([]byte(nil), command...)
+	cla := command[0]
+	if channel < 4 {
+		command[0] = cla&0x9C | channel
+	} else {
+		command[0] = cla&0xB0 | 0x40 | (channel - 4)
+	}
+	return command
+}
@@ -0,0 +1,177 @@
+package vpcd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/damonto/euicc-go/driver/virtual"
+	"github.com/damonto/euicc-go/driver/virtual/virtualtest"
+	"github.com/damonto/euicc-go/lpa"
+)
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return listener
+}
+
+func TestReaderCard(t *testing.T) {
+	listener := listen(t)
+	card := NewCard(virtualtest.NewCard(t))
+	served := make(chan error, 1)
+	go func() {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			served <- err
+			return
+		}
+		served <- card.Serve(t.Context(), conn)
+	}()
+
+	reader := Accept(listener)
+	client, err := lpa.New(t.Context(), &lpa.Options{
+		Channel: reader,
+		Logger:  slog.New(slog.DiscardHandler),
+	})
+	if err != nil {
+		t.Fatalf("lpa.New() error = %v", err)
+	}
+	if !bytes.Equal(reader.ATR(), DefaultATR) {
+		t.Errorf("ATR() = %X, want %X", reader.ATR(), DefaultATR)
+	}
+	eid, err := client.EID(t.Context())
+	if err != nil {
+		t.Fatalf("EID() error = %v", err)
+	}
+	if eid.String() != virtual.DefaultEID {
+		t.Errorf("EID() = %s, want %s", eid, virtual.DefaultEID)
+	}
+	profiles, err := client.ListProfile(t.Context(), nil, nil)
+	if err != nil {
+		t.Fatalf("ListProfile() error = %v", err)
+	}
+	if len(profiles) != 1 || profiles[0].ICCID.String() != virtualtest.ICCID {
+		t.Errorf("ListProfile() = %v, want the profile of the card", profiles)
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve() error = %v", err)
+	}
+	if err := card.Close(); err != nil {
+		t.Errorf("Card.Close() error = %v", err)
+	}
+}
+
+func TestCardManageChannel(t *testing.T) {
+	listener := listen(t)
+	card := NewCard(virtualtest.NewCard(t), WithATR([]byte{0x3B, 0x00}))
+	defer card.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			card.Serve(t.Context(), conn)
+		}
+	}()
+
+	reader := Dial("tcp", listener.Addr().String())
+	if err := reader.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer reader.Disconnect()
+	if !bytes.Equal(reader.ATR(), []byte{0x3B, 0x00}) {
+		t.Errorf("ATR() = %X, want 3B00", reader.ATR())
+	}
+	selectISDR := append([]byte{0x00, 0xA4, 0x04, 0x00, byte(len(lpa.GSMAISDRApplicationAID))}, lpa.GSMAISDRApplicationAID...)
+	for _, tt := range []struct {
+		name    string
+		command []byte
+		want    []byte
+	}{
+		{"open", []byte{0x00, 0x70, 0x00, 0x00, 0x01}, []byte{0x01, 0x90, 0x00}},
+		{"open 5", []byte{0x00, 0x70, 0x00, 0x05, 0x00}, swOK},
+		{"open 5 again", []byte{0x00, 0x70, 0x00, 0x05, 0x00}, swIncorrectP1P2},
+		{"select on 5", withChannel(selectISDR, 5), swOK},
+		{"get EID on 1", []byte{0x81, 0xE2, 0x91, 0x00, 0x06, 0xBF, 0x3E, 0x03, 0x5C, 0x01, 0x5A}, swChannelNotSupported},
+		{"select unknown on 1", withChannel([]byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0xA0, 0x01}, 1), swFileNotFound},
+		{"close 5", []byte{0x00, 0x70, 0x80, 0x05, 0x00}, swOK},
+		{"close 5 again", []byte{0x00, 0x70, 0x80, 0x05, 0x00}, swIncorrectP1P2},
+		{"select on closed 5", withChannel(selectISDR, 5), swChannelNotSupported},
+	} {
+		response, err := reader.Transmit(t.Context(), tt.command)
+		if err != nil {
+			t.Fatalf("%s: Transmit() error = %v", tt.name, err)
+		}
+		if !bytes.Equal(response, tt.want) {
+			t.Errorf("%s: Transmit() = %X, want %X", tt.name, response, tt.want)
+		}
+		if tt.name != "select on 5" {
+			continue
+		}
+		// The channel of the card opened for channel 5 of the reader is not 5.
+		response, err = reader.Transmit(t.Context(), []byte{0xC1, 0xE2, 0x91, 0x00, 0x06, 0xBF, 0x3E, 0x03, 0x5C, 0x01, 0x5A})
+		if err == nil && len(response) == 2 && response[0] == 0x61 {
+			response, err = reader.Transmit(t.Context(), []byte{0xC1, 0xC0, 0x00, 0x00, response[1]})
+		}
+		if err != nil || !bytes.HasPrefix(response, []byte{0xBF, 0x3E}) || !bytes.HasSuffix(response, swOK) {
+			t.Errorf("get EID on 5: Transmit() = %X, %v, want the EID", response, err)
+		}
+	}
+}
+
+func TestReaderContext(t *testing.T) {
+	listener := listen(t)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if err := Accept(listener).Connect(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Connect() with a canceled context error = %v, want %v", err, context.Canceled)
+	}
+
+	// A card that never answers, canceling ctx once it gets a command.
+	ctx, cancel = context.WithCancel(t.Context())
+	go func() {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			message, err := readMessage(conn)
+			if err != nil {
+				return
+			}
+			if len(message) > 1 {
+				cancel()
+			} else if message[0] == getATR {
+				writeMessage(conn, DefaultATR)
+			}
+		}
+	}()
+	reader := Accept(listener)
+	card, err := reader.connect(t.Context())
+	if err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	virtualCard := &virtualCard{conn: card}
+	if _, err := virtualCard.powerOn(t.Context()); err != nil {
+		t.Fatalf("powerOn() error = %v", err)
+	}
+	if _, err := virtualCard.Transmit(ctx, []byte{0x00, 0xA4, 0x04, 0x00}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Transmit() canceled error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := virtualCard.Transmit(t.Context(), []byte{0x00, 0xA4, 0x04, 0x00}); err == nil {
+		t.Error("Transmit() after an interrupted exchange error = nil, want an error")
+	}
+	if err := virtualCard.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
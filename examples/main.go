@@ -24,6 +24,8 @@ func main() {
 }
 
 func run() (err error) {
+	ctx := context.Background()
+
 	// ch, err := mbim.New(mbim.WithProxy("/dev/cdc-wdm0"), mbim.WithSlot(1), mbim.WithTimeout(30*time.Second))
 	// if err != nil {
 	// 	return fmt.Errorf("open MBIM channel: %w", err)
@@ -50,7 +52,7 @@ func run() (err error) {
 	// 	return fmt.Errorf("select CCID reader: %w", err)
 	// }
 
-	client, err := lpa.New(&lpa.Options{
+	client, err := lpa.New(ctx, &lpa.Options{
 		Channel: ch,
 	})
 	if err != nil {
@@ -60,33 +62,33 @@ func run() (err error) {
 		err = errors.Join(err, client.Close())
 	}()
 
-	if err := testEID(client); err != nil {
+	if err := testEID(ctx, client); err != nil {
 		return err
 	}
 
-	// if err := testDownload(client); err != nil {
+	// if err := testDownload(ctx, client); err != nil {
 	// 	return err
 	// }
 
-	if err := testListProfiles(client); err != nil {
+	if err := testListProfiles(ctx, client); err != nil {
 		return err
 	}
 
-	// return testDiscovery(client)
+	// return testDiscovery(ctx, client)
 	return nil
 }
 
-func testEID(client *lpa.Client) error {
-	eid, err := client.EID()
+func testEID(ctx context.Context, client *lpa.Client) error {
+	eid, err := client.EID(ctx)
 	if err != nil {
 		return fmt.Errorf("read EID: %w", err)
 	}
-	fmt.Printf("EID: %X\n", eid)
+	fmt.Printf("EID: %s\n", eid)
 	return nil
 }
 
-func testDownload(client *lpa.Client) error {
-	ctx, cancel := context.WithCancel(context.Background())
+func testDownload(ctx context.Context, client *lpa.Client) error {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	installResult, err := client.DownloadProfile(ctx, &lpa.ActivationCode{
@@ -97,6 +99,9 @@ func testDownload(client *lpa.Client) error {
 		OnProgress: func(stage lpa.DownloadStage) {
 			fmt.Println(stage)
 		},
+		OnInstallProgress: func(progress lpa.InstallProgress) {
+			fmt.Printf("%d/%d bytes\n", progress.Bytes, progress.TotalBytes)
+		},
 		OnConfirm: func(metadata *sgp22.ProfileInfo) bool {
 			fmt.Printf("Confirm download of profile %s with ICCID %s\n", metadata.ProfileName, metadata.ICCID)
 			return true // Return true to confirm the download
@@ -112,8 +117,8 @@ func testDownload(client *lpa.Client) error {
 	return nil
 }
 
-func testListProfiles(client *lpa.Client) error {
-	profiles, err := client.ListProfile(nil, []bertlv.Tag{sgp22.TagNotificationConfigurationInfo})
+func testListProfiles(ctx context.Context, client *lpa.Client) error {
+	profiles, err := client.ListProfile(ctx, nil, []bertlv.Tag{sgp22.TagNotificationConfigurationInfo})
 	if err != nil {
 		return fmt.Errorf("list profiles: %w", err)
 	}
@@ -126,8 +131,8 @@ func testListProfiles(client *lpa.Client) error {
 	return nil
 }
 
-func testListNotifications(client *lpa.Client) error {
-	notifications, err := client.ListNotification()
+func testListNotifications(ctx context.Context, client *lpa.Client) error {
+	notifications, err := client.ListNotification(ctx)
 	if err != nil {
 		return fmt.Errorf("list notifications: %w", err)
 	}
@@ -138,32 +143,32 @@ func testListNotifications(client *lpa.Client) error {
 	return nil
 }
 
-func testEnableProfile(client *lpa.Client) error {
+func testEnableProfile(ctx context.Context, client *lpa.Client) error {
 	id, err := sgp22.NewICCID("8944476500001224158")
 	if err != nil {
 		return fmt.Errorf("parse ICCID: %w", err)
 	}
-	if err := client.EnableProfile(id, true); err != nil {
+	if err := client.EnableProfile(ctx, id, true); err != nil {
 		return fmt.Errorf("enable profile: %w", err)
 	}
 	fmt.Println("Profile enabled successfully")
 	return nil
 }
 
-func testDisableProfile(client *lpa.Client) error {
+func testDisableProfile(ctx context.Context, client *lpa.Client) error {
 	id, err := sgp22.NewICCID("8944476500001224158")
 	if err != nil {
 		return fmt.Errorf("parse ICCID: %w", err)
 	}
-	if err := client.DisableProfile(id, true); err != nil {
+	if err := client.DisableProfile(ctx, id, true); err != nil {
 		return fmt.Errorf("disable profile: %w", err)
 	}
 	fmt.Println("Profile disabled successfully")
 	return nil
 }
 
-func testSendNotification(client *lpa.Client, sequenceNumber sgp22.SequenceNumber) error {
-	notifications, err := client.RetrieveNotificationList(sequenceNumber)
+func testSendNotification(ctx context.Context, client *lpa.Client, sequenceNumber sgp22.SequenceNumber) error {
+	notifications, err := client.RetrieveNotificationList(ctx, sequenceNumber)
 	if err != nil {
 		return fmt.Errorf("retrieve notifications: %w", err)
 	}
@@ -171,14 +176,14 @@ func testSendNotification(client *lpa.Client, sequenceNumber sgp22.SequenceNumbe
 		fmt.Println("No notifications found")
 		return nil
 	}
-	if err := client.HandleNotification(notifications[0]); err != nil {
+	if err := client.HandleNotification(ctx, notifications[0]); err != nil {
 		return fmt.Errorf("handle notification: %w", err)
 	}
 	fmt.Println("Notification handled successfully")
 	return nil
 }
 
-func testDiscovery(client *lpa.Client) error {
+func testDiscovery(ctx context.Context, client *lpa.Client) error {
 	addresses := []url.URL{
 		{Scheme: "https", Host: "lpa.ds.gsma.com"},
 		{Scheme: "https", Host: "lpa.live.esimdiscovery.com"},
@@ -191,7 +196,7 @@ func testDiscovery(client *lpa.Client) error {
 	var errs []error
 	for _, address := range addresses {
 		fmt.Printf("Discovering profiles at %s...\n", address.Host)
-		entries, err := client.Discovery(&address, imei)
+		entries, err := client.Discovery(ctx, &address, imei)
 		if err != nil {
 			errs = append(errs, fmt.Errorf("discover profiles at %s: %w", address.Host, err))
 			continue
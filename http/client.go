@@ -2,6 +2,7 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,17 +11,26 @@ import (
 	"net/url"
 )
 
+// StatusError is returned by SendRequest when the server answers with a status code other than 2xx.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
 type Client struct {
 	Client               *http.Client
 	AdminProtocolVersion string
 }
 
-func (c *Client) NewRequest(u *url.URL, request any) (*http.Request, error) {
+func (c *Client) NewRequest(ctx context.Context, u *url.URL, request any) (*http.Request, error) {
 	var body bytes.Buffer
 	if err := json.NewEncoder(&body).Encode(request); err != nil {
 		return nil, err
 	}
-	httpRequest, err := http.NewRequest(http.MethodPost, u.String(), &body)
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
 	if err != nil {
 		return nil, err
 	}
@@ -28,25 +38,31 @@ func (c *Client) NewRequest(u *url.URL, request any) (*http.Request, error) {
 	return httpRequest, nil
 }
 
-func (c *Client) SendRequest(u *url.URL, request, response any) (err error) {
-	httpRequest, err := c.NewRequest(u, request)
+func (c *Client) SendRequest(ctx context.Context, u *url.URL, request, response any) error {
+	_, err := c.Send(ctx, u, request, response)
+	return err
+}
+
+// Send is SendRequest returning the status code of the response, or 0 if the server did not answer.
+func (c *Client) Send(ctx context.Context, u *url.URL, request, response any) (statusCode int, err error) {
+	httpRequest, err := c.NewRequest(ctx, u, request)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	httpResponse, err := c.Client.Do(httpRequest)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer func() {
 		err = errors.Join(err, httpResponse.Body.Close())
 	}()
 	if httpResponse.StatusCode > 299 {
-		return fmt.Errorf("unexpected status code: %d", httpResponse.StatusCode)
+		return httpResponse.StatusCode, &StatusError{StatusCode: httpResponse.StatusCode}
 	}
 	if err = json.NewDecoder(httpResponse.Body).Decode(response); err != nil && !errors.Is(err, io.EOF) {
-		return err
+		return httpResponse.StatusCode, err
 	}
-	return nil
+	return httpResponse.StatusCode, nil
 }
 
 func (c *Client) Header() http.Header {
@@ -0,0 +1,469 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exchange is an ES9+ or ES11 request and its response, recorded by a [Recorder].
+// Bodies are kept as JSON; a body that is not JSON, such as an error page, is kept as a JSON string.
+type Exchange struct {
+	// Endpoint is the path of the function, such as /gsma/rsp2/es9plus/initiateAuthentication.
+	Endpoint string `json:"endpoint"`
+	// Sequence numbers the exchanges with the same endpoint from 1.
+	Sequence int       `json:"sequence"`
+	Host     string    `json:"host"`
+	Time     time.Time `json:"time"`
+	// Duration is the time the exchange took, in nanoseconds.
+	Duration   time.Duration   `json:"duration"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	StatusCode int             `json:"status,omitempty"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	// Error is the message of the transport error, if the exchange failed without a response.
+	Error string `json:"error,omitempty"`
+}
+
+// ReadExchanges reads the exchanges written by a [Recorder], one JSON object per line.
+func ReadExchanges(r io.Reader) ([]Exchange, error) {
+	var exchanges []Exchange
+	scanner := bufio.NewScanner(r)
+	// Bound profile packages take longer lines than the default buffer of the scanner.
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var exchange Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, fmt.Errorf("exchange line %d: %w", line, err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	return exchanges, scanner.Err()
+}
+
+type options struct {
+	fields []string
+	values []string
+}
+
+// Option configures a [Recorder] or a [Replayer].
+type Option func(*options)
+
+// WithRedaction redacts the string values of the JSON fields with one of the names, at any depth
+// of the bodies, such as "transactionId". The hexadecimal values found in these fields are then
+// redacted like the ones of [WithRedactedValues] in every exchange that follows.
+func WithRedaction(fields ...string) Option {
+	return func(options *options) {
+		options.fields = append(options.fields, fields...)
+	}
+}
+
+// WithRedactedValues redacts the hexadecimal values, such as the EID, wherever they occur in the bodies:
+// as text in any JSON string, and as bytes or text inside the base64 ASN.1 of the signed data,
+// certificates, notifications and bound profile packages. Each value is replaced by as many zeros,
+// so the redacted data keeps its length and encoding.
+//
+// Redaction changes signed data, so the signatures and the hash of the confirmation code no longer
+// verify. A [Replayer] given the same options redacts the requests before it checks them, so the values
+// sent by the client, such as the EID, replay as they are. The values sent by the SM-DP+, such as the
+// transaction ID, are replayed redacted, and an APDU trace of the download diverges where the eUICC receives them.
+func WithRedactedValues(values ...string) Option {
+	return func(options *options) {
+		options.values = append(options.values, values...)
+	}
+}
+
+// Recorder is an [http.RoundTripper] that passes requests to a transport and writes each
+// exchange to w as a JSON line. It is safe for concurrent use if the transport is.
+type Recorder struct {
+	transport http.RoundTripper
+	redactor  *redactor
+	now       func() time.Time
+
+	mu        sync.Mutex
+	encoder   *json.Encoder
+	sequences map[string]int
+	err       error
+}
+
+// NewRecorder returns a transport recording the exchanges made with transport, or with
+// [http.DefaultTransport] if it is nil. The recorder does not close w.
+func NewRecorder(transport http.RoundTripper, w io.Writer, opts ...Option) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Recorder{
+		transport: transport,
+		redactor:  newRedactor(options),
+		now:       time.Now,
+		encoder:   json.NewEncoder(w),
+		sequences: make(map[string]int),
+	}
+}
+
+// Err returns the first error writing an exchange. Requests are not affected by it,
+// but the exchanges after the failed one may be missing.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	body, err := readAndClose(request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read HTTP request body: %w", err)
+	}
+	transportRequest := request.Clone(request.Context())
+	if request.Body != nil {
+		transportRequest.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	exchange := Exchange{
+		Endpoint: request.URL.Path,
+		Host:     request.URL.Host,
+		Time:     r.now(),
+	}
+	if exchange.Request, err = marshalBody(body, r.redactor); err != nil {
+		return nil, err
+	}
+	response, err := r.transport.RoundTrip(transportRequest)
+	if err != nil {
+		exchange.Error = err.Error()
+		r.record(&exchange)
+		return nil, err
+	}
+	rb, err := readAndClose(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read HTTP response body: %w", err)
+	}
+	response.Body = io.NopCloser(bytes.NewReader(rb))
+	exchange.StatusCode = response.StatusCode
+	if exchange.Response, err = marshalBody(rb, r.redactor); err != nil {
+		return nil, err
+	}
+	r.record(&exchange)
+	return response, nil
+}
+
+func (r *Recorder) record(exchange *Exchange) {
+	exchange.Duration = r.now().Sub(exchange.Time)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sequences[exchange.Endpoint]++
+	exchange.Sequence = r.sequences[exchange.Endpoint]
+	if r.err != nil {
+		return
+	}
+	r.err = r.encoder.Encode(exchange)
+}
+
+// ReplayError reports a request the recorded exchanges cannot answer.
+type ReplayError struct {
+	Endpoint string
+	Sequence int
+	// Reason tells why the request cannot be answered.
+	Reason string
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("replay %s #%d: %s", e.Endpoint, e.Sequence, e.Reason)
+}
+
+// Replayer is an [http.RoundTripper] answering requests with recorded exchanges, without a network.
+// The nth request to an endpoint is answered with the nth exchange recorded for it, whatever the host,
+// once its body, redacted like the recorded ones, is checked against the recorded request. The first request that cannot be answered
+// fails with a [*ReplayError], and so does every request after it. It is safe for concurrent use.
+type Replayer struct {
+	redactor *redactor
+
+	mu        sync.Mutex
+	exchanges map[string][]Exchange
+	sequences map[string]int
+	err       error
+}
+
+// NewReplayer returns a transport replaying the exchanges, see [ReadExchanges].
+// The options are the ones the exchanges were recorded with.
+func NewReplayer(exchanges []Exchange, opts ...Option) *Replayer {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	r := &Replayer{
+		redactor:  newRedactor(options),
+		exchanges: make(map[string][]Exchange),
+		sequences: make(map[string]int),
+	}
+	for _, exchange := range exchanges {
+		r.exchanges[exchange.Endpoint] = append(r.exchanges[exchange.Endpoint], exchange)
+	}
+	for _, exchanges := range r.exchanges {
+		slices.SortStableFunc(exchanges, func(a, b Exchange) int { return cmp.Compare(a.Sequence, b.Sequence) })
+	}
+	return r
+}
+
+// Done reports whether every exchange was replayed. It returns the first failure if a request could not be answered.
+func (r *Replayer) Done() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	var errs []error
+	for _, endpoint := range slices.Sorted(maps.Keys(r.exchanges)) {
+		exchanges := r.exchanges[endpoint]
+		if n := r.sequences[endpoint]; n < len(exchanges) {
+			errs = append(errs, fmt.Errorf("%d of %d exchanges with %s were not replayed", len(exchanges)-n, len(exchanges), endpoint))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Replayer) RoundTrip(request *http.Request) (*http.Response, error) {
+	body, err := readAndClose(request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read HTTP request body: %w", err)
+	}
+	if err := request.Context().Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	exchange, err := r.match(request.URL.Path, body)
+	if err != nil {
+		r.err = err
+		return nil, err
+	}
+	if exchange.Error != "" {
+		return nil, errors.New(exchange.Error)
+	}
+	rb, err := unmarshalBody(exchange.Response)
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	if len(rb) > 0 {
+		header.Set("Content-Type", "application/json")
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", exchange.StatusCode, http.StatusText(exchange.StatusCode)),
+		StatusCode:    exchange.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(rb)),
+		ContentLength: int64(len(rb)),
+		Request:       request,
+	}, nil
+}
+
+// match returns the next exchange recorded for the endpoint, if its request matches body.
+func (r *Replayer) match(endpoint string, body []byte) (*Exchange, error) {
+	r.sequences[endpoint]++
+	sequence := r.sequences[endpoint]
+	exchanges := r.exchanges[endpoint]
+	if sequence > len(exchanges) {
+		return nil, &ReplayError{Endpoint: endpoint, Sequence: sequence, Reason: "no exchange recorded"}
+	}
+	exchange := &exchanges[sequence-1]
+	request, err := marshalBody(body, r.redactor)
+	if err != nil {
+		return nil, err
+	}
+	if !equalJSON(request, exchange.Request) {
+		return nil, &ReplayError{Endpoint: endpoint, Sequence: sequence, Reason: fmt.Sprintf("request %s differs from the recorded %s", request, exchange.Request)}
+	}
+	return exchange, nil
+}
+
+// marshalBody returns the JSON of a body redacted by redactor, if any, or the body as a JSON string if it is not JSON.
+func marshalBody(body []byte, redactor *redactor) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+	if !json.Valid(body) {
+		return json.Marshal(string(body))
+	}
+	if redactor == nil {
+		var compacted bytes.Buffer
+		err := json.Compact(&compacted, body)
+		return compacted.Bytes(), err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redactor.redact(value))
+}
+
+// unmarshalBody returns the body of a recorded JSON, which is the content of a JSON string.
+func unmarshalBody(data json.RawMessage) ([]byte, error) {
+	if len(data) == 0 || data[0] != '"' {
+		return data, nil
+	}
+	var body string
+	err := json.Unmarshal(data, &body)
+	return []byte(body), err
+}
+
+// redactor redacts the named fields of the bodies and the secret values wherever they occur.
+// It learns the values of the named fields as it goes, since the transaction ID returned by
+// the SM-DP+ is found in every exchange that follows.
+type redactor struct {
+	fields []string
+
+	mu      sync.Mutex
+	secrets [][]byte
+}
+
+// newRedactor returns a redactor for options, or nil if they redact nothing.
+func newRedactor(options options) *redactor {
+	if len(options.fields) == 0 && len(options.values) == 0 {
+		return nil
+	}
+	r := &redactor{fields: options.fields}
+	for _, value := range options.values {
+		r.learn(value)
+	}
+	return r
+}
+
+func (r *redactor) redact(value any) any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.learnFields(value)
+	return r.redactValue(value)
+}
+
+// learn adds a hexadecimal value to the secrets.
+func (r *redactor) learn(value string) {
+	secret, err := hex.DecodeString(value)
+	if err != nil || len(secret) == 0 {
+		return
+	}
+	if !slices.ContainsFunc(r.secrets, func(known []byte) bool { return bytes.Equal(known, secret) }) {
+		r.secrets = append(r.secrets, secret)
+	}
+}
+
+func (r *redactor) learnFields(value any) {
+	switch value := value.(type) {
+	case map[string]any:
+		for name, field := range value {
+			if s, ok := field.(string); ok && r.redacted(name) {
+				r.learn(s)
+				continue
+			}
+			r.learnFields(field)
+		}
+	case []any:
+		for _, element := range value {
+			r.learnFields(element)
+		}
+	}
+}
+
+func (r *redactor) redacted(name string) bool {
+	return slices.ContainsFunc(r.fields, func(field string) bool { return strings.EqualFold(field, name) })
+}
+
+func (r *redactor) redactValue(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for name, field := range value {
+			if s, ok := field.(string); ok && r.redacted(name) {
+				value[name] = strings.Repeat("0", len(s))
+				continue
+			}
+			value[name] = r.redactValue(field)
+		}
+	case []any:
+		for i, element := range value {
+			value[i] = r.redactValue(element)
+		}
+	case string:
+		return r.redactString(value)
+	}
+	return value
+}
+
+// redactString redacts the secrets in the base64 data of s, or else in its text.
+func (r *redactor) redactString(s string) string {
+	if data, err := base64.StdEncoding.DecodeString(s); err == nil && r.redactBytes(data) {
+		return base64.StdEncoding.EncodeToString(data)
+	}
+	data := []byte(s)
+	if r.redactBytes(data) {
+		return string(data)
+	}
+	return s
+}
+
+// redactBytes replaces the secrets in data, as bytes or as hexadecimal text, with zeros.
+// It reports whether data changed.
+func (r *redactor) redactBytes(data []byte) bool {
+	var changed bool
+	for _, secret := range r.secrets {
+		text := []byte(hex.EncodeToString(secret))
+		for i := 0; i < len(data); i++ {
+			switch {
+			case i+len(secret) <= len(data) && bytes.Equal(data[i:i+len(secret)], secret):
+				clear(data[i : i+len(secret)])
+				i += len(secret) - 1
+				changed = true
+			case i+len(text) <= len(data) && bytes.EqualFold(data[i:i+len(text)], text):
+				copy(data[i:], bytes.Repeat([]byte{'0'}, len(text)))
+				i += len(text) - 1
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func equalJSON(a, b json.RawMessage) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	var x, y any
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func readAndClose(body io.ReadCloser) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	data, err := io.ReadAll(body)
+	return data, errors.Join(err, body.Close())
+}
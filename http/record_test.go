@@ -0,0 +1,163 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// testServer answers each endpoint with the transaction ID and EID of the request,
+// the notification endpoint with no content and any other one with an error page.
+func testServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/gsma/rsp2/es9plus/handleNotification":
+			w.WriteHeader(http.StatusNoContent)
+		case "/gsma/rsp2/es9plus/initiateAuthentication", "/gsma/rsp2/es9plus/authenticateClient":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(bytes.Replace(body, []byte(`"request"`), []byte(`"response"`), 1))
+		default:
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+type testMessage struct {
+	Kind          string `json:"kind"`
+	TransactionID string `json:"transactionId"`
+	EID           string `json:"eid,omitempty"`
+}
+
+func exchange(t *testing.T, client *Client, server, endpoint string, request *testMessage) (*testMessage, error) {
+	t.Helper()
+	address, _ := url.Parse(server + endpoint)
+	response := new(testMessage)
+	return response, client.SendRequest(t.Context(), address, request, response)
+}
+
+func TestRecordReplay(t *testing.T) {
+	server := testServer(t)
+	var trace bytes.Buffer
+	recorder := NewRecorder(nil, &trace)
+	client := &Client{Client: &http.Client{Transport: recorder}, AdminProtocolVersion: "2.5.0"}
+	run := func(client *Client, server string) []string {
+		var got []string
+		for _, endpoint := range []string{"initiateAuthentication", "authenticateClient", "authenticateClient", "handleNotification", "cancelSession"} {
+			response, err := exchange(t, client, server, "/gsma/rsp2/es9plus/"+endpoint, &testMessage{Kind: "request", TransactionID: "0A0B"})
+			got = append(got, response.Kind+"/"+response.TransactionID)
+			if err != nil {
+				got = append(got, err.Error())
+			}
+		}
+		return got
+	}
+	recorded := run(client, server.URL)
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("Recorder.Err() = %v", err)
+	}
+
+	exchanges, err := ReadExchanges(&trace)
+	if err != nil {
+		t.Fatalf("ReadExchanges() error = %v", err)
+	}
+	if len(exchanges) != 5 {
+		t.Fatalf("ReadExchanges() = %d exchanges, want 5", len(exchanges))
+	}
+	if e := exchanges[2]; e.Endpoint != "/gsma/rsp2/es9plus/authenticateClient" || e.Sequence != 2 || e.StatusCode != http.StatusOK {
+		t.Errorf("exchanges[2] = %+v, want the second authenticateClient", e)
+	}
+	if e := exchanges[3]; e.StatusCode != http.StatusNoContent || e.Response != nil {
+		t.Errorf("exchanges[3] = %+v, want no content", e)
+	}
+	if e := exchanges[4]; e.StatusCode != http.StatusBadGateway || string(e.Response) != `"bad gateway\n"` {
+		t.Errorf("exchanges[4] response = %s, want the error page as a string", e.Response)
+	}
+
+	replayer := NewReplayer(exchanges)
+	client = &Client{Client: &http.Client{Transport: replayer}, AdminProtocolVersion: "2.5.0"}
+	if replayed := run(client, "https://smdp.invalid"); strings.Join(replayed, ",") != strings.Join(recorded, ",") {
+		t.Errorf("replayed = %v, want %v", replayed, recorded)
+	}
+	if err := replayer.Done(); err != nil {
+		t.Errorf("Replayer.Done() = %v", err)
+	}
+}
+
+func TestReplayDivergence(t *testing.T) {
+	replayer := NewReplayer([]Exchange{
+		{Endpoint: "/gsma/rsp2/es9plus/initiateAuthentication", Sequence: 1, Request: []byte(`{"kind":"request","transactionId":"0A0B"}`), StatusCode: http.StatusOK, Response: []byte(`{"kind":"response"}`)},
+		{Endpoint: "/gsma/rsp2/es9plus/authenticateClient", Sequence: 1, Request: []byte(`{"kind":"request","transactionId":"0A0B"}`), StatusCode: http.StatusOK, Response: []byte(`{"kind":"response"}`)},
+	})
+	client := &Client{Client: &http.Client{Transport: replayer}, AdminProtocolVersion: "2.5.0"}
+	if err := replayer.Done(); err == nil {
+		t.Error("Replayer.Done() before replay = nil, want an error")
+	}
+	if _, err := exchange(t, client, "https://smdp.invalid", "/gsma/rsp2/es9plus/initiateAuthentication", &testMessage{Kind: "request", TransactionID: "0A0B"}); err != nil {
+		t.Fatalf("initiateAuthentication error = %v", err)
+	}
+	var replayError *ReplayError
+	_, err := exchange(t, client, "https://smdp.invalid", "/gsma/rsp2/es9plus/authenticateClient", &testMessage{Kind: "request", TransactionID: "0C0D"})
+	if !errors.As(err, &replayError) || replayError.Sequence != 1 || !strings.Contains(replayError.Reason, "differs") {
+		t.Errorf("authenticateClient with another transaction ID error = %v, want a ReplayError", err)
+	}
+	_, err = exchange(t, client, "https://smdp.invalid", "/gsma/rsp2/es9plus/initiateAuthentication", &testMessage{Kind: "request", TransactionID: "0A0B"})
+	if !errors.As(err, &replayError) || replayError.Endpoint != "/gsma/rsp2/es9plus/authenticateClient" {
+		t.Errorf("request after a divergence error = %v, want the first ReplayError", err)
+	}
+	if err := replayer.Done(); !errors.As(err, &replayError) {
+		t.Errorf("Replayer.Done() = %v, want the ReplayError", err)
+	}
+
+	replayer = NewReplayer(nil)
+	client = &Client{Client: &http.Client{Transport: replayer}, AdminProtocolVersion: "2.5.0"}
+	_, err = exchange(t, client, "https://smdp.invalid", "/gsma/rsp2/es11/authenticateClient", &testMessage{})
+	if !errors.As(err, &replayError) || replayError.Reason != "no exchange recorded" {
+		t.Errorf("request without an exchange error = %v, want a ReplayError", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	address, _ := url.Parse("https://smdp.invalid/gsma/rsp2/es11/authenticateClient")
+	if err := client.SendRequest(ctx, address, &testMessage{}, new(testMessage)); !errors.Is(err, context.Canceled) {
+		t.Errorf("SendRequest() with a canceled context error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRecordRedaction(t *testing.T) {
+	server := testServer(t)
+	var trace bytes.Buffer
+	recorder := NewRecorder(nil, &trace, WithRedaction("transactionId"), WithRedactedValues("89049032123451234512345678901235"))
+	client := &Client{Client: &http.Client{Transport: recorder}}
+	request := &testMessage{Kind: "request", TransactionID: "0A0B0C", EID: "89049032123451234512345678901235"}
+	if _, err := exchange(t, client, server.URL, "/gsma/rsp2/es9plus/initiateAuthentication", request); err != nil {
+		t.Fatalf("initiateAuthentication error = %v", err)
+	}
+	// The transaction ID is redacted in the exchanges that follow, in base64 data too.
+	request.Kind = base64.StdEncoding.EncodeToString([]byte{0x80, 0x03, 0x0A, 0x0B, 0x0C})
+	if _, err := exchange(t, client, server.URL, "/gsma/rsp2/es9plus/authenticateClient", request); err != nil {
+		t.Fatalf("authenticateClient error = %v", err)
+	}
+	if strings.Contains(trace.String(), "0A0B0C") || strings.Contains(trace.String(), "8904903212345") {
+		t.Fatalf("trace = %s, want the transaction ID and EID redacted", trace.String())
+	}
+	exchanges, err := ReadExchanges(&trace)
+	if err != nil {
+		t.Fatalf("ReadExchanges() error = %v", err)
+	}
+	if want := `{"eid":"00000000000000000000000000000000","kind":"response","transactionId":"000000"}`; string(exchanges[0].Response) != want {
+		t.Errorf("recorded response = %s, want %s", exchanges[0].Response, want)
+	}
+	if want := `{"eid":"00000000000000000000000000000000","kind":"gAMAAAA=","transactionId":"000000"}`; string(exchanges[1].Request) != want {
+		t.Errorf("recorded request = %s, want %s", exchanges[1].Request, want)
+	}
+}
@@ -0,0 +1,29 @@
+// Package netctx bounds the I/O on network connections by a context.
+package netctx
+
+import (
+	"cmp"
+	"context"
+	"net"
+	"time"
+)
+
+// Do runs fn on conn, interrupting its I/O when ctx is done. The deadline of ctx,
+// if any, becomes the deadline of conn, and the error of ctx is returned if fn fails after it is done.
+func Do(ctx context.Context, conn net.Conn, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+	if err := fn(); err != nil {
+		return cmp.Or(ctx.Err(), err)
+	}
+	return nil
+}
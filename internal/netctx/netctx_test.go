@@ -0,0 +1,37 @@
+package netctx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	err := Do(ctx, conn, func() error {
+		_, err := conn.Read(make([]byte, 1))
+		return err
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() interrupted error = %v, want %v", err, context.Canceled)
+	}
+	if err := Do(ctx, conn, func() error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() with a canceled context error = %v, want %v", err, context.Canceled)
+	}
+
+	go peer.Write([]byte{0x01})
+	err = Do(t.Context(), conn, func() error {
+		_, err := conn.Read(make([]byte, 1))
+		return err
+	})
+	if err != nil {
+		t.Errorf("Do() after an interrupted call error = %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+// Package scp03t implements the SCP03t secure channel of GSMA SGP.22,
+// which protects the '87', '88' and '86' TLVs of a bound profile package.
+package scp03t
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"slices"
+
+	"github.com/damonto/euicc-go/bertlv"
+)
+
+const (
+	// KeyType is the key type of the control reference template, AES.
+	KeyType = 0x88
+	// KeyLength is the length of the session keys in bytes.
+	KeyLength = 16
+	// MACLength is the length of the C-MAC appended to each TLV.
+	MACLength = 8
+	// MaxSegmentLength is the maximum length of the data protected in one TLV.
+	MaxSegmentLength = 1020
+)
+
+var (
+	ErrInvalidMAC     = errors.New("invalid C-MAC")
+	ErrInvalidPadding = errors.New("invalid padding")
+)
+
+// Keys are the session keys of a secure channel.
+type Keys struct {
+	// MACChainingValue is the initial MAC chaining value.
+	MACChainingValue []byte
+	Enc              []byte
+	MAC              []byte
+}
+
+// DeriveKeys derives the session keys from the shared secret of the one-time keys of the eUICC
+// and the SM-DP+, using the X9.63 key derivation function with SHA-256.
+// The shared info is the key type, key length and host ID of the control reference template, followed by the EID.
+func DeriveKeys(sharedSecret, hostID, eid []byte) *Keys {
+	sharedInfo := slices.Concat(
+		[]byte{KeyType, KeyLength, byte(len(hostID))}, hostID,
+		[]byte{byte(len(eid))}, eid,
+	)
+	var keyData []byte
+	for counter := uint32(1); len(keyData) < 3*KeyLength; counter++ {
+		digest := sha256.New()
+		digest.Write(sharedSecret)
+		digest.Write(binary.BigEndian.AppendUint32(nil, counter))
+		digest.Write(sharedInfo)
+		keyData = digest.Sum(keyData)
+	}
+	return &Keys{
+		MACChainingValue: keyData[:KeyLength],
+		Enc:              keyData[KeyLength : 2*KeyLength],
+		MAC:              keyData[2*KeyLength : 3*KeyLength],
+	}
+}
+
+// Channel protects or unprotects the TLVs of a bound profile package in order.
+// It is not safe for concurrent use.
+type Channel struct {
+	enc, mac cipher.Block
+	// counter is the number of TLVs processed, it seeds the ICV of the next encrypted TLV.
+	counter          uint64
+	macChainingValue []byte
+}
+
+// New returns a channel using the session keys.
+func New(keys *Keys) (*Channel, error) {
+	enc, err := aes.NewCipher(keys.Enc)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := aes.NewCipher(keys.MAC)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys.MACChainingValue) != aes.BlockSize {
+		return nil, errors.New("MAC chaining value must be 16 bytes")
+	}
+	return &Channel{enc: enc, mac: mac, macChainingValue: slices.Clone(keys.MACChainingValue)}, nil
+}
+
+// Wrap returns a TLV with the given tag carrying data followed by its C-MAC.
+// The data is encrypted first if encrypt is set, as for the '86' and '87' TLVs.
+func (c *Channel) Wrap(tag bertlv.Tag, data []byte, encrypt bool) (*bertlv.TLV, error) {
+	if len(data) > MaxSegmentLength {
+		return nil, errors.New("segment exceeds 1020 bytes")
+	}
+	c.counter++
+	if encrypt {
+		data = pad(data)
+		cipher.NewCBCEncrypter(c.enc, c.icv()).CryptBlocks(data, data)
+	}
+	tlv := bertlv.NewValue(tag, append(slices.Clone(data), make([]byte, MACLength)...))
+	mac, err := c.chain(tlv)
+	if err != nil {
+		return nil, err
+	}
+	copy(tlv.Value[len(data):], mac)
+	return tlv, nil
+}
+
+// Unwrap verifies the C-MAC of a TLV produced by [Channel.Wrap] and returns its data,
+// decrypted if decrypt is set.
+func (c *Channel) Unwrap(tlv *bertlv.TLV, decrypt bool) ([]byte, error) {
+	if len(tlv.Value) < MACLength {
+		return nil, ErrInvalidMAC
+	}
+	c.counter++
+	mac, err := c.chain(tlv)
+	if err != nil {
+		return nil, err
+	}
+	data := tlv.Value[:len(tlv.Value)-MACLength]
+	if subtle.ConstantTimeCompare(mac, tlv.Value[len(data):]) != 1 {
+		return nil, ErrInvalidMAC
+	}
+	if !decrypt {
+		return slices.Clone(data), nil
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, ErrInvalidPadding
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(c.enc, c.icv()).CryptBlocks(plain, data)
+	return unpad(plain)
+}
+
+// chain computes the C-MAC of the TLV over the MAC chaining value, the tag, the length and the data
+// without the C-MAC, and chains the full MAC into the next computation.
+func (c *Channel) chain(tlv *bertlv.TLV) ([]byte, error) {
+	encoded, err := tlv.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	mac := cmac(c.mac, slices.Concat(c.macChainingValue, encoded[:len(encoded)-MACLength]))
+	c.macChainingValue = mac
+	return mac[:MACLength], nil
+}
+
+// icv returns the initial chaining value of the next encryption, the encrypted counter.
+func (c *Channel) icv() []byte {
+	icv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(icv[8:], c.counter)
+	c.enc.Encrypt(icv, icv)
+	return icv
+}
+
+// pad pads data with '80' and zeros to a multiple of the block size.
+func pad(data []byte) []byte {
+	padded := append(slices.Clone(data), 0x80)
+	return append(padded, make([]byte, (aes.BlockSize-len(padded)%aes.BlockSize)%aes.BlockSize)...)
+}
+
+func unpad(data []byte) ([]byte, error) {
+	index := len(data) - 1
+	for index >= 0 && data[index] == 0 {
+		index--
+	}
+	if index < 0 || data[index] != 0x80 || len(data)-index > aes.BlockSize {
+		return nil, ErrInvalidPadding
+	}
+	return data[:index], nil
+}
+
+// cmac computes the AES-CMAC of RFC 4493.
+func cmac(block cipher.Block, data []byte) []byte {
+	k1 := make([]byte, aes.BlockSize)
+	block.Encrypt(k1, k1)
+	k1 = shift(k1)
+	k2 := shift(k1)
+	n := max((len(data)+aes.BlockSize-1)/aes.BlockSize, 1)
+	last := make([]byte, aes.BlockSize)
+	if len(data) > 0 && len(data)%aes.BlockSize == 0 {
+		subtle.XORBytes(last, data[(n-1)*aes.BlockSize:], k1)
+	} else {
+		subtle.XORBytes(last, pad(data[(n-1)*aes.BlockSize:]), k2)
+	}
+	mac := make([]byte, aes.BlockSize)
+	for index := range n - 1 {
+		subtle.XORBytes(mac, mac, data[index*aes.BlockSize:])
+		block.Encrypt(mac, mac)
+	}
+	subtle.XORBytes(mac, mac, last)
+	block.Encrypt(mac, mac)
+	return mac
+}
+
+// shift doubles a subkey in GF(2^128).
+func shift(key []byte) []byte {
+	shifted := make([]byte, len(key))
+	for index := range key {
+		shifted[index] = key[index] << 1
+		if index+1 < len(key) {
+			shifted[index] |= key[index+1] >> 7
+		}
+	}
+	if key[0]&0x80 != 0 {
+		shifted[len(shifted)-1] ^= 0x87
+	}
+	return shifted
+}
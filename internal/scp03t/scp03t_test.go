@@ -0,0 +1,72 @@
+package scp03t
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/damonto/euicc-go/bertlv"
+)
+
+func TestCMAC(t *testing.T) {
+	// RFC 4493 Section 4 test vectors.
+	key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	message, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411")
+	block, _ := aes.NewCipher(key)
+	tests := []struct {
+		length int
+		want   string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(cmac(block, message[:tt.length])); got != tt.want {
+			t.Errorf("cmac(%d bytes) = %s, want %s", tt.length, got, tt.want)
+		}
+	}
+}
+
+func TestChannelRoundTrip(t *testing.T) {
+	keys := DeriveKeys([]byte("shared secret"), []byte("host"), bytes.Repeat([]byte{0x89}, 16))
+	sender, err := New(keys)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	receiver, _ := New(keys)
+	segments := [][]byte{{0xBF, 0x24, 0x00}, bytes.Repeat([]byte{0x5A}, 32), bytes.Repeat([]byte{0xA5}, MaxSegmentLength)}
+	for index, segment := range segments {
+		encrypt := index != 1
+		tlv, err := sender.Wrap(bertlv.ContextSpecific.Primitive(6), segment, encrypt)
+		if err != nil {
+			t.Fatalf("Wrap() error = %v", err)
+		}
+		if encrypt && bytes.Contains(tlv.Value, segment) {
+			t.Errorf("Wrap() = %X, want encrypted data", tlv.Value)
+		}
+		got, err := receiver.Unwrap(tlv, encrypt)
+		if err != nil {
+			t.Fatalf("Unwrap() error = %v", err)
+		}
+		if !bytes.Equal(got, segment) {
+			t.Errorf("Unwrap() = %X, want %X", got, segment)
+		}
+	}
+}
+
+func TestChannelRejectsTamperedTLV(t *testing.T) {
+	keys := DeriveKeys([]byte("shared secret"), []byte("host"), nil)
+	sender, _ := New(keys)
+	receiver, _ := New(keys)
+	tlv, _ := sender.Wrap(bertlv.ContextSpecific.Primitive(7), []byte{0x01, 0x02}, true)
+	tlv.Value[0] ^= 0xFF
+	if _, err := receiver.Unwrap(tlv, true); !errors.Is(err, ErrInvalidMAC) {
+		t.Errorf("Unwrap() error = %v, want %v", err, ErrInvalidMAC)
+	}
+	if _, err := sender.Wrap(bertlv.ContextSpecific.Primitive(6), make([]byte, MaxSegmentLength+1), true); err == nil {
+		t.Error("Wrap() error = nil for an oversized segment")
+	}
+}
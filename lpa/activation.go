@@ -0,0 +1,256 @@
+package lpa
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ActivationCode represents the activation code for downloading a profile.
+//
+// See https://aka.pw/sgp22/v2.5#page=113 (Section 4.1 Activation Code)
+type ActivationCode struct {
+	SMDP       *url.URL
+	MatchingID string
+	IMEI       string
+	OID        string
+	// ConfirmationCodeRequired is the Confirmation Code Required Flag of the activation code.
+	ConfirmationCodeRequired bool
+	ConfirmationCode         string
+	// Extra holds the fields following the Confirmation Code Required Flag, which are kept for round-tripping.
+	Extra []string
+}
+
+// ActivationCodeField identifies a field of an activation code.
+type ActivationCodeField uint8
+
+const (
+	ActivationCodeFieldFormat ActivationCodeField = iota
+	ActivationCodeFieldSMDPAddress
+	ActivationCodeFieldMatchingID
+	ActivationCodeFieldOID
+	ActivationCodeFieldConfirmationCodeRequired
+)
+
+func (f ActivationCodeField) String() string {
+	switch f {
+	case ActivationCodeFieldFormat:
+		return "AC_Format"
+	case ActivationCodeFieldSMDPAddress:
+		return "SM-DP+ address"
+	case ActivationCodeFieldMatchingID:
+		return "matching ID"
+	case ActivationCodeFieldOID:
+		return "SM-DP+ OID"
+	case ActivationCodeFieldConfirmationCodeRequired:
+		return "confirmation code required flag"
+	}
+	return fmt.Sprintf("unknown(%d)", f)
+}
+
+// ActivationCodeError describes an invalid field of an activation code.
+// Parsing and validation return all invalid fields joined with errors.Join.
+type ActivationCodeError struct {
+	Field ActivationCodeField
+	Value string
+	Err   error
+}
+
+func (e *ActivationCodeError) Error() string {
+	return fmt.Sprintf("activation code %s %q: %v", e.Field, e.Value, e.Err)
+}
+
+func (e *ActivationCodeError) Unwrap() error {
+	return e.Err
+}
+
+// activationCodeScheme is the URI scheme of activation codes in QR codes and deep links.
+const activationCodeScheme = "LPA:"
+
+// ParseActivationCode parses an activation code, either bare ("1$...") or in its URI form ("LPA:1$...").
+// The returned activation code holds every field that could be parsed, even if an error is returned.
+func ParseActivationCode(code string) (*ActivationCode, error) {
+	ac := new(ActivationCode)
+	return ac, ac.UnmarshalText([]byte(code))
+}
+
+// MarshalText encodes the activation code in its URI form.
+func (ac *ActivationCode) MarshalText() ([]byte, error) {
+	if ac.SMDP == nil {
+		return nil, errors.New("SM-DP+ is required")
+	}
+	if err := ac.Validate(); err != nil {
+		return nil, err
+	}
+	b := []byte(activationCodeScheme + "1$")
+	b = append(append(b, ac.SMDP.Host...), '$')
+	b = append(b, ac.MatchingID...)
+	ccRequired := ac.ConfirmationCodeRequired || ac.ConfirmationCode != ""
+	if ac.OID != "" || ccRequired || len(ac.Extra) > 0 {
+		b = append(append(b, '$'), ac.OID...)
+	}
+	if ccRequired || len(ac.Extra) > 0 {
+		b = append(b, '$')
+		if ccRequired {
+			b = append(b, '1')
+		}
+	}
+	for _, field := range ac.Extra {
+		b = append(append(b, '$'), field...)
+	}
+	return b, nil
+}
+
+func (ac *ActivationCode) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		return errors.New("activation code is required")
+	}
+	code := strings.TrimSpace(string(text))
+	if len(code) >= len(activationCodeScheme) && strings.EqualFold(code[:len(activationCodeScheme)], activationCodeScheme) {
+		code = code[len(activationCodeScheme):]
+	}
+	parts := strings.Split(code, "$")
+	if parts[0] != "1" {
+		return &ActivationCodeError{Field: ActivationCodeFieldFormat, Value: parts[0], Err: errors.New("unsupported format")}
+	}
+	if len(parts) < 2 {
+		return &ActivationCodeError{Field: ActivationCodeFieldSMDPAddress, Err: errors.New("missing")}
+	}
+	ac.SMDP = &url.URL{Scheme: "https", Host: parts[1]}
+	ac.MatchingID, ac.OID, ac.ConfirmationCodeRequired, ac.Extra = "", "", false, nil
+	if len(parts) > 2 {
+		ac.MatchingID = parts[2]
+	}
+	if len(parts) > 3 {
+		ac.OID = parts[3]
+	}
+	var errs []error
+	if len(parts) > 4 {
+		switch parts[4] {
+		case "1":
+			ac.ConfirmationCodeRequired = true
+		case "", "0":
+		default:
+			errs = append(errs, &ActivationCodeError{
+				Field: ActivationCodeFieldConfirmationCodeRequired,
+				Value: parts[4],
+				Err:   errors.New(`must be "1" or empty`),
+			})
+		}
+	}
+	if len(parts) > 5 {
+		ac.Extra = parts[5:]
+	}
+	return errors.Join(append(ac.validateFields(), errs...)...)
+}
+
+// Validate checks the fields carried by the activation code against SGP.22 Section 4.1.
+// The returned error joins an *ActivationCodeError for each invalid field.
+func (ac *ActivationCode) Validate() error {
+	return errors.Join(ac.validateFields()...)
+}
+
+func (ac *ActivationCode) validateFields() []error {
+	var errs []error
+	if ac.SMDP == nil {
+		errs = append(errs, &ActivationCodeError{Field: ActivationCodeFieldSMDPAddress, Err: errors.New("missing")})
+	} else {
+		if ac.SMDP.Scheme != "" && ac.SMDP.Scheme != "https" {
+			errs = append(errs, &ActivationCodeError{
+				Field: ActivationCodeFieldSMDPAddress,
+				Value: ac.SMDP.String(),
+				Err:   fmt.Errorf("scheme %s cannot be represented, SM-DP+ addresses are always https", ac.SMDP.Scheme),
+			})
+		}
+		if err := validSMDPAddress(ac.SMDP.Host); err != nil {
+			errs = append(errs, &ActivationCodeError{Field: ActivationCodeFieldSMDPAddress, Value: ac.SMDP.Host, Err: err})
+		}
+	}
+	if err := validMatchingID(ac.MatchingID); err != nil {
+		errs = append(errs, &ActivationCodeError{Field: ActivationCodeFieldMatchingID, Value: ac.MatchingID, Err: err})
+	}
+	if err := validOID(ac.OID); err != nil {
+		errs = append(errs, &ActivationCodeError{Field: ActivationCodeFieldOID, Value: ac.OID, Err: err})
+	}
+	return errs
+}
+
+// validSMDPAddress checks address is a fully qualified domain name, optionally followed by a port.
+func validSMDPAddress(address string) error {
+	if address == "" {
+		return errors.New("missing")
+	}
+	host := address
+	if h, port, err := net.SplitHostPort(address); err == nil {
+		if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+			return fmt.Errorf("invalid port %s", port)
+		}
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	if len(host) > 253 {
+		return errors.New("longer than 253 characters")
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("invalid label %q", label)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("label %q starts or ends with a hyphen", label)
+		}
+		for _, r := range label {
+			if !isAlphanumeric(r) && r != '-' {
+				return fmt.Errorf("label %q contains %q", label, r)
+			}
+		}
+	}
+	return nil
+}
+
+// validMatchingID checks the matching ID only uses alphanumeric characters and hyphens.
+// SGP.22 restricts it to upper case, lower case is accepted as some SM-DP+ issue such codes.
+func validMatchingID(matchingID string) error {
+	if len(matchingID) > 255 {
+		return errors.New("longer than 255 characters")
+	}
+	for _, r := range matchingID {
+		if !isAlphanumeric(r) && r != '-' {
+			return fmt.Errorf("contains %q", r)
+		}
+	}
+	return nil
+}
+
+// validOID checks oid is empty or a dotted decimal object identifier.
+func validOID(oid string) error {
+	if oid == "" {
+		return nil
+	}
+	arcs := strings.Split(oid, ".")
+	if len(arcs) < 2 {
+		return errors.New("not a dotted decimal object identifier")
+	}
+	for _, arc := range arcs {
+		if _, err := strconv.ParseUint(arc, 10, 64); err != nil || (len(arc) > 1 && arc[0] == '0') {
+			return fmt.Errorf("invalid arc %q", arc)
+		}
+	}
+	return nil
+}
+
+func isAlphanumeric(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')
+}
+
+func (ac *ActivationCode) validate() error {
+	if ac.SMDP == nil || ac.SMDP.Host == "" {
+		return errors.New("SM-DP+ is required")
+	}
+	if ac.IMEI == "" {
+		return errors.New("IMEI is required")
+	}
+	return nil
+}
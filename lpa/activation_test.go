@@ -0,0 +1,120 @@
+package lpa
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestActivationCodeRoundTrip(t *testing.T) {
+	tests := []string{
+		"LPA:1$smdp.example.com$",
+		"LPA:1$smdp.example.com$QR-G-5C-1LS-1W1Z9P7",
+		"LPA:1$smdp.example.com:8443$ABC-123$1.3.6.1.4.1.31746",
+		"LPA:1$smdp.example.com$ABC-123$$1",
+		"LPA:1$smdp.example.com$ABC-123$1.3.6.1.4.1.31746$1",
+		"LPA:1$smdp.example.com$ABC-123$$$future",
+	}
+	for _, code := range tests {
+		t.Run(code, func(t *testing.T) {
+			ac, err := ParseActivationCode(code)
+			if err != nil {
+				t.Fatalf("ParseActivationCode() error = %v", err)
+			}
+			text, err := ac.MarshalText()
+			if err != nil {
+				t.Fatalf("MarshalText() error = %v", err)
+			}
+			if string(text) != code {
+				t.Errorf("MarshalText() = %q, want %q", text, code)
+			}
+		})
+	}
+}
+
+func TestParseActivationCode(t *testing.T) {
+	ac, err := ParseActivationCode("lpa:1$smdp.example.com:8443$ABC-123$1.3.6.1.4.1.31746$1$x$y")
+	if err != nil {
+		t.Fatalf("ParseActivationCode() error = %v", err)
+	}
+	want := &ActivationCode{
+		SMDP:                     &url.URL{Scheme: "https", Host: "smdp.example.com:8443"},
+		MatchingID:               "ABC-123",
+		OID:                      "1.3.6.1.4.1.31746",
+		ConfirmationCodeRequired: true,
+		Extra:                    []string{"x", "y"},
+	}
+	if !reflect.DeepEqual(ac, want) {
+		t.Errorf("ParseActivationCode() = %+v, want %+v", ac, want)
+	}
+	if _, err := ParseActivationCode("1$smdp.example.com$ABC-123"); err != nil {
+		t.Errorf("ParseActivationCode() error = %v for the bare form", err)
+	}
+}
+
+func TestParseActivationCodeReportsFields(t *testing.T) {
+	tests := []struct {
+		code   string
+		fields []ActivationCodeField
+	}{
+		{"LPA:2$smdp.example.com$ABC", []ActivationCodeField{ActivationCodeFieldFormat}},
+		{"LPA:1$-smdp.example.com$ABC", []ActivationCodeField{ActivationCodeFieldSMDPAddress}},
+		{"LPA:1$smdp.example.com:0$ABC", []ActivationCodeField{ActivationCodeFieldSMDPAddress}},
+		{"LPA:1$smdp..example.com$AB C$1.x$2", []ActivationCodeField{
+			ActivationCodeFieldSMDPAddress,
+			ActivationCodeFieldMatchingID,
+			ActivationCodeFieldOID,
+			ActivationCodeFieldConfirmationCodeRequired,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			_, err := ParseActivationCode(tt.code)
+			if err == nil {
+				t.Fatal("ParseActivationCode() error = nil")
+			}
+			var fields []ActivationCodeField
+			for _, err := range unwrapJoined(err) {
+				var acErr *ActivationCodeError
+				if !errors.As(err, &acErr) {
+					t.Fatalf("error %v is not an *ActivationCodeError", err)
+				}
+				fields = append(fields, acErr.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func unwrapJoined(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+func TestActivationCodeMarshalTextRejectsScheme(t *testing.T) {
+	ac := &ActivationCode{SMDP: &url.URL{Scheme: "http", Host: "smdp.example.com"}}
+	var acErr *ActivationCodeError
+	if _, err := ac.MarshalText(); !errors.As(err, &acErr) || acErr.Field != ActivationCodeFieldSMDPAddress {
+		t.Errorf("MarshalText() error = %v, want SM-DP+ address error", err)
+	}
+}
+
+func TestActivationCodeMarshalTextWithConfirmationCode(t *testing.T) {
+	ac := &ActivationCode{
+		SMDP:             &url.URL{Scheme: "https", Host: "smdp.example.com"},
+		MatchingID:       "ABC",
+		ConfirmationCode: "1234",
+	}
+	text, err := ac.MarshalText()
+	if err != nil {
+		t.Fatalf("MarshalText() error = %v", err)
+	}
+	if want := "LPA:1$smdp.example.com$ABC$$1"; string(text) != want {
+		t.Errorf("MarshalText() = %q, want %q", text, want)
+	}
+}
@@ -0,0 +1,245 @@
+package lpa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/damonto/euicc-go/bertlv"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// NotificationArchive keeps a copy of every notification the client removes from the eUICC,
+// and of every replayed one, so the signed notification survives its removal.
+type NotificationArchive interface {
+	Archive(ctx context.Context, notification *ArchivedNotification) error
+}
+
+// ArchivedNotification is a pending notification together with the result of sending it.
+type ArchivedNotification struct {
+	// PendingNotification is the signed notification as retrieved from the eUICC.
+	PendingNotification *bertlv.TLV
+	Notification        *sgp22.NotificationMetadata
+	// SentAt is the time the notification was last sent to the SM-DP+, zero if it was not.
+	SentAt time.Time
+	// StatusCode is the HTTP status code the SM-DP+ answered with, zero if it did not answer.
+	StatusCode int
+	// Error is the error sending the notification, empty if it was delivered.
+	Error string
+}
+
+// Delivered reports whether the SM-DP+ accepted the notification.
+func (n *ArchivedNotification) Delivered() bool {
+	return n.Error == ""
+}
+
+// archivedNotificationJSON is the serialised form of an ArchivedNotification.
+// The metadata is exported for readability and decoded again from the pending notification.
+type archivedNotificationJSON struct {
+	SequenceNumber      sgp22.SequenceNumber `json:"seqNumber"`
+	Operation           string               `json:"profileManagementOperation"`
+	Address             string               `json:"notificationAddress"`
+	ICCID               string               `json:"iccid,omitempty"`
+	SentAt              time.Time            `json:"sentAt"`
+	Delivered           bool                 `json:"delivered"`
+	StatusCode          int                  `json:"statusCode,omitempty"`
+	Error               string               `json:"error,omitempty"`
+	PendingNotification *bertlv.TLV          `json:"pendingNotification"`
+}
+
+var notificationOperations = map[sgp22.NotificationEvent]string{
+	sgp22.NotificationEventInstall: "notificationInstall",
+	sgp22.NotificationEventEnable:  "notificationEnable",
+	sgp22.NotificationEventDisable: "notificationDisable",
+	sgp22.NotificationEventDelete:  "notificationDelete",
+}
+
+// MarshalJSON serialises the archived notification.
+func (n *ArchivedNotification) MarshalJSON() ([]byte, error) {
+	if n.PendingNotification == nil || n.Notification == nil {
+		return nil, errors.New("pending notification is required")
+	}
+	var iccid string
+	if n.Notification.ICCID != nil {
+		iccid = n.Notification.ICCID.String()
+	}
+	return json.Marshal(&archivedNotificationJSON{
+		SequenceNumber:      n.Notification.SequenceNumber,
+		Operation:           notificationOperations[n.Notification.ProfileManagementOperation],
+		Address:             n.Notification.Address,
+		ICCID:               iccid,
+		SentAt:              n.SentAt,
+		Delivered:           n.Delivered(),
+		StatusCode:          n.StatusCode,
+		Error:               n.Error,
+		PendingNotification: n.PendingNotification,
+	})
+}
+
+// UnmarshalJSON restores an archived notification serialised with [json.Marshal].
+func (n *ArchivedNotification) UnmarshalJSON(data []byte) error {
+	var archived archivedNotificationJSON
+	if err := json.Unmarshal(data, &archived); err != nil {
+		return err
+	}
+	if archived.PendingNotification == nil {
+		return errors.New("pending notification is required")
+	}
+	var pending sgp22.PendingNotification
+	if err := pending.UnmarshalBERTLV(archived.PendingNotification); err != nil {
+		return err
+	}
+	*n = ArchivedNotification{
+		PendingNotification: pending.PendingNotification,
+		Notification:        pending.Notification,
+		SentAt:              archived.SentAt,
+		StatusCode:          archived.StatusCode,
+		Error:               archived.Error,
+	}
+	return nil
+}
+
+// ReplayNotification sends an archived notification to the SM-DP+ at address,
+// or to the address in its metadata if address is nil. The replay is archived as well.
+func (c *Client) ReplayNotification(ctx context.Context, notification *ArchivedNotification, address *url.URL) error {
+	if address == nil {
+		address = &url.URL{Scheme: "https", Host: notification.Notification.Address}
+	}
+	archived, err := c.handleNotification(ctx, address, &sgp22.PendingNotification{
+		PendingNotification: notification.PendingNotification,
+		Notification:        notification.Notification,
+	})
+	if c.archive != nil {
+		return errors.Join(err, c.archiveNotification(ctx, archived))
+	}
+	return err
+}
+
+// archiveRemoved archives a notification about to be removed from the eUICC with the result of
+// the last attempt to send it. A notification this client did not send is retrieved from the eUICC.
+func (c *Client) archiveRemoved(ctx context.Context, sequenceNumber sgp22.SequenceNumber) error {
+	archived := c.sent[sequenceNumber]
+	if archived == nil {
+		pending, err := c.RetrieveNotificationList(ctx, sequenceNumber)
+		if err != nil {
+			return fmt.Errorf("retrieve notification %d: %w", sequenceNumber, err)
+		}
+		if len(pending) == 0 {
+			// The eUICC has nothing to remove either.
+			return nil
+		}
+		archived = &ArchivedNotification{
+			PendingNotification: pending[0].PendingNotification,
+			Notification:        pending[0].Notification,
+			Error:               "removed without being sent by the client",
+		}
+	}
+	return c.archiveNotification(ctx, archived)
+}
+
+func (c *Client) archiveNotification(ctx context.Context, archived *ArchivedNotification) error {
+	if err := c.archive.Archive(context.WithoutCancel(ctx), archived); err != nil {
+		return fmt.Errorf("archive notification %d: %w", archived.Notification.SequenceNumber, err)
+	}
+	return nil
+}
+
+// maxSent bounds the results kept for the notifications sent and not removed yet.
+// The eUICC keeps few notifications, so the ones with the lowest sequence numbers are dropped.
+const maxSent = 32
+
+// keepSent keeps the result of sending a notification until it is removed from the eUICC.
+func (c *Client) keepSent(archived *ArchivedNotification) {
+	if c.sent == nil {
+		c.sent = make(map[sgp22.SequenceNumber]*ArchivedNotification)
+	}
+	c.sent[archived.Notification.SequenceNumber] = archived
+	if len(c.sent) > maxSent {
+		delete(c.sent, slices.Min(slices.Collect(maps.Keys(c.sent))))
+	}
+}
+
+// FileArchive appends archived notifications to a JSON Lines file. It is safe for concurrent use.
+type FileArchive struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileArchive returns an archive appending to the JSON Lines file at path.
+func NewFileArchive(path string) *FileArchive {
+	return &FileArchive{path: path}
+}
+
+// Archive appends the notification to the file and syncs it to disk.
+func (a *FileArchive) Archive(_ context.Context, notification *ArchivedNotification) (err error) {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	fp, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, fp.Close())
+	}()
+	if _, err = fp.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return fp.Sync()
+}
+
+// Notifications returns the archived notifications, oldest first.
+func (a *FileArchive) Notifications() (notifications []*ArchivedNotification, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	fp, err := os.Open(a.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = errors.Join(err, fp.Close())
+	}()
+	decoder := json.NewDecoder(fp)
+	for {
+		notification := new(ArchivedNotification)
+		if err := decoder.Decode(notification); errors.Is(err, io.EOF) {
+			return notifications, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("archived notification %d: %w", len(notifications)+1, err)
+		}
+		notifications = append(notifications, notification)
+	}
+}
+
+// Export writes the archive to w as JSON Lines, one archived notification per line.
+func (a *FileArchive) Export(w io.Writer) (err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	fp, err := os.Open(a.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, fp.Close())
+	}()
+	_, err = io.Copy(w, fp)
+	return err
+}
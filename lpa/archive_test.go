@@ -0,0 +1,123 @@
+package lpa
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/http"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+func TestHandleNotificationArchives(t *testing.T) {
+	status := nethttp.StatusServiceUnavailable
+	var bodies []string
+	server := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	address, _ := url.Parse(server.URL)
+	archive := NewFileArchive(filepath.Join(t.TempDir(), "notifications.jsonl"))
+	transmitter := new(fakeTransmitter)
+	client := &Client{
+		APDU:    transmitter,
+		HTTP:    &http.Client{Client: server.Client(), AdminProtocolVersion: "2.5.0"},
+		archive: archive,
+	}
+	metadata := notificationMetadata(t, 9, sgp22.NotificationEventDisable, address.Host)
+	metadata.Children = append(metadata.Children, bertlv.NewValue(bertlv.Application.Primitive(26), []byte{0x98, 0x10, 0x32, 0x54, 0x76, 0x98, 0x10, 0x32, 0x54, 0xF6}))
+	var pending sgp22.PendingNotification
+	if err := pending.UnmarshalBERTLV(bertlv.NewChildren(
+		bertlv.Universal.Constructed(16),
+		metadata,
+		bertlv.NewValue(bertlv.Application.Primitive(55), []byte{0x01, 0x02}),
+	)); err != nil {
+		t.Fatalf("PendingNotification.UnmarshalBERTLV() error = %v", err)
+	}
+
+	if err := client.HandleNotification(t.Context(), &pending); err == nil {
+		t.Fatal("HandleNotification() error = nil, want status code error")
+	}
+	status = nethttp.StatusNoContent
+	if err := client.HandleNotification(t.Context(), &pending); err != nil {
+		t.Fatalf("HandleNotification() error = %v", err)
+	}
+	if notifications, err := archive.Notifications(); err != nil || len(notifications) != 0 {
+		t.Fatalf("Notifications() = %v, %v, want none before the removal", notifications, err)
+	}
+
+	// The notification is archived with the last result when it is removed, and so is
+	// one removed without being sent.
+	removed := notificationResponses(t, 10, sgp22.NotificationEventDelete, address.Host)
+	transmitter.responses = []*bertlv.TLV{removed[1], removed[0], removed[1]}
+	if err := client.RemoveNotificationFromList(t.Context(), 9); err != nil {
+		t.Fatalf("RemoveNotificationFromList() error = %v", err)
+	}
+	if err := client.RemoveNotificationFromList(t.Context(), 10); err != nil {
+		t.Fatalf("RemoveNotificationFromList() error = %v", err)
+	}
+	notifications, err := archive.Notifications()
+	if err != nil {
+		t.Fatalf("Notifications() error = %v", err)
+	}
+	if len(notifications) != 2 {
+		t.Fatalf("Notifications() = %d notifications, want 2", len(notifications))
+	}
+	if got := notifications[0]; !got.Delivered() || got.SentAt.IsZero() || got.StatusCode != nethttp.StatusNoContent {
+		t.Errorf("sent notification = %+v, want delivered with status code 204", got)
+	}
+	archived, _ := notifications[0].PendingNotification.MarshalBinary()
+	want, _ := pending.PendingNotification.MarshalBinary()
+	if got := notifications[0]; !bytes.Equal(archived, want) ||
+		got.Notification.SequenceNumber != 9 || got.Notification.ICCID.String() != "8901234567890123456" {
+		t.Errorf("archived notification = %+v, want the pending notification", got.Notification)
+	}
+	if got := notifications[1]; got.Delivered() || !got.SentAt.IsZero() || got.Notification.SequenceNumber != 10 {
+		t.Errorf("removed notification = %+v, want sequence number 10 not sent", got)
+	}
+
+	var export bytes.Buffer
+	if err := archive.Export(&export); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(export.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Export() = %d lines, want 2", len(lines))
+	}
+	var line map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if line["profileManagementOperation"] != "notificationDisable" || line["iccid"] != "8901234567890123456" || line["statusCode"] != float64(204) {
+		t.Errorf("Export() line = %s", lines[0])
+	}
+
+	if err := client.ReplayNotification(t.Context(), notifications[0], nil); err != nil {
+		t.Fatalf("ReplayNotification() error = %v", err)
+	}
+	if len(bodies) != 3 || bodies[2] != bodies[1] {
+		t.Errorf("replayed request = %q, want %q", bodies[len(bodies)-1], bodies[1])
+	}
+	if notifications, err = archive.Notifications(); err != nil || len(notifications) != 3 || !notifications[2].Delivered() {
+		t.Errorf("Notifications() = %v, %v, want the replay archived", notifications, err)
+	}
+}
+
+func TestKeepSentDropsOldest(t *testing.T) {
+	var client Client
+	for sequenceNumber := range sgp22.SequenceNumber(maxSent + 2) {
+		client.keepSent(&ArchivedNotification{Notification: &sgp22.NotificationMetadata{SequenceNumber: sequenceNumber}})
+	}
+	if len(client.sent) != maxSent || client.sent[0] != nil || client.sent[1] != nil || client.sent[maxSent+1] == nil {
+		t.Errorf("sent = %d results, want the last %d", len(client.sent), maxSent)
+	}
+}
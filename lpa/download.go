@@ -4,87 +4,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/url"
-	"strings"
 
 	"github.com/damonto/euicc-go/bertlv"
 	"github.com/damonto/euicc-go/bertlv/primitive"
 	sgp22 "github.com/damonto/euicc-go/v2"
 )
 
-// ActivationCode represents the activation code for downloading a profile.
-//
-// See https://aka.pw/sgp22/v2.5#page=113 (Section 4.1 Activation Code)
-type ActivationCode struct {
-	SMDP             *url.URL
-	MatchingID       string
-	IMEI             string
-	OID              string
-	ConfirmationCode string
-}
-
-func (ac *ActivationCode) MarshalText() ([]byte, error) {
-	if ac.SMDP == nil {
-		return nil, errors.New("SM-DP+ is required")
-	}
-	b := []byte("LPA:1$")
-	b = append(append(b, ac.SMDP.Host...), '$')
-	if ac.MatchingID != "" {
-		b = append(b, ac.MatchingID...)
-	}
-	if ac.OID != "" {
-		b = append(append(b, '$'), ac.OID...)
-	}
-	if ac.ConfirmationCode != "" {
-		if ac.OID == "" {
-			b = append(b, '$')
-		}
-		b = append(b, '$', '1')
-	}
-	return b, nil
-}
-
-func (ac *ActivationCode) UnmarshalText(text []byte) error {
-	if text == nil {
-		return errors.New("activation code is required")
-	}
-	code := string(text)
-	if !strings.HasPrefix(code, "LPA:1") {
-		return errors.New("invalid activation code format")
-	}
-	parts := strings.Split(code, "$")
-	if len(parts) < 2 {
-		return errors.New("invalid activation code format")
-	}
-	var err error
-	if ac.SMDP, err = url.Parse("https://" + parts[1]); err != nil {
-		return err
-	}
-	if len(parts) > 2 {
-		ac.MatchingID = parts[2]
-	}
-	if len(parts) > 3 {
-		ac.OID = parts[3]
-	}
-	return nil
-}
-
-func (ac *ActivationCode) validate() error {
-	if ac.SMDP == nil || ac.SMDP.Host == "" {
-		return errors.New("SM-DP+ is required")
-	}
-	if ac.IMEI == "" {
-		return errors.New("IMEI is required")
-	}
-	return nil
-}
-
+// DownloadStage is a stage of a profile download reported to OnProgress.
+// The stages are reported in the order of their values, so they can be compared with <.
 type DownloadStage uint8
 
 const (
 	DownloadStageAuthenticateClient DownloadStage = iota
 	DownloadStageAuthenticateServer
+	// DownloadStageGetBoundProfilePackage is reported while the bound profile package is fetched from the SM-DP+.
+	DownloadStageGetBoundProfilePackage
 	DownloadStageInstall
+	// DownloadStageLoadProfileElements is reported when the eUICC starts loading the profile elements,
+	// which is the largest part of the bound profile package.
+	DownloadStageLoadProfileElements
+	// DownloadStageSendNotification is reported while the install notification is sent to the SM-DP+,
+	// which the client does with [NotificationPolicyImmediate] only.
+	DownloadStageSendNotification
 )
 
 // String returns a string representation of the DownloadStage.
@@ -94,94 +35,113 @@ func (s DownloadStage) String() string {
 		return "Authenticating Client"
 	case DownloadStageAuthenticateServer:
 		return "Authenticating Server"
+	case DownloadStageGetBoundProfilePackage:
+		return "Fetching Bound Profile Package"
 	case DownloadStageInstall:
 		return "Installing"
+	case DownloadStageLoadProfileElements:
+		return "Loading Profile Elements"
+	case DownloadStageSendNotification:
+		return "Sending Notification"
 	default:
 		return fmt.Sprintf("Unknown Stage (%d)", s)
 	}
 }
 
+// InstallProgress is the progress of loading the bound profile package onto the eUICC.
+type InstallProgress struct {
+	// Command is the BPP command of the last segment sent.
+	Command sgp22.BPPCommandID
+	// Segments is the number of segments sent out of TotalSegments.
+	Segments      int
+	TotalSegments int
+	// Bytes is the number of bytes sent out of TotalBytes.
+	Bytes      int
+	TotalBytes int
+}
+
 // DownloadOptions provides user interaction callbacks during profile download.
 type DownloadOptions struct {
-	OnProgress              func(stage DownloadStage)
+	// OnProgress is called when the download enters a stage. The last stage is DownloadStageSendNotification
+	// with NotificationPolicyImmediate, and DownloadStageLoadProfileElements with the other policies.
+	OnProgress func(stage DownloadStage)
+	// OnInstallProgress is called after each segment of the bound profile package is sent to the eUICC.
+	OnInstallProgress       func(progress InstallProgress)
 	OnConfirm               func(metadata *sgp22.ProfileInfo) bool
 	OnEnterConfirmationCode func() string
+	// CheckPolicyRules checks the Profile Policy Rules of the profile against the
+	// Rules Authorisation Table of the eUICC before the profile is downloaded.
+	CheckPolicyRules bool
+	// OnConsentRequired is called when the RAT requires end user consent for the
+	// Profile Policy Rules of the profile. The download is rejected if it is nil or returns false.
+	OnConsentRequired func(metadata *sgp22.ProfileInfo) bool
 }
 
 // DownloadProfile downloads a profile using the provided activation code and options.
+// It runs the steps of a [DownloadSession], asking for confirmation through the callbacks of opts.
 func (c *Client) DownloadProfile(ctx context.Context, ac *ActivationCode, opts *DownloadOptions) (*sgp22.LoadBoundProfilePackageResponse, error) {
-	if err := ac.validate(); err != nil {
-		return nil, err
-	}
-
-	if opts != nil && opts.OnProgress != nil {
-		opts.OnProgress(DownloadStageAuthenticateClient)
+	session := c.NewDownloadSession(ac)
+	if opts != nil {
+		session.OnProgress = opts.OnProgress
+		session.OnInstallProgress = opts.OnInstallProgress
 	}
-
-	clientResponse, metadata, ccRequired, err := c.authenticateClient(ac)
+	metadata, err := session.Start(ctx)
 	if err != nil {
-		if clientResponse != nil && clientResponse.FunctionExecutionStatus().ExecutedSuccess() {
-			return nil, c.abort(ac, clientResponse.TransactionID, err, sgp22.CancelSessionReasonMetadataMismatch)
-		}
 		return nil, err
 	}
 
-	if c.isCanceled(ctx) || (opts != nil && opts.OnConfirm != nil && !opts.OnConfirm(metadata)) {
-		_, err := c.cancelSession(ac, clientResponse.TransactionID, sgp22.CancelSessionReasonPostponed)
-		return nil, err
-	}
-
-	if ccRequired && ac.ConfirmationCode == "" {
-		if opts != nil && opts.OnEnterConfirmationCode != nil {
-			ac.ConfirmationCode = opts.OnEnterConfirmationCode()
+	if opts != nil && opts.CheckPolicyRules {
+		consentRequired, err := c.checkPolicyRules(ctx, metadata)
+		if err != nil {
+			reason := sgp22.CancelSessionReasonUndefined
+			if errors.Is(err, sgp22.ErrPPRNotAllowed) {
+				reason = sgp22.CancelSessionReasonPPRNotAllowed
+			}
+			return nil, session.abort(ctx, err, reason)
 		}
-		if ac.ConfirmationCode == "" {
-			return nil, c.abort(
-				ac,
-				clientResponse.TransactionID,
-				errors.New("confirmation code is required"),
-				sgp22.CancelSessionReasonPostponed,
-			)
+		if consentRequired && (opts.OnConsentRequired == nil || !opts.OnConsentRequired(metadata)) {
+			return nil, session.Cancel(ctx, sgp22.CancelSessionReasonEndUserRejection)
 		}
 	}
 
-	if opts != nil && opts.OnProgress != nil {
-		opts.OnProgress(DownloadStageAuthenticateServer)
-	}
-	if c.isCanceled(ctx) {
-		_, err := c.cancelSession(ac, clientResponse.TransactionID, sgp22.CancelSessionReasonPostponed)
-		return nil, err
-	}
-	serverResponse, err := c.authenticateServer(ac, clientResponse)
-	if err != nil {
-		return nil, c.abort(ac, clientResponse.TransactionID, err, sgp22.CancelSessionReasonPostponed)
+	if c.isCanceled(ctx) || (opts != nil && opts.OnConfirm != nil && !opts.OnConfirm(metadata)) {
+		return nil, session.Cancel(context.WithoutCancel(ctx), sgp22.CancelSessionReasonPostponed)
 	}
 
-	if opts != nil && opts.OnProgress != nil {
-		opts.OnProgress(DownloadStageInstall)
-	}
-	if c.isCanceled(ctx) {
-		_, err := c.cancelSession(ac, serverResponse.TransactionID, sgp22.CancelSessionReasonPostponed)
-		return nil, err
+	var code string
+	if session.ConfirmationCodeRequired() && ac.ConfirmationCode == "" && opts != nil && opts.OnEnterConfirmationCode != nil {
+		code = opts.OnEnterConfirmationCode()
 	}
-	result, err := c.install(serverResponse)
-	if err != nil {
-		return result, c.abort(ac, serverResponse.TransactionID, err, sgp22.CancelSessionReasonLoadBppExecutionError)
+	if err := session.Confirm(code); err != nil {
+		return nil, session.abort(ctx, err, sgp22.CancelSessionReasonPostponed)
 	}
-	return result, nil
+	return session.Install(ctx)
 }
 
-func (c *Client) install(bppResponse *sgp22.ES9BoundProfilePackageResponse) (*sgp22.LoadBoundProfilePackageResponse, error) {
-	segments, err := sgp22.SegmentedBoundProfilePackage(bppResponse.BoundProfilePackage)
+// install loads the bound profile package onto the eUICC segment by segment,
+// reporting the Loading Profile Elements stage and the progress of each segment.
+func (c *Client) install(ctx context.Context, bppResponse *sgp22.ES9BoundProfilePackageResponse, onStage func(DownloadStage), onProgress func(InstallProgress)) (*sgp22.LoadBoundProfilePackageResponse, error) {
+	segments, err := sgp22.BoundProfilePackageSegments(bppResponse.BoundProfilePackage)
 	if err != nil {
 		return nil, err
 	}
+	progress := InstallProgress{TotalSegments: len(segments)}
+	for _, segment := range segments {
+		progress.TotalBytes += len(segment.Data)
+	}
 	var r []byte
-	for _, command := range segments {
-		r, err = sgp22.InvokeRawAPDU(c.APDU, command)
+	for _, segment := range segments {
+		if segment.Command == sgp22.BPPCommandIDLoadProfileElements && progress.Command != segment.Command {
+			onStage(DownloadStageLoadProfileElements)
+		}
+		r, err = sgp22.InvokeRawAPDU(ctx, c.APDU, segment.Data)
 		if err != nil {
 			return nil, err
 		}
+		progress.Command = segment.Command
+		progress.Segments++
+		progress.Bytes += len(segment.Data)
+		onProgress(progress)
 		if len(r) > 0 {
 			break
 		}
@@ -197,19 +157,21 @@ func (c *Client) install(bppResponse *sgp22.ES9BoundProfilePackageResponse) (*sg
 	return &response, response.Valid()
 }
 
-func (c *Client) authenticateServer(ac *ActivationCode, clientResponse *sgp22.ES9AuthenticateClientResponse) (*sgp22.ES9BoundProfilePackageResponse, error) {
-	return c.PrepareDownload(ac.SMDP, &sgp22.PrepareDownloadRequest{
+// authenticateServer prepares the download with the eUICC and fetches the bound profile package,
+// reporting the Fetching Bound Profile Package stage in between.
+func (c *Client) authenticateServer(ctx context.Context, ac *ActivationCode, clientResponse *sgp22.ES9AuthenticateClientResponse, onStage func(DownloadStage)) (*sgp22.ES9BoundProfilePackageResponse, error) {
+	return c.prepareDownload(ctx, ac.SMDP, &sgp22.PrepareDownloadRequest{
 		TransactionID:    clientResponse.TransactionID,
 		ProfileMetadata:  clientResponse.ProfileMetadata,
 		Signed2:          clientResponse.Signed2,
 		Signature2:       clientResponse.Signature2,
 		Certificate:      clientResponse.Certificate,
 		ConfirmationCode: []byte(ac.ConfirmationCode),
-	})
+	}, func() { onStage(DownloadStageGetBoundProfilePackage) })
 }
 
-func (c *Client) authenticateClient(ac *ActivationCode) (*sgp22.ES9AuthenticateClientResponse, *sgp22.ProfileInfo, bool, error) {
-	initiateAuthenticationResponse, err := c.InitiateAuthentication(ac.SMDP)
+func (c *Client) authenticateClient(ctx context.Context, ac *ActivationCode) (*sgp22.ES9AuthenticateClientResponse, *sgp22.ProfileInfo, bool, error) {
+	initiateAuthenticationResponse, err := c.InitiateAuthentication(ctx, ac.SMDP)
 	if err != nil {
 		return nil, nil, false, err
 	}
@@ -217,7 +179,7 @@ func (c *Client) authenticateClient(ac *ActivationCode) (*sgp22.ES9AuthenticateC
 	if err != nil {
 		return nil, nil, false, err
 	}
-	response, err := c.AuthenticateClient(ac.SMDP, &sgp22.AuthenticateServerRequest{
+	response, err := c.AuthenticateClient(ctx, ac.SMDP, &sgp22.AuthenticateServerRequest{
 		TransactionID: initiateAuthenticationResponse.TransactionID,
 		Signed1:       initiateAuthenticationResponse.Signed1,
 		Signature1:    initiateAuthenticationResponse.Signature1,
@@ -257,6 +219,32 @@ func (c *Client) profileMetadata(tlv *bertlv.TLV) (*sgp22.ProfileInfo, error) {
 	return profileInfo, nil
 }
 
+// checkPolicyRules reports whether the end user has to consent to the Profile Policy Rules
+// of the profile, or an error wrapping [sgp22.ErrPPRNotAllowed] if the RAT does not allow them.
+func (c *Client) checkPolicyRules(ctx context.Context, metadata *sgp22.ProfileInfo) (bool, error) {
+	rules := metadata.ProfilePolicyRules
+	if !rules.DisablingNotAllowed && !rules.DeletionNotAllowed {
+		return false, nil
+	}
+	rat, err := c.RulesAuthorisationTable(ctx)
+	if err != nil {
+		return false, err
+	}
+	return rat.Allows(rules, &metadata.ProfileOwner)
+}
+
+// verificationCancelReason maps a failed verification to the reason used to cancel the session.
+func verificationCancelReason(err error, fallback sgp22.CancelSessionReason) sgp22.CancelSessionReason {
+	var verificationError *VerificationError
+	if !errors.As(err, &verificationError) {
+		return fallback
+	}
+	if verificationError.Reason == VerificationReasonMetadata {
+		return sgp22.CancelSessionReasonMetadataMismatch
+	}
+	return sgp22.CancelSessionReasonUndefined
+}
+
 func (c *Client) isCanceled(ctx context.Context) bool {
 	select {
 	case <-ctx.Done():
@@ -266,21 +254,22 @@ func (c *Client) isCanceled(ctx context.Context) bool {
 	}
 }
 
-func (c *Client) abort(ac *ActivationCode, transactionID []byte, err error, cancelReason sgp22.CancelSessionReason) error {
-	_, cancelErr := c.cancelSession(ac, transactionID, cancelReason)
+// abort cancels the session after err, even if err was caused by the cancellation of ctx.
+func (c *Client) abort(ctx context.Context, ac *ActivationCode, transactionID []byte, err error, cancelReason sgp22.CancelSessionReason) error {
+	_, cancelErr := c.cancelSession(context.WithoutCancel(ctx), ac, transactionID, cancelReason)
 	if cancelErr != nil {
 		return fmt.Errorf("%w (cancel session error: %v)", err, cancelErr)
 	}
 	return err
 }
 
-func (c *Client) cancelSession(ac *ActivationCode, transactionID []byte, reason sgp22.CancelSessionReason) (*sgp22.ES9CancelSessionResponse, error) {
-	cancelSessionRequest, err := sgp22.InvokeAPDU(c.APDU, &sgp22.CancelSessionRequest{
+func (c *Client) cancelSession(ctx context.Context, ac *ActivationCode, transactionID []byte, reason sgp22.CancelSessionReason) (*sgp22.ES9CancelSessionResponse, error) {
+	cancelSessionRequest, err := sgp22.InvokeAPDU(ctx, c.APDU, &sgp22.CancelSessionRequest{
 		TransactionID: transactionID,
 		Reason:        reason,
 	})
 	if err != nil {
 		return nil, err
 	}
-	return sgp22.InvokeHTTP(c.HTTP, ac.SMDP, cancelSessionRequest)
+	return sgp22.InvokeHTTP(ctx, c.HTTP, ac.SMDP, cancelSessionRequest)
 }
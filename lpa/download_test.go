@@ -1,9 +1,20 @@
 package lpa
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"net/url"
+	"slices"
 	"testing"
 
 	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/driver/virtual"
+	"github.com/damonto/euicc-go/http"
+	"github.com/damonto/euicc-go/rsptest"
+	sgp22 "github.com/damonto/euicc-go/v2"
 )
 
 func TestConfirmationCodeRequiredUsesBooleanTag(t *testing.T) {
@@ -21,3 +32,321 @@ func TestConfirmationCodeRequiredUsesBooleanTag(t *testing.T) {
 		t.Error("confirmationCodeRequired() = false, want true")
 	}
 }
+
+var errUnexpectedRequest = errors.New("unexpected request")
+
+type fakeTransmitter struct {
+	responses []*bertlv.TLV
+	requests  []*bertlv.TLV
+}
+
+func (f *fakeTransmitter) Transmit(_ context.Context, request bertlv.Marshaler, response bertlv.Unmarshaler) error {
+	tlv, err := request.MarshalBERTLV()
+	if err != nil {
+		return err
+	}
+	f.requests = append(f.requests, tlv)
+	if len(f.responses) == 0 {
+		return errUnexpectedRequest
+	}
+	next := f.responses[0]
+	f.responses = f.responses[1:]
+	return response.UnmarshalBERTLV(next)
+}
+
+func (f *fakeTransmitter) TransmitRaw(context.Context, []byte) ([]byte, error) {
+	return nil, errors.New("unexpected raw request")
+}
+
+// testRAT returns a RAT allowing the operator 130014, only, to set ppr1 with the consent of the end user.
+func testRAT() *bertlv.TLV {
+	return bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(67),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(0),
+			bertlv.NewChildren(
+				bertlv.Universal.Constructed(16),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{0x06, 0x40}),
+				bertlv.NewChildren(
+					bertlv.ContextSpecific.Constructed(1),
+					bertlv.NewChildren(
+						bertlv.Universal.Constructed(16),
+						bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{0x13, 0x00, 0x14}),
+					),
+				),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), []byte{0x07, 0x80}),
+			),
+		),
+	)
+}
+
+func TestCheckPolicyRules(t *testing.T) {
+	rat := testRAT()
+	owner := sgp22.OperatorId{PLMN: []byte{0x13, 0x00, 0x14}}
+
+	transmitter := &fakeTransmitter{responses: []*bertlv.TLV{rat}}
+	client := &Client{APDU: transmitter}
+	consentRequired, err := client.checkPolicyRules(t.Context(), &sgp22.ProfileInfo{
+		ProfileOwner:       owner,
+		ProfilePolicyRules: sgp22.ProfilePolicyRules{DisablingNotAllowed: true},
+	})
+	if err != nil {
+		t.Fatalf("checkPolicyRules(ppr1) error = %v", err)
+	}
+	if !consentRequired {
+		t.Error("checkPolicyRules(ppr1) = false, want true")
+	}
+
+	transmitter.responses = []*bertlv.TLV{rat}
+	if _, err := client.checkPolicyRules(t.Context(), &sgp22.ProfileInfo{
+		ProfileOwner:       owner,
+		ProfilePolicyRules: sgp22.ProfilePolicyRules{DeletionNotAllowed: true},
+	}); !errors.Is(err, sgp22.ErrPPRNotAllowed) {
+		t.Errorf("checkPolicyRules(ppr2) error = %v, want PPR not allowed", err)
+	}
+}
+
+func TestCheckPolicyRulesSkipsRATWithoutRules(t *testing.T) {
+	transmitter := new(fakeTransmitter)
+	client := &Client{APDU: transmitter}
+
+	consentRequired, err := client.checkPolicyRules(t.Context(), &sgp22.ProfileInfo{
+		ProfilePolicyRules: sgp22.ProfilePolicyRules{UpdateControl: true},
+	})
+	if err != nil || consentRequired {
+		t.Errorf("checkPolicyRules() = %t, %v, want false, nil", consentRequired, err)
+	}
+	if len(transmitter.requests) != 0 {
+		t.Errorf("checkPolicyRules() sent %d requests, want 0", len(transmitter.requests))
+	}
+}
+
+type fakeRawTransmitter struct {
+	fakeTransmitter
+	commands [][]byte
+	response []byte
+}
+
+// TransmitRaw answers the last command with the response, and the others with no data.
+func (f *fakeRawTransmitter) TransmitRaw(_ context.Context, command []byte) ([]byte, error) {
+	f.commands = append(f.commands, command)
+	if len(f.commands) < 6 {
+		return nil, nil
+	}
+	return f.response, nil
+}
+
+func TestInstallReportsProgress(t *testing.T) {
+	transactionID := []byte{0x01, 0x02}
+	bpp := bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(54),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(35)),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(0),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(7), []byte{0x01}),
+		),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(1),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(8), []byte{0x02}),
+		),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(3),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(6), []byte{0x03}),
+		),
+	)
+	result, err := bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(55),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(39),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), transactionID),
+			bertlv.NewChildren(
+				bertlv.ContextSpecific.Constructed(47),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{0x01}),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{0x07, 0x80}),
+				bertlv.NewValue(bertlv.Universal.Primitive(12), []byte("smdp.example.com")),
+			),
+			bertlv.NewChildren(
+				bertlv.ContextSpecific.Constructed(2),
+				bertlv.NewChildren(
+					bertlv.ContextSpecific.Constructed(0),
+					bertlv.NewValue(bertlv.Application.Primitive(15), []byte{0xA0, 0x00}),
+				),
+			),
+		),
+	).Bytes()
+	if err != nil {
+		t.Fatalf("TLV.Bytes() error = %v", err)
+	}
+	transmitter := &fakeRawTransmitter{response: result}
+	client := &Client{APDU: transmitter}
+
+	var stages []DownloadStage
+	var progress []InstallProgress
+	response, err := client.install(
+		t.Context(),
+		&sgp22.ES9BoundProfilePackageResponse{TransactionID: transactionID, BoundProfilePackage: bpp},
+		func(stage DownloadStage) { stages = append(stages, stage) },
+		func(p InstallProgress) { progress = append(progress, p) },
+	)
+	if err != nil {
+		t.Fatalf("install() error = %v", err)
+	}
+	if !bytes.Equal(response.TransactionID, transactionID) {
+		t.Errorf("install() TransactionID = %X, want %X", response.TransactionID, transactionID)
+	}
+	if len(stages) != 1 || stages[0] != DownloadStageLoadProfileElements {
+		t.Errorf("stages = %v, want [%v]", stages, DownloadStageLoadProfileElements)
+	}
+	var total int
+	for _, command := range transmitter.commands {
+		total += len(command)
+	}
+	if len(progress) != len(transmitter.commands) {
+		t.Fatalf("progress events = %d, want %d", len(progress), len(transmitter.commands))
+	}
+	for index, p := range progress {
+		if p.Segments != index+1 || p.TotalSegments != len(transmitter.commands) || p.TotalBytes != total {
+			t.Errorf("progress %d = %+v", index, p)
+		}
+	}
+	last := progress[len(progress)-1]
+	if last.Bytes != total || last.Command != sgp22.BPPCommandIDLoadProfileElements {
+		t.Errorf("last progress = %+v, want %d bytes of %v", last, total, sgp22.BPPCommandIDLoadProfileElements)
+	}
+}
+
+// ratTransmitter answers GetRAT with its RAT, and passes the other requests to the transmitter.
+type ratTransmitter struct {
+	sgp22.Transmitter
+	rat *bertlv.TLV
+}
+
+func (r *ratTransmitter) Transmit(ctx context.Context, request bertlv.Marshaler, response bertlv.Unmarshaler) error {
+	if _, ok := request.(*sgp22.GetRATRequest); ok {
+		return response.UnmarshalBERTLV(r.rat)
+	}
+	return r.Transmitter.Transmit(ctx, request, response)
+}
+
+// newPolicyDownload returns a client of a virtual eUICC with the RAT of testRAT, and the activation code
+// of an order of the operator 130014 with the policy rules on an SM-DP+ recording the canceled sessions.
+func newPolicyDownload(t *testing.T, rules sgp22.ProfilePolicyRules) (*Client, *ActivationCode, *rsptest.SMDP) {
+	t.Helper()
+	ci, err := rsptest.NewCI()
+	if err != nil {
+		t.Fatalf("NewCI() error = %v", err)
+	}
+	card, err := virtual.New(ci)
+	if err != nil {
+		t.Fatalf("virtual.New() error = %v", err)
+	}
+	smdp, err := rsptest.NewSMDP(ci)
+	if err != nil {
+		t.Fatalf("NewSMDP() error = %v", err)
+	}
+	iccid, _ := sgp22.NewICCID("8944476500001224199")
+	smdp.AddOrder(&rsptest.Order{
+		MatchingID: "MATCHING-ID",
+		Profile: &rsptest.Profile{
+			ICCID:               iccid,
+			ServiceProviderName: "RSPTEST",
+			ProfileName:         "Policy",
+			ProfileClass:        sgp22.ProfileClassOperational,
+			ProfileOwner:        sgp22.OperatorId{PLMN: []byte{0x13, 0x00, 0x14}},
+			ProfilePolicyRules:  rules,
+			Elements:            bytes.Repeat([]byte{0xA0, 0x03, 0x80, 0x01, 0x00}, 10),
+		},
+	})
+	server := httptest.NewTLSServer(smdp)
+	t.Cleanup(server.Close)
+
+	client, err := New(t.Context(), &Options{Channel: card, Logger: slog.New(slog.DiscardHandler)})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	client.APDU = &ratTransmitter{Transmitter: client.APDU, rat: testRAT()}
+	client.HTTP = &http.Client{Client: server.Client(), AdminProtocolVersion: "2.5.0"}
+	address, _ := url.Parse(server.URL)
+	return client, &ActivationCode{SMDP: address, MatchingID: "MATCHING-ID", IMEI: "356938035643809"}, smdp
+}
+
+func TestDownloadProfileConsent(t *testing.T) {
+	tests := []struct {
+		name      string
+		consent   func(*sgp22.ProfileInfo) bool
+		installed bool
+	}{
+		{name: "no callback"},
+		{name: "rejected", consent: func(*sgp22.ProfileInfo) bool { return false }},
+		{name: "given", consent: func(*sgp22.ProfileInfo) bool { return true }, installed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, ac, smdp := newPolicyDownload(t, sgp22.ProfilePolicyRules{DisablingNotAllowed: true})
+			opts := &DownloadOptions{CheckPolicyRules: true}
+			var asked *sgp22.ProfileInfo
+			if tt.consent != nil {
+				opts.OnConsentRequired = func(metadata *sgp22.ProfileInfo) bool {
+					asked = metadata
+					return tt.consent(metadata)
+				}
+			}
+			response, err := client.DownloadProfile(t.Context(), ac, opts)
+			if err != nil {
+				t.Fatalf("DownloadProfile() error = %v", err)
+			}
+			if tt.consent != nil && (asked == nil || asked.ProfileName != "Policy") {
+				t.Errorf("OnConsentRequired() metadata = %+v, want the profile", asked)
+			}
+			canceled := smdp.CanceledSessions()
+			if tt.installed {
+				if response == nil || len(canceled) != 0 {
+					t.Errorf("DownloadProfile() = %+v, canceled %v, want the profile installed", response, canceled)
+				}
+				return
+			}
+			if response != nil || len(canceled) != 1 || canceled[0].Reason != sgp22.CancelSessionReasonEndUserRejection {
+				t.Errorf("DownloadProfile() = %+v, canceled %v, want the session canceled by end user rejection", response, canceled)
+			}
+		})
+	}
+}
+
+func TestDownloadProfileStages(t *testing.T) {
+	client, ac, _ := newPolicyDownload(t, sgp22.ProfilePolicyRules{})
+	var stages []DownloadStage
+	if _, err := client.DownloadProfile(t.Context(), ac, &DownloadOptions{
+		OnProgress: func(stage DownloadStage) { stages = append(stages, stage) },
+	}); err != nil {
+		t.Fatalf("DownloadProfile() error = %v", err)
+	}
+	want := []DownloadStage{
+		DownloadStageAuthenticateClient,
+		DownloadStageAuthenticateServer,
+		DownloadStageGetBoundProfilePackage,
+		DownloadStageInstall,
+		DownloadStageLoadProfileElements,
+	}
+	if !slices.Equal(stages, want) || !slices.IsSorted(stages) {
+		t.Errorf("stages = %v, want %v", stages, want)
+	}
+}
+
+func TestDownloadProfilePPRNotAllowed(t *testing.T) {
+	client, ac, smdp := newPolicyDownload(t, sgp22.ProfilePolicyRules{DeletionNotAllowed: true})
+	response, err := client.DownloadProfile(t.Context(), ac, &DownloadOptions{
+		CheckPolicyRules: true,
+		OnConsentRequired: func(*sgp22.ProfileInfo) bool {
+			t.Error("OnConsentRequired() called for rules the RAT does not allow")
+			return true
+		},
+	})
+	if response != nil || !errors.Is(err, sgp22.ErrPPRNotAllowed) {
+		t.Errorf("DownloadProfile() = %+v, %v, want %v", response, err, sgp22.ErrPPRNotAllowed)
+	}
+	canceled := smdp.CanceledSessions()
+	if len(canceled) != 1 || canceled[0].Reason != sgp22.CancelSessionReasonPPRNotAllowed {
+		t.Errorf("CanceledSessions() = %v, want the session canceled as PPR not allowed", canceled)
+	}
+}
@@ -1,6 +1,8 @@
 package lpa
 
 import (
+	"context"
+
 	"github.com/damonto/euicc-go/v2"
 )
 
@@ -12,8 +14,8 @@ type EUICCConfiguredAddresses struct {
 // EUICCConfiguredAddresses returns the default SM-DP+ address and the root SM-DS address.
 //
 // See https://aka.pw/sgp22/v2.5#page=183 (Section 5.7.3, ES10a.GetEuiccConfiguredAddresses)
-func (c *Client) EUICCConfiguredAddresses() (*EUICCConfiguredAddresses, error) {
-	response, err := sgp22.InvokeAPDU(c.APDU, new(sgp22.EuiccConfiguredAddressesRequest))
+func (c *Client) EUICCConfiguredAddresses(ctx context.Context) (*EUICCConfiguredAddresses, error) {
+	response, err := sgp22.InvokeAPDU(ctx, c.APDU, new(sgp22.EuiccConfiguredAddressesRequest))
 	if err != nil {
 		return nil, err
 	}
@@ -27,8 +29,8 @@ func (c *Client) EUICCConfiguredAddresses() (*EUICCConfiguredAddresses, error) {
 // SetDefaultDPAddress sets the default SM-DP+ address.
 //
 // See https://aka.pw/sgp22/v2.5#page=183 (Section 5.7.4, ES10a.SetDefaultDpAddress)
-func (c *Client) SetDefaultDPAddress(address string) error {
-	_, err := sgp22.InvokeAPDU(c.APDU, &sgp22.SetDefaultDPAddressRequest{
+func (c *Client) SetDefaultDPAddress(ctx context.Context, address string) error {
+	_, err := sgp22.InvokeAPDU(ctx, c.APDU, &sgp22.SetDefaultDPAddressRequest{
 		DefaultDPAddress: address,
 	})
 	return err
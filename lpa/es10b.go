@@ -1,6 +1,7 @@
 package lpa
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -9,8 +10,8 @@ import (
 	sgp22 "github.com/damonto/euicc-go/v2"
 )
 
-func (c *Client) EUICCChallenge() ([]byte, error) {
-	euiccChallenge, err := sgp22.InvokeAPDU(c.APDU, new(sgp22.GetEuiccChallengeRequest))
+func (c *Client) EUICCChallenge(ctx context.Context) ([]byte, error) {
+	euiccChallenge, err := sgp22.InvokeAPDU(ctx, c.APDU, new(sgp22.GetEuiccChallengeRequest))
 	if err != nil {
 		return nil, err
 	}
@@ -20,51 +21,73 @@ func (c *Client) EUICCChallenge() ([]byte, error) {
 // EUICCInfo1 retrieves the eUICC information (version 1).
 //
 // See https://aka.pw/sgp22/v2.5#page=187 (Section 5.7.8, ES10b.GetEUICCInfo)
-func (c *Client) EUICCInfo1() (*bertlv.TLV, error) {
-	euiccInfo1, err := sgp22.InvokeAPDU(c.APDU, &sgp22.GetEuiccInfoRequest{Version: 1})
+func (c *Client) EUICCInfo1(ctx context.Context) (*sgp22.EUICCInfo1, error) {
+	response, err := c.euiccInfo(ctx, sgp22.EuiccInfoVersion1)
 	if err != nil {
 		return nil, err
 	}
-	return euiccInfo1.Response, nil
+	return response.Info1, nil
 }
 
 // EUICCInfo2 retrieves the eUICC information (version 2).
 //
 // See https://aka.pw/sgp22/v2.5#page=187 (Section 5.7.8, ES10b.GetEUICCInfo)
-func (c *Client) EUICCInfo2() (*bertlv.TLV, error) {
-	euiccInfo1, err := sgp22.InvokeAPDU(c.APDU, &sgp22.GetEuiccInfoRequest{Version: 2})
+func (c *Client) EUICCInfo2(ctx context.Context) (*sgp22.EUICCInfo2, error) {
+	response, err := c.euiccInfo(ctx, sgp22.EuiccInfoVersion2)
 	if err != nil {
 		return nil, err
 	}
-	return euiccInfo1.Response, nil
+	return response.Info2, nil
+}
+
+func (c *Client) euiccInfo(ctx context.Context, version sgp22.EuiccInfoVersion) (*sgp22.GetEuiccInfoResponse, error) {
+	return sgp22.InvokeAPDU(ctx, c.APDU, &sgp22.GetEuiccInfoRequest{Version: version})
 }
 
 // AuthenticateClient authenticates the client to the eUICC.
 //
 // See https://aka.pw/sgp22/v2.5#page=195 (Section 5.7.13, ES10b.AuthenticateClient)
-func (c *Client) AuthenticateClient(address *url.URL, request *sgp22.AuthenticateServerRequest) (*sgp22.ES9AuthenticateClientResponse, error) {
-	authenticateClientRequest, err := sgp22.InvokeAPDU(c.APDU, request)
+func (c *Client) AuthenticateClient(ctx context.Context, address *url.URL, request *sgp22.AuthenticateServerRequest) (*sgp22.ES9AuthenticateClientResponse, error) {
+	authenticateClientRequest, err := sgp22.InvokeAPDU(ctx, c.APDU, request)
 	if err != nil {
 		return nil, err
 	}
-	return sgp22.InvokeHTTP(c.HTTP, address, authenticateClientRequest)
+	response, err := sgp22.InvokeHTTP(ctx, c.HTTP, address, authenticateClientRequest)
+	if err != nil {
+		return response, err
+	}
+	if c.verifier != nil {
+		// The response is returned with the error so that the caller can cancel the session.
+		if err := c.verifier.VerifyAuthenticateClient(authenticateClientRequest, response); err != nil {
+			return response, err
+		}
+	}
+	return response, nil
 }
 
 // PrepareDownload prepares the eUICC for a profile download.
 //
 // See https://aka.pw/sgp22/v2.5#page=184 (Section 5.7.13, ES10b.PrepareDownload)
-func (c *Client) PrepareDownload(address *url.URL, request *sgp22.PrepareDownloadRequest) (*sgp22.ES9BoundProfilePackageResponse, error) {
-	boundProfilePackageRequest, err := sgp22.InvokeAPDU(c.APDU, request)
+func (c *Client) PrepareDownload(ctx context.Context, address *url.URL, request *sgp22.PrepareDownloadRequest) (*sgp22.ES9BoundProfilePackageResponse, error) {
+	return c.prepareDownload(ctx, address, request, nil)
+}
+
+// prepareDownload is PrepareDownload calling onPrepared, if not nil, once the eUICC prepared the download.
+func (c *Client) prepareDownload(ctx context.Context, address *url.URL, request *sgp22.PrepareDownloadRequest, onPrepared func()) (*sgp22.ES9BoundProfilePackageResponse, error) {
+	boundProfilePackageRequest, err := sgp22.InvokeAPDU(ctx, c.APDU, request)
 	if err != nil {
 		return nil, err
 	}
-	return sgp22.InvokeHTTP(c.HTTP, address, boundProfilePackageRequest)
+	if onPrepared != nil {
+		onPrepared()
+	}
+	return sgp22.InvokeHTTP(ctx, c.HTTP, address, boundProfilePackageRequest)
 }
 
 // ListNotification retrieves a list of notifications from the eUICC.
 //
 // See https://aka.pw/sgp22/v2.5#page=191 (Section 5.7.9, ES10b.ListNotification)
-func (c *Client) ListNotification(filters ...sgp22.NotificationEvent) ([]*sgp22.NotificationMetadata, error) {
+func (c *Client) ListNotification(ctx context.Context, filters ...sgp22.NotificationEvent) ([]*sgp22.NotificationMetadata, error) {
 	var request sgp22.ListNotificationRequest
 	if len(filters) > 0 {
 		request.Filter = make(map[sgp22.NotificationEvent]bool, len(filters))
@@ -72,7 +95,7 @@ func (c *Client) ListNotification(filters ...sgp22.NotificationEvent) ([]*sgp22.
 			request.Filter[event] = true
 		}
 	}
-	response, err := sgp22.InvokeAPDU(c.APDU, &request)
+	response, err := sgp22.InvokeAPDU(ctx, c.APDU, &request)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +107,7 @@ func (c *Client) ListNotification(filters ...sgp22.NotificationEvent) ([]*sgp22.
 // Search Criteria:
 // - [sgp22.SequenceNumber]: The sequence number of the notification.
 // - [sgp22.NotificationEvent]: The event type of the notification.
-func (c *Client) RetrieveNotificationList(searchCriteria any) ([]*sgp22.PendingNotification, error) {
+func (c *Client) RetrieveNotificationList(ctx context.Context, searchCriteria any) ([]*sgp22.PendingNotification, error) {
 	var request sgp22.RetrieveNotificationsListRequest
 	var err error
 	switch v := searchCriteria.(type) {
@@ -99,7 +122,7 @@ func (c *Client) RetrieveNotificationList(searchCriteria any) ([]*sgp22.PendingN
 	if err != nil {
 		return nil, fmt.Errorf("marshal notification search criteria: %w", err)
 	}
-	response, err := sgp22.InvokeAPDU(c.APDU, &request)
+	response, err := sgp22.InvokeAPDU(ctx, c.APDU, &request)
 	if err != nil {
 		return nil, err
 	}
@@ -107,11 +130,32 @@ func (c *Client) RetrieveNotificationList(searchCriteria any) ([]*sgp22.PendingN
 }
 
 // RemoveNotificationFromList removes a notification from the eUICC's notification list.
+// If the client has a notification archive, the notification is archived first with the result of
+// the last attempt to send it, and it is not removed if it cannot be archived.
 //
 // See https://aka.pw/sgp22/v2.5#page=193 (Section 5.7.11, ES10b.RemoveNotificationFromList)
-func (c *Client) RemoveNotificationFromList(sequenceNumber sgp22.SequenceNumber) error {
-	_, err := sgp22.InvokeAPDU(c.APDU, &sgp22.NotificationSentRequest{
+func (c *Client) RemoveNotificationFromList(ctx context.Context, sequenceNumber sgp22.SequenceNumber) error {
+	if c.archive != nil {
+		if err := c.archiveRemoved(ctx, sequenceNumber); err != nil {
+			return err
+		}
+	}
+	_, err := sgp22.InvokeAPDU(ctx, c.APDU, &sgp22.NotificationSentRequest{
 		SequenceNumber: sequenceNumber,
 	})
+	if err == nil {
+		delete(c.sent, sequenceNumber)
+	}
 	return err
 }
+
+// RulesAuthorisationTable retrieves the Rules Authorisation Table of the eUICC.
+//
+// See https://aka.pw/sgp22/v2.5#page=210 (Section 5.7.22, ES10b.GetRAT)
+func (c *Client) RulesAuthorisationTable(ctx context.Context) (sgp22.RulesAuthorisationTable, error) {
+	response, err := sgp22.InvokeAPDU(ctx, c.APDU, new(sgp22.GetRATRequest))
+	if err != nil {
+		return nil, err
+	}
+	return response.RulesAuthorisationTable, nil
+}
@@ -1,6 +1,7 @@
 package lpa
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"slices"
@@ -19,7 +20,7 @@ import (
 // - [sgp22.ProfileClass]: The profile class of the profile.
 //
 // See https://aka.pw/sgp22/v2.5#page=199 (Section 5.7.15, ES10c.GetProfilesInfo)
-func (c *Client) ListProfile(searchCriteria any, tags []bertlv.Tag) ([]*sgp22.ProfileInfo, error) {
+func (c *Client) ListProfile(ctx context.Context, searchCriteria any, tags []bertlv.Tag) ([]*sgp22.ProfileInfo, error) {
 	var request sgp22.ProfileInfoListRequest
 	switch v := searchCriteria.(type) {
 	case nil:
@@ -48,7 +49,7 @@ func (c *Client) ListProfile(searchCriteria any, tags []bertlv.Tag) ([]*sgp22.Pr
 		sgp22.TagProfileClass,
 		sgp22.TagProfileOwner,
 	}, tags)
-	response, err := sgp22.InvokeAPDU(c.APDU, &request)
+	response, err := sgp22.InvokeAPDU(ctx, c.APDU, &request)
 	if err != nil {
 		return nil, err
 	}
@@ -63,8 +64,8 @@ func (c *Client) ListProfile(searchCriteria any, tags []bertlv.Tag) ([]*sgp22.Pr
 // - [sgp22.ISDPAID]: The ISD-P AID of the profile.
 //
 // See https://aka.pw/sgp22/v2.5#page=201 (Section 5.7.16, ES10c.EnableProfile)
-func (c *Client) EnableProfile(identifier any, refresh bool) error {
-	return c.setProfile(sgp22.EnableProfile, identifier, refresh)
+func (c *Client) EnableProfile(ctx context.Context, identifier any, refresh bool) error {
+	return c.setProfile(ctx, sgp22.EnableProfile, identifier, refresh)
 }
 
 // DisableProfile disables a profile.
@@ -75,8 +76,8 @@ func (c *Client) EnableProfile(identifier any, refresh bool) error {
 // - [sgp22.ISDPAID]: The ISD-P AID of the profile.
 //
 // See https://aka.pw/sgp22/v2.5#page=204 (Section 5.7.17, ES10c.DisableProfile)
-func (c *Client) DisableProfile(identifier any, refresh bool) error {
-	return c.setProfile(sgp22.DisableProfile, identifier, refresh)
+func (c *Client) DisableProfile(ctx context.Context, identifier any, refresh bool) error {
+	return c.setProfile(ctx, sgp22.DisableProfile, identifier, refresh)
 }
 
 // DeleteProfile deletes a profile.
@@ -87,11 +88,13 @@ func (c *Client) DisableProfile(identifier any, refresh bool) error {
 // - [sgp22.ISDPAID]: The ISD-P AID of the profile.
 //
 // See https://aka.pw/sgp22/v2.5#page=206 (Section 5.7.18, ES10c.DeleteProfile)
-func (c *Client) DeleteProfile(identifier any) error {
-	return c.setProfile(sgp22.DeleteProfile, identifier, false)
+func (c *Client) DeleteProfile(ctx context.Context, identifier any) error {
+	return c.setProfile(ctx, sgp22.DeleteProfile, identifier, false)
 }
 
-func (c *Client) setProfile(operation sgp22.ProfileOperation, identifier any, refresh bool) error {
+// setProfile runs the profile operation and then handles the notifications the eUICC queued for it
+// according to the notification policy.
+func (c *Client) setProfile(ctx context.Context, operation sgp22.ProfileOperation, identifier any, refresh bool) error {
 	var request sgp22.ProfileOperationRequest
 	request.Operation = operation
 	switch v := identifier.(type) {
@@ -103,8 +106,16 @@ func (c *Client) setProfile(operation sgp22.ProfileOperation, identifier any, re
 		return errors.New("invalid profile identifier")
 	}
 	request.Refresh = refresh
-	_, err := sgp22.InvokeAPDU(c.APDU, &request)
-	return err
+	known, listErr := c.knownNotifications(ctx)
+	if _, err := sgp22.InvokeAPDU(ctx, c.APDU, &request); err != nil {
+		return err
+	}
+	if listErr != nil {
+		c.notifyLatest(ctx, operationEvents[operation], listErr)
+		return nil
+	}
+	c.notifyNew(ctx, known)
+	return nil
 }
 
 // MemoryReset resets the eUICC memory.
@@ -112,8 +123,8 @@ func (c *Client) setProfile(operation sgp22.ProfileOperation, identifier any, re
 // and resets the default SM-DP+ address.
 //
 // See https://aka.pw/sgp22/v2.5#page=207 (Section 5.7.19, ES10c.eUICCMemoryReset)
-func (c *Client) MemoryReset() error {
-	_, err := sgp22.InvokeAPDU(c.APDU, &sgp22.EuiccMemoryResetRequest{
+func (c *Client) MemoryReset(ctx context.Context) error {
+	_, err := sgp22.InvokeAPDU(ctx, c.APDU, &sgp22.EuiccMemoryResetRequest{
 		DeleteOperationalProfiles:     true,
 		DeleteFieldLoadedTestProfiles: true,
 		ResetDefaultSMDPAddress:       true,
@@ -125,8 +136,8 @@ func (c *Client) MemoryReset() error {
 // The EID is a unique identifier of the eUICC.
 //
 // See https://aka.pw/sgp22/v2.5#page=209 (Section 5.7.20, ES10c.GetEID)
-func (c *Client) EID() ([]byte, error) {
-	response, err := sgp22.InvokeAPDU(c.APDU, new(sgp22.GetEuiccDataRequest))
+func (c *Client) EID(ctx context.Context) (sgp22.EID, error) {
+	response, err := sgp22.InvokeAPDU(ctx, c.APDU, new(sgp22.GetEuiccDataRequest))
 	if err != nil {
 		return nil, err
 	}
@@ -136,8 +147,8 @@ func (c *Client) EID() ([]byte, error) {
 // SetNickname sets the nickname of the profile.
 //
 // See https://aka.pw/sgp22/v2.5#page=209 (Section 5.7.21, ES10c.SetNickname)
-func (c *Client) SetNickname(iccid sgp22.ICCID, nickname string) error {
-	_, err := sgp22.InvokeAPDU(c.APDU, &sgp22.SetNicknameRequest{
+func (c *Client) SetNickname(ctx context.Context, iccid sgp22.ICCID, nickname string) error {
+	_, err := sgp22.InvokeAPDU(ctx, c.APDU, &sgp22.SetNicknameRequest{
 		ICCID:    iccid,
 		Nickname: []byte(nickname),
 	})
@@ -1,7 +1,9 @@
 package lpa
 
 import (
+	"context"
 	"net/url"
+	"time"
 
 	"github.com/damonto/euicc-go/v2"
 )
@@ -9,28 +11,60 @@ import (
 // InitiateAuthentication initiates the authentication process.
 //
 // See https://aka.pw/sgp22/v2.5#page=170 (Section 5.6.1, ES9p.InitiateAuthentication)
-func (c *Client) InitiateAuthentication(address *url.URL) (*sgp22.ES9InitiateAuthenticationResponse, error) {
+func (c *Client) InitiateAuthentication(ctx context.Context, address *url.URL) (*sgp22.ES9InitiateAuthenticationResponse, error) {
 	var err error
 	request := sgp22.ES9InitiateAuthenticationRequest{Address: address.Host}
-	if request.Challenge, err = c.EUICCChallenge(); err != nil {
+	if request.Challenge, err = c.EUICCChallenge(ctx); err != nil {
 		return nil, err
 	}
-	if request.Info1, err = c.EUICCInfo1(); err != nil {
+	info1, err := c.euiccInfo(ctx, sgp22.EuiccInfoVersion1)
+	if err != nil {
 		return nil, err
 	}
-	return sgp22.InvokeHTTP(c.HTTP, address, &request)
+	request.Info1 = info1.Response
+	response, err := sgp22.InvokeHTTP(ctx, c.HTTP, address, &request)
+	if err != nil {
+		return nil, err
+	}
+	if c.verifier != nil {
+		if err := c.verifier.VerifyInitiateAuthentication(&request, response); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
 }
 
 // HandleNotification handles the pending notification.
+// If the client has a notification archive, the result of sending the notification is kept until
+// [Client.RemoveNotificationFromList] archives it, for the last notifications sent only.
 //
 // See https://aka.pw/sgp22/v2.5#page=177 (Section 5.6.4, ES9p.HandleNotification)
-func (c *Client) HandleNotification(pendingNotification *sgp22.PendingNotification) error {
-	request := sgp22.ES9HandleNotificationRequest{
-		PendingNotification: pendingNotification.PendingNotification,
-	}
-	_, err := sgp22.InvokeHTTP(c.HTTP, &url.URL{
+func (c *Client) HandleNotification(ctx context.Context, pendingNotification *sgp22.PendingNotification) error {
+	archived, err := c.handleNotification(ctx, &url.URL{
 		Scheme: "https",
 		Host:   pendingNotification.Notification.Address,
-	}, &request)
+	}, pendingNotification)
+	if c.archive != nil {
+		c.keepSent(archived)
+	}
 	return err
 }
+
+// handleNotification sends the pending notification to address and returns the result to archive.
+func (c *Client) handleNotification(ctx context.Context, address *url.URL, pendingNotification *sgp22.PendingNotification) (*ArchivedNotification, error) {
+	request := sgp22.ES9HandleNotificationRequest{
+		PendingNotification: pendingNotification.PendingNotification,
+	}
+	archived := &ArchivedNotification{
+		PendingNotification: pendingNotification.PendingNotification,
+		Notification:        pendingNotification.Notification,
+		SentAt:              time.Now(),
+	}
+	// HandleNotification has no function execution status to check, see ES9HandleNotificationResponse.
+	var err error
+	archived.StatusCode, err = c.HTTP.Send(ctx, request.URL(address), &request, request.RemoteResponse())
+	if err != nil {
+		archived.Error = err.Error()
+	}
+	return archived, err
+}
@@ -1,9 +1,11 @@
 package lpa
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	nethttp "net/http"
 	"strings"
 	"time"
 
@@ -16,12 +18,20 @@ import (
 // See https://www.gsma.com/solutions-and-impact/technologies/esim/wp-content/uploads/2020/07/SGP.02-v4.2.pdf#page=26 (Section 2.2.3 Identification of Security Domains: AID and TAR)
 var GSMAISDRApplicationAID = []byte{0xA0, 0x00, 0x00, 0x05, 0x59, 0x10, 0x10, 0xFF, 0xFF, 0xFF, 0xFF, 0x89, 0x00, 0x00, 0x01, 0x00}
 
-// Client is the main structure for the LPA client.
+// Client is the main structure for the LPA client. It is not safe for concurrent use,
+// see the shared package for a client that is.
 type Client struct {
 	HTTP *http.Client
 	APDU sgp22.Transmitter
 
-	transmitter driver.Transmitter
+	transmitter         driver.Transmitter
+	verifier            Verifier
+	notificationPolicy  NotificationPolicy
+	onNotificationError func(notification *sgp22.NotificationMetadata, err error)
+	queue               []*sgp22.NotificationMetadata
+	archive             NotificationArchive
+	// sent holds the result of the last attempt to send each notification until it is archived, see keepSent.
+	sent map[sgp22.SequenceNumber]*ArchivedNotification
 }
 
 // Options is the configuration for the LPA client.
@@ -39,6 +49,21 @@ type Options struct {
 	Logger *slog.Logger
 	// Timeout is the timeout for the HTTP client. It defaults to 30 seconds.
 	Timeout time.Duration
+	// WrapTransport, if set, returns the transport the HTTP client sends its requests with, given the one
+	// trusting the GSMA CI certificates. See http.NewRecorder and http.NewReplayer.
+	WrapTransport func(nethttp.RoundTripper) nethttp.RoundTripper
+	// Verifier checks data received from the SM-DP+ before it is forwarded to the eUICC.
+	// It is optional, see NewCIVerifier for a verifier using the GSMA CI bundle.
+	Verifier Verifier
+	// NotificationPolicy controls whether the notifications queued by the eUICC when a profile is installed,
+	// enabled, disabled or deleted are sent to the SM-DP+. It defaults to NotificationPolicyNever.
+	NotificationPolicy NotificationPolicy
+	// OnNotificationError is called when a notification cannot be sent or removed after a profile operation.
+	// The notification is nil if it could not be found on the eUICC. The profile operation itself still succeeds.
+	OnNotificationError func(notification *sgp22.NotificationMetadata, err error)
+	// NotificationArchive receives every notification before it is removed from the eUICC, with the result of sending it.
+	// It is optional, see NewFileArchive for an archive in a JSON Lines file.
+	NotificationArchive NotificationArchive
 }
 
 func (opts *Options) validateAdminProtocolVersion() error {
@@ -67,6 +92,9 @@ func (opts *Options) validate() error {
 	if opts.Channel == nil {
 		return errors.New("channel is required for APDU communication")
 	}
+	if opts.NotificationPolicy > NotificationPolicyQueue {
+		return fmt.Errorf("invalid notification policy: %s", opts.NotificationPolicy)
+	}
 	return nil
 }
 
@@ -98,20 +126,25 @@ func (opts *Options) Normalize() error {
 }
 
 // New creates a new LPA client with the given options.
-func New(opts *Options) (*Client, error) {
+// The context bounds connecting to the channel and opening the logical channel.
+func New(ctx context.Context, opts *Options) (*Client, error) {
 	var c Client
 	var err error
 	if err := opts.Normalize(); err != nil {
 		return nil, err
 	}
-	httpClient, err := driver.NewHTTPClient(opts.Logger, opts.Timeout)
+	httpClient, err := driver.NewHTTPClient(opts.Logger, opts.Timeout, opts.WrapTransport)
 	if err != nil {
 		return nil, err
 	}
-	if c.transmitter, err = driver.NewTransmitter(opts.Logger, opts.Channel, opts.AID, opts.MSS); err != nil {
+	if c.transmitter, err = driver.NewTransmitter(ctx, opts.Logger, opts.Channel, opts.AID, opts.MSS); err != nil {
 		return nil, err
 	}
 	c.APDU = c.transmitter
+	c.verifier = opts.Verifier
+	c.notificationPolicy = opts.NotificationPolicy
+	c.onNotificationError = opts.OnNotificationError
+	c.archive = opts.NotificationArchive
 	c.HTTP = &http.Client{
 		Client:               httpClient,
 		AdminProtocolVersion: opts.AdminProtocolVersion,
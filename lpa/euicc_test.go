@@ -7,7 +7,7 @@ func TestOptionsNormalizeRejectsNil(t *testing.T) {
 	if err := opts.Normalize(); err == nil {
 		t.Error("Options.Normalize() error = nil for nil options")
 	}
-	if _, err := New(nil); err == nil {
+	if _, err := New(t.Context(), nil); err == nil {
 		t.Error("New(nil) error = nil")
 	}
 }
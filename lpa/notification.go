@@ -0,0 +1,164 @@
+package lpa
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// NotificationPolicy controls what the client does with the notifications the eUICC
+// queues when a profile is installed, enabled, disabled or deleted.
+type NotificationPolicy uint8
+
+const (
+	// NotificationPolicyNever leaves the notification on the eUICC. It is the default.
+	NotificationPolicyNever NotificationPolicy = iota
+	// NotificationPolicyImmediate sends the notification to the SM-DP+ right after the operation
+	// and removes it from the eUICC once it is delivered.
+	NotificationPolicyImmediate
+	// NotificationPolicyQueue keeps the notification until [Client.FlushNotifications] is called.
+	NotificationPolicyQueue
+)
+
+// String returns a string representation of the NotificationPolicy.
+func (p NotificationPolicy) String() string {
+	switch p {
+	case NotificationPolicyNever:
+		return "Never"
+	case NotificationPolicyImmediate:
+		return "Immediate"
+	case NotificationPolicyQueue:
+		return "Queue"
+	default:
+		return fmt.Sprintf("Unknown Policy (%d)", p)
+	}
+}
+
+// QueuedNotifications returns the notifications waiting for [Client.FlushNotifications].
+func (c *Client) QueuedNotifications() []*sgp22.NotificationMetadata {
+	return slices.Clone(c.queue)
+}
+
+// FlushNotifications sends the queued notifications to their SM-DP+ and removes them from the eUICC.
+// Notifications that could not be delivered stay queued, and their errors are returned joined.
+func (c *Client) FlushNotifications(ctx context.Context) error {
+	var errs []error
+	queue := c.queue[:0]
+	for _, notification := range c.queue {
+		if err := c.sendNotification(ctx, notification); err != nil {
+			errs = append(errs, err)
+			queue = append(queue, notification)
+		}
+	}
+	c.queue = queue
+	return errors.Join(errs...)
+}
+
+// notify applies the notification policy to a notification the eUICC queued.
+// Errors are reported through OnNotificationError and never fail the profile operation.
+func (c *Client) notify(ctx context.Context, notification *sgp22.NotificationMetadata) {
+	if c.notificationPolicy == NotificationPolicyNever {
+		return
+	}
+	if c.notificationPolicy == NotificationPolicyQueue {
+		c.queue = append(c.queue, notification)
+		return
+	}
+	if err := c.sendNotification(ctx, notification); err != nil {
+		c.notificationError(notification, err)
+	}
+}
+
+// operationEvents are the events of the notifications queued by the profile operations.
+var operationEvents = map[sgp22.ProfileOperation]sgp22.NotificationEvent{
+	sgp22.EnableProfile:  sgp22.NotificationEventEnable,
+	sgp22.DisableProfile: sgp22.NotificationEventDisable,
+	sgp22.DeleteProfile:  sgp22.NotificationEventDelete,
+}
+
+// knownNotifications returns the sequence numbers of the notifications on the eUICC before a profile
+// operation, if the notification policy is [NotificationPolicyImmediate] or [NotificationPolicyQueue],
+// or nil otherwise.
+func (c *Client) knownNotifications(ctx context.Context) (map[sgp22.SequenceNumber]bool, error) {
+	if c.notificationPolicy != NotificationPolicyImmediate && c.notificationPolicy != NotificationPolicyQueue {
+		return nil, nil
+	}
+	notifications, err := c.ListNotification(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list notifications: %w", err)
+	}
+	known := make(map[sgp22.SequenceNumber]bool, len(notifications))
+	for _, notification := range notifications {
+		known[notification.SequenceNumber] = true
+	}
+	return known, nil
+}
+
+// notifyNew applies the notification policy to every notification the eUICC queued
+// since known was listed, whatever its event, in the order they were queued.
+// Enabling a profile, for instance, also queues the notification of the profile it disables.
+func (c *Client) notifyNew(ctx context.Context, known map[sgp22.SequenceNumber]bool) {
+	if known == nil {
+		return
+	}
+	notifications, err := c.ListNotification(ctx)
+	if err != nil {
+		c.notificationError(nil, fmt.Errorf("list notifications: %w", err))
+		return
+	}
+	notifications = slices.DeleteFunc(notifications, func(notification *sgp22.NotificationMetadata) bool {
+		return known[notification.SequenceNumber]
+	})
+	slices.SortFunc(notifications, func(a, b *sgp22.NotificationMetadata) int {
+		return cmp.Compare(a.SequenceNumber, b.SequenceNumber)
+	})
+	for _, notification := range notifications {
+		c.notify(ctx, notification)
+	}
+}
+
+// notifyLatest applies the notification policy to the latest notification of the event, when the
+// notifications on the eUICC could not be listed before the profile operation, which failed with listErr.
+// The notifications of other events the operation queued are left on the eUICC.
+func (c *Client) notifyLatest(ctx context.Context, event sgp22.NotificationEvent, listErr error) {
+	notifications, err := c.ListNotification(ctx, event)
+	if err != nil {
+		c.notificationError(nil, errors.Join(listErr, fmt.Errorf("list notifications: %w", err)))
+		return
+	}
+	if len(notifications) == 0 {
+		c.notificationError(nil, fmt.Errorf("no notification of the profile operation: %w", listErr))
+		return
+	}
+	c.notify(ctx, slices.MaxFunc(notifications, func(a, b *sgp22.NotificationMetadata) int {
+		return cmp.Compare(a.SequenceNumber, b.SequenceNumber)
+	}))
+}
+
+// sendNotification delivers the notification to its SM-DP+ and removes it from the eUICC.
+func (c *Client) sendNotification(ctx context.Context, notification *sgp22.NotificationMetadata) error {
+	pending, err := c.RetrieveNotificationList(ctx, notification.SequenceNumber)
+	if err != nil {
+		return fmt.Errorf("retrieve notification %d: %w", notification.SequenceNumber, err)
+	}
+	if len(pending) == 0 {
+		return fmt.Errorf("notification %d not found", notification.SequenceNumber)
+	}
+	if err := c.HandleNotification(ctx, pending[0]); err != nil {
+		return fmt.Errorf("send notification %d to %s: %w", notification.SequenceNumber, pending[0].Notification.Address, err)
+	}
+	if err := c.RemoveNotificationFromList(ctx, notification.SequenceNumber); err != nil {
+		return fmt.Errorf("remove notification %d: %w", notification.SequenceNumber, err)
+	}
+	return nil
+}
+
+func (c *Client) notificationError(notification *sgp22.NotificationMetadata, err error) {
+	if c.onNotificationError != nil {
+		c.onNotificationError(notification, err)
+	}
+}
@@ -0,0 +1,226 @@
+package lpa
+
+import (
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/http"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+func notificationMetadata(t *testing.T, sequenceNumber byte, event sgp22.NotificationEvent, address string) *bertlv.TLV {
+	t.Helper()
+	operation, err := event.MarshalBinary()
+	if err != nil {
+		t.Fatalf("NotificationEvent.MarshalBinary() error = %v", err)
+	}
+	return bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(47),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{sequenceNumber}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), operation),
+		bertlv.NewValue(bertlv.Universal.Primitive(12), []byte(address)),
+	)
+}
+
+// newNotificationClient returns a client whose SM-DP+ answers HandleNotification with status,
+// and records the ES9+ request paths.
+func newNotificationClient(t *testing.T, status int, policy NotificationPolicy) (*Client, *fakeTransmitter, string, *[]string) {
+	t.Helper()
+	var paths []string
+	server := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	address, _ := url.Parse(server.URL)
+	transmitter := new(fakeTransmitter)
+	client := &Client{
+		APDU:               transmitter,
+		HTTP:               &http.Client{Client: server.Client(), AdminProtocolVersion: "2.5.0"},
+		notificationPolicy: policy,
+	}
+	return client, transmitter, address.Host, &paths
+}
+
+func notificationResponses(t *testing.T, sequenceNumber byte, event sgp22.NotificationEvent, address string) []*bertlv.TLV {
+	t.Helper()
+	return []*bertlv.TLV{
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(43),
+			bertlv.NewChildren(
+				bertlv.ContextSpecific.Constructed(0),
+				bertlv.NewChildren(
+					bertlv.Universal.Constructed(16),
+					notificationMetadata(t, sequenceNumber, event, address),
+					bertlv.NewValue(bertlv.Application.Primitive(55), []byte{0x01}),
+				),
+			),
+		),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(48),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{0x00}),
+		),
+	}
+}
+
+func notificationList(notifications ...*bertlv.TLV) *bertlv.TLV {
+	return bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(40),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0), notifications...),
+	)
+}
+
+func profileOperationResponse(tag uint64) *bertlv.TLV {
+	return bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(tag),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{0x00}),
+	)
+}
+
+func TestEnableProfileSendsNotification(t *testing.T) {
+	client, transmitter, address, paths := newNotificationClient(t, nethttp.StatusNoContent, NotificationPolicyImmediate)
+	transmitter.responses = []*bertlv.TLV{
+		notificationList(notificationMetadata(t, 3, sgp22.NotificationEventEnable, address)),
+		profileOperationResponse(49),
+		// Enabling the profile disabled the one enabled before.
+		notificationList(
+			notificationMetadata(t, 3, sgp22.NotificationEventEnable, address),
+			notificationMetadata(t, 5, sgp22.NotificationEventEnable, address),
+			notificationMetadata(t, 4, sgp22.NotificationEventDisable, address),
+		),
+	}
+	transmitter.responses = append(transmitter.responses, notificationResponses(t, 4, sgp22.NotificationEventDisable, address)...)
+	transmitter.responses = append(transmitter.responses, notificationResponses(t, 5, sgp22.NotificationEventEnable, address)...)
+	client.onNotificationError = func(_ *sgp22.NotificationMetadata, err error) {
+		t.Errorf("OnNotificationError() error = %v", err)
+	}
+
+	if err := client.EnableProfile(t.Context(), sgp22.ICCID{0x98, 0x10}, false); err != nil {
+		t.Fatalf("EnableProfile() error = %v", err)
+	}
+	if len(*paths) != 2 {
+		t.Errorf("ES9+ requests = %v, want two handleNotification", *paths)
+	}
+	if len(transmitter.requests) != 7 {
+		t.Fatalf("requests = %d, want 7", len(transmitter.requests))
+	}
+	for i, want := range map[int]byte{4: 4, 6: 5} {
+		removed := transmitter.requests[i].First(bertlv.ContextSpecific.Primitive(0))
+		if removed == nil || len(removed.Value) != 1 || removed.Value[0] != want {
+			t.Errorf("removed notification = %v, want sequence number %d", removed, want)
+		}
+	}
+}
+
+func TestDisableProfileWithoutNewNotification(t *testing.T) {
+	client, transmitter, address, paths := newNotificationClient(t, nethttp.StatusNoContent, NotificationPolicyImmediate)
+	transmitter.responses = []*bertlv.TLV{
+		notificationList(notificationMetadata(t, 2, sgp22.NotificationEventEnable, address)),
+		profileOperationResponse(50),
+		notificationList(notificationMetadata(t, 2, sgp22.NotificationEventEnable, address)),
+	}
+	client.onNotificationError = func(_ *sgp22.NotificationMetadata, err error) {
+		t.Errorf("OnNotificationError() error = %v", err)
+	}
+
+	if err := client.DisableProfile(t.Context(), sgp22.ICCID{0x98, 0x10}, false); err != nil {
+		t.Fatalf("DisableProfile() error = %v", err)
+	}
+	if len(*paths) != 0 || len(transmitter.requests) != 3 {
+		t.Errorf("requests = %d, ES9+ requests = %v, want no notification sent", len(transmitter.requests), *paths)
+	}
+}
+
+func TestDeleteProfileReportsNotificationError(t *testing.T) {
+	client, transmitter, address, _ := newNotificationClient(t, nethttp.StatusInternalServerError, NotificationPolicyImmediate)
+	transmitter.responses = []*bertlv.TLV{
+		notificationList(),
+		profileOperationResponse(51),
+		notificationList(notificationMetadata(t, 7, sgp22.NotificationEventDelete, address)),
+		notificationResponses(t, 7, sgp22.NotificationEventDelete, address)[0],
+	}
+	var failed *sgp22.NotificationMetadata
+	var failure error
+	client.onNotificationError = func(notification *sgp22.NotificationMetadata, err error) {
+		failed, failure = notification, err
+	}
+
+	if err := client.DeleteProfile(t.Context(), sgp22.ICCID{0x98, 0x10}); err != nil {
+		t.Fatalf("DeleteProfile() error = %v", err)
+	}
+	if failed == nil || failed.SequenceNumber != 7 {
+		t.Errorf("OnNotificationError() notification = %+v, want sequence number 7", failed)
+	}
+	if failure == nil || !strings.Contains(failure.Error(), "500") {
+		t.Errorf("OnNotificationError() error = %v, want status code 500", failure)
+	}
+	if len(transmitter.requests) != 4 {
+		t.Errorf("requests = %d, want the notification kept on the eUICC", len(transmitter.requests))
+	}
+}
+
+func TestFlushNotifications(t *testing.T) {
+	client, transmitter, address, paths := newNotificationClient(t, nethttp.StatusNoContent, NotificationPolicyQueue)
+	notification := &sgp22.NotificationMetadata{SequenceNumber: 2, ProfileManagementOperation: sgp22.NotificationEventInstall, Address: address}
+	client.notify(t.Context(), notification)
+	if len(transmitter.requests) != 0 || len(*paths) != 0 {
+		t.Fatal("notify() sent a queued notification")
+	}
+	if queued := client.QueuedNotifications(); len(queued) != 1 || queued[0] != notification {
+		t.Fatalf("QueuedNotifications() = %v, want the install notification", queued)
+	}
+
+	if err := client.FlushNotifications(t.Context()); !errors.Is(err, errUnexpectedRequest) {
+		t.Errorf("FlushNotifications() error = %v, want %v", err, errUnexpectedRequest)
+	}
+	if len(client.QueuedNotifications()) != 1 {
+		t.Fatal("FlushNotifications() dropped an undelivered notification")
+	}
+
+	transmitter.responses = notificationResponses(t, 2, sgp22.NotificationEventInstall, address)
+	if err := client.FlushNotifications(t.Context()); err != nil {
+		t.Fatalf("FlushNotifications() error = %v", err)
+	}
+	if len(client.QueuedNotifications()) != 0 {
+		t.Errorf("QueuedNotifications() = %v, want none", client.QueuedNotifications())
+	}
+	if len(*paths) != 1 {
+		t.Errorf("ES9+ requests = %v, want one", *paths)
+	}
+}
+
+func TestDeleteProfileNotifiesWhenListFails(t *testing.T) {
+	client, transmitter, address, paths := newNotificationClient(t, nethttp.StatusNoContent, NotificationPolicyImmediate)
+	transmitter.responses = []*bertlv.TLV{
+		// The notifications cannot be listed before the operation.
+		profileOperationResponse(51),
+		profileOperationResponse(51),
+		notificationList(
+			notificationMetadata(t, 2, sgp22.NotificationEventDelete, address),
+			notificationMetadata(t, 6, sgp22.NotificationEventDelete, address),
+		),
+	}
+	transmitter.responses = append(transmitter.responses, notificationResponses(t, 6, sgp22.NotificationEventDelete, address)...)
+	client.onNotificationError = func(_ *sgp22.NotificationMetadata, err error) {
+		t.Errorf("OnNotificationError() error = %v", err)
+	}
+
+	if err := client.DeleteProfile(t.Context(), sgp22.ICCID{0x98, 0x10}); err != nil {
+		t.Fatalf("DeleteProfile() error = %v", err)
+	}
+	if len(*paths) != 1 || len(transmitter.requests) != 5 {
+		t.Fatalf("requests = %d, ES9+ requests = %v, want the delete notification sent", len(transmitter.requests), *paths)
+	}
+	filter := transmitter.requests[2].First(bertlv.ContextSpecific.Primitive(1))
+	removed := transmitter.requests[4].First(bertlv.ContextSpecific.Primitive(0))
+	if filter == nil || removed == nil || len(removed.Value) != 1 || removed.Value[0] != 6 {
+		t.Errorf("list request = %v, removed notification = %v, want the latest delete notification", transmitter.requests[2], removed)
+	}
+}
@@ -0,0 +1,185 @@
+// Package outbox delivers the pending notifications of an eUICC to their SM-DP+,
+// retrying failed deliveries with an exponential backoff per SM-DP+ host.
+//
+// A notification is removed from the eUICC only after the SM-DP+ accepted it.
+// Delivery attempts are persisted in a [Store], so the backoff survives restarts.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// Client is the part of the LPA client used by the outbox. It is implemented by *lpa.Client.
+type Client interface {
+	RetrieveNotificationList(ctx context.Context, searchCriteria any) ([]*sgp22.PendingNotification, error)
+	HandleNotification(ctx context.Context, pendingNotification *sgp22.PendingNotification) error
+	RemoveNotificationFromList(ctx context.Context, sequenceNumber sgp22.SequenceNumber) error
+}
+
+// Outbox delivers the pending notifications of one eUICC.
+// It is not safe for concurrent use, and must not run alongside other operations on the same client.
+type Outbox struct {
+	client         Client
+	store          Store
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	now            func() time.Time
+}
+
+// Option configures an Outbox.
+type Option func(*Outbox)
+
+// WithBackoff sets the delay after the first failed delivery to a host, which doubles
+// with every consecutive failure up to maxDelay. It defaults to one minute and six hours.
+func WithBackoff(initial, maxDelay time.Duration) Option {
+	return func(o *Outbox) {
+		o.initialBackoff = initial
+		o.maxBackoff = maxDelay
+	}
+}
+
+// New creates an outbox for the notifications of client, keeping its state in store.
+func New(client Client, store Store, opts ...Option) *Outbox {
+	o := &Outbox{
+		client:         client,
+		store:          store,
+		initialBackoff: time.Minute,
+		maxBackoff:     6 * time.Hour,
+		now:            time.Now,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Result summarises a call to [Outbox.Process].
+type Result struct {
+	// Delivered is the number of notifications sent and removed from the eUICC.
+	Delivered int
+	// Failed is the number of notifications that could not be sent or removed.
+	Failed int
+	// Deferred is the number of notifications skipped because their host is backing off.
+	Deferred int
+	// NextAttempt is the earliest time a host backing off can be retried, or zero if none is.
+	NextAttempt time.Time
+}
+
+// Process sends every pending notification on the eUICC whose host is not backing off,
+// and removes the delivered notifications from the eUICC. It is meant to be called periodically.
+//
+// Delivery failures are recorded in the store rather than returned. An error is returned
+// if the notifications cannot be listed or the state cannot be loaded or saved.
+func (o *Outbox) Process(ctx context.Context) (result *Result, err error) {
+	state, err := o.store.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if state.Notifications == nil {
+		state.Notifications = make(map[sgp22.SequenceNumber]Delivery)
+	}
+	if state.Hosts == nil {
+		state.Hosts = make(map[string]Host)
+	}
+	pending, err := o.client.RetrieveNotificationList(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// Keep the attempts made so far even if ctx was canceled.
+		err = errors.Join(err, o.store.Save(context.WithoutCancel(ctx), state))
+	}()
+
+	seen := make(map[sgp22.SequenceNumber]bool, len(pending))
+	for _, notification := range pending {
+		seen[notification.Notification.SequenceNumber] = true
+	}
+	// Notifications removed from the eUICC by someone else are no longer tracked.
+	for sequenceNumber := range state.Notifications {
+		if !seen[sequenceNumber] {
+			delete(state.Notifications, sequenceNumber)
+		}
+	}
+
+	result = new(Result)
+	for _, notification := range pending {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		o.process(ctx, state, notification, result)
+	}
+	// Hosts without undelivered notifications no longer back off.
+	waiting := make(map[string]bool, len(state.Hosts))
+	for _, delivery := range state.Notifications {
+		waiting[delivery.Address] = waiting[delivery.Address] || !delivery.Delivered
+	}
+	for address, host := range state.Hosts {
+		if !waiting[address] {
+			delete(state.Hosts, address)
+			continue
+		}
+		if result.NextAttempt.IsZero() || host.NextAttempt.Before(result.NextAttempt) {
+			result.NextAttempt = host.NextAttempt
+		}
+	}
+	return result, nil
+}
+
+func (o *Outbox) process(ctx context.Context, state *State, notification *sgp22.PendingNotification, result *Result) {
+	sequenceNumber := notification.Notification.SequenceNumber
+	delivery, ok := state.Notifications[sequenceNumber]
+	if !ok {
+		delivery = Delivery{
+			Address: notification.Notification.Address,
+			Event:   notification.Notification.ProfileManagementOperation,
+		}
+	}
+	if !delivery.Delivered {
+		host, backingOff := state.Hosts[delivery.Address]
+		now := o.now()
+		if backingOff && now.Before(host.NextAttempt) {
+			state.Notifications[sequenceNumber] = delivery
+			result.Deferred++
+			return
+		}
+		delivery.Attempts++
+		delivery.LastAttempt = now
+		if err := o.client.HandleNotification(ctx, notification); err != nil {
+			host.Failures++
+			host.NextAttempt = now.Add(o.backoff(host.Failures))
+			state.Hosts[delivery.Address] = host
+			delivery.LastError = err.Error()
+			state.Notifications[sequenceNumber] = delivery
+			result.Failed++
+			return
+		}
+		delete(state.Hosts, delivery.Address)
+		delivery.Delivered = true
+		delivery.LastError = ""
+	}
+	err := o.client.RemoveNotificationFromList(ctx, sequenceNumber)
+	if err != nil && !errors.Is(err, sgp22.ErrNothingToDelete) {
+		delivery.LastError = err.Error()
+		state.Notifications[sequenceNumber] = delivery
+		result.Failed++
+		return
+	}
+	delete(state.Notifications, sequenceNumber)
+	result.Delivered++
+}
+
+// backoff returns the delay after the given number of consecutive failures.
+func (o *Outbox) backoff(failures int) time.Duration {
+	delay := o.initialBackoff
+	for range failures - 1 {
+		if delay >= o.maxBackoff/2 {
+			return o.maxBackoff
+		}
+		delay *= 2
+	}
+	return min(delay, o.maxBackoff)
+}
@@ -0,0 +1,164 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/damonto/euicc-go/lpa"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+var _ Client = (*lpa.Client)(nil)
+
+type fakeClient struct {
+	pending []*sgp22.PendingNotification
+	down    map[string]bool
+	sent    []sgp22.SequenceNumber
+	removed []sgp22.SequenceNumber
+	remove  error
+}
+
+func (f *fakeClient) RetrieveNotificationList(context.Context, any) ([]*sgp22.PendingNotification, error) {
+	return slices.Clone(f.pending), nil
+}
+
+func (f *fakeClient) HandleNotification(_ context.Context, notification *sgp22.PendingNotification) error {
+	if f.down[notification.Notification.Address] {
+		return errors.New("unexpected status code: 503")
+	}
+	f.sent = append(f.sent, notification.Notification.SequenceNumber)
+	return nil
+}
+
+func (f *fakeClient) RemoveNotificationFromList(_ context.Context, sequenceNumber sgp22.SequenceNumber) error {
+	if f.remove != nil {
+		return f.remove
+	}
+	f.removed = append(f.removed, sequenceNumber)
+	f.pending = slices.DeleteFunc(f.pending, func(notification *sgp22.PendingNotification) bool {
+		return notification.Notification.SequenceNumber == sequenceNumber
+	})
+	return nil
+}
+
+func pendingNotification(sequenceNumber sgp22.SequenceNumber, address string) *sgp22.PendingNotification {
+	return &sgp22.PendingNotification{Notification: &sgp22.NotificationMetadata{
+		SequenceNumber:             sequenceNumber,
+		ProfileManagementOperation: sgp22.NotificationEventEnable,
+		Address:                    address,
+	}}
+}
+
+func TestProcessBacksOffPerHost(t *testing.T) {
+	client := &fakeClient{
+		pending: []*sgp22.PendingNotification{
+			pendingNotification(1, "down.example.com"),
+			pendingNotification(2, "up.example.com"),
+			pendingNotification(3, "down.example.com"),
+		},
+		down: map[string]bool{"down.example.com": true},
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	outbox := New(client, NewMemoryStore(), WithBackoff(time.Minute, 3*time.Minute))
+	outbox.now = func() time.Time { return now }
+
+	result, err := outbox.Process(t.Context())
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	want := &Result{Delivered: 1, Failed: 1, Deferred: 1, NextAttempt: now.Add(time.Minute)}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Process() = %+v, want %+v", result, want)
+	}
+	if !slices.Equal(client.removed, []sgp22.SequenceNumber{2}) {
+		t.Errorf("removed = %v, want [2]", client.removed)
+	}
+
+	// The host is still backing off.
+	now = now.Add(30 * time.Second)
+	if result, _ = outbox.Process(t.Context()); result.Deferred != 2 {
+		t.Errorf("Process() deferred = %d, want 2", result.Deferred)
+	}
+
+	for _, delay := range []time.Duration{2 * time.Minute, 3 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		now = result.NextAttempt
+		if result, _ = outbox.Process(t.Context()); !result.NextAttempt.Equal(now.Add(delay)) {
+			t.Errorf("Process() next attempt = %v, want %v", result.NextAttempt, now.Add(delay))
+		}
+	}
+
+	state, _ := outbox.store.Load(t.Context())
+	if delivery := state.Notifications[1]; delivery.Attempts != 5 || delivery.LastError == "" {
+		t.Errorf("notification 1 delivery = %+v, want 5 failed attempts", delivery)
+	}
+
+	client.down = nil
+	now = result.NextAttempt
+	if result, err = outbox.Process(t.Context()); err != nil || result.Delivered != 2 {
+		t.Fatalf("Process() = %+v, %v, want 2 delivered", result, err)
+	}
+	state, _ = outbox.store.Load(t.Context())
+	if len(state.Notifications) != 0 || len(state.Hosts) != 0 {
+		t.Errorf("state = %+v, want empty", state)
+	}
+}
+
+func TestProcessRemovesOnlyDeliveredNotifications(t *testing.T) {
+	client := &fakeClient{
+		pending: []*sgp22.PendingNotification{pendingNotification(4, "smdp.example.com")},
+		remove:  sgp22.ErrUndefined,
+	}
+	outbox := New(client, NewFileStore(filepath.Join(t.TempDir(), "outbox.json")))
+
+	if result, err := outbox.Process(t.Context()); err != nil || result.Failed != 1 {
+		t.Fatalf("Process() = %+v, %v, want 1 failed", result, err)
+	}
+	client.remove = nil
+	if result, err := outbox.Process(t.Context()); err != nil || result.Delivered != 1 {
+		t.Fatalf("Process() = %+v, %v, want 1 delivered", result, err)
+	}
+	if !slices.Equal(client.sent, []sgp22.SequenceNumber{4}) {
+		t.Errorf("sent = %v, want the notification sent once", client.sent)
+	}
+	if !slices.Equal(client.removed, []sgp22.SequenceNumber{4}) {
+		t.Errorf("removed = %v, want [4]", client.removed)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "outbox.json"))
+	state, err := store.Load(t.Context())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(state.Notifications) != 0 {
+		t.Errorf("Load() = %+v, want empty state", state)
+	}
+	want := &State{
+		Notifications: map[sgp22.SequenceNumber]Delivery{
+			7: {Address: "smdp.example.com", Event: sgp22.NotificationEventDelete, Attempts: 2, LastAttempt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), LastError: "timeout"},
+		},
+		Hosts: map[string]Host{
+			"smdp.example.com": {Failures: 2, NextAttempt: time.Date(2026, 1, 1, 0, 2, 0, 0, time.UTC)},
+		},
+	}
+	if err := store.Save(t.Context(), want); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, err := store.Load(t.Context())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+	matches, _ := filepath.Glob(store.path + ".*")
+	if len(matches) != 0 {
+		t.Errorf("temporary files = %v, want none", matches)
+	}
+}
@@ -0,0 +1,132 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// Delivery is the delivery state of a pending notification.
+type Delivery struct {
+	Address string                  `json:"address"`
+	Event   sgp22.NotificationEvent `json:"event"`
+	// Attempts is the number of times the notification was sent to the SM-DP+.
+	Attempts    int       `json:"attempts"`
+	LastAttempt time.Time `json:"lastAttempt,omitzero"`
+	LastError   string    `json:"lastError,omitempty"`
+	// Delivered reports whether the SM-DP+ accepted the notification,
+	// so only the removal from the eUICC is left.
+	Delivered bool `json:"delivered,omitempty"`
+}
+
+// Host is the backoff state of an SM-DP+ host.
+type Host struct {
+	// Failures is the number of consecutive failed deliveries to the host.
+	Failures    int       `json:"failures"`
+	NextAttempt time.Time `json:"nextAttempt"`
+}
+
+// State is the state of an outbox, persisted by a [Store] between calls to [Outbox.Process].
+type State struct {
+	Notifications map[sgp22.SequenceNumber]Delivery `json:"notifications,omitempty"`
+	Hosts         map[string]Host                   `json:"hosts,omitempty"`
+}
+
+func (s *State) clone() *State {
+	return &State{
+		Notifications: maps.Clone(s.Notifications),
+		Hosts:         maps.Clone(s.Hosts),
+	}
+}
+
+// Store persists the state of an outbox.
+// Sequence numbers are only unique on one eUICC, so a store must not be shared between eUICCs.
+type Store interface {
+	// Load returns the saved state, or an empty state if nothing was saved yet.
+	Load(ctx context.Context) (*State, error)
+	Save(ctx context.Context, state *State) error
+}
+
+// MemoryStore keeps the state in memory. It is safe for concurrent use.
+type MemoryStore struct {
+	mu    sync.Mutex
+	state State
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return new(MemoryStore)
+}
+
+func (s *MemoryStore) Load(context.Context) (*State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.clone(), nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, state *State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = *state.clone()
+	return nil
+}
+
+// FileStore keeps the state in a JSON file.
+// The file is replaced atomically, so a crash never leaves a partially written state.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore returns a store saving the state to the JSON file at path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) Load(context.Context) (*State, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return new(State), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := new(State)
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s *FileStore) Save(_ context.Context, state *State) (err error) {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	fp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, os.Remove(fp.Name()))
+		}
+	}()
+	if _, err = fp.Write(data); err != nil {
+		return errors.Join(err, fp.Close())
+	}
+	if err = fp.Sync(); err != nil {
+		return errors.Join(err, fp.Close())
+	}
+	if err = fp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(fp.Name(), s.path)
+}
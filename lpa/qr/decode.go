@@ -0,0 +1,673 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+	"slices"
+
+	"github.com/damonto/euicc-go/lpa"
+)
+
+// ErrNotFound is returned when no QR code can be located or read in an image.
+var ErrNotFound = errors.New("qr: no QR code found")
+
+// DecodeActivationCode decodes the QR code in img and parses its data as an activation code.
+// The activation code is returned whenever the QR code could be read, together with
+// the validation errors of its fields, see [lpa.ParseActivationCode].
+func DecodeActivationCode(img image.Image) (*lpa.ActivationCode, error) {
+	data, err := Decode(img)
+	if err != nil {
+		return nil, err
+	}
+	return lpa.ParseActivationCode(string(data))
+}
+
+// Decode locates a QR code in img and returns its data.
+// Both dark on light and light on dark symbols are recognised.
+func Decode(img image.Image) ([]byte, error) {
+	b := binarize(img)
+	var lastErr error
+	for range 2 {
+		data, err := b.decode()
+		if err == nil {
+			return data, nil
+		}
+		lastErr = err
+		b.invert()
+	}
+	return nil, lastErr
+}
+
+// binaryImage is a thresholded image, true pixels are dark.
+type binaryImage struct {
+	width, height int
+	pixels        []bool
+}
+
+// binarize converts img to a binary image with Otsu's threshold.
+func binarize(img image.Image) *binaryImage {
+	bounds := img.Bounds()
+	b := &binaryImage{width: bounds.Dx(), height: bounds.Dy()}
+	gray := make([]uint8, b.width*b.height)
+	var histogram [256]int
+	for y := range b.height {
+		for x := range b.width {
+			value := color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray).Y
+			gray[y*b.width+x] = value
+			histogram[value]++
+		}
+	}
+	var sum, sumBackground float64
+	for value, count := range histogram {
+		sum += float64(value * count)
+	}
+	var threshold, weightBackground int
+	var best float64
+	for value, count := range histogram {
+		if weightBackground += count; weightBackground == 0 {
+			continue
+		}
+		weightForeground := len(gray) - weightBackground
+		if weightForeground == 0 {
+			break
+		}
+		sumBackground += float64(value * count)
+		meanBackground := sumBackground / float64(weightBackground)
+		meanForeground := (sum - sumBackground) / float64(weightForeground)
+		variance := float64(weightBackground) * float64(weightForeground) * (meanBackground - meanForeground) * (meanBackground - meanForeground)
+		if variance > best {
+			best, threshold = variance, value
+		}
+	}
+	b.pixels = make([]bool, len(gray))
+	for index, value := range gray {
+		b.pixels[index] = int(value) <= threshold
+	}
+	return b
+}
+
+// dark reports whether the pixel is dark, pixels outside of the image are light.
+func (b *binaryImage) dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < b.width && y < b.height && b.pixels[y*b.width+x]
+}
+
+func (b *binaryImage) invert() {
+	for index := range b.pixels {
+		b.pixels[index] = !b.pixels[index]
+	}
+}
+
+// decode locates the finder patterns and reads the symbol they delimit.
+func (b *binaryImage) decode() ([]byte, error) {
+	topLeft, topRight, bottomLeft, ok := orderFinders(b.finders())
+	if !ok {
+		return nil, ErrNotFound
+	}
+	module := (topLeft.module + topRight.module + bottomLeft.module) / 3
+	distance := (math.Hypot(topRight.x-topLeft.x, topRight.y-topLeft.y) + math.Hypot(bottomLeft.x-topLeft.x, bottomLeft.y-topLeft.y)) / 2
+	estimate := int(math.Round((distance/module + 7 - 17) / 4))
+	var lastErr error = ErrNotFound
+	tried := make(map[int]bool)
+	versions := []int{estimate, estimate - 1, estimate + 1, estimate - 2, estimate + 2}
+	for len(versions) > 0 {
+		version := versions[0]
+		versions = versions[1:]
+		if version < minVersion || version > maxVersion || tried[version] {
+			continue
+		}
+		tried[version] = true
+		grid := b.sample(version, topLeft, topRight, bottomLeft)
+		if version >= 7 {
+			// The version information overrides the estimate from the finder pattern distance.
+			if actual, ok := readVersion(grid); ok && actual != version {
+				versions = append([]int{actual}, versions...)
+				continue
+			}
+		}
+		data, err := decodeGrid(version, grid)
+		if err == nil {
+			return data, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// finder is a candidate finder pattern centre.
+type finder struct {
+	x, y, module float64
+	count        int
+}
+
+// finders scans the rows of the image for the 1:1:3:1:1 pattern of the finder patterns
+// and confirms each candidate along the column and the row through its centre.
+func (b *binaryImage) finders() []*finder {
+	var candidates []*finder
+	for y := range b.height {
+		var runs [][2]int // start and length of each run, the colour alternates
+		for x := 0; x < b.width; {
+			start := x
+			for x < b.width && b.dark(x, y) == b.dark(start, y) {
+				x++
+			}
+			runs = append(runs, [2]int{start, x - start})
+		}
+		for index := 0; index+5 <= len(runs); index++ {
+			if !b.dark(runs[index][0], y) {
+				continue
+			}
+			var counts [5]int
+			for i := range counts {
+				counts[i] = runs[index+i][1]
+			}
+			if !finderRatio(counts) {
+				continue
+			}
+			x := runs[index+2][0] + runs[index+2][1]/2
+			cy, vertical, ok := b.crossCheck(x, y, false)
+			if !ok {
+				continue
+			}
+			cx, horizontal, ok := b.crossCheck(x, int(cy), true)
+			if !ok {
+				continue
+			}
+			module := (vertical + horizontal) / 2
+			candidates = mergeFinder(candidates, &finder{x: cx, y: cy, module: module, count: 1})
+		}
+	}
+	return candidates
+}
+
+func mergeFinder(candidates []*finder, f *finder) []*finder {
+	for _, candidate := range candidates {
+		if math.Abs(candidate.x-f.x) <= candidate.module && math.Abs(candidate.y-f.y) <= candidate.module &&
+			math.Abs(candidate.module-f.module) <= math.Max(1, candidate.module/2) {
+			n := float64(candidate.count)
+			candidate.x = (candidate.x*n + f.x) / (n + 1)
+			candidate.y = (candidate.y*n + f.y) / (n + 1)
+			candidate.module = (candidate.module*n + f.module) / (n + 1)
+			candidate.count++
+			return candidates
+		}
+	}
+	return append(candidates, f)
+}
+
+// crossCheck measures the finder pattern through (x, y) along a row or a column,
+// returning the centre coordinate on that line and the module size.
+func (b *binaryImage) crossCheck(x, y int, horizontal bool) (float64, float64, bool) {
+	position, limit := y, b.height
+	if horizontal {
+		position, limit = x, b.width
+	}
+	dark := func(i int) bool {
+		if horizontal {
+			return b.dark(i, y)
+		}
+		return b.dark(x, i)
+	}
+	if !dark(position) {
+		return 0, 0, false
+	}
+	start, end := position, position
+	for start > 0 && dark(start-1) {
+		start--
+	}
+	for end+1 < limit && dark(end+1) {
+		end++
+	}
+	var counts [5]int
+	counts[2] = end - start + 1
+	i := start - 1
+	for ; i >= 0 && !dark(i); i-- {
+		counts[1]++
+	}
+	for ; i >= 0 && dark(i); i-- {
+		counts[0]++
+	}
+	j := end + 1
+	for ; j < limit && !dark(j); j++ {
+		counts[3]++
+	}
+	for ; j < limit && dark(j); j++ {
+		counts[4]++
+	}
+	if !finderRatio(counts) {
+		return 0, 0, false
+	}
+	var total int
+	for _, count := range counts {
+		total += count
+	}
+	return float64(start+end+1) / 2, float64(total) / 7, true
+}
+
+// finderRatio reports whether the run lengths are close to 1:1:3:1:1.
+func finderRatio(counts [5]int) bool {
+	var total int
+	for _, count := range counts {
+		if count == 0 {
+			return false
+		}
+		total += count
+	}
+	if total < 7 {
+		return false
+	}
+	module := float64(total) / 7
+	variance := module / 2
+	for index, count := range counts {
+		expected := module
+		if index == 2 {
+			expected *= 3
+		}
+		if math.Abs(float64(count)-expected) >= variance*expected/module {
+			return false
+		}
+	}
+	return true
+}
+
+// orderFinders picks the three candidates that best form the corners of a symbol,
+// and returns them as the top left, top right and bottom left finder patterns.
+func orderFinders(candidates []*finder) (topLeft, topRight, bottomLeft *finder, ok bool) {
+	if len(candidates) > 3 {
+		confirmed := slices.DeleteFunc(slices.Clone(candidates), func(f *finder) bool { return f.count < 2 })
+		if len(confirmed) >= 3 {
+			candidates = confirmed
+		}
+		slices.SortFunc(candidates, func(a, b *finder) int { return b.count - a.count })
+		candidates = candidates[:min(len(candidates), 10)]
+	}
+	best := math.Inf(1)
+	for i := 0; i < len(candidates); i++ {
+		for j := i + 1; j < len(candidates); j++ {
+			for k := j + 1; k < len(candidates); k++ {
+				tl, tr, bl, score := cornerScore(candidates[i], candidates[j], candidates[k])
+				if score < best {
+					best, topLeft, topRight, bottomLeft = score, tl, tr, bl
+				}
+			}
+		}
+	}
+	return topLeft, topRight, bottomLeft, topLeft != nil && best < 1
+}
+
+// cornerScore orders three finder patterns and scores how far they are from an isosceles right triangle
+// with equal module sizes, lower is better.
+func cornerScore(a, b, c *finder) (topLeft, topRight, bottomLeft *finder, score float64) {
+	squared := func(p, q *finder) float64 {
+		return (p.x-q.x)*(p.x-q.x) + (p.y-q.y)*(p.y-q.y)
+	}
+	ab, bc, ca := squared(a, b), squared(b, c), squared(c, a)
+	// The top left pattern is opposite the hypotenuse.
+	switch {
+	case bc >= ab && bc >= ca:
+		topLeft, topRight, bottomLeft = a, b, c
+	case ca >= ab && ca >= bc:
+		topLeft, topRight, bottomLeft = b, c, a
+	default:
+		topLeft, topRight, bottomLeft = c, a, b
+	}
+	if (topRight.x-topLeft.x)*(bottomLeft.y-topLeft.y)-(topRight.y-topLeft.y)*(bottomLeft.x-topLeft.x) < 0 {
+		topRight, bottomLeft = bottomLeft, topRight
+	}
+	legs := [2]float64{squared(topLeft, topRight), squared(topLeft, bottomLeft)}
+	hypotenuse := squared(topRight, bottomLeft)
+	if legs[0] == 0 || legs[1] == 0 {
+		return nil, nil, nil, math.Inf(1)
+	}
+	modules := []float64{a.module, b.module, c.module}
+	spread := (slices.Max(modules) - slices.Min(modules)) / slices.Max(modules)
+	score = math.Abs(hypotenuse-legs[0]-legs[1])/hypotenuse + math.Abs(legs[0]-legs[1])/math.Max(legs[0], legs[1]) + spread
+	return topLeft, topRight, bottomLeft, score
+}
+
+// sample reads the modules of a version from the image, mapping the finder pattern centres,
+// and the bottom right alignment pattern when one is found, onto the module grid.
+func (b *binaryImage) sample(version int, topLeft, topRight, bottomLeft *finder) [][]bool {
+	size := float64(sizeOf(version))
+	bottomRight := [2]float64{topRight.x - topLeft.x + bottomLeft.x, topRight.y - topLeft.y + bottomLeft.y}
+	corner := [2]float64{size - 3.5, size - 3.5}
+	if version >= 2 {
+		affine := squareToQuad(
+			[2]float64{topLeft.x, topLeft.y},
+			[2]float64{topRight.x, topRight.y},
+			bottomRight,
+			[2]float64{bottomLeft.x, bottomLeft.y},
+		)
+		// Alignment pattern centre relative to the finder pattern centres.
+		t := (size - 6.5 - 3.5) / (size - 7)
+		if x, y, ok := b.alignment(affine, t, (topLeft.module+topRight.module+bottomLeft.module)/3, size-7); ok {
+			bottomRight = [2]float64{x, y}
+			corner = [2]float64{size - 6.5, size - 6.5}
+		} else {
+			bottomRight = affine.apply(t, t)
+			corner = [2]float64{size - 6.5, size - 6.5}
+		}
+	}
+	transform := quadToQuad(
+		[4][2]float64{{3.5, 3.5}, {size - 3.5, 3.5}, corner, {3.5, size - 3.5}},
+		[4][2]float64{{topLeft.x, topLeft.y}, {topRight.x, topRight.y}, bottomRight, {bottomLeft.x, bottomLeft.y}},
+	)
+	grid := make([][]bool, int(size))
+	for y := range grid {
+		grid[y] = make([]bool, int(size))
+		for x := range grid[y] {
+			point := transform.apply(float64(x)+0.5, float64(y)+0.5)
+			grid[y][x] = b.dark(int(math.Floor(point[0])), int(math.Floor(point[1])))
+		}
+	}
+	return grid
+}
+
+// alignment searches around the estimated position of the bottom right alignment pattern,
+// t being its position in the unit square spanned by the finder pattern centres.
+func (b *binaryImage) alignment(affine *perspective, t, module, span float64) (float64, float64, bool) {
+	estimate := affine.apply(t, t)
+	// Unit vectors of one module along the symbol axes.
+	origin := affine.apply(0, 0)
+	right := affine.apply(1/span, 0)
+	down := affine.apply(0, 1/span)
+	ux := [2]float64{right[0] - origin[0], right[1] - origin[1]}
+	uy := [2]float64{down[0] - origin[0], down[1] - origin[1]}
+	radius := int(math.Ceil(module * 4))
+	found, best := false, math.Inf(1)
+	var bx, by float64
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			cx, cy := estimate[0]+float64(dx), estimate[1]+float64(dy)
+			if !b.alignmentAt(cx, cy, ux, uy) {
+				continue
+			}
+			if distance := float64(dx*dx + dy*dy); distance < best {
+				found, best, bx, by = true, distance, cx, cy
+			}
+		}
+	}
+	return bx, by, found
+}
+
+// alignmentAt reports whether an alignment pattern is centred on (x, y).
+func (b *binaryImage) alignmentAt(x, y float64, ux, uy [2]float64) bool {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			px := x + float64(dx)*ux[0] + float64(dy)*uy[0]
+			py := y + float64(dx)*ux[1] + float64(dy)*uy[1]
+			if b.dark(int(math.Floor(px)), int(math.Floor(py))) != (max(abs(dx), abs(dy)) != 1) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// readVersion decodes the version information next to the top right finder pattern,
+// falling back to the copy next to the bottom left finder pattern.
+func readVersion(grid [][]bool) (int, bool) {
+	size := len(grid)
+	var first, second uint32
+	for index := range 18 {
+		a, b := size-11+index%3, index/3
+		if grid[b][a] {
+			first |= 1 << index
+		}
+		if grid[a][b] {
+			second |= 1 << index
+		}
+	}
+	for _, bits := range []uint32{first, second} {
+		for version := 7; version <= maxVersion; version++ {
+			if distance(bits, versionInformation(version)) <= 3 {
+				return version, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// readFormat decodes the error correction level and mask from either copy of the format information.
+func readFormat(grid [][]bool) (Level, int, error) {
+	size := len(grid)
+	var first, second uint32
+	bit := func(bits *uint32, index int, dark bool) {
+		if dark {
+			*bits |= 1 << index
+		}
+	}
+	for index := range 6 {
+		bit(&first, index, grid[index][8])
+	}
+	bit(&first, 6, grid[7][8])
+	bit(&first, 7, grid[8][8])
+	bit(&first, 8, grid[8][7])
+	for index := 9; index < 15; index++ {
+		bit(&first, index, grid[8][14-index])
+	}
+	for index := range 8 {
+		bit(&second, index, grid[8][size-1-index])
+	}
+	for index := 8; index < 15; index++ {
+		bit(&second, index, grid[size-15+index][8])
+	}
+	best, level, mask := 4, LevelL, 0
+	for l := LevelL; l <= LevelH; l++ {
+		for m := range 8 {
+			information := formatInformation(l, m)
+			if d := min(distance(first, information), distance(second, information)); d < best {
+				best, level, mask = d, l, m
+			}
+		}
+	}
+	if best > 3 {
+		return 0, 0, errors.New("qr: unreadable format information")
+	}
+	return level, mask, nil
+}
+
+func distance(a, b uint32) int {
+	return bits.OnesCount32(a ^ b)
+}
+
+// decodeGrid reads, corrects and decodes the codewords of a sampled symbol.
+func decodeGrid(version int, grid [][]bool) ([]byte, error) {
+	level, mask, err := readFormat(grid)
+	if err != nil {
+		return nil, err
+	}
+	raw := rawDataModules(version) / 8
+	codewords := make([]byte, raw)
+	var index int
+	dataModules(version, functionModules(version), func(x, y int) {
+		if index < raw*8 && grid[y][x] != masked(mask, x, y) {
+			codewords[index>>3] |= 1 << (7 - index&7)
+		}
+		index++
+	})
+	data, err := deinterleave(version, level, codewords)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSegments(version, data)
+}
+
+// deinterleave reverses interleave, correcting each block.
+func deinterleave(version int, level Level, codewords []byte) ([]byte, error) {
+	blocks := errorCorrectionBlocks[level][version]
+	eccLength := eccCodewordsPerBlock[level][version]
+	shortBlocks := blocks - len(codewords)%blocks
+	shortBlockLength := len(codewords) / blocks
+	encoded := make([][]byte, blocks)
+	for index := range encoded {
+		encoded[index] = make([]byte, shortBlockLength+1)
+	}
+	var offset int
+	for i := range shortBlockLength + 1 {
+		for j := range encoded {
+			if i != shortBlockLength-eccLength || j >= shortBlocks {
+				encoded[j][i] = codewords[offset]
+				offset++
+			}
+		}
+	}
+	var data []byte
+	for index, block := range encoded {
+		if index < shortBlocks {
+			block = slices.Delete(block, shortBlockLength-eccLength, shortBlockLength-eccLength+1)
+		}
+		if err := reedSolomonCorrect(block, eccLength); err != nil {
+			return nil, fmt.Errorf("block %d: %w", index, err)
+		}
+		data = append(data, block[:len(block)-eccLength]...)
+	}
+	return data, nil
+}
+
+// decodeSegments decodes the numeric, alphanumeric and byte mode segments of the data codewords.
+// ECI designators are skipped and the data is returned as is.
+func decodeSegments(version int, data []byte) ([]byte, error) {
+	reader := bitReader{data: data}
+	var text []byte
+	for reader.remaining() >= 4 {
+		m := mode(reader.read(4))
+		if m == 0 {
+			break
+		}
+		if m == 0b0111 {
+			// ECI designator of 1, 2 or 3 bytes
+			switch {
+			case reader.read(1) == 0:
+				reader.read(7)
+			case reader.read(1) == 0:
+				reader.read(14)
+			default:
+				reader.read(22)
+			}
+			continue
+		}
+		switch m {
+		case modeNumeric, modeAlphanumeric, modeByte:
+		default:
+			return nil, fmt.Errorf("qr: unsupported mode %04b", m)
+		}
+		count := int(reader.read(m.characterCountBits(version)))
+		if reader.remaining() < dataBits(m, count) {
+			return nil, errors.New("qr: segment exceeds the data")
+		}
+		switch m {
+		case modeNumeric:
+			for ; count >= 3; count -= 3 {
+				text = fmt.Appendf(text, "%03d", reader.read(10))
+			}
+			switch count {
+			case 2:
+				text = fmt.Appendf(text, "%02d", reader.read(7))
+			case 1:
+				text = fmt.Appendf(text, "%d", reader.read(4))
+			}
+		case modeAlphanumeric:
+			for ; count >= 2; count -= 2 {
+				pair := reader.read(11)
+				if pair >= 45*45 {
+					return nil, errors.New("qr: invalid alphanumeric data")
+				}
+				text = append(text, alphanumeric[pair/45], alphanumeric[pair%45])
+			}
+			if count == 1 {
+				single := reader.read(6)
+				if single >= 45 {
+					return nil, errors.New("qr: invalid alphanumeric data")
+				}
+				text = append(text, alphanumeric[single])
+			}
+		case modeByte:
+			for range count {
+				text = append(text, byte(reader.read(8)))
+			}
+		}
+	}
+	return text, nil
+}
+
+// bitReader reads big endian bit fields.
+type bitReader struct {
+	data   []byte
+	offset int
+}
+
+func (r *bitReader) remaining() int {
+	return len(r.data)*8 - r.offset
+}
+
+// read returns the next n bits, reading zeros past the end of the data.
+func (r *bitReader) read(n int) uint32 {
+	var value uint32
+	for range n {
+		value <<= 1
+		if r.offset < len(r.data)*8 {
+			value |= uint32(r.data[r.offset>>3]>>(7-r.offset&7)) & 1
+		}
+		r.offset++
+	}
+	return value
+}
+
+// perspective is a projective transform, mapping (x, y, 1) to (X·w, Y·w, w).
+type perspective [3][3]float64
+
+func (p *perspective) apply(x, y float64) [2]float64 {
+	w := p[2][0]*x + p[2][1]*y + p[2][2]
+	return [2]float64{(p[0][0]*x + p[0][1]*y + p[0][2]) / w, (p[1][0]*x + p[1][1]*y + p[1][2]) / w}
+}
+
+// squareToQuad maps the unit square corners (0,0), (1,0), (1,1) and (0,1) onto the quadrilateral p0, p1, p2, p3.
+func squareToQuad(p0, p1, p2, p3 [2]float64) *perspective {
+	dx3 := p0[0] - p1[0] + p2[0] - p3[0]
+	dy3 := p0[1] - p1[1] + p2[1] - p3[1]
+	if dx3 == 0 && dy3 == 0 {
+		return &perspective{
+			{p1[0] - p0[0], p3[0] - p0[0], p0[0]},
+			{p1[1] - p0[1], p3[1] - p0[1], p0[1]},
+			{0, 0, 1},
+		}
+	}
+	dx1, dx2 := p1[0]-p2[0], p3[0]-p2[0]
+	dy1, dy2 := p1[1]-p2[1], p3[1]-p2[1]
+	denominator := dx1*dy2 - dx2*dy1
+	g := (dx3*dy2 - dx2*dy3) / denominator
+	h := (dx1*dy3 - dx3*dy1) / denominator
+	return &perspective{
+		{p1[0] - p0[0] + g*p1[0], p3[0] - p0[0] + h*p3[0], p0[0]},
+		{p1[1] - p0[1] + g*p1[1], p3[1] - p0[1] + h*p3[1], p0[1]},
+		{g, h, 1},
+	}
+}
+
+// quadToQuad maps the corners of the from quadrilateral onto the corners of the to quadrilateral.
+func quadToQuad(from, to [4][2]float64) *perspective {
+	forward := squareToQuad(to[0], to[1], to[2], to[3])
+	backward := squareToQuad(from[0], from[1], from[2], from[3]).adjugate()
+	var product perspective
+	for i := range 3 {
+		for j := range 3 {
+			for k := range 3 {
+				product[i][j] += forward[i][k] * backward[k][j]
+			}
+		}
+	}
+	return &product
+}
+
+// adjugate returns the adjugate matrix, the inverse up to a scale factor, which projective transforms ignore.
+func (p *perspective) adjugate() *perspective {
+	return &perspective{
+		{p[1][1]*p[2][2] - p[1][2]*p[2][1], p[0][2]*p[2][1] - p[0][1]*p[2][2], p[0][1]*p[1][2] - p[0][2]*p[1][1]},
+		{p[1][2]*p[2][0] - p[1][0]*p[2][2], p[0][0]*p[2][2] - p[0][2]*p[2][0], p[0][2]*p[1][0] - p[0][0]*p[1][2]},
+		{p[1][0]*p[2][1] - p[1][1]*p[2][0], p[0][1]*p[2][0] - p[0][0]*p[2][1], p[0][0]*p[1][1] - p[0][1]*p[1][0]},
+	}
+}
@@ -0,0 +1,176 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/damonto/euicc-go/lpa"
+)
+
+func TestReedSolomonCorrect(t *testing.T) {
+	data := []byte{0x20, 0x5B, 0x0B, 0x78, 0xD1, 0x72, 0xDC, 0x4D, 0x43, 0x40, 0xEC, 0x11, 0xEC}
+	block := append(bytes.Clone(data), reedSolomonRemainder(data, reedSolomonGenerator(13))...)
+	for n := 0; n <= 6; n++ {
+		corrupted := bytes.Clone(block)
+		for index := range n {
+			corrupted[index*4] ^= byte(index + 1)
+		}
+		if err := reedSolomonCorrect(corrupted, 13); err != nil {
+			t.Errorf("reedSolomonCorrect() with %d errors error = %v", n, err)
+		} else if !bytes.Equal(corrupted, block) {
+			t.Errorf("reedSolomonCorrect() with %d errors = %X, want %X", n, corrupted, block)
+		}
+	}
+	corrupted := bytes.Clone(block)
+	for index := range 8 {
+		corrupted[index*3] ^= 0xFF
+	}
+	if err := reedSolomonCorrect(corrupted, 13); !errors.Is(err, ErrTooManyErrors) {
+		t.Errorf("reedSolomonCorrect() with 8 errors error = %v, want %v", err, ErrTooManyErrors)
+	}
+}
+
+func TestFunctionModules(t *testing.T) {
+	for version := minVersion; version <= maxVersion; version++ {
+		var n int
+		dataModules(version, functionModules(version), func(x, y int) { n++ })
+		if want := rawDataModules(version); n != want {
+			t.Errorf("dataModules(%d) = %d modules, want %d", version, n, want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		data  string
+		level Level
+		scale int
+	}{
+		{"HELLO WORLD", LevelQ, 1},
+		{"LPA:1$RSP.EXAMPLE.COM$ABCD-1234", LevelM, 3},
+		{"LPA:1$rsp.example.com$abcd-1234$1.3.6.1.4.1.31746$1", LevelL, 4},
+		{strings.Repeat("LPA:1$SMDP.EXAMPLE.COM$", 8), LevelH, 2},
+	}
+	for _, tt := range tests {
+		code, err := Encode([]byte(tt.data), tt.level)
+		if err != nil {
+			t.Fatalf("Encode(%q) error = %v", tt.data, err)
+		}
+		got, err := Decode(code.Image(tt.scale))
+		if err != nil {
+			t.Errorf("Decode() version %d error = %v", code.Version, err)
+			continue
+		}
+		if string(got) != tt.data {
+			t.Errorf("Decode() = %q, want %q", got, tt.data)
+		}
+	}
+}
+
+func TestDecodeTransformed(t *testing.T) {
+	const data = "LPA:1$RSP.EXAMPLE.COM$ABCD-1234-EFGH-5678"
+	code, err := Encode([]byte(data), LevelM)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	img := code.Image(6)
+	tests := map[string]image.Image{
+		"inverted": transform(img, 0, true),
+		"rotated":  transform(img, math.Pi/2, false),
+		"tilted":   transform(img, 0.3, false),
+	}
+	for name, img := range tests {
+		got, err := Decode(img)
+		if err != nil {
+			t.Errorf("Decode() %s error = %v", name, err)
+			continue
+		}
+		if string(got) != data {
+			t.Errorf("Decode() %s = %q, want %q", name, got, data)
+		}
+	}
+}
+
+func TestDecodeCorrupted(t *testing.T) {
+	const data = "LPA:1$RSP.EXAMPLE.COM$ABCD-1234"
+	code, err := Encode([]byte(data), LevelH)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	// Flip a patch of data modules in the bottom right corner.
+	for y := code.Size - 5; y < code.Size; y++ {
+		for x := code.Size - 5; x < code.Size; x++ {
+			code.modules[y][x] = !code.modules[y][x]
+		}
+	}
+	got, err := Decode(code.Image(2))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if string(got) != data {
+		t.Errorf("Decode() = %q, want %q", got, data)
+	}
+}
+
+func TestDecodeNotFound(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	if _, err := Decode(img); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Decode() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestDecodeActivationCode(t *testing.T) {
+	code, err := Encode([]byte("LPA:1$rsp.example.com$ABCD-1234$$1"), LevelM)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	ac, err := DecodeActivationCode(code.Image(4))
+	if err != nil {
+		t.Fatalf("DecodeActivationCode() error = %v", err)
+	}
+	if ac.SMDP.Host != "rsp.example.com" || ac.MatchingID != "ABCD-1234" || !ac.ConfirmationCodeRequired {
+		t.Errorf("DecodeActivationCode() = %+v", ac)
+	}
+
+	code, err = Encode([]byte("LPA:1$rsp.example.com$ABCD_1234"), LevelM)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	ac, err = DecodeActivationCode(code.Image(4))
+	var acErr *lpa.ActivationCodeError
+	if !errors.As(err, &acErr) || acErr.Field != lpa.ActivationCodeFieldMatchingID {
+		t.Errorf("DecodeActivationCode() error = %v, want a matching ID error", err)
+	}
+	if ac == nil || ac.MatchingID != "ABCD_1234" {
+		t.Errorf("DecodeActivationCode() = %+v, want the activation code with its errors", ac)
+	}
+}
+
+// transform rotates img about its centre by angle, optionally inverting it, onto a larger white canvas.
+func transform(img image.Image, angle float64, invert bool) image.Image {
+	bounds := img.Bounds()
+	size := bounds.Dx() * 3 / 2
+	result := image.NewGray(image.Rect(0, 0, size, size))
+	sin, cos := math.Sincos(angle)
+	centre, origin := float64(size)/2, float64(bounds.Dx())/2
+	for y := range size {
+		for x := range size {
+			dx, dy := float64(x)-centre, float64(y)-centre
+			sx := int(math.Floor(cos*dx + sin*dy + origin))
+			sy := int(math.Floor(-sin*dx + cos*dy + origin))
+			light := true
+			if image.Pt(sx, sy).In(bounds) {
+				light = color.GrayModel.Convert(img.At(sx, sy)).(color.Gray).Y > 127
+			}
+			if light != invert {
+				result.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,357 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/damonto/euicc-go/lpa"
+)
+
+// ErrDataTooLong is returned when the data does not fit in a version 40 symbol.
+var ErrDataTooLong = errors.New("qr: data too long")
+
+// alphanumeric is the character set of the alphanumeric mode, a character encodes as its index.
+const alphanumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// mode is the data encoding mode of a segment.
+type mode uint8
+
+const (
+	modeNumeric      mode = 0b0001
+	modeAlphanumeric mode = 0b0010
+	modeByte         mode = 0b0100
+)
+
+// characterCountBits returns the length of the character count indicator of a mode in a version.
+func (m mode) characterCountBits(version int) int {
+	var bits [3]int
+	switch m {
+	case modeNumeric:
+		bits = [3]int{10, 12, 14}
+	case modeAlphanumeric:
+		bits = [3]int{9, 11, 13}
+	case modeByte:
+		bits = [3]int{8, 16, 16}
+	}
+	switch {
+	case version <= 9:
+		return bits[0]
+	case version <= 26:
+		return bits[1]
+	}
+	return bits[2]
+}
+
+// EncodeActivationCode encodes the URI form of the activation code ("LPA:1$...") as a QR code.
+func EncodeActivationCode(ac *lpa.ActivationCode, level Level) (*Code, error) {
+	text, err := ac.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return Encode(text, level)
+}
+
+// Encode encodes data as a QR code of the smallest version that fits it at the error correction level.
+// Data made of upper case letters, digits and " $%*+-./:" uses the alphanumeric mode, anything else the byte mode.
+func Encode(data []byte, level Level) (*Code, error) {
+	if level > LevelH {
+		return nil, fmt.Errorf("qr: invalid error correction level %d", level)
+	}
+	m := modeAlphanumeric
+	for _, b := range data {
+		if strings.IndexByte(alphanumeric, b) < 0 {
+			m = modeByte
+			break
+		}
+	}
+	for version := minVersion; version <= maxVersion; version++ {
+		capacity := dataCodewords(version, level) * 8
+		if 4+m.characterCountBits(version)+dataBits(m, len(data)) > capacity {
+			continue
+		}
+		var buffer bitBuffer
+		buffer.append(uint32(m), 4)
+		buffer.append(uint32(len(data)), m.characterCountBits(version))
+		switch m {
+		case modeAlphanumeric:
+			for index := 0; index+1 < len(data); index += 2 {
+				buffer.append(uint32(strings.IndexByte(alphanumeric, data[index])*45+strings.IndexByte(alphanumeric, data[index+1])), 11)
+			}
+			if len(data)%2 == 1 {
+				buffer.append(uint32(strings.IndexByte(alphanumeric, data[len(data)-1])), 6)
+			}
+		case modeByte:
+			for _, b := range data {
+				buffer.append(uint32(b), 8)
+			}
+		}
+		// Terminator, then pad to a byte boundary and fill the capacity with the pad codewords.
+		buffer.append(0, min(4, capacity-buffer.len()))
+		buffer.append(0, (8-buffer.len()%8)%8)
+		for pad := uint32(0xEC); buffer.len() < capacity; pad ^= 0xEC ^ 0x11 {
+			buffer.append(pad, 8)
+		}
+		return newCode(version, level, interleave(version, level, buffer.bytes())), nil
+	}
+	return nil, ErrDataTooLong
+}
+
+// dataBits returns the number of bits of n characters in a mode, excluding the headers.
+func dataBits(m mode, n int) int {
+	switch m {
+	case modeNumeric:
+		return n/3*10 + [...]int{0, 4, 7}[n%3]
+	case modeAlphanumeric:
+		return n/2*11 + n%2*6
+	}
+	return n * 8
+}
+
+// interleave splits the data codewords into blocks, appends the error correction codewords
+// of each block and interleaves the blocks.
+func interleave(version int, level Level, data []byte) []byte {
+	blocks := errorCorrectionBlocks[level][version]
+	eccLength := eccCodewordsPerBlock[level][version]
+	raw := rawDataModules(version) / 8
+	shortBlocks := blocks - raw%blocks
+	shortBlockLength := raw / blocks
+	generator := reedSolomonGenerator(eccLength)
+	encoded := make([][]byte, blocks)
+	for index, offset := 0, 0; index < blocks; index++ {
+		length := shortBlockLength - eccLength
+		if index >= shortBlocks {
+			length++
+		}
+		block := append([]byte(nil), data[offset:offset+length]...)
+		offset += length
+		ecc := reedSolomonRemainder(block, generator)
+		if index < shortBlocks {
+			// Short blocks are padded so all blocks have the same length, the pad is skipped when interleaving.
+			block = append(block, 0)
+		}
+		encoded[index] = append(block, ecc...)
+	}
+	result := make([]byte, 0, raw)
+	for i := range encoded[0] {
+		for j, block := range encoded {
+			if i != shortBlockLength-eccLength || j >= shortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// newCode draws the symbol of the codewords with the mask of the lowest penalty.
+func newCode(version int, level Level, codewords []byte) *Code {
+	code := &Code{Version: version, Level: level, Size: sizeOf(version)}
+	reserved := functionModules(version)
+	code.modules = make([][]bool, code.Size)
+	for y := range code.modules {
+		code.modules[y] = make([]bool, code.Size)
+	}
+	code.drawFunctionPatterns()
+	var index int
+	dataModules(version, reserved, func(x, y int) {
+		if index < len(codewords)*8 {
+			code.modules[y][x] = codewords[index>>3]>>(7-index&7)&1 == 1
+		}
+		index++
+	})
+	best := -1
+	for mask := range 8 {
+		code.applyMask(reserved, mask)
+		code.drawFormatInformation(mask)
+		if penalty := code.penalty(); best < 0 || penalty < best {
+			best, code.Mask = penalty, mask
+		}
+		// Applying the mask again reverts it.
+		code.applyMask(reserved, mask)
+	}
+	code.applyMask(reserved, code.Mask)
+	code.drawFormatInformation(code.Mask)
+	return code
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+}
+
+func (c *Code) drawFunctionPatterns() {
+	for index := range c.Size {
+		c.set(6, index, index%2 == 0)
+		c.set(index, 6, index%2 == 0)
+	}
+	for _, centre := range [][2]int{{3, 3}, {c.Size - 4, 3}, {3, c.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := centre[0]+dx, centre[1]+dy
+				if x >= 0 && y >= 0 && x < c.Size && y < c.Size {
+					distance := max(abs(dx), abs(dy))
+					c.set(x, y, distance != 2 && distance != 4)
+				}
+			}
+		}
+	}
+	positions := alignmentPositions(c.Version)
+	for i, y := range positions {
+		for j, x := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == len(positions)-1) || (i == len(positions)-1 && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	if c.Version >= 7 {
+		bits := versionInformation(c.Version)
+		for index := range 18 {
+			dark := bits>>index&1 == 1
+			a, b := c.Size-11+index%3, index/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+func (c *Code) drawFormatInformation(mask int) {
+	bits := formatInformation(c.Level, mask)
+	bit := func(index int) bool {
+		return bits>>index&1 == 1
+	}
+	// Around the top left finder pattern
+	for index := range 6 {
+		c.set(8, index, bit(index))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for index := 9; index < 15; index++ {
+		c.set(14-index, 8, bit(index))
+	}
+	// Next to the top right and bottom left finder patterns
+	for index := range 8 {
+		c.set(c.Size-1-index, 8, bit(index))
+	}
+	for index := 8; index < 15; index++ {
+		c.set(8, c.Size-15+index, bit(index))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+func (c *Code) applyMask(reserved [][]bool, mask int) {
+	for y := range c.Size {
+		for x := range c.Size {
+			if !reserved[y][x] && masked(mask, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol with the four rules of ISO/IEC 18004 Section 7.8.3.
+func (c *Code) penalty() int {
+	var penalty, dark int
+	line := make([]bool, c.Size)
+	for _, horizontal := range []bool{true, false} {
+		for i := range c.Size {
+			for j := range c.Size {
+				if horizontal {
+					line[j] = c.modules[i][j]
+				} else {
+					line[j] = c.modules[j][i]
+				}
+			}
+			penalty += linePenalty(line)
+		}
+	}
+	for y := range c.Size {
+		for x := range c.Size {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size {
+				color := c.modules[y][x]
+				if c.modules[y][x+1] == color && c.modules[y+1][x] == color && c.modules[y+1][x+1] == color {
+					penalty += 3
+				}
+			}
+		}
+	}
+	total := c.Size * c.Size
+	// Each 5% deviation from an equal number of dark and light modules adds 10 points.
+	penalty += (abs(dark*20-total*10)+total-1)/total*10 - 10
+	return penalty
+}
+
+// finderLike is the 1:1:3:1:1 finder pattern preceded or followed by 4 light modules.
+var finderLike = [2][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// linePenalty scores the runs of the same color and the finder-like patterns of a row or column.
+func linePenalty(line []bool) int {
+	var penalty int
+	for start := 0; start < len(line); {
+		end := start
+		for end < len(line) && line[end] == line[start] {
+			end++
+		}
+		if run := end - start; run >= 5 {
+			penalty += run - 2
+		}
+		start = end
+	}
+	for start := 0; start+11 <= len(line); start++ {
+		for _, pattern := range finderLike {
+			match := true
+			for index, dark := range pattern {
+				if line[start+index] != dark {
+					match = false
+					break
+				}
+			}
+			if match {
+				penalty += 40
+			}
+		}
+	}
+	return penalty
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// bitBuffer is a sequence of bits, appended most significant bit first.
+type bitBuffer struct {
+	bits []bool
+}
+
+func (b *bitBuffer) append(value uint32, n int) {
+	for index := n - 1; index >= 0; index-- {
+		b.bits = append(b.bits, value>>index&1 == 1)
+	}
+}
+
+func (b *bitBuffer) len() int {
+	return len(b.bits)
+}
+
+func (b *bitBuffer) bytes() []byte {
+	data := make([]byte, (len(b.bits)+7)/8)
+	for index, bit := range b.bits {
+		if bit {
+			data[index>>3] |= 1 << (7 - index&7)
+		}
+	}
+	return data
+}
@@ -0,0 +1,106 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/damonto/euicc-go/lpa"
+)
+
+func TestReedSolomonRemainder(t *testing.T) {
+	// "HELLO WORLD" encoded as a 1-Q symbol.
+	data := []byte{0x20, 0x5B, 0x0B, 0x78, 0xD1, 0x72, 0xDC, 0x4D, 0x43, 0x40, 0xEC, 0x11, 0xEC}
+	want := []byte{168, 72, 22, 82, 217, 54, 156, 0, 46, 15, 180, 122, 16}
+	if got := reedSolomonRemainder(data, reedSolomonGenerator(13)); !bytes.Equal(got, want) {
+		t.Errorf("reedSolomonRemainder() = %v, want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionInformation(t *testing.T) {
+	if got, want := formatInformation(LevelL, 0), uint32(0b111011111000100); got != want {
+		t.Errorf("formatInformation(L, 0) = %015b, want %015b", got, want)
+	}
+	if got, want := formatInformation(LevelM, 0), uint32(0b101010000010010); got != want {
+		t.Errorf("formatInformation(M, 0) = %015b, want %015b", got, want)
+	}
+	if got, want := versionInformation(7), uint32(0b000111110010010100); got != want {
+		t.Errorf("versionInformation(7) = %018b, want %018b", got, want)
+	}
+}
+
+func TestDataCodewords(t *testing.T) {
+	tests := []struct {
+		version int
+		level   Level
+		want    int
+	}{
+		{1, LevelL, 19},
+		{1, LevelH, 9},
+		{2, LevelM, 28},
+		{10, LevelL, 274},
+		{10, LevelM, 216},
+		{40, LevelL, 2956},
+		{40, LevelH, 1276},
+	}
+	for _, tt := range tests {
+		if got := dataCodewords(tt.version, tt.level); got != tt.want {
+			t.Errorf("dataCodewords(%d, %s) = %d, want %d", tt.version, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	code, err := Encode([]byte("HELLO WORLD"), LevelQ)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if code.Version != 1 || code.Size != 21 {
+		t.Errorf("Encode() version = %d, size = %d, want 1, 21", code.Version, code.Size)
+	}
+	// Top left finder pattern and the dark module
+	for _, module := range [][2]int{{0, 0}, {6, 0}, {2, 2}, {8, code.Size - 8}} {
+		if !code.Dark(module[0], module[1]) {
+			t.Errorf("Dark(%d, %d) = false", module[0], module[1])
+		}
+	}
+	if code.Dark(7, 0) || code.Dark(1, 1) {
+		t.Error("separator or finder ring is dark")
+	}
+	if _, err := Encode(bytes.Repeat([]byte{'a'}, 3000), LevelL); err != ErrDataTooLong {
+		t.Errorf("Encode() error = %v, want %v", err, ErrDataTooLong)
+	}
+}
+
+func TestEncodeActivationCode(t *testing.T) {
+	ac := &lpa.ActivationCode{
+		SMDP:       &url.URL{Scheme: "https", Host: "smdp.example.com"},
+		MatchingID: "QR-G-5C-1LS-1W1Z9P7",
+	}
+	code, err := EncodeActivationCode(ac, LevelM)
+	if err != nil {
+		t.Fatalf("EncodeActivationCode() error = %v", err)
+	}
+	var buffer bytes.Buffer
+	if err := code.WritePNG(&buffer, 4); err != nil {
+		t.Fatalf("WritePNG() error = %v", err)
+	}
+	img, err := png.Decode(&buffer)
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	if got, want := img.Bounds().Dx(), (code.Size+2*quietZone)*4; got != want {
+		t.Errorf("PNG width = %d, want %d", got, want)
+	}
+	if svg := code.SVG(); !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, "M4,4h1v1h-1z") {
+		t.Errorf("SVG() = %.80s...", svg)
+	}
+	if lines := strings.Count(code.Terminal(), "\n"); lines != (code.Size+2*quietZone+1)/2 {
+		t.Errorf("Terminal() has %d lines, want %d", lines, (code.Size+2*quietZone+1)/2)
+	}
+	if _, err := EncodeActivationCode(&lpa.ActivationCode{}, LevelM); err == nil {
+		t.Error("EncodeActivationCode() error = nil for an empty activation code")
+	}
+}
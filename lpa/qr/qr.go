@@ -0,0 +1,213 @@
+// Package qr encodes and decodes activation codes as QR codes.
+//
+// See ISO/IEC 18004:2015 (QR Code bar code symbology specification)
+package qr
+
+import "fmt"
+
+// Level is the error correction level of a QR code.
+type Level uint8
+
+const (
+	LevelL Level = iota // Recovers about 7% of the codewords
+	LevelM              // Recovers about 15% of the codewords
+	LevelQ              // Recovers about 25% of the codewords
+	LevelH              // Recovers about 30% of the codewords
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelL:
+		return "L"
+	case LevelM:
+		return "M"
+	case LevelQ:
+		return "Q"
+	case LevelH:
+		return "H"
+	}
+	return fmt.Sprintf("unknown(%d)", l)
+}
+
+// formatBits returns the error correction level indicator of the format information.
+func (l Level) formatBits() uint32 {
+	return [...]uint32{1, 0, 3, 2}[l]
+}
+
+const (
+	minVersion = 1
+	maxVersion = 40
+	// quietZone is the width of the light border around the symbol, in modules.
+	quietZone = 4
+)
+
+// eccCodewordsPerBlock is the number of error correction codewords per block, indexed by level and version.
+var eccCodewordsPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+// errorCorrectionBlocks is the number of error correction blocks, indexed by level and version.
+var errorCorrectionBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// Code is a QR code symbol.
+type Code struct {
+	Version int
+	Level   Level
+	Mask    int
+	Size    int
+	modules [][]bool
+}
+
+// Dark reports whether the module at column x and row y is dark.
+// Modules outside of the symbol are light.
+func (c *Code) Dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < c.Size && y < c.Size && c.modules[y][x]
+}
+
+// sizeOf returns the number of modules per side of a version.
+func sizeOf(version int) int {
+	return version*4 + 17
+}
+
+// rawDataModules returns the number of modules available for codewords,
+// i.e. the modules not used by function patterns and format or version information.
+func rawDataModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		alignments := version/7 + 2
+		n -= (25*alignments-10)*alignments - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+// dataCodewords returns the number of data codewords of a version and level.
+func dataCodewords(version int, level Level) int {
+	return rawDataModules(version)/8 - eccCodewordsPerBlock[level][version]*errorCorrectionBlocks[level][version]
+}
+
+// alignmentPositions returns the centre coordinates of the alignment patterns of a version.
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	alignments := version/7 + 2
+	step := (version*8 + alignments*3 + 5) / (alignments*4 - 4) * 2
+	positions := make([]int, alignments)
+	positions[0] = 6
+	for index, position := alignments-1, sizeOf(version)-7; index >= 1; index, position = index-1, position-step {
+		positions[index] = position
+	}
+	return positions
+}
+
+// formatInformation returns the 15 bit BCH encoded format information of a level and mask.
+func formatInformation(level Level, mask int) uint32 {
+	data := level.formatBits()<<3 | uint32(mask)
+	remainder := data
+	for range 10 {
+		remainder = remainder<<1 ^ (remainder>>9)*0x537
+	}
+	return (data<<10 | remainder) ^ 0x5412
+}
+
+// versionInformation returns the 18 bit BCH encoded version information of versions 7 and above.
+func versionInformation(version int) uint32 {
+	remainder := uint32(version)
+	for range 12 {
+		remainder = remainder<<1 ^ (remainder>>11)*0x1F25
+	}
+	return uint32(version)<<12 | remainder
+}
+
+// masked reports whether mask inverts the module at column x and row y.
+func masked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	case 7:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+	panic("qr: invalid mask")
+}
+
+// functionModules returns the modules of a version reserved for function patterns and format and version information.
+func functionModules(version int) [][]bool {
+	size := sizeOf(version)
+	reserved := make([][]bool, size)
+	for y := range reserved {
+		reserved[y] = make([]bool, size)
+	}
+	fill := func(x0, y0, width, height int) {
+		for y := max(y0, 0); y < min(y0+height, size); y++ {
+			for x := max(x0, 0); x < min(x0+width, size); x++ {
+				reserved[y][x] = true
+			}
+		}
+	}
+	// Timing patterns
+	fill(6, 0, 1, size)
+	fill(0, 6, size, 1)
+	// Finder patterns, separators and format information
+	fill(0, 0, 9, 9)
+	fill(size-8, 0, 8, 9)
+	fill(0, size-8, 9, 8)
+	positions := alignmentPositions(version)
+	for i, y := range positions {
+		for j, x := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == len(positions)-1) || (i == len(positions)-1 && j == 0) {
+				continue
+			}
+			fill(x-2, y-2, 5, 5)
+		}
+	}
+	if version >= 7 {
+		fill(size-11, 0, 3, 6)
+		fill(0, size-11, 6, 3)
+	}
+	return reserved
+}
+
+// dataModules calls fn for every data module of a version, in the order the codeword bits are placed.
+func dataModules(version int, reserved [][]bool, fn func(x, y int)) {
+	size := sizeOf(version)
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vertical := range size {
+			y := vertical
+			if upward {
+				y = size - 1 - vertical
+			}
+			for column := range 2 {
+				if x := right - column; !reserved[y][x] {
+					fn(x, y)
+				}
+			}
+		}
+	}
+}
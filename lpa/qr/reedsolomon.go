@@ -0,0 +1,167 @@
+package qr
+
+import "errors"
+
+// ErrTooManyErrors is returned when a block has more errors than its error correction codewords can correct.
+var ErrTooManyErrors = errors.New("qr: too many errors")
+
+// gfExp and gfLog are the exponent and logarithm tables of GF(2^8) with generator 2.
+var gfExp, gfLog = gfTables()
+
+func gfTables() (exp [510]byte, log [256]int) {
+	x := byte(1)
+	for index := range 255 {
+		exp[index], exp[index+255] = x, x
+		log[x] = index
+		x = gfMultiply(x, 0x02)
+	}
+	return exp, log
+}
+
+// gfMultiply multiplies two elements of GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	var z uint16
+	for index := 7; index >= 0; index-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= uint16(y>>index&1) * uint16(x)
+	}
+	return byte(z)
+}
+
+// gfDivide divides x by the non-zero y.
+func gfDivide(x, y byte) byte {
+	if x == 0 {
+		return 0
+	}
+	return gfExp[gfLog[x]+255-gfLog[y]]
+}
+
+// gfPower returns 2 raised to n.
+func gfPower(n int) byte {
+	return gfExp[(n%255+255)%255]
+}
+
+// reedSolomonGenerator returns the coefficients of the generator polynomial of a degree,
+// from the highest to the lowest power, excluding the leading 1.
+func reedSolomonGenerator(degree int) []byte {
+	generator := make([]byte, degree)
+	generator[degree-1] = 1
+	root := byte(1)
+	for range degree {
+		for index := range generator {
+			generator[index] = gfMultiply(generator[index], root)
+			if index+1 < len(generator) {
+				generator[index] ^= generator[index+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return generator
+}
+
+// reedSolomonRemainder returns the error correction codewords of data.
+func reedSolomonRemainder(data, generator []byte) []byte {
+	remainder := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[len(remainder)-1] = 0
+		for index, coefficient := range generator {
+			remainder[index] ^= gfMultiply(coefficient, factor)
+		}
+	}
+	return remainder
+}
+
+// evaluate evaluates the polynomial p, stored from the lowest to the highest power, at x.
+func evaluate(p []byte, x byte) byte {
+	var y byte
+	for index := len(p) - 1; index >= 0; index-- {
+		y = gfMultiply(y, x) ^ p[index]
+	}
+	return y
+}
+
+// syndromes returns the syndromes of a block, the first codeword being the highest power.
+func syndromes(block []byte, eccLength int) ([]byte, bool) {
+	s := make([]byte, eccLength)
+	var corrupted bool
+	for index := range s {
+		root := gfPower(index)
+		for _, codeword := range block {
+			s[index] = gfMultiply(s[index], root) ^ codeword
+		}
+		corrupted = corrupted || s[index] != 0
+	}
+	return s, corrupted
+}
+
+// reedSolomonCorrect corrects up to eccLength/2 erroneous codewords of a block in place,
+// using the Berlekamp-Massey algorithm, a Chien search and the Forney algorithm.
+func reedSolomonCorrect(block []byte, eccLength int) error {
+	s, corrupted := syndromes(block, eccLength)
+	if !corrupted {
+		return nil
+	}
+	locator, previous := []byte{1}, []byte{1}
+	var count int
+	shift, scale := 1, byte(1)
+	for n := range eccLength {
+		discrepancy := s[n]
+		for index := 1; index <= count && index < len(locator); index++ {
+			discrepancy ^= gfMultiply(locator[index], s[n-index])
+		}
+		if discrepancy == 0 {
+			shift++
+			continue
+		}
+		next := make([]byte, max(len(locator), len(previous)+shift))
+		copy(next, locator)
+		coefficient := gfDivide(discrepancy, scale)
+		for index, p := range previous {
+			next[index+shift] ^= gfMultiply(coefficient, p)
+		}
+		if 2*count <= n {
+			previous, count, scale, shift = locator, n+1-count, discrepancy, 1
+		} else {
+			shift++
+		}
+		locator = next
+	}
+	if 2*count > eccLength {
+		return ErrTooManyErrors
+	}
+	var positions []int
+	for index := range block {
+		if evaluate(locator, gfPower(-(len(block)-1-index))) == 0 {
+			positions = append(positions, index)
+		}
+	}
+	if len(positions) != count {
+		return ErrTooManyErrors
+	}
+	// The error evaluator is S(x)Λ(x) mod x^eccLength.
+	evaluator := make([]byte, eccLength)
+	for i := range evaluator {
+		for j := 0; j <= i && j < len(locator); j++ {
+			evaluator[i] ^= gfMultiply(s[i-j], locator[j])
+		}
+	}
+	for _, index := range positions {
+		power := len(block) - 1 - index
+		x, inverse := gfPower(power), gfPower(-power)
+		// The formal derivative of Λ keeps the odd powers.
+		var derivative byte
+		for degree := 1; degree < len(locator); degree += 2 {
+			derivative ^= gfMultiply(locator[degree], gfPower(-power*(degree-1)))
+		}
+		if derivative == 0 {
+			return ErrTooManyErrors
+		}
+		block[index] ^= gfMultiply(x, gfDivide(evaluate(evaluator, inverse), derivative))
+	}
+	if _, corrupted := syndromes(block, eccLength); corrupted {
+		return ErrTooManyErrors
+	}
+	return nil
+}
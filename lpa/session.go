@@ -0,0 +1,282 @@
+package lpa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// ErrConfirmationCodeRequired is returned by [DownloadSession.Confirm] when the SM-DP+
+// requires a confirmation code and none was given.
+var ErrConfirmationCodeRequired = errors.New("confirmation code is required")
+
+// DownloadSessionState is the step a download session has reached.
+type DownloadSessionState uint8
+
+const (
+	DownloadSessionNew DownloadSessionState = iota
+	DownloadSessionStarted
+	DownloadSessionConfirmed
+	DownloadSessionInstalled
+	DownloadSessionCanceled
+)
+
+// String returns a string representation of the DownloadSessionState.
+func (s DownloadSessionState) String() string {
+	switch s {
+	case DownloadSessionNew:
+		return "New"
+	case DownloadSessionStarted:
+		return "Started"
+	case DownloadSessionConfirmed:
+		return "Confirmed"
+	case DownloadSessionInstalled:
+		return "Installed"
+	case DownloadSessionCanceled:
+		return "Canceled"
+	default:
+		return fmt.Sprintf("Unknown State (%d)", s)
+	}
+}
+
+// DownloadSession downloads a profile in explicit steps, so the end user can confirm
+// the download in a separate request: Start, Confirm and Install, or Cancel at any point before Install.
+//
+// The session is serialised with [json.Marshal] and restored with [Client.ResumeDownloadSession],
+// which allows a server to pause between steps. The serialised session leaves out the confirmation code,
+// so a confirmed session requiring one is resumed as started and confirmed again. The eUICC keeps its own session state, so the session must be resumed
+// with a client of the same eUICC and no other download in between.
+type DownloadSession struct {
+	// OnProgress is called when the session enters a download stage, see DownloadOptions.OnProgress.
+	// It is not serialised.
+	OnProgress func(stage DownloadStage)
+	// OnInstallProgress is called after each segment of the bound profile package is sent to the eUICC.
+	// It is not serialised.
+	OnInstallProgress func(progress InstallProgress)
+
+	client         *Client
+	ac             *ActivationCode
+	state          DownloadSessionState
+	clientResponse *sgp22.ES9AuthenticateClientResponse
+	metadata       *sgp22.ProfileInfo
+	ccRequired     bool
+}
+
+// NewDownloadSession creates a download session for the activation code.
+func (c *Client) NewDownloadSession(ac *ActivationCode) *DownloadSession {
+	return &DownloadSession{client: c, ac: ac}
+}
+
+// State returns the step the session has reached.
+func (s *DownloadSession) State() DownloadSessionState {
+	return s.state
+}
+
+// Metadata returns the metadata of the profile, available once the session is started.
+func (s *DownloadSession) Metadata() *sgp22.ProfileInfo {
+	return s.metadata
+}
+
+// TransactionID returns the transaction ID assigned by the SM-DP+, available once the session is started.
+func (s *DownloadSession) TransactionID() []byte {
+	if s.clientResponse == nil {
+		return nil
+	}
+	return s.clientResponse.TransactionID
+}
+
+// ConfirmationCodeRequired reports whether the SM-DP+ requires a confirmation code.
+func (s *DownloadSession) ConfirmationCodeRequired() bool {
+	return s.ccRequired
+}
+
+// Start authenticates the eUICC and the SM-DP+ and returns the metadata of the profile.
+// If the metadata cannot be decoded or verified, the session is canceled.
+func (s *DownloadSession) Start(ctx context.Context) (*sgp22.ProfileInfo, error) {
+	if err := s.expect(DownloadSessionNew); err != nil {
+		return nil, err
+	}
+	if err := s.ac.validate(); err != nil {
+		return nil, err
+	}
+	if s.client.isCanceled(ctx) {
+		return nil, ctx.Err()
+	}
+	s.progress(DownloadStageAuthenticateClient)
+	clientResponse, metadata, ccRequired, err := s.client.authenticateClient(ctx, s.ac)
+	if err != nil {
+		if clientResponse != nil && clientResponse.FunctionExecutionStatus().ExecutedSuccess() {
+			s.clientResponse = clientResponse
+			return nil, s.abort(ctx, err, verificationCancelReason(err, sgp22.CancelSessionReasonMetadataMismatch))
+		}
+		return nil, err
+	}
+	s.clientResponse, s.metadata, s.ccRequired = clientResponse, metadata, ccRequired
+	s.state = DownloadSessionStarted
+	return metadata, nil
+}
+
+// Confirm records the end user's consent to download the profile, with the confirmation code if one is required.
+// An empty code keeps the confirmation code of the activation code. It returns [ErrConfirmationCodeRequired]
+// without canceling the session when a required code is missing, so it can be asked for again.
+func (s *DownloadSession) Confirm(code string) error {
+	if err := s.expect(DownloadSessionStarted); err != nil {
+		return err
+	}
+	if code != "" {
+		s.ac.ConfirmationCode = code
+	}
+	if s.ccRequired && s.ac.ConfirmationCode == "" {
+		return ErrConfirmationCodeRequired
+	}
+	s.state = DownloadSessionConfirmed
+	return nil
+}
+
+// Install fetches the bound profile package from the SM-DP+ and loads it onto the eUICC.
+// The session is canceled if any of the steps fails. The install notification is then
+// handled according to the notification policy of the client.
+func (s *DownloadSession) Install(ctx context.Context) (*sgp22.LoadBoundProfilePackageResponse, error) {
+	if err := s.expect(DownloadSessionConfirmed); err != nil {
+		return nil, err
+	}
+	s.progress(DownloadStageAuthenticateServer)
+	if s.client.isCanceled(ctx) {
+		return nil, s.Cancel(context.WithoutCancel(ctx), sgp22.CancelSessionReasonPostponed)
+	}
+	serverResponse, err := s.client.authenticateServer(ctx, s.ac, s.clientResponse, s.progress)
+	if err != nil {
+		return nil, s.abort(ctx, err, sgp22.CancelSessionReasonPostponed)
+	}
+	if s.client.verifier != nil {
+		if err := s.client.verifier.VerifyBoundProfilePackage(s.clientResponse.TransactionID, s.metadata, serverResponse); err != nil {
+			return nil, s.abort(ctx, err, verificationCancelReason(err, sgp22.CancelSessionReasonUndefined))
+		}
+	}
+
+	s.progress(DownloadStageInstall)
+	if s.client.isCanceled(ctx) {
+		return nil, s.Cancel(context.WithoutCancel(ctx), sgp22.CancelSessionReasonPostponed)
+	}
+	result, err := s.client.install(ctx, serverResponse, s.progress, s.installProgress)
+	if err != nil {
+		return result, s.abort(ctx, err, sgp22.CancelSessionReasonLoadBppExecutionError)
+	}
+	s.state = DownloadSessionInstalled
+	if s.client.notificationPolicy == NotificationPolicyImmediate {
+		s.progress(DownloadStageSendNotification)
+	}
+	s.client.notify(ctx, result.Notification)
+	return result, nil
+}
+
+// Cancel cancels a started or confirmed session on the eUICC and the SM-DP+.
+func (s *DownloadSession) Cancel(ctx context.Context, reason sgp22.CancelSessionReason) error {
+	if s.state != DownloadSessionStarted && s.state != DownloadSessionConfirmed {
+		return fmt.Errorf("download session is %s", s.state)
+	}
+	s.state = DownloadSessionCanceled
+	_, err := s.client.cancelSession(ctx, s.ac, s.clientResponse.TransactionID, reason)
+	return err
+}
+
+func (s *DownloadSession) abort(ctx context.Context, err error, reason sgp22.CancelSessionReason) error {
+	s.state = DownloadSessionCanceled
+	return s.client.abort(ctx, s.ac, s.clientResponse.TransactionID, err, reason)
+}
+
+func (s *DownloadSession) expect(state DownloadSessionState) error {
+	if s.state != state {
+		return fmt.Errorf("download session is %s, want %s", s.state, state)
+	}
+	return nil
+}
+
+func (s *DownloadSession) progress(stage DownloadStage) {
+	if s.OnProgress != nil {
+		s.OnProgress(stage)
+	}
+}
+
+func (s *DownloadSession) installProgress(progress InstallProgress) {
+	if s.OnInstallProgress != nil {
+		s.OnInstallProgress(progress)
+	}
+}
+
+// downloadSessionJSON is the serialised form of a DownloadSession.
+// The profile metadata and the confirmation code requirement are decoded again from the SM-DP+ response.
+type downloadSessionJSON struct {
+	State                    DownloadSessionState                 `json:"state"`
+	SMDP                     string                               `json:"smdpAddress"`
+	MatchingID               string                               `json:"matchingId,omitempty"`
+	IMEI                     string                               `json:"imei,omitempty"`
+	OID                      string                               `json:"oid,omitempty"`
+	ConfirmationCodeRequired bool                                 `json:"confirmationCodeRequired,omitempty"`
+	Extra                    []string                             `json:"extra,omitempty"`
+	ClientResponse           *sgp22.ES9AuthenticateClientResponse `json:"authenticateClientResponse,omitempty"`
+}
+
+// MarshalJSON serialises the state of the session, without the confirmation code. The serialised
+// AuthenticateClient response identifies the session on the SM-DP+, so it is kept as privately as the session.
+func (s *DownloadSession) MarshalJSON() ([]byte, error) {
+	if s.ac == nil || s.ac.SMDP == nil {
+		return nil, errors.New("SM-DP+ is required")
+	}
+	return json.Marshal(&downloadSessionJSON{
+		State:                    s.state,
+		SMDP:                     s.ac.SMDP.String(),
+		MatchingID:               s.ac.MatchingID,
+		IMEI:                     s.ac.IMEI,
+		OID:                      s.ac.OID,
+		ConfirmationCodeRequired: s.ac.ConfirmationCodeRequired,
+		Extra:                    s.ac.Extra,
+		ClientResponse:           s.clientResponse,
+	})
+}
+
+// ResumeDownloadSession restores a download session serialised with [json.Marshal].
+// A confirmed session requiring a confirmation code is restored as started, to be confirmed again with the code.
+func (c *Client) ResumeDownloadSession(data []byte) (*DownloadSession, error) {
+	var state downloadSessionJSON
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	smdp, err := url.Parse(state.SMDP)
+	if err != nil {
+		return nil, err
+	}
+	s := &DownloadSession{
+		client: c,
+		ac: &ActivationCode{
+			SMDP:                     smdp,
+			MatchingID:               state.MatchingID,
+			IMEI:                     state.IMEI,
+			OID:                      state.OID,
+			ConfirmationCodeRequired: state.ConfirmationCodeRequired,
+			Extra:                    state.Extra,
+		},
+		state:          state.State,
+		clientResponse: state.ClientResponse,
+	}
+	if s.state == DownloadSessionNew {
+		return s, nil
+	}
+	if s.clientResponse == nil || s.clientResponse.ProfileMetadata == nil || s.clientResponse.Signed2 == nil {
+		return nil, fmt.Errorf("%s download session is missing the AuthenticateClient response", s.state)
+	}
+	if s.metadata, err = c.profileMetadata(s.clientResponse.ProfileMetadata); err != nil {
+		return nil, err
+	}
+	if s.ccRequired, err = c.confirmationCodeRequired(s.clientResponse.Signed2); err != nil {
+		return nil, err
+	}
+	if s.ccRequired && s.state == DownloadSessionConfirmed {
+		s.state = DownloadSessionStarted
+	}
+	return s, nil
+}
@@ -0,0 +1,150 @@
+package lpa
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/http"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+func newTestDownloadSession(t *testing.T, client *Client, ccRequired bool) *DownloadSession {
+	t.Helper()
+	transactionID := []byte{0x01, 0x02}
+	ccFlag := byte(0x00)
+	if ccRequired {
+		ccFlag = 0xFF
+	}
+	smdp, _ := url.Parse("https://smdp.example.com")
+	session := client.NewDownloadSession(&ActivationCode{SMDP: smdp, MatchingID: "ABCD-1234", IMEI: "356938035643809"})
+	session.clientResponse = &sgp22.ES9AuthenticateClientResponse{
+		TransactionID: transactionID,
+		ProfileMetadata: bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(37),
+			bertlv.NewValue(sgp22.TagICCID, []byte{0x98, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xF0}),
+			bertlv.NewValue(sgp22.TagServiceProviderName, []byte("Example")),
+			bertlv.NewValue(sgp22.TagProfileName, []byte("Example")),
+		),
+		Signed2: bertlv.NewChildren(
+			bertlv.Universal.Constructed(16),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), transactionID),
+			bertlv.NewValue(bertlv.Universal.Primitive(1), []byte{ccFlag}),
+		),
+	}
+	var err error
+	if session.metadata, err = client.profileMetadata(session.clientResponse.ProfileMetadata); err != nil {
+		t.Fatalf("profileMetadata() error = %v", err)
+	}
+	session.ccRequired = ccRequired
+	session.state = DownloadSessionStarted
+	return session
+}
+
+func TestDownloadSessionResume(t *testing.T) {
+	client := new(Client)
+	session := newTestDownloadSession(t, client, true)
+	if err := session.Confirm("2468"); err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if bytes.Contains(data, []byte("2468")) {
+		t.Errorf("json.Marshal() = %s, want the confirmation code left out", data)
+	}
+
+	// The confirmation code is asked for again.
+	resumed, err := client.ResumeDownloadSession(data)
+	if err != nil {
+		t.Fatalf("ResumeDownloadSession() error = %v", err)
+	}
+	if resumed.State() != DownloadSessionStarted {
+		t.Errorf("State() = %v, want %v", resumed.State(), DownloadSessionStarted)
+	}
+	if err := resumed.Confirm(""); !errors.Is(err, ErrConfirmationCodeRequired) {
+		t.Errorf("Confirm() error = %v, want %v", err, ErrConfirmationCodeRequired)
+	}
+	if err := resumed.Confirm("2468"); err != nil || resumed.State() != DownloadSessionConfirmed {
+		t.Errorf("Confirm() error = %v, State() = %v, want %v", err, resumed.State(), DownloadSessionConfirmed)
+	}
+	if !bytes.Equal(resumed.TransactionID(), session.TransactionID()) {
+		t.Errorf("TransactionID() = %X, want %X", resumed.TransactionID(), session.TransactionID())
+	}
+	if !resumed.ConfirmationCodeRequired() {
+		t.Error("ConfirmationCodeRequired() = false, want true")
+	}
+	if got := resumed.Metadata(); got == nil || got.ProfileName != "Example" {
+		t.Errorf("Metadata() = %+v, want profile name Example", got)
+	}
+	if resumed.ac.SMDP.String() != session.ac.SMDP.String() || resumed.ac.IMEI != session.ac.IMEI || resumed.ac.ConfirmationCode != "2468" {
+		t.Errorf("activation code = %+v, want %+v", resumed.ac, session.ac)
+	}
+}
+
+func TestDownloadSessionConfirm(t *testing.T) {
+	session := newTestDownloadSession(t, new(Client), true)
+	if err := session.Confirm(""); !errors.Is(err, ErrConfirmationCodeRequired) {
+		t.Errorf("Confirm() error = %v, want %v", err, ErrConfirmationCodeRequired)
+	}
+	if session.State() != DownloadSessionStarted {
+		t.Errorf("State() = %v, want %v", session.State(), DownloadSessionStarted)
+	}
+	if err := session.Confirm("1234"); err != nil {
+		t.Errorf("Confirm() error = %v", err)
+	}
+	if err := session.Confirm("1234"); err == nil {
+		t.Error("Confirm() on a confirmed session error = nil")
+	}
+	if _, err := session.Start(t.Context()); err == nil {
+		t.Error("Start() on a confirmed session error = nil")
+	}
+}
+
+func TestDownloadSessionCancel(t *testing.T) {
+	var path, body string
+	server := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		data, _ := io.ReadAll(r.Body)
+		path, body = r.URL.Path, string(data)
+		_, _ = io.WriteString(w, `{"header":{"functionExecutionStatus":{"status":"Executed-Success"}}}`)
+	}))
+	defer server.Close()
+
+	transmitter := &fakeTransmitter{responses: []*bertlv.TLV{
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(65), bertlv.NewValue(bertlv.Universal.Primitive(16), nil)),
+	}}
+	client := &Client{APDU: transmitter, HTTP: &http.Client{Client: server.Client(), AdminProtocolVersion: "2.5.0"}}
+	session := newTestDownloadSession(t, client, false)
+	session.ac.SMDP, _ = url.Parse(server.URL)
+
+	if err := session.Cancel(t.Context(), sgp22.CancelSessionReasonEndUserRejection); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if session.State() != DownloadSessionCanceled {
+		t.Errorf("State() = %v, want %v", session.State(), DownloadSessionCanceled)
+	}
+	if len(transmitter.requests) != 1 {
+		t.Fatalf("requests = %d, want 1", len(transmitter.requests))
+	}
+	reason := transmitter.requests[0].First(bertlv.ContextSpecific.Primitive(1))
+	if reason == nil || !bytes.Equal(reason.Value, []byte{byte(sgp22.CancelSessionReasonEndUserRejection)}) {
+		t.Errorf("cancel session reason = %v, want %d", reason, sgp22.CancelSessionReasonEndUserRejection)
+	}
+	if path != "/gsma/rsp2/es9plus/cancelSession" || !strings.Contains(body, `"transactionId":"0102"`) {
+		t.Errorf("ES9+ request = %s %s", path, body)
+	}
+	if err := session.Cancel(t.Context(), sgp22.CancelSessionReasonPostponed); err == nil {
+		t.Error("Cancel() on a canceled session error = nil")
+	}
+	if _, err := session.Install(t.Context()); err == nil {
+		t.Error("Install() on a canceled session error = nil")
+	}
+}
@@ -0,0 +1,120 @@
+package shared
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/lpa"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// The methods below run the method of lpa.Client with the same name as an
+// operation of that name. Reads run with PriorityNormal, profile changes and
+// downloads with PriorityHigh, and notifications and discovery with PriorityLow.
+
+// EID runs [lpa.Client.EID].
+func (c *Client) EID(ctx context.Context) (eid sgp22.EID, err error) {
+	err = c.Do(ctx, Operation{Name: "EID", Priority: PriorityNormal}, func(ctx context.Context, client *lpa.Client) error {
+		eid, err = client.EID(ctx)
+		return err
+	})
+	return eid, err
+}
+
+// EUICCInfo2 runs [lpa.Client.EUICCInfo2].
+func (c *Client) EUICCInfo2(ctx context.Context) (info *sgp22.EUICCInfo2, err error) {
+	err = c.Do(ctx, Operation{Name: "EUICCInfo2", Priority: PriorityNormal}, func(ctx context.Context, client *lpa.Client) error {
+		info, err = client.EUICCInfo2(ctx)
+		return err
+	})
+	return info, err
+}
+
+// ListProfile runs [lpa.Client.ListProfile].
+func (c *Client) ListProfile(ctx context.Context, searchCriteria any, tags []bertlv.Tag) (profiles []*sgp22.ProfileInfo, err error) {
+	err = c.Do(ctx, Operation{Name: "ListProfile", Priority: PriorityNormal}, func(ctx context.Context, client *lpa.Client) error {
+		profiles, err = client.ListProfile(ctx, searchCriteria, tags)
+		return err
+	})
+	return profiles, err
+}
+
+// EnableProfile runs [lpa.Client.EnableProfile].
+func (c *Client) EnableProfile(ctx context.Context, identifier any, refresh bool) error {
+	return c.Do(ctx, Operation{Name: "EnableProfile", Priority: PriorityHigh}, func(ctx context.Context, client *lpa.Client) error {
+		return client.EnableProfile(ctx, identifier, refresh)
+	})
+}
+
+// DisableProfile runs [lpa.Client.DisableProfile].
+func (c *Client) DisableProfile(ctx context.Context, identifier any, refresh bool) error {
+	return c.Do(ctx, Operation{Name: "DisableProfile", Priority: PriorityHigh}, func(ctx context.Context, client *lpa.Client) error {
+		return client.DisableProfile(ctx, identifier, refresh)
+	})
+}
+
+// DeleteProfile runs [lpa.Client.DeleteProfile].
+func (c *Client) DeleteProfile(ctx context.Context, identifier any) error {
+	return c.Do(ctx, Operation{Name: "DeleteProfile", Priority: PriorityHigh}, func(ctx context.Context, client *lpa.Client) error {
+		return client.DeleteProfile(ctx, identifier)
+	})
+}
+
+// SetNickname runs [lpa.Client.SetNickname].
+func (c *Client) SetNickname(ctx context.Context, iccid sgp22.ICCID, nickname string) error {
+	return c.Do(ctx, Operation{Name: "SetNickname", Priority: PriorityHigh}, func(ctx context.Context, client *lpa.Client) error {
+		return client.SetNickname(ctx, iccid, nickname)
+	})
+}
+
+// DownloadProfile runs [lpa.Client.DownloadProfile]. The callbacks of opts are
+// called while the operation runs, so they must not use the client.
+func (c *Client) DownloadProfile(ctx context.Context, ac *lpa.ActivationCode, opts *lpa.DownloadOptions) (response *sgp22.LoadBoundProfilePackageResponse, err error) {
+	err = c.Do(ctx, Operation{Name: "DownloadProfile", Priority: PriorityHigh}, func(ctx context.Context, client *lpa.Client) error {
+		response, err = client.DownloadProfile(ctx, ac, opts)
+		return err
+	})
+	return response, err
+}
+
+// ListNotification runs [lpa.Client.ListNotification].
+func (c *Client) ListNotification(ctx context.Context, filters ...sgp22.NotificationEvent) (notifications []*sgp22.NotificationMetadata, err error) {
+	err = c.Do(ctx, Operation{Name: "ListNotification", Priority: PriorityNormal}, func(ctx context.Context, client *lpa.Client) error {
+		notifications, err = client.ListNotification(ctx, filters...)
+		return err
+	})
+	return notifications, err
+}
+
+// RetrieveNotificationList runs [lpa.Client.RetrieveNotificationList].
+func (c *Client) RetrieveNotificationList(ctx context.Context, searchCriteria any) (notifications []*sgp22.PendingNotification, err error) {
+	err = c.Do(ctx, Operation{Name: "RetrieveNotificationList", Priority: PriorityLow}, func(ctx context.Context, client *lpa.Client) error {
+		notifications, err = client.RetrieveNotificationList(ctx, searchCriteria)
+		return err
+	})
+	return notifications, err
+}
+
+// HandleNotification runs [lpa.Client.HandleNotification].
+func (c *Client) HandleNotification(ctx context.Context, pendingNotification *sgp22.PendingNotification) error {
+	return c.Do(ctx, Operation{Name: "HandleNotification", Priority: PriorityLow}, func(ctx context.Context, client *lpa.Client) error {
+		return client.HandleNotification(ctx, pendingNotification)
+	})
+}
+
+// RemoveNotificationFromList runs [lpa.Client.RemoveNotificationFromList].
+func (c *Client) RemoveNotificationFromList(ctx context.Context, sequenceNumber sgp22.SequenceNumber) error {
+	return c.Do(ctx, Operation{Name: "RemoveNotificationFromList", Priority: PriorityLow}, func(ctx context.Context, client *lpa.Client) error {
+		return client.RemoveNotificationFromList(ctx, sequenceNumber)
+	})
+}
+
+// Discovery runs [lpa.Client.Discovery].
+func (c *Client) Discovery(ctx context.Context, address *url.URL, imei []byte) (events []*sgp22.EventEntry, err error) {
+	err = c.Do(ctx, Operation{Name: "Discovery", Priority: PriorityLow}, func(ctx context.Context, client *lpa.Client) error {
+		events, err = client.Discovery(ctx, address, imei)
+		return err
+	})
+	return events, err
+}
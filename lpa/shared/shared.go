@@ -0,0 +1,301 @@
+// Package shared lets several goroutines use one LPA client.
+//
+// An [lpa.Client] is not safe for concurrent use, since neither is the smart
+// card channel below it. A [Client] owns one and runs the operations of its
+// callers one at a time, from a queue ordered by [Priority]: an operation never
+// interleaves with another, so a notification sent by a background job cannot
+// slip between the APDUs of a bound profile package. Each operation can be
+// given a timeout, and [Client.Stats] reports the depth of the queue.
+package shared
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/damonto/euicc-go/lpa"
+)
+
+// ErrClosed is returned for operations queued on or after [Client.Close].
+var ErrClosed = errors.New("shared LPA client is closed")
+
+// Priority orders the queued operations. Operations with the same priority
+// run in the order they were queued, and a higher priority goes first.
+type Priority int
+
+const (
+	// PriorityLow is for background work, such as sending notifications.
+	PriorityLow Priority = iota
+	// PriorityNormal is for reading the state of the eUICC.
+	PriorityNormal
+	// PriorityHigh is for changes requested by a user, such as a download.
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return "unknown"
+}
+
+// Operation describes an operation run with [Client.Do].
+type Operation struct {
+	// Name identifies the operation in [Stats].
+	Name     string
+	Priority Priority
+	// Timeout bounds the run of the operation, not its wait in the queue,
+	// which ends with the context of the caller. Zero uses the timeout of the client.
+	Timeout time.Duration
+}
+
+// Stats is a snapshot of the queue of a [Client].
+type Stats struct {
+	// Queued is the number of operations waiting to run.
+	Queued int
+	// QueuedByPriority is the number of operations waiting to run, by priority.
+	QueuedByPriority map[Priority]int
+	// MaxQueued is the highest number of operations that waited at once.
+	MaxQueued int
+	// Running is the name of the running operation, or empty if none is.
+	Running string
+	// Completed is the number of operations that ran and succeeded.
+	Completed uint64
+	// Failed is the number of operations that ran and failed, including time-outs.
+	Failed uint64
+	// Abandoned is the number of operations whose caller gave up while they waited.
+	Abandoned uint64
+	// Waited is the total time operations waited before they ran.
+	Waited time.Duration
+}
+
+type config struct {
+	timeout time.Duration
+}
+
+// Option configures a Client.
+type Option func(*config)
+
+// WithTimeout sets the timeout of the operations without their own. The default is none.
+func WithTimeout(timeout time.Duration) Option {
+	return func(config *config) {
+		config.timeout = timeout
+	}
+}
+
+// Client runs the operations of concurrent callers on an [lpa.Client]. It is safe for concurrent use.
+type Client struct {
+	client *lpa.Client
+	config config
+	now    func() time.Time
+
+	mu      sync.Mutex
+	queue   queue
+	seq     uint64
+	running *operation
+	closed  bool
+	// idle is closed when the running operation is done after Close.
+	idle  chan struct{}
+	stats Stats
+}
+
+// New returns a client running operations on client, which it takes ownership of.
+func New(client *lpa.Client, options ...Option) *Client {
+	var config config
+	for _, option := range options {
+		option(&config)
+	}
+	return &Client{client: client, config: config, now: time.Now}
+}
+
+// Do runs fn once the operations queued before it with the same or a higher priority are done,
+// and returns its error. It returns the error of ctx if ctx is done before fn runs, and
+// [ErrClosed] if the client is closed first. Fn must not keep the client after it returns.
+func (c *Client) Do(ctx context.Context, op Operation, fn func(ctx context.Context, client *lpa.Client) error) error {
+	queued := c.now()
+	o, err := c.acquire(ctx, op)
+	if err != nil {
+		return err
+	}
+	defer c.release(o)
+	c.mu.Lock()
+	c.stats.Waited += c.now().Sub(queued)
+	c.mu.Unlock()
+
+	timeout := op.Timeout
+	if timeout == 0 {
+		timeout = c.config.timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	o.err = fn(ctx, c.client)
+	return o.err
+}
+
+// Stats returns a snapshot of the queue.
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Queued = len(c.queue)
+	stats.QueuedByPriority = make(map[Priority]int)
+	for _, o := range c.queue {
+		stats.QueuedByPriority[o.Priority]++
+	}
+	if c.running != nil {
+		stats.Running = c.running.Name
+	}
+	return stats
+}
+
+// Close fails the queued operations with [ErrClosed], waits for the running
+// one and closes the LPA client. It must not be called by an operation.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	for _, o := range c.queue {
+		o.index = -1
+		close(o.ready)
+	}
+	c.queue = nil
+	var idle chan struct{}
+	if c.running != nil {
+		c.idle = make(chan struct{})
+		idle = c.idle
+	}
+	c.mu.Unlock()
+	if idle != nil {
+		<-idle
+	}
+	return c.client.Close()
+}
+
+// acquire waits for the turn of an operation.
+func (c *Client) acquire(ctx context.Context, op Operation) (*operation, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.seq++
+	o := &operation{Operation: op, seq: c.seq, ready: make(chan struct{})}
+	if c.running == nil {
+		c.running = o
+		c.mu.Unlock()
+		return o, nil
+	}
+	heap.Push(&c.queue, o)
+	c.stats.MaxQueued = max(c.stats.MaxQueued, len(c.queue))
+	c.mu.Unlock()
+
+	select {
+	case <-o.ready:
+	case <-ctx.Done():
+		c.mu.Lock()
+		if o.index >= 0 {
+			heap.Remove(&c.queue, o.index)
+			c.stats.Abandoned++
+			c.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		c.mu.Unlock()
+		// The operation was given its turn or the client closed meanwhile.
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running != o {
+		return nil, ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		c.stats.Abandoned++
+		c.next()
+		return nil, err
+	}
+	return o, nil
+}
+
+// release ends the turn of an operation.
+func (c *Client) release(o *operation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if o.err != nil {
+		c.stats.Failed++
+	} else {
+		c.stats.Completed++
+	}
+	c.next()
+}
+
+// next gives the turn to the first queued operation.
+func (c *Client) next() {
+	c.running = nil
+	if len(c.queue) > 0 {
+		c.running = heap.Pop(&c.queue).(*operation)
+		close(c.running.ready)
+		return
+	}
+	if c.idle != nil {
+		close(c.idle)
+		c.idle = nil
+	}
+}
+
+type operation struct {
+	Operation
+	seq uint64
+	// index is the index of the operation in the queue, or -1 once it left it.
+	index int
+	ready chan struct{}
+	err   error
+}
+
+// queue is a heap of the waiting operations, the first to run at the root.
+type queue []*operation
+
+func (q queue) Len() int { return len(q) }
+
+func (q queue) Less(i, j int) bool {
+	if q[i].Priority != q[j].Priority {
+		return q[i].Priority > q[j].Priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *queue) Push(x any) {
+	o := x.(*operation)
+	o.index = len(*q)
+	*q = append(*q, o)
+}
+
+func (q *queue) Pop() any {
+	old := *q
+	o := old[len(old)-1]
+	old[len(old)-1] = nil
+	o.index = -1
+	*q = old[:len(old)-1]
+	return o
+}
@@ -0,0 +1,197 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/damonto/euicc-go/driver/virtual"
+	"github.com/damonto/euicc-go/driver/virtual/virtualtest"
+	"github.com/damonto/euicc-go/lpa"
+	"github.com/damonto/euicc-go/lpa/outbox"
+)
+
+var _ outbox.Client = (*Client)(nil)
+
+func newClient(t *testing.T, options ...Option) *Client {
+	t.Helper()
+	client, err := lpa.New(t.Context(), &lpa.Options{
+		Channel: virtualtest.NewCard(t),
+		Logger:  slog.New(slog.DiscardHandler),
+	})
+	if err != nil {
+		t.Fatalf("lpa.New() error = %v", err)
+	}
+	shared := New(client, options...)
+	t.Cleanup(func() { shared.Close() })
+	return shared
+}
+
+// block runs an operation until the returned function is called.
+func block(t *testing.T, client *Client) func() {
+	t.Helper()
+	started, release, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		client.Do(context.Background(), Operation{Name: "block"}, func(context.Context, *lpa.Client) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	return func() {
+		close(release)
+		<-done
+	}
+}
+
+// waitQueued waits until n operations are queued.
+func waitQueued(t *testing.T, client *Client, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Stats().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Stats().Queued = %d, want %d", client.Stats().Queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestClientConcurrent(t *testing.T) {
+	client := newClient(t)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			if eid, err := client.EID(t.Context()); err != nil || eid.String() != virtual.DefaultEID {
+				t.Errorf("EID() = %s, %v, want %s", eid, err, virtual.DefaultEID)
+			}
+		})
+		wg.Go(func() {
+			if profiles, err := client.ListProfile(t.Context(), nil, nil); err != nil || len(profiles) != 1 {
+				t.Errorf("ListProfile() = %v, %v, want one profile", profiles, err)
+			}
+		})
+	}
+	wg.Wait()
+	stats := client.Stats()
+	if stats.Completed != 16 || stats.Failed != 0 || stats.Queued != 0 || stats.Running != "" {
+		t.Errorf("Stats() = %+v, want 16 completed operations", stats)
+	}
+}
+
+func TestClientPriority(t *testing.T) {
+	client := newClient(t)
+	release := block(t, client)
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	for _, op := range []Operation{
+		{Name: "notify", Priority: PriorityLow},
+		{Name: "list", Priority: PriorityNormal},
+		{Name: "download", Priority: PriorityHigh},
+		{Name: "enable", Priority: PriorityHigh},
+	} {
+		n := client.Stats().Queued
+		wg.Go(func() {
+			client.Do(t.Context(), op, func(context.Context, *lpa.Client) error {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, op.Name)
+				return nil
+			})
+		})
+		waitQueued(t, client, n+1)
+	}
+	stats := client.Stats()
+	if want := map[Priority]int{PriorityLow: 1, PriorityNormal: 1, PriorityHigh: 2}; !reflect.DeepEqual(stats.QueuedByPriority, want) ||
+		stats.Running != "block" || stats.MaxQueued != 4 {
+		t.Errorf("Stats() = %+v, want 4 operations queued behind block", stats)
+	}
+	release()
+	wg.Wait()
+	if want := []string{"download", "enable", "list", "notify"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("operations ran in order %v, want %v", order, want)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	client := newClient(t, WithTimeout(time.Hour))
+	err := client.Do(t.Context(), Operation{Name: "slow", Timeout: 10 * time.Millisecond}, func(ctx context.Context, _ *lpa.Client) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	err = client.Do(t.Context(), Operation{Name: "default"}, func(ctx context.Context, _ *lpa.Client) error {
+		if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) < 59*time.Minute {
+			t.Errorf("operation deadline = %v, %t, want the timeout of the client", deadline, ok)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Do() error = %v", err)
+	}
+	if stats := client.Stats(); stats.Failed != 1 || stats.Completed != 1 {
+		t.Errorf("Stats() = %+v, want 1 failed and 1 completed operation", stats)
+	}
+}
+
+func TestClientAbandon(t *testing.T) {
+	client := newClient(t)
+	release := block(t, client)
+	ctx, cancel := context.WithCancel(t.Context())
+	abandoned := make(chan error, 1)
+	go func() {
+		abandoned <- client.Do(ctx, Operation{Name: "abandoned"}, func(context.Context, *lpa.Client) error {
+			t.Error("abandoned operation ran")
+			return nil
+		})
+	}()
+	waitQueued(t, client, 1)
+	cancel()
+	if err := <-abandoned; !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() error = %v, want %v", err, context.Canceled)
+	}
+	release()
+	if stats := client.Stats(); stats.Abandoned != 1 || stats.Queued != 0 || stats.Completed != 1 {
+		t.Errorf("Stats() = %+v, want 1 abandoned and 1 completed operation", stats)
+	}
+	if _, err := client.EID(t.Context()); err != nil {
+		t.Errorf("EID() after an abandoned operation error = %v", err)
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	client := newClient(t)
+	release := block(t, client)
+	queued := make(chan error, 1)
+	go func() {
+		_, err := client.EID(t.Context())
+		queued <- err
+	}()
+	waitQueued(t, client, 1)
+	closed := make(chan error, 1)
+	go func() { closed <- client.Close() }()
+	if err := <-queued; !errors.Is(err, ErrClosed) {
+		t.Errorf("EID() queued before Close() error = %v, want %v", err, ErrClosed)
+	}
+	select {
+	case err := <-closed:
+		t.Fatalf("Close() = %v before the running operation is done", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	release()
+	if err := <-closed; err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if _, err := client.EID(t.Context()); !errors.Is(err, ErrClosed) {
+		t.Errorf("EID() after Close() error = %v, want %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,239 @@
+package lpa
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/http/rootci"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// Verifier checks data received from the SM-DP+ before it is forwarded to the eUICC.
+type Verifier interface {
+	// VerifyInitiateAuthentication checks the ES9+.InitiateAuthentication response against its request.
+	VerifyInitiateAuthentication(request *sgp22.ES9InitiateAuthenticationRequest, response *sgp22.ES9InitiateAuthenticationResponse) error
+	// VerifyAuthenticateClient checks the ES9+.AuthenticateClient response against its request.
+	VerifyAuthenticateClient(request *sgp22.ES9AuthenticateClientRequest, response *sgp22.ES9AuthenticateClientResponse) error
+	// VerifyBoundProfilePackage checks the Bound Profile Package against the session transaction ID
+	// and the profile metadata confirmed by the end user, before the first segment is sent to the eUICC.
+	VerifyBoundProfilePackage(transactionID []byte, metadata *sgp22.ProfileInfo, response *sgp22.ES9BoundProfilePackageResponse) error
+}
+
+type VerificationReason uint8
+
+const (
+	VerificationReasonCertificate VerificationReason = iota
+	VerificationReasonSignature
+	VerificationReasonTransactionID
+	VerificationReasonServerAddress
+	VerificationReasonEUICCChallenge
+	VerificationReasonRemoteOperation
+	VerificationReasonMetadata
+)
+
+func (r VerificationReason) String() string {
+	switch r {
+	case VerificationReasonCertificate:
+		return "certificate"
+	case VerificationReasonSignature:
+		return "signature"
+	case VerificationReasonTransactionID:
+		return "transaction ID"
+	case VerificationReasonServerAddress:
+		return "server address"
+	case VerificationReasonEUICCChallenge:
+		return "eUICC challenge"
+	case VerificationReasonRemoteOperation:
+		return "remote operation"
+	case VerificationReasonMetadata:
+		return "profile metadata"
+	}
+	return fmt.Sprintf("unknown(%d)", r)
+}
+
+// VerificationError is returned when data received from the SM-DP+ fails verification.
+type VerificationError struct {
+	Reason VerificationReason
+	Err    error
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("verify %s: %v", e.Reason, e.Err)
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
+
+// CIVerifier verifies the SM-DP+ certificates and signatures against the CI root certificates.
+type CIVerifier struct {
+	// Roots are the trusted CI root certificates.
+	Roots *x509.CertPool
+	// CurrentTime returns the time used to check certificate validity. It defaults to time.Now.
+	CurrentTime func() time.Time
+}
+
+// NewCIVerifier creates a CIVerifier that trusts the CI bundle of [rootci].
+func NewCIVerifier() (*CIVerifier, error) {
+	roots, err := rootci.TrustedRootCAs()
+	if err != nil {
+		return nil, err
+	}
+	return &CIVerifier{Roots: roots}, nil
+}
+
+// VerifyInitiateAuthentication validates CERT.DPauth.ECDSA up to a CI root, the serverSignature1
+// over serverSigned1, and that serverSigned1 echoes the SM-DP+ address and eUICC challenge that were sent.
+//
+// See https://aka.pw/sgp22/v2.5#page=170 (Section 5.6.1, ES9p.InitiateAuthentication)
+func (v *CIVerifier) VerifyInitiateAuthentication(request *sgp22.ES9InitiateAuthenticationRequest, response *sgp22.ES9InitiateAuthenticationResponse) error {
+	var pkid []byte
+	if response.UsedIssuer != nil {
+		pkid = response.UsedIssuer.Value
+	}
+	certificate, err := v.verifyCertificate(response.Certificate, pkid)
+	if err != nil {
+		return err
+	}
+	if err = sgp22.VerifySignature(certificate, response.Signature1, response.Signed1); err != nil {
+		return &VerificationError{Reason: VerificationReasonSignature, Err: err}
+	}
+	signed1, err := response.ServerSigned1()
+	if err != nil {
+		return &VerificationError{Reason: VerificationReasonSignature, Err: err}
+	}
+	if !bytes.Equal(signed1.TransactionID, response.TransactionID) {
+		return &VerificationError{
+			Reason: VerificationReasonTransactionID,
+			Err:    fmt.Errorf("got %X, want %X", signed1.TransactionID, []byte(response.TransactionID)),
+		}
+	}
+	if !strings.EqualFold(signed1.ServerAddress, request.Address) {
+		return &VerificationError{
+			Reason: VerificationReasonServerAddress,
+			Err:    fmt.Errorf("got %q, want %q", signed1.ServerAddress, request.Address),
+		}
+	}
+	if !bytes.Equal(signed1.EUICCChallenge, request.Challenge) {
+		return &VerificationError{
+			Reason: VerificationReasonEUICCChallenge,
+			Err:    fmt.Errorf("got %X, want %X", signed1.EUICCChallenge, request.Challenge),
+		}
+	}
+	return nil
+}
+
+// VerifyAuthenticateClient validates CERT.DPpb.ECDSA up to a CI root and the smdpSignature2
+// over smdpSigned2 and euiccSignature1, and that smdpSigned2 belongs to the current session.
+func (v *CIVerifier) VerifyAuthenticateClient(request *sgp22.ES9AuthenticateClientRequest, response *sgp22.ES9AuthenticateClientResponse) error {
+	certificate, err := v.verifyCertificate(response.Certificate, nil)
+	if err != nil {
+		return err
+	}
+	euiccSignature1 := request.EUICCSignature1()
+	if euiccSignature1 == nil {
+		return &VerificationError{Reason: VerificationReasonSignature, Err: errors.New("missing euiccSignature1")}
+	}
+	if err = sgp22.VerifySignature(certificate, response.Signature2, response.Signed2, euiccSignature1); err != nil {
+		return &VerificationError{Reason: VerificationReasonSignature, Err: err}
+	}
+	signed2, err := response.SMDPSigned2()
+	if err != nil {
+		return &VerificationError{Reason: VerificationReasonSignature, Err: err}
+	}
+	for _, transactionID := range [][]byte{response.TransactionID, request.TransactionID} {
+		if !bytes.Equal(signed2.TransactionID, transactionID) {
+			return &VerificationError{
+				Reason: VerificationReasonTransactionID,
+				Err:    fmt.Errorf("got %X, want %X", signed2.TransactionID, transactionID),
+			}
+		}
+	}
+	return nil
+}
+
+// VerifyBoundProfilePackage checks that the initialiseSecureChannelRequest installs a profile
+// within the current session and that the StoreMetadataRequest matches the confirmed metadata.
+func (v *CIVerifier) VerifyBoundProfilePackage(transactionID []byte, metadata *sgp22.ProfileInfo, response *sgp22.ES9BoundProfilePackageResponse) error {
+	bpp := response.BoundProfilePackage
+	if err := sgp22.ValidBoundProfilePackage(bpp); err != nil {
+		return &VerificationError{Reason: VerificationReasonRemoteOperation, Err: err}
+	}
+	var request sgp22.InitialiseSecureChannelRequest
+	if err := request.UnmarshalBERTLV(bpp.First(bertlv.ContextSpecific.Constructed(35))); err != nil {
+		return &VerificationError{Reason: VerificationReasonRemoteOperation, Err: err}
+	}
+	if !bytes.Equal(request.TransactionID, transactionID) {
+		return &VerificationError{
+			Reason: VerificationReasonTransactionID,
+			Err:    fmt.Errorf("got %X, want %X", request.TransactionID, transactionID),
+		}
+	}
+	if request.RemoteOperationID != sgp22.RemoteOperationInstallBoundProfilePackage {
+		return &VerificationError{
+			Reason: VerificationReasonRemoteOperation,
+			Err:    fmt.Errorf("got %d, want %d", request.RemoteOperationID, sgp22.RemoteOperationInstallBoundProfilePackage),
+		}
+	}
+	stored, err := sgp22.BoundProfilePackageMetadata(bpp)
+	if err != nil {
+		return &VerificationError{Reason: VerificationReasonMetadata, Err: err}
+	}
+	if err = compareMetadata(stored, metadata); err != nil {
+		return &VerificationError{Reason: VerificationReasonMetadata, Err: err}
+	}
+	return nil
+}
+
+// compareMetadata reports the first field of the StoreMetadataRequest that differs from the confirmed metadata.
+func compareMetadata(got, want *sgp22.ProfileInfo) error {
+	switch {
+	case !bytes.Equal(got.ICCID, want.ICCID):
+		return fmt.Errorf("ICCID %s, want %s", got.ICCID, want.ICCID)
+	case got.ServiceProviderName != want.ServiceProviderName:
+		return fmt.Errorf("service provider name %q, want %q", got.ServiceProviderName, want.ServiceProviderName)
+	case got.ProfileName != want.ProfileName:
+		return fmt.Errorf("profile name %q, want %q", got.ProfileName, want.ProfileName)
+	case got.ProfileClass != want.ProfileClass:
+		return fmt.Errorf("profile class %s, want %s", got.ProfileClass, want.ProfileClass)
+	case got.ProfilePolicyRules != want.ProfilePolicyRules:
+		return fmt.Errorf("profile policy rules %+v, want %+v", got.ProfilePolicyRules, want.ProfilePolicyRules)
+	case !bytes.Equal(got.ProfileOwner.PLMN, want.ProfileOwner.PLMN) ||
+		!bytes.Equal(got.ProfileOwner.GID1, want.ProfileOwner.GID1) ||
+		!bytes.Equal(got.ProfileOwner.GID2, want.ProfileOwner.GID2):
+		return errors.New("profile owner differs")
+	case got.IconType != want.IconType || !bytes.Equal(got.Icon, want.Icon):
+		return errors.New("profile icon differs")
+	}
+	return nil
+}
+
+// verifyCertificate parses the certificate and verifies it is issued under the CI identified by pkid.
+func (v *CIVerifier) verifyCertificate(tlv *bertlv.TLV, pkid []byte) (*x509.Certificate, error) {
+	certificate, err := sgp22.ParseCertificate(tlv)
+	if err != nil {
+		return nil, &VerificationError{Reason: VerificationReasonCertificate, Err: err}
+	}
+	if pkid != nil && !bytes.Equal(certificate.AuthorityKeyId, pkid) {
+		return nil, &VerificationError{
+			Reason: VerificationReasonCertificate,
+			Err:    fmt.Errorf("issued by CI %X, want %X", certificate.AuthorityKeyId, pkid),
+		}
+	}
+	var currentTime time.Time
+	if v.CurrentTime != nil {
+		currentTime = v.CurrentTime()
+	}
+	if v.Roots == nil {
+		return nil, &VerificationError{Reason: VerificationReasonCertificate, Err: errors.New("no CI roots")}
+	}
+	if err = sgp22.VerifyCertificate(certificate, v.Roots, currentTime); err != nil {
+		return nil, &VerificationError{Reason: VerificationReasonCertificate, Err: err}
+	}
+	return certificate, nil
+}
@@ -0,0 +1,368 @@
+package lpa
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/damonto/euicc-go/bertlv"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+type testPKI struct {
+	ci      *x509.Certificate
+	ciKey   *ecdsa.PrivateKey
+	leaf    *bertlv.TLV
+	leafKey *ecdsa.PrivateKey
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	var pki testPKI
+	var err error
+	if pki.ciKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	ci := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CI"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, ci, ci, &pki.ciKey.PublicKey, pki.ciKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	if pki.ci, err = x509.ParseCertificate(der); err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	pki.leaf, pki.leafKey = pki.issue(t)
+	return &pki
+}
+
+func (pki *testPKI) issue(t *testing.T) (*bertlv.TLV, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test SM-DP+"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, pki.ci, &key.PublicKey, pki.ciKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	var tlv bertlv.TLV
+	if err := tlv.UnmarshalBinary(der); err != nil {
+		t.Fatalf("TLV.UnmarshalBinary() error = %v", err)
+	}
+	return &tlv, key
+}
+
+func (pki *testPKI) verifier() *CIVerifier {
+	roots := x509.NewCertPool()
+	roots.AddCert(pki.ci)
+	return &CIVerifier{Roots: roots}
+}
+
+func (pki *testPKI) sign(t *testing.T, key *ecdsa.PrivateKey, signed ...*bertlv.TLV) *bertlv.TLV {
+	t.Helper()
+	digest := sha256.New()
+	for _, tlv := range signed {
+		data, err := tlv.Bytes()
+		if err != nil {
+			t.Fatalf("TLV.Bytes() error = %v", err)
+		}
+		digest.Write(data)
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return bertlv.NewValue(bertlv.Application.Primitive(55), signature)
+}
+
+func (pki *testPKI) initiateAuthentication(t *testing.T, address string, challenge []byte) *sgp22.ES9InitiateAuthenticationResponse {
+	t.Helper()
+	signed1 := bertlv.NewChildren(
+		bertlv.Universal.Constructed(16),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{0x01, 0x02}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), challenge),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(3), []byte(address)),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(4), make([]byte, 16)),
+	)
+	return &sgp22.ES9InitiateAuthenticationResponse{
+		TransactionID: []byte{0x01, 0x02},
+		Signed1:       signed1,
+		Signature1:    pki.sign(t, pki.leafKey, signed1),
+		UsedIssuer:    bertlv.NewValue(bertlv.Universal.Primitive(4), pki.ci.SubjectKeyId),
+		Certificate:   pki.leaf,
+	}
+}
+
+func TestCIVerifierVerifyInitiateAuthentication(t *testing.T) {
+	pki := newTestPKI(t)
+	request := &sgp22.ES9InitiateAuthenticationRequest{
+		Challenge: []byte{0xAA, 0xBB},
+		Address:   "smdp.example.com",
+	}
+
+	if err := pki.verifier().VerifyInitiateAuthentication(request, pki.initiateAuthentication(t, "SMDP.example.com", request.Challenge)); err != nil {
+		t.Errorf("VerifyInitiateAuthentication() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		response func() *sgp22.ES9InitiateAuthenticationResponse
+		verifier *CIVerifier
+		reason   VerificationReason
+	}{
+		{
+			name: "server address",
+			response: func() *sgp22.ES9InitiateAuthenticationResponse {
+				return pki.initiateAuthentication(t, "evil.example.com", request.Challenge)
+			},
+			reason: VerificationReasonServerAddress,
+		},
+		{
+			name: "eUICC challenge",
+			response: func() *sgp22.ES9InitiateAuthenticationResponse {
+				return pki.initiateAuthentication(t, request.Address, []byte{0x00})
+			},
+			reason: VerificationReasonEUICCChallenge,
+		},
+		{
+			name: "transaction ID",
+			response: func() *sgp22.ES9InitiateAuthenticationResponse {
+				response := pki.initiateAuthentication(t, request.Address, request.Challenge)
+				response.TransactionID = []byte{0x03}
+				return response
+			},
+			reason: VerificationReasonTransactionID,
+		},
+		{
+			name: "signature",
+			response: func() *sgp22.ES9InitiateAuthenticationResponse {
+				response := pki.initiateAuthentication(t, request.Address, request.Challenge)
+				_, key := pki.issue(t)
+				response.Signature1 = pki.sign(t, key, response.Signed1)
+				return response
+			},
+			reason: VerificationReasonSignature,
+		},
+		{
+			name: "untrusted CI",
+			response: func() *sgp22.ES9InitiateAuthenticationResponse {
+				return pki.initiateAuthentication(t, request.Address, request.Challenge)
+			},
+			verifier: newTestPKI(t).verifier(),
+			reason:   VerificationReasonCertificate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := tt.verifier
+			if verifier == nil {
+				verifier = pki.verifier()
+			}
+			err := verifier.VerifyInitiateAuthentication(request, tt.response())
+			var verificationError *VerificationError
+			if !errors.As(err, &verificationError) {
+				t.Fatalf("VerifyInitiateAuthentication() error = %v, want *VerificationError", err)
+			}
+			if verificationError.Reason != tt.reason {
+				t.Errorf("Reason = %v, want %v", verificationError.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestNewCIVerifierUsesRootCIBundle(t *testing.T) {
+	verifier, err := NewCIVerifier()
+	if err != nil {
+		t.Fatalf("NewCIVerifier() error = %v", err)
+	}
+	if verifier.Roots == nil {
+		t.Error("NewCIVerifier() Roots = nil")
+	}
+}
+
+func (pki *testPKI) authenticateClient(t *testing.T, transactionID []byte) (*sgp22.ES9AuthenticateClientRequest, *sgp22.ES9AuthenticateClientResponse) {
+	t.Helper()
+	euiccSignature1 := bertlv.NewValue(bertlv.Application.Primitive(55), make([]byte, 64))
+	request := &sgp22.ES9AuthenticateClientRequest{
+		TransactionID: transactionID,
+		Response: bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(56),
+			bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0), euiccSignature1),
+		),
+	}
+	signed2 := bertlv.NewChildren(
+		bertlv.Universal.Constructed(16),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), transactionID),
+		bertlv.NewValue(bertlv.Universal.Primitive(1), []byte{0x00}),
+	)
+	return request, &sgp22.ES9AuthenticateClientResponse{
+		TransactionID: transactionID,
+		Signed2:       signed2,
+		Signature2:    pki.sign(t, pki.leafKey, signed2, euiccSignature1),
+		Certificate:   pki.leaf,
+	}
+}
+
+func TestCIVerifierVerifyAuthenticateClient(t *testing.T) {
+	pki := newTestPKI(t)
+	transactionID := []byte{0x01, 0x02}
+
+	if err := pki.verifier().VerifyAuthenticateClient(pki.authenticateClient(t, transactionID)); err != nil {
+		t.Errorf("VerifyAuthenticateClient() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*sgp22.ES9AuthenticateClientRequest, *sgp22.ES9AuthenticateClientResponse)
+		reason VerificationReason
+	}{
+		{
+			name: "transaction ID",
+			modify: func(_ *sgp22.ES9AuthenticateClientRequest, response *sgp22.ES9AuthenticateClientResponse) {
+				response.TransactionID = []byte{0x03}
+			},
+			reason: VerificationReasonTransactionID,
+		},
+		{
+			name: "euiccSignature1",
+			modify: func(request *sgp22.ES9AuthenticateClientRequest, _ *sgp22.ES9AuthenticateClientResponse) {
+				request.EUICCSignature1().Value[0] = 0xFF
+			},
+			reason: VerificationReasonSignature,
+		},
+		{
+			name: "certificate",
+			modify: func(_ *sgp22.ES9AuthenticateClientRequest, response *sgp22.ES9AuthenticateClientResponse) {
+				response.Certificate = newTestPKI(t).leaf
+			},
+			reason: VerificationReasonCertificate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, response := pki.authenticateClient(t, transactionID)
+			tt.modify(request, response)
+			err := pki.verifier().VerifyAuthenticateClient(request, response)
+			var verificationError *VerificationError
+			if !errors.As(err, &verificationError) {
+				t.Fatalf("VerifyAuthenticateClient() error = %v, want *VerificationError", err)
+			}
+			if verificationError.Reason != tt.reason {
+				t.Errorf("Reason = %v, want %v", verificationError.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func newTestBoundProfilePackage(t *testing.T, remoteOperationID byte, transactionID []byte, metadata *bertlv.TLV) *sgp22.ES9BoundProfilePackageResponse {
+	t.Helper()
+	data, err := metadata.Bytes()
+	if err != nil {
+		t.Fatalf("TLV.Bytes() error = %v", err)
+	}
+	mac := make([]byte, 8)
+	return &sgp22.ES9BoundProfilePackageResponse{
+		TransactionID: transactionID,
+		BoundProfilePackage: bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(54),
+			bertlv.NewChildren(
+				bertlv.ContextSpecific.Constructed(35),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), []byte{remoteOperationID}),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), transactionID),
+				bertlv.NewChildren(bertlv.ContextSpecific.Constructed(6)),
+				bertlv.NewValue(bertlv.Application.Primitive(73), make([]byte, 65)),
+				bertlv.NewValue(bertlv.Application.Primitive(55), make([]byte, 64)),
+			),
+			bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0)),
+			bertlv.NewChildren(
+				bertlv.ContextSpecific.Constructed(1),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(8), append(data[:len(data)/2:len(data)/2], mac...)),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(8), append(data[len(data)/2:], mac...)),
+			),
+			bertlv.NewChildren(bertlv.ContextSpecific.Constructed(3)),
+		),
+	}
+}
+
+func TestCIVerifierVerifyBoundProfilePackage(t *testing.T) {
+	transactionID := []byte{0x01, 0x02}
+	storeMetadata := func(name string) *bertlv.TLV {
+		return bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(37),
+			bertlv.NewValue(sgp22.TagICCID, []byte{0x98, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xF0}),
+			bertlv.NewValue(sgp22.TagServiceProviderName, []byte("Example")),
+			bertlv.NewValue(sgp22.TagProfileName, []byte(name)),
+		)
+	}
+	var metadata sgp22.ProfileInfo
+	if err := metadata.UnmarshalBERTLV(storeMetadata("Example")); err != nil {
+		t.Fatalf("ProfileInfo.UnmarshalBERTLV() error = %v", err)
+	}
+	verifier := newTestPKI(t).verifier()
+
+	if err := verifier.VerifyBoundProfilePackage(transactionID, &metadata, newTestBoundProfilePackage(t, 1, transactionID, storeMetadata("Example"))); err != nil {
+		t.Errorf("VerifyBoundProfilePackage() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		response *sgp22.ES9BoundProfilePackageResponse
+		reason   VerificationReason
+	}{
+		{"transaction ID", newTestBoundProfilePackage(t, 1, []byte{0x03}, storeMetadata("Example")), VerificationReasonTransactionID},
+		{"remote operation", newTestBoundProfilePackage(t, 2, transactionID, storeMetadata("Example")), VerificationReasonRemoteOperation},
+		{"metadata", newTestBoundProfilePackage(t, 1, transactionID, storeMetadata("Substituted")), VerificationReasonMetadata},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifier.VerifyBoundProfilePackage(transactionID, &metadata, tt.response)
+			var verificationError *VerificationError
+			if !errors.As(err, &verificationError) {
+				t.Fatalf("VerifyBoundProfilePackage() error = %v, want *VerificationError", err)
+			}
+			if verificationError.Reason != tt.reason {
+				t.Errorf("Reason = %v, want %v", verificationError.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestVerificationCancelReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want sgp22.CancelSessionReason
+	}{
+		{errors.New("other"), sgp22.CancelSessionReasonPostponed},
+		{&VerificationError{Reason: VerificationReasonMetadata}, sgp22.CancelSessionReasonMetadataMismatch},
+		{&VerificationError{Reason: VerificationReasonSignature}, sgp22.CancelSessionReasonUndefined},
+	}
+	for _, tt := range tests {
+		if got := verificationCancelReason(tt.err, sgp22.CancelSessionReasonPostponed); got != tt.want {
+			t.Errorf("verificationCancelReason(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
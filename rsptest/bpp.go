@@ -0,0 +1,169 @@
+package rsptest
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"slices"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/bertlv/primitive"
+	"github.com/damonto/euicc-go/internal/scp03t"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// hostID identifies the SM-DP+ in the control reference template.
+var hostID = []byte("RSPTEST SM-DP+")
+
+// storeMetadataRequest returns the StoreMetadataRequest of the profile, the profile metadata
+// returned by authenticateClient and stored on the eUICC by the '88' TLVs.
+func storeMetadataRequest(profile *Profile) (*bertlv.TLV, error) {
+	if profile == nil || len(profile.ICCID) == 0 {
+		return nil, errors.New("order has no profile")
+	}
+	profileClass, err := profile.ProfileClass.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	metadata := bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(37),
+		bertlv.NewValue(sgp22.TagICCID, profile.ICCID),
+		bertlv.NewValue(sgp22.TagServiceProviderName, []byte(profile.ServiceProviderName)),
+		bertlv.NewValue(sgp22.TagProfileName, []byte(profile.ProfileName)),
+		bertlv.NewValue(sgp22.TagProfileClass, profileClass),
+	)
+	if owner := profile.ProfileOwner; len(owner.PLMN) > 0 {
+		tlv := bertlv.NewChildren(sgp22.TagProfileOwner, bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), owner.PLMN))
+		if owner.GID1 != nil {
+			tlv.Children = append(tlv.Children, bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), owner.GID1))
+		}
+		if owner.GID2 != nil {
+			tlv.Children = append(tlv.Children, bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), owner.GID2))
+		}
+		metadata.Children = append(metadata.Children, tlv)
+	}
+	if rules := profile.ProfilePolicyRules; rules != (sgp22.ProfilePolicyRules{}) {
+		tlv, err := bertlv.MarshalValue(sgp22.TagProfilePolicyRules, primitive.MarshalBitString([]bool{
+			rules.UpdateControl, rules.DisablingNotAllowed, rules.DeletionNotAllowed,
+		}))
+		if err != nil {
+			return nil, err
+		}
+		metadata.Children = append(metadata.Children, tlv)
+	}
+	return metadata, nil
+}
+
+// boundProfilePackage binds the profile of the session to the one-time public key of the eUICC.
+// The session keys are agreed with a new one-time key of the SM-DP+, and protect ConfigureISDP,
+// StoreMetadata and, unless the profile has its own protection keys, the profile elements.
+func (s *SMDP) boundProfilePackage(session *session, euiccOtpk *bertlv.TLV) (*bertlv.TLV, error) {
+	publicKey, err := ecdh.P256().NewPublicKey(euiccOtpk.Value)
+	if err != nil {
+		return nil, err
+	}
+	otsk, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := otsk.ECDH(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	eid, err := sgp22.ParseEID(session.certificates.EID())
+	if err != nil {
+		return nil, err
+	}
+	channel, err := scp03t.New(scp03t.DeriveKeys(sharedSecret, hostID, eid))
+	if err != nil {
+		return nil, err
+	}
+
+	initialiseSecureChannel := bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(35),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), []byte{byte(sgp22.RemoteOperationInstallBoundProfilePackage)}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), session.transactionID),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(6),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{scp03t.KeyType}),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{scp03t.KeyLength}),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(4), hostID),
+		),
+		bertlv.NewValue(bertlv.Application.Primitive(73), otsk.PublicKey().Bytes()),
+	)
+	// smdpSign is computed over the preceding data objects followed by the one-time key of the eUICC.
+	smdpSign, err := sign(s.pbKey, slices.Concat(initialiseSecureChannel.Children, []*bertlv.TLV{euiccOtpk})...)
+	if err != nil {
+		return nil, err
+	}
+	initialiseSecureChannel.Children = append(initialiseSecureChannel.Children, smdpSign)
+
+	configureISDP, err := wrap(channel, bertlv.ContextSpecific.Constructed(0), bertlv.ContextSpecific.Primitive(7), true,
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(36)))
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := storeMetadataRequest(session.order.Profile)
+	if err != nil {
+		return nil, err
+	}
+	storeMetadata, err := wrap(channel, bertlv.ContextSpecific.Constructed(1), bertlv.ContextSpecific.Primitive(8), false, metadata)
+	if err != nil {
+		return nil, err
+	}
+	bpp := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(54), initialiseSecureChannel, configureISDP, storeMetadata)
+
+	if session.order.Profile.ProtectionKeys {
+		keys := &scp03t.Keys{
+			MACChainingValue: make([]byte, scp03t.KeyLength),
+			Enc:              make([]byte, scp03t.KeyLength),
+			MAC:              make([]byte, scp03t.KeyLength),
+		}
+		rand.Read(keys.MACChainingValue)
+		rand.Read(keys.Enc)
+		rand.Read(keys.MAC)
+		replaceSessionKeys, err := wrap(channel, bertlv.ContextSpecific.Constructed(2), bertlv.ContextSpecific.Primitive(7), true,
+			bertlv.NewChildren(
+				bertlv.ContextSpecific.Constructed(38),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), keys.MACChainingValue),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), keys.Enc),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), keys.MAC),
+			))
+		if err != nil {
+			return nil, err
+		}
+		bpp.Children = append(bpp.Children, replaceSessionKeys)
+		if channel, err = scp03t.New(keys); err != nil {
+			return nil, err
+		}
+	}
+
+	elements := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(3))
+	for data := range slices.Chunk(session.order.Profile.Elements, scp03t.MaxSegmentLength) {
+		tlv, err := channel.Wrap(bertlv.ContextSpecific.Primitive(6), data, true)
+		if err != nil {
+			return nil, err
+		}
+		elements.Children = append(elements.Children, tlv)
+	}
+	bpp.Children = append(bpp.Children, elements)
+	return bpp, nil
+}
+
+// wrap protects the encoding of command in TLVs with the given tag, segmented if it is too long,
+// and returns them in a sequence.
+func wrap(channel *scp03t.Channel, sequence, tag bertlv.Tag, encrypt bool, command *bertlv.TLV) (*bertlv.TLV, error) {
+	data, err := command.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	tlv := bertlv.NewChildren(sequence)
+	for segment := range slices.Chunk(data, scp03t.MaxSegmentLength) {
+		protected, err := channel.Wrap(tag, segment, encrypt)
+		if err != nil {
+			return nil, err
+		}
+		tlv.Children = append(tlv.Children, protected)
+	}
+	return tlv, nil
+}
@@ -0,0 +1,158 @@
+package rsptest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"time"
+
+	"github.com/damonto/euicc-go/bertlv"
+)
+
+// CI is a certificate issuer standing in for the GSMA CI.
+type CI struct {
+	Certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+// NewCI creates a self-signed CI certificate valid for a day.
+func NewCI() (*CI, error) {
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CI", Organization: []string{"RSPTEST"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	certificate, key, err := issue(template, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &CI{Certificate: certificate, key: key}, nil
+}
+
+// Roots returns a certificate pool trusting only the CI.
+func (ci *CI) Roots() *x509.CertPool {
+	roots := x509.NewCertPool()
+	roots.AddCert(ci.Certificate)
+	return roots
+}
+
+// Issue issues a certificate from template for a new P-256 key.
+// The serial number and validity are filled in if the template leaves them empty.
+func (ci *CI) Issue(template *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	if template.SerialNumber == nil {
+		serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+		if err != nil {
+			return nil, nil, err
+		}
+		template.SerialNumber = serialNumber
+	}
+	if template.NotBefore.IsZero() {
+		template.NotBefore = ci.Certificate.NotBefore
+	}
+	if template.NotAfter.IsZero() {
+		template.NotAfter = ci.Certificate.NotAfter
+	}
+	return issue(template, ci.Certificate, ci.key)
+}
+
+// IssueEUICC issues CERT.EUM.ECDSA and, from it, CERT.EUICC.ECDSA bound to the EID,
+// and returns both certificates with the private key of the eUICC.
+func (ci *CI) IssueEUICC(eid string) (certificate, eum *x509.Certificate, key *ecdsa.PrivateKey, err error) {
+	eum, eumKey, err := ci.Issue(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test EUM", Organization: []string{"RSPTEST"}},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	certificate, key, err = issue(&x509.Certificate{
+		SerialNumber: eum.SerialNumber,
+		Subject:      pkix.Name{CommonName: "Test eUICC", Organization: []string{"RSPTEST"}, SerialNumber: eid},
+		NotBefore:    eum.NotBefore,
+		NotAfter:     eum.NotAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, eum, eumKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return certificate, eum, key, nil
+}
+
+// issue creates a certificate signed by parentKey, or a self-signed certificate if parent is nil.
+func issue(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return certificate, key, nil
+}
+
+// registeredID returns a subject alternative name extension holding the OID,
+// the form an SM-DP+ certificate carries the SM-DP+ OID in.
+func registeredID(oid asn1.ObjectIdentifier) (pkix.Extension, error) {
+	names, err := asn1.Marshal([]asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 8, Bytes: oidValue(oid)}})
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: asn1.ObjectIdentifier{2, 5, 29, 17}, Value: names}, nil
+}
+
+// oidValue returns the content octets of the DER encoding of oid.
+func oidValue(oid asn1.ObjectIdentifier) []byte {
+	encoded, _ := asn1.Marshal(oid)
+	var value asn1.RawValue
+	asn1.Unmarshal(encoded, &value)
+	return value.Bytes
+}
+
+// certificateTLV returns the DER encoding of the certificate as a TLV.
+func certificateTLV(certificate *x509.Certificate) (*bertlv.TLV, error) {
+	var tlv bertlv.TLV
+	if err := tlv.UnmarshalBinary(certificate.Raw); err != nil {
+		return nil, err
+	}
+	return &tlv, nil
+}
+
+// sign computes an ECDSA signature over the concatenated encodings of the signed TLVs,
+// in the plain r || s format verified by [sgp22.VerifySignature].
+func sign(key *ecdsa.PrivateKey, signed ...*bertlv.TLV) (*bertlv.TLV, error) {
+	digest := sha256.New()
+	for _, tlv := range signed {
+		data, err := tlv.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		digest.Write(data)
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return bertlv.NewValue(bertlv.Application.Primitive(55), signature), nil
+}
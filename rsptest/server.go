@@ -0,0 +1,261 @@
+package rsptest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/damonto/euicc-go/bertlv"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// Function is an ES9+ or ES11 function, named after the last element of its path.
+type Function string
+
+const (
+	FunctionInitiateAuthentication Function = "initiateAuthentication"
+	FunctionAuthenticateClient     Function = "authenticateClient"
+	FunctionGetBoundProfilePackage Function = "getBoundProfilePackage"
+	FunctionHandleNotification     Function = "handleNotification"
+	FunctionCancelSession          Function = "cancelSession"
+)
+
+// Status codes returned by the SM-DP+ and the SM-DS, see [sgp22.StatusCodeData].
+var (
+	StatusInvalidSignature        = &sgp22.StatusCodeData{SubjectCode: "8.1", ReasonCode: "6.1"}
+	StatusEIDMissing              = &sgp22.StatusCodeData{SubjectCode: "8.1.1", ReasonCode: "2.2"}
+	StatusEIDMismatch             = &sgp22.StatusCodeData{SubjectCode: "8.1.1", ReasonCode: "3.8"}
+	StatusInvalidEUICCCertificate = &sgp22.StatusCodeData{SubjectCode: "8.1.3", ReasonCode: "6.1"}
+	StatusMatchingIDRefused       = &sgp22.StatusCodeData{SubjectCode: "8.2.6", ReasonCode: "3.8"}
+	StatusConfirmationCodeMissing = &sgp22.StatusCodeData{SubjectCode: "8.2.7", ReasonCode: "2.2"}
+	StatusConfirmationCodeRefused = &sgp22.StatusCodeData{SubjectCode: "8.2.7", ReasonCode: "3.8"}
+	StatusInvalidOID              = &sgp22.StatusCodeData{SubjectCode: "8.8", ReasonCode: "3.10"}
+	StatusInvalidAddress          = &sgp22.StatusCodeData{SubjectCode: "8.8.1", ReasonCode: "3.8"}
+	StatusUnsupportedCI           = &sgp22.StatusCodeData{SubjectCode: "8.8.2", ReasonCode: "3.1"}
+	StatusUnknownTransaction      = &sgp22.StatusCodeData{SubjectCode: "8.10.1", ReasonCode: "3.9"}
+)
+
+// session is an RSP session, identified by its transaction ID.
+type session struct {
+	transactionID   []byte
+	serverChallenge []byte
+	certificates    *sgp22.EUICCCertificates
+	order           *Order
+	smdpSigned2     *bertlv.TLV
+	smdpSignature2  *bertlv.TLV
+}
+
+// authenticator runs the mutual authentication shared by ES9+ and ES11,
+// signing with the authentication certificate of the server.
+type authenticator struct {
+	ci          *CI
+	key         *ecdsa.PrivateKey
+	certificate *bertlv.TLV
+	sessions    map[string]*session
+}
+
+func newAuthenticator(ci *CI, name string, oid asn1.ObjectIdentifier) (*authenticator, error) {
+	key, certificate, err := issueServer(ci, name, oid)
+	if err != nil {
+		return nil, err
+	}
+	return &authenticator{ci: ci, key: key, certificate: certificate, sessions: make(map[string]*session)}, nil
+}
+
+// issueServer issues a server certificate carrying oid, if it is not nil, as its registered ID.
+func issueServer(ci *CI, name string, oid asn1.ObjectIdentifier) (*ecdsa.PrivateKey, *bertlv.TLV, error) {
+	template := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: name, Organization: []string{"RSPTEST"}},
+		KeyUsage: x509.KeyUsageDigitalSignature,
+	}
+	if oid != nil {
+		extension, err := registeredID(oid)
+		if err != nil {
+			return nil, nil, err
+		}
+		template.ExtraExtensions = []pkix.Extension{extension}
+	}
+	certificate, key, err := ci.Issue(template)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlv, err := certificateTLV(certificate)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, tlv, nil
+}
+
+// session returns the session of the transaction ID.
+func (a *authenticator) session(transactionID []byte) (*session, *sgp22.StatusCodeData) {
+	if session, ok := a.sessions[hex.EncodeToString(transactionID)]; ok {
+		return session, nil
+	}
+	return nil, StatusUnknownTransaction
+}
+
+// end ends the session.
+func (a *authenticator) end(session *session) {
+	delete(a.sessions, hex.EncodeToString(session.transactionID))
+}
+
+// begin starts a session and signs the eUICC challenge. Any address is accepted if address is empty.
+//
+// See https://aka.pw/sgp22/v2.5#page=170 (Section 5.6.1, ES9p.InitiateAuthentication)
+func (a *authenticator) begin(address string, request *sgp22.ES9InitiateAuthenticationRequest) (*sgp22.ES9InitiateAuthenticationResponse, *sgp22.StatusCodeData) {
+	if address != "" && !strings.EqualFold(request.Address, address) {
+		return nil, StatusInvalidAddress
+	}
+	if request.Info1 != nil {
+		var info1 sgp22.EUICCInfo1
+		if err := info1.UnmarshalBERTLV(request.Info1); err != nil {
+			return nil, withMessage(StatusUnsupportedCI, err)
+		}
+		if !slices.ContainsFunc(info1.CIPKIDListForVerification, func(pkid []byte) bool {
+			return bytes.Equal(pkid, a.ci.Certificate.SubjectKeyId)
+		}) {
+			return nil, StatusUnsupportedCI
+		}
+	}
+	session := &session{
+		transactionID:   make([]byte, 16),
+		serverChallenge: make([]byte, 16),
+	}
+	rand.Read(session.transactionID)
+	rand.Read(session.serverChallenge)
+	signed1 := bertlv.NewChildren(
+		bertlv.Universal.Constructed(16),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), session.transactionID),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), request.Challenge),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(3), []byte(request.Address)),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(4), session.serverChallenge),
+	)
+	signature1, err := sign(a.key, signed1)
+	if err != nil {
+		return nil, withMessage(StatusInvalidSignature, err)
+	}
+	a.sessions[hex.EncodeToString(session.transactionID)] = session
+	return &sgp22.ES9InitiateAuthenticationResponse{
+		Header:        executed(),
+		TransactionID: session.transactionID,
+		Signed1:       signed1,
+		Signature1:    signature1,
+		UsedIssuer:    bertlv.NewValue(bertlv.Universal.Primitive(4), a.ci.Certificate.SubjectKeyId),
+		Certificate:   a.certificate,
+	}, nil
+}
+
+// authenticate verifies the eUICC certificates up to roots and the euiccSignature1 of the session,
+// and returns the session with the certificates, the euiccSigned1 and the euiccSignature1.
+func (a *authenticator) authenticate(roots *x509.CertPool, request *sgp22.ES9AuthenticateClientRequest) (*session, *bertlv.TLV, *bertlv.TLV, *sgp22.StatusCodeData) {
+	session, status := a.session(request.TransactionID)
+	if status != nil {
+		return nil, nil, nil, status
+	}
+	if err := request.Valid(); err != nil {
+		return nil, nil, nil, withMessage(StatusInvalidSignature, err)
+	}
+	certificates, err := request.Certificates()
+	if err != nil {
+		return nil, nil, nil, withMessage(StatusInvalidEUICCCertificate, err)
+	}
+	if err := certificates.Verify(roots, time.Time{}); err != nil {
+		return nil, nil, nil, withMessage(StatusInvalidEUICCCertificate, err)
+	}
+	signed1 := request.Response.First(bertlv.ContextSpecific.Constructed(0)).First(bertlv.Universal.Constructed(16))
+	signature1 := request.EUICCSignature1()
+	if err := sgp22.VerifySignature(certificates.EUICC, signature1, signed1); err != nil {
+		return nil, nil, nil, withMessage(StatusInvalidSignature, err)
+	}
+	transactionID := signed1.First(bertlv.ContextSpecific.Primitive(0))
+	serverChallenge := signed1.First(bertlv.ContextSpecific.Primitive(4))
+	if transactionID == nil || !bytes.Equal(transactionID.Value, session.transactionID) ||
+		serverChallenge == nil || !bytes.Equal(serverChallenge.Value, session.serverChallenge) {
+		return nil, nil, nil, StatusInvalidSignature
+	}
+	session.certificates = certificates
+	return session, signed1, signature1, nil
+}
+
+// matchingID returns the matching ID in the ctxParams1 of euiccSigned1, or an empty string if it has none.
+func matchingID(signed1 *bertlv.TLV) string {
+	if ctxParams1 := signed1.First(bertlv.ContextSpecific.Constructed(0)); ctxParams1 != nil {
+		if tlv := ctxParams1.First(bertlv.ContextSpecific.Primitive(0)); tlv != nil {
+			return string(tlv.Value)
+		}
+	}
+	return ""
+}
+
+// route checks the method and the X-Admin-Protocol header of a request to a path under one of the prefixes,
+// and returns the function it calls. Otherwise an error is written and false is returned.
+func route(w http.ResponseWriter, r *http.Request, prefixes ...string) (Function, bool) {
+	for _, prefix := range prefixes {
+		name, ok := strings.CutPrefix(r.URL.Path, prefix)
+		if !ok {
+			continue
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return "", false
+		}
+		if !strings.HasPrefix(r.Header.Get("X-Admin-Protocol"), "gsma/rsp/v") {
+			http.Error(w, "missing X-Admin-Protocol header", http.StatusBadRequest)
+			return "", false
+		}
+		return Function(name), true
+	}
+	http.NotFound(w, r)
+	return "", false
+}
+
+// reply writes the JSON response of a function, or a header with status if the function failed.
+// An error means the request could not be decoded.
+func reply(w http.ResponseWriter, r *http.Request, response any, status *sgp22.StatusCodeData, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("X-Admin-Protocol", r.Header.Get("X-Admin-Protocol"))
+	if status != nil {
+		response = &struct {
+			Header *sgp22.Header `json:"header"`
+		}{failed(status)}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handle decodes the JSON request of a function and calls its handler.
+// An error is returned if the request cannot be decoded.
+func handle[Request, Response any](r *http.Request, handler func(*Request) (*Response, *sgp22.StatusCodeData)) (any, *sgp22.StatusCodeData, error) {
+	request := new(Request)
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		return nil, nil, err
+	}
+	response, status := handler(request)
+	return response, status, nil
+}
+
+func executed() *sgp22.Header {
+	return &sgp22.Header{ExecutionStatus: &sgp22.ExecutionStatus{Status: "Executed-Success"}}
+}
+
+func failed(status *sgp22.StatusCodeData) *sgp22.Header {
+	return &sgp22.Header{ExecutionStatus: &sgp22.ExecutionStatus{Status: "Failed", StatusCodeData: status}}
+}
+
+// withMessage returns a copy of status describing err.
+func withMessage(status *sgp22.StatusCodeData, err error) *sgp22.StatusCodeData {
+	return &sgp22.StatusCodeData{SubjectCode: status.SubjectCode, ReasonCode: status.ReasonCode, Message: err.Error()}
+}
@@ -0,0 +1,340 @@
+// Package rsptest provides in-process stand-ins for the remote SIM provisioning servers,
+// so that RSP clients can be tested offline with [net/http/httptest].
+//
+//	ci, _ := rsptest.NewCI()
+//	smdp, _ := rsptest.NewSMDP(ci)
+//	smdp.AddOrder(&rsptest.Order{MatchingID: "MATCHING-ID", Profile: profile})
+//	server := httptest.NewTLSServer(smdp)
+//	defer server.Close()
+package rsptest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/bertlv/primitive"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// OID is the SM-DP+ OID carried by the certificates of an [SMDP].
+var OID = asn1.ObjectIdentifier{2, 999, 1}
+
+// Profile is a profile the SM-DP+ can bind to an eUICC.
+type Profile struct {
+	ICCID               sgp22.ICCID
+	ServiceProviderName string
+	ProfileName         string
+	ProfileClass        sgp22.ProfileClass
+	ProfileOwner        sgp22.OperatorId
+	ProfilePolicyRules  sgp22.ProfilePolicyRules
+	// Elements is the unprotected profile package, loaded with the '86' TLVs of the bound profile package.
+	Elements []byte
+	// ProtectionKeys encrypts the elements with random profile protection keys,
+	// which are sent to the eUICC with ReplaceSessionKeys.
+	ProtectionKeys bool
+}
+
+// Order is a download order of a profile.
+type Order struct {
+	// MatchingID identifies the order in the activation code. An order with an empty
+	// matching ID is downloaded by its EID, as from a default SM-DP+ address.
+	MatchingID string
+	// EID binds the order to one eUICC. Any eUICC can download the order if it is empty.
+	EID string
+	// ConfirmationCode is required from the end user if it is not empty.
+	ConfirmationCode string
+	Profile          *Profile
+}
+
+// CanceledSession is a session canceled with ES9+.CancelSession.
+type CanceledSession struct {
+	TransactionID []byte
+	Reason        sgp22.CancelSessionReason
+}
+
+// SMDP is an SM-DP+ serving ES9+. It is an [http.Handler] and safe for concurrent use.
+//
+// The SM-DP+ verifies the eUICC like a real one, the signatures, the eUICC and EUM certificates
+// and the confirmation code, and binds the profile of the order to the one-time key of the eUICC.
+type SMDP struct {
+	// Address is the SM-DP+ address expected in initiateAuthentication. Any address is accepted if it is empty.
+	Address string
+	// Roots are the CI roots the eUICC certificates are verified against. NewSMDP sets it to the CI of the SM-DP+.
+	Roots *x509.CertPool
+
+	*authenticator
+	pbKey         *ecdsa.PrivateKey
+	pbTLV         *bertlv.TLV
+	mu            sync.Mutex
+	orders        map[string]*Order
+	defaultOrders map[string]*Order
+	failures      map[Function]*sgp22.StatusCodeData
+	notifications []*sgp22.PendingNotification
+	canceled      []CanceledSession
+}
+
+// NewSMDP creates an SM-DP+ whose CERT.DPauth.ECDSA and CERT.DPpb.ECDSA are issued by ci.
+func NewSMDP(ci *CI) (*SMDP, error) {
+	authenticator, err := newAuthenticator(ci, "Test SM-DP+ Auth", OID)
+	if err != nil {
+		return nil, err
+	}
+	s := &SMDP{
+		Roots:         ci.Roots(),
+		authenticator: authenticator,
+		orders:        make(map[string]*Order),
+		defaultOrders: make(map[string]*Order),
+		failures:      make(map[Function]*sgp22.StatusCodeData),
+	}
+	if s.pbKey, s.pbTLV, err = issueServer(ci, "Test SM-DP+ PB", OID); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// AddOrder adds a download order, replacing the order with the same matching ID,
+// or the order with the same EID if the matching ID is empty.
+func (s *SMDP) AddOrder(order *Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if order.MatchingID == "" {
+		s.defaultOrders[strings.ToUpper(order.EID)] = order
+		return
+	}
+	s.orders[order.MatchingID] = order
+}
+
+// Fail makes every call of function fail with status, until it is called again with a nil status.
+// ES9+.HandleNotification returns no function execution status,
+// so a failing notification is answered with 500 Internal Server Error instead.
+func (s *SMDP) Fail(function Function, status *sgp22.StatusCodeData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status == nil {
+		delete(s.failures, function)
+		return
+	}
+	s.failures[function] = status
+}
+
+// Notifications returns the pending notifications received, oldest first.
+func (s *SMDP) Notifications() []*sgp22.PendingNotification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.notifications)
+}
+
+// CanceledSessions returns the sessions canceled by the eUICC, oldest first.
+func (s *SMDP) CanceledSessions() []CanceledSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.canceled)
+}
+
+// ServeHTTP serves the ES9+ functions under /gsma/rsp2/es9plus/.
+func (s *SMDP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	function, ok := route(w, r, "/gsma/rsp2/es9plus/")
+	if !ok {
+		return
+	}
+	var response any
+	var status *sgp22.StatusCodeData
+	var err error
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status = s.failures[function]; status == nil {
+		switch function {
+		case FunctionInitiateAuthentication:
+			response, status, err = handle(r, s.initiateAuthentication)
+		case FunctionAuthenticateClient:
+			response, status, err = handle(r, s.authenticateClient)
+		case FunctionGetBoundProfilePackage:
+			response, status, err = handle(r, s.getBoundProfilePackage)
+		case FunctionHandleNotification:
+			response, status, err = handle(r, s.handleNotification)
+		case FunctionCancelSession:
+			response, status, err = handle(r, s.cancelSession)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+	}
+	if function == FunctionHandleNotification && err == nil {
+		if status != nil {
+			http.Error(w, status.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	reply(w, r, response, status, err)
+}
+
+// initiateAuthentication starts a session and signs the eUICC challenge with CERT.DPauth.ECDSA.
+//
+// See https://aka.pw/sgp22/v2.5#page=170 (Section 5.6.1, ES9p.InitiateAuthentication)
+func (s *SMDP) initiateAuthentication(request *sgp22.ES9InitiateAuthenticationRequest) (*sgp22.ES9InitiateAuthenticationResponse, *sgp22.StatusCodeData) {
+	return s.begin(s.Address, request)
+}
+
+// authenticateClient verifies the eUICC and returns the metadata of the ordered profile,
+// signed with CERT.DPpb.ECDSA.
+func (s *SMDP) authenticateClient(request *sgp22.ES9AuthenticateClientRequest) (*sgp22.ES9AuthenticateClientResponse, *sgp22.StatusCodeData) {
+	session, signed1, signature1, status := s.authenticate(s.Roots, request)
+	if status != nil {
+		return nil, status
+	}
+	order, status := s.order(matchingID(signed1), session.certificates.EID())
+	if status != nil {
+		return nil, status
+	}
+	metadata, err := storeMetadataRequest(order.Profile)
+	if err != nil {
+		return nil, withMessage(StatusMatchingIDRefused, err)
+	}
+	ccRequired, _ := primitive.MarshalBool(order.ConfirmationCode != "").MarshalBinary()
+	signed2 := bertlv.NewChildren(
+		bertlv.Universal.Constructed(16),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), session.transactionID),
+		bertlv.NewValue(bertlv.Universal.Primitive(1), ccRequired),
+	)
+	signature2, err := sign(s.pbKey, signed2, signature1)
+	if err != nil {
+		return nil, withMessage(StatusInvalidSignature, err)
+	}
+	session.order = order
+	session.smdpSigned2 = signed2
+	session.smdpSignature2 = signature2
+	return &sgp22.ES9AuthenticateClientResponse{
+		Header:          executed(),
+		TransactionID:   session.transactionID,
+		ProfileMetadata: metadata,
+		Signed2:         signed2,
+		Signature2:      signature2,
+		Certificate:     s.pbTLV,
+	}, nil
+}
+
+// order returns the order of the matching ID, or the order bound to the EID if the matching ID is empty.
+func (s *SMDP) order(matchingID, eid string) (*Order, *sgp22.StatusCodeData) {
+	if matchingID == "" {
+		order, ok := s.defaultOrders[strings.ToUpper(eid)]
+		if !ok || eid == "" {
+			return nil, StatusEIDMissing
+		}
+		return order, nil
+	}
+	order, ok := s.orders[matchingID]
+	if !ok {
+		return nil, StatusMatchingIDRefused
+	}
+	if order.EID != "" && !strings.EqualFold(order.EID, eid) {
+		return nil, StatusEIDMismatch
+	}
+	return order, nil
+}
+
+// getBoundProfilePackage verifies the PrepareDownloadResponse and the confirmation code,
+// and binds the profile to the one-time key of the eUICC.
+func (s *SMDP) getBoundProfilePackage(request *sgp22.ES9BoundProfilePackageRequest) (*sgp22.ES9BoundProfilePackageResponse, *sgp22.StatusCodeData) {
+	session, status := s.session(request.TransactionID)
+	if status != nil {
+		return nil, status
+	}
+	if session.order == nil || request.Response == nil {
+		return nil, StatusUnknownTransaction
+	}
+	ok := request.Response.First(bertlv.ContextSpecific.Constructed(0))
+	if ok == nil {
+		return nil, withMessage(StatusInvalidSignature, errors.New("eUICC rejected PrepareDownload"))
+	}
+	signed2 := ok.First(bertlv.Universal.Constructed(16))
+	if err := sgp22.VerifySignature(session.certificates.EUICC, ok.First(bertlv.Application.Primitive(55)), signed2, session.smdpSignature2); err != nil {
+		return nil, withMessage(StatusInvalidSignature, err)
+	}
+	transactionID := signed2.First(bertlv.ContextSpecific.Primitive(0))
+	otpk := signed2.First(bertlv.Application.Primitive(73))
+	if transactionID == nil || !bytes.Equal(transactionID.Value, session.transactionID) || otpk == nil {
+		return nil, StatusInvalidSignature
+	}
+	if code := session.order.ConfirmationCode; code != "" {
+		hashed := signed2.First(bertlv.Universal.Primitive(4))
+		if hashed == nil {
+			return nil, StatusConfirmationCodeMissing
+		}
+		want, err := (&sgp22.PrepareDownloadRequest{
+			TransactionID:    session.transactionID,
+			Signed2:          session.smdpSigned2,
+			ConfirmationCode: []byte(code),
+		}).HashedConfirmationCode()
+		if err != nil || !bytes.Equal(hashed.Value, want) {
+			return nil, StatusConfirmationCodeRefused
+		}
+	}
+	bpp, err := s.boundProfilePackage(session, otpk)
+	if err != nil {
+		return nil, withMessage(StatusInvalidSignature, err)
+	}
+	return &sgp22.ES9BoundProfilePackageResponse{
+		Header:              executed(),
+		TransactionID:       session.transactionID,
+		BoundProfilePackage: bpp,
+	}, nil
+}
+
+// handleNotification records the pending notification.
+//
+// See https://aka.pw/sgp22/v2.5#page=177 (Section 5.6.4, ES9p.HandleNotification)
+func (s *SMDP) handleNotification(request *sgp22.ES9HandleNotificationRequest) (*sgp22.ES9HandleNotificationResponse, *sgp22.StatusCodeData) {
+	if request.PendingNotification == nil {
+		return nil, withMessage(StatusInvalidSignature, errors.New("missing pending notification"))
+	}
+	notification := new(sgp22.PendingNotification)
+	if err := notification.UnmarshalBERTLV(request.PendingNotification); err != nil {
+		return nil, withMessage(StatusInvalidSignature, err)
+	}
+	s.notifications = append(s.notifications, notification)
+	return new(sgp22.ES9HandleNotificationResponse), nil
+}
+
+// cancelSession verifies the signed CancelSessionResponse of the eUICC and ends the session.
+//
+// See https://aka.pw/sgp22/v2.5#page=177 (Section 5.6.5, ES9p.CancelSession)
+func (s *SMDP) cancelSession(request *sgp22.ES9CancelSessionRequest) (*sgp22.ES9CancelSessionResponse, *sgp22.StatusCodeData) {
+	session, status := s.session(request.TransactionID)
+	if status != nil {
+		return nil, status
+	}
+	if request.Response == nil {
+		return nil, withMessage(StatusInvalidSignature, errors.New("missing cancelSessionResponse"))
+	}
+	if ok := request.Response.First(bertlv.ContextSpecific.Constructed(0)); ok != nil {
+		signed := ok.First(bertlv.Universal.Constructed(16))
+		if signed == nil {
+			return nil, withMessage(StatusInvalidSignature, errors.New("missing euiccCancelSessionSigned"))
+		}
+		if session.certificates != nil {
+			if err := sgp22.VerifySignature(session.certificates.EUICC, ok.First(bertlv.Application.Primitive(55)), signed); err != nil {
+				return nil, withMessage(StatusInvalidSignature, err)
+			}
+		}
+		if oid := signed.First(bertlv.Universal.Primitive(6)); oid == nil || !bytes.Equal(oid.Value, oidValue(OID)) {
+			return nil, StatusInvalidOID
+		}
+		canceled := CanceledSession{TransactionID: session.transactionID}
+		if reason := signed.First(bertlv.ContextSpecific.Primitive(1)); reason != nil && len(reason.Value) == 1 {
+			canceled.Reason = sgp22.CancelSessionReason(reason.Value[0])
+		}
+		s.canceled = append(s.canceled, canceled)
+	}
+	s.end(session)
+	return &sgp22.ES9CancelSessionResponse{Header: executed()}, nil
+}
@@ -0,0 +1,449 @@
+package rsptest
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/http"
+	"github.com/damonto/euicc-go/internal/scp03t"
+	"github.com/damonto/euicc-go/lpa"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+const testEID = "89049032123451234512345678901235"
+
+// testEUICC plays the eUICC side of a download against an SM-DP+.
+type testEUICC struct {
+	t           *testing.T
+	ci          *CI
+	client      *http.Client
+	address     *url.URL
+	key         *ecdsa.PrivateKey
+	certificate *bertlv.TLV
+	eum         *bertlv.TLV
+	otsk        *ecdh.PrivateKey
+	verifier    *lpa.CIVerifier
+}
+
+func newTestSMDP(t *testing.T, order *Order) (*SMDP, *testEUICC) {
+	t.Helper()
+	ci, err := NewCI()
+	if err != nil {
+		t.Fatalf("NewCI() error = %v", err)
+	}
+	smdp, err := NewSMDP(ci)
+	if err != nil {
+		t.Fatalf("NewSMDP() error = %v", err)
+	}
+	smdp.AddOrder(order)
+	server := httptest.NewTLSServer(smdp)
+	t.Cleanup(server.Close)
+	address, _ := url.Parse(server.URL)
+
+	certificate, eum, key, err := ci.IssueEUICC(testEID)
+	if err != nil {
+		t.Fatalf("IssueEUICC() error = %v", err)
+	}
+	euicc := &testEUICC{
+		t:        t,
+		ci:       ci,
+		client:   &http.Client{Client: server.Client(), AdminProtocolVersion: "2.5.0"},
+		address:  address,
+		key:      key,
+		verifier: &lpa.CIVerifier{Roots: ci.Roots()},
+	}
+	euicc.certificate, _ = certificateTLV(certificate)
+	euicc.eum, _ = certificateTLV(eum)
+	return smdp, euicc
+}
+
+// at returns a copy of the eUICC talking to another server.
+func (e *testEUICC) at(server *httptest.Server) *testEUICC {
+	address, _ := url.Parse(server.URL)
+	return &testEUICC{
+		t:           e.t,
+		ci:          e.ci,
+		client:      &http.Client{Client: server.Client(), AdminProtocolVersion: "2.5.0"},
+		address:     address,
+		key:         e.key,
+		certificate: e.certificate,
+		eum:         e.eum,
+		verifier:    e.verifier,
+	}
+}
+
+func (e *testEUICC) sign(signed ...*bertlv.TLV) *bertlv.TLV {
+	e.t.Helper()
+	signature, err := sign(e.key, signed...)
+	if err != nil {
+		e.t.Fatalf("sign() error = %v", err)
+	}
+	return signature
+}
+
+func (e *testEUICC) initiateAuthentication() (*sgp22.ES9InitiateAuthenticationResponse, error) {
+	request := &sgp22.ES9InitiateAuthenticationRequest{
+		Challenge: make([]byte, 16),
+		Info1: bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(32),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), []byte{2, 5, 0}),
+			bertlv.NewChildren(bertlv.ContextSpecific.Constructed(9), bertlv.NewValue(bertlv.Universal.Primitive(4), e.ci.Certificate.SubjectKeyId)),
+			bertlv.NewChildren(bertlv.ContextSpecific.Constructed(10), bertlv.NewValue(bertlv.Universal.Primitive(4), e.ci.Certificate.SubjectKeyId)),
+		),
+		Address: e.address.Host,
+	}
+	rand.Read(request.Challenge)
+	response, err := sgp22.InvokeHTTP(e.t.Context(), e.client, e.address, request)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.verifier.VerifyInitiateAuthentication(request, response); err != nil {
+		e.t.Fatalf("VerifyInitiateAuthentication() error = %v", err)
+	}
+	return response, nil
+}
+
+// authenticateServer authenticates the server and returns the AuthenticateServerResponse signed by the eUICC.
+func (e *testEUICC) authenticateServer(matchingID string) (*sgp22.ES9AuthenticateClientRequest, error) {
+	e.t.Helper()
+	initiated, err := e.initiateAuthentication()
+	if err != nil {
+		return nil, err
+	}
+	signed1, _ := initiated.ServerSigned1()
+	euiccSigned1 := bertlv.NewChildren(
+		bertlv.Universal.Constructed(16),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), signed1.TransactionID),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(3), []byte(signed1.ServerAddress)),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(4), signed1.ServerChallenge),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(34)),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(0),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte(matchingID)),
+		),
+	)
+	return &sgp22.ES9AuthenticateClientRequest{
+		TransactionID: initiated.TransactionID,
+		Response: bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(56),
+			bertlv.NewChildren(
+				bertlv.ContextSpecific.Constructed(0),
+				euiccSigned1,
+				e.sign(euiccSigned1),
+				e.certificate,
+				e.eum,
+			),
+		),
+	}, nil
+}
+
+func (e *testEUICC) authenticateClient(matchingID string) (*sgp22.ES9AuthenticateClientResponse, *sgp22.ES9AuthenticateClientRequest, error) {
+	e.t.Helper()
+	request, err := e.authenticateServer(matchingID)
+	if err != nil {
+		return nil, nil, err
+	}
+	response, err := sgp22.InvokeHTTP(e.t.Context(), e.client, e.address, request)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := e.verifier.VerifyAuthenticateClient(request, response); err != nil {
+		e.t.Fatalf("VerifyAuthenticateClient() error = %v", err)
+	}
+	return response, request, nil
+}
+
+func (e *testEUICC) getBoundProfilePackage(response *sgp22.ES9AuthenticateClientResponse, confirmationCode string) (*sgp22.ES9BoundProfilePackageResponse, error) {
+	e.t.Helper()
+	var err error
+	if e.otsk, err = ecdh.P256().GenerateKey(rand.Reader); err != nil {
+		e.t.Fatalf("GenerateKey() error = %v", err)
+	}
+	signed2 := bertlv.NewChildren(
+		bertlv.Universal.Constructed(16),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), response.TransactionID),
+		bertlv.NewValue(bertlv.Application.Primitive(73), e.otsk.PublicKey().Bytes()),
+	)
+	if confirmationCode != "" {
+		hashed, _ := (&sgp22.PrepareDownloadRequest{
+			TransactionID:    response.TransactionID,
+			Signed2:          response.Signed2,
+			ConfirmationCode: []byte(confirmationCode),
+		}).HashedConfirmationCode()
+		signed2.Children = append(signed2.Children, bertlv.NewValue(bertlv.Universal.Primitive(4), hashed))
+	}
+	return sgp22.InvokeHTTP(e.t.Context(), e.client, e.address, &sgp22.ES9BoundProfilePackageRequest{
+		TransactionID: response.TransactionID,
+		Response: bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(33),
+			bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0), signed2, e.sign(signed2, response.Signature2)),
+		),
+	})
+}
+
+// unwrap opens the bound profile package with the one-time key of the eUICC,
+// and returns the decrypted ConfigureISDP, StoreMetadata and profile elements.
+func (e *testEUICC) unwrap(bpp *bertlv.TLV) (configureISDP, metadata, elements []byte) {
+	e.t.Helper()
+	var request sgp22.InitialiseSecureChannelRequest
+	if err := request.UnmarshalBERTLV(bpp.First(bertlv.ContextSpecific.Constructed(35))); err != nil {
+		e.t.Fatalf("InitialiseSecureChannelRequest.UnmarshalBERTLV() error = %v", err)
+	}
+	publicKey, err := ecdh.P256().NewPublicKey(request.SMDPOtpk)
+	if err != nil {
+		e.t.Fatalf("NewPublicKey() error = %v", err)
+	}
+	sharedSecret, _ := e.otsk.ECDH(publicKey)
+	eid, _ := sgp22.ParseEID(testEID)
+	hostID := request.ControlReferenceTemplate.First(bertlv.ContextSpecific.Primitive(4)).Value
+	channel, err := scp03t.New(scp03t.DeriveKeys(sharedSecret, hostID, eid))
+	if err != nil {
+		e.t.Fatalf("scp03t.New() error = %v", err)
+	}
+	open := func(tag bertlv.Tag, decrypt bool) (data []byte) {
+		sequence := bpp.First(tag)
+		if sequence == nil {
+			return nil
+		}
+		for _, tlv := range sequence.Children {
+			segment, err := channel.Unwrap(tlv, decrypt)
+			if err != nil {
+				e.t.Fatalf("Unwrap(%s) error = %v", tlv.Tag.String(), err)
+			}
+			data = append(data, segment...)
+		}
+		return data
+	}
+	configureISDP = open(bertlv.ContextSpecific.Constructed(0), true)
+	metadata = open(bertlv.ContextSpecific.Constructed(1), false)
+	if replaceSessionKeys := open(bertlv.ContextSpecific.Constructed(2), true); replaceSessionKeys != nil {
+		var tlv bertlv.TLV
+		if err := tlv.UnmarshalBinary(replaceSessionKeys); err != nil {
+			e.t.Fatalf("decode ReplaceSessionKeysRequest: %v", err)
+		}
+		channel, _ = scp03t.New(&scp03t.Keys{
+			MACChainingValue: tlv.First(bertlv.ContextSpecific.Primitive(0)).Value,
+			Enc:              tlv.First(bertlv.ContextSpecific.Primitive(1)).Value,
+			MAC:              tlv.First(bertlv.ContextSpecific.Primitive(2)).Value,
+		})
+	}
+	return configureISDP, metadata, open(bertlv.ContextSpecific.Constructed(3), true)
+}
+
+func testOrder() *Order {
+	return &Order{
+		MatchingID: "MATCHING-ID",
+		Profile: &Profile{
+			ICCID:               sgp22.ICCID{0x98, 0x10, 0x32, 0x54, 0x76, 0x98, 0x10, 0x32, 0x54, 0xF6},
+			ServiceProviderName: "RSPTEST",
+			ProfileName:         "Test Profile",
+			ProfileClass:        sgp22.ProfileClassOperational,
+			ProfileOwner:        sgp22.OperatorId{PLMN: []byte{0x64, 0xF0, 0x00}},
+			ProfilePolicyRules:  sgp22.ProfilePolicyRules{DeletionNotAllowed: true},
+			Elements:            bytes.Repeat([]byte{0xA0, 0x03, 0x80, 0x01, 0x00}, 500),
+		},
+	}
+}
+
+func TestSMDPDownload(t *testing.T) {
+	for _, protectionKeys := range []bool{false, true} {
+		order := testOrder()
+		order.Profile.ProtectionKeys = protectionKeys
+		smdp, euicc := newTestSMDP(t, order)
+
+		response, _, err := euicc.authenticateClient(order.MatchingID)
+		if err != nil {
+			t.Fatalf("authenticateClient() error = %v", err)
+		}
+		var metadata sgp22.ProfileInfo
+		if err := metadata.UnmarshalBERTLV(response.ProfileMetadata); err != nil {
+			t.Fatalf("ProfileInfo.UnmarshalBERTLV() error = %v", err)
+		}
+		if metadata.ProfileName != "Test Profile" || metadata.ProfileClass != sgp22.ProfileClassOperational ||
+			!metadata.ProfilePolicyRules.DeletionNotAllowed || metadata.ProfileOwner.MCC() != "460" {
+			t.Errorf("profile metadata = %+v", metadata)
+		}
+
+		bpp, err := euicc.getBoundProfilePackage(response, "")
+		if err != nil {
+			t.Fatalf("getBoundProfilePackage() error = %v", err)
+		}
+		if err := euicc.verifier.VerifyBoundProfilePackage(response.TransactionID, &metadata, bpp); err != nil {
+			t.Errorf("VerifyBoundProfilePackage() error = %v", err)
+		}
+		configureISDP, stored, elements := euicc.unwrap(bpp.BoundProfilePackage)
+		if !bytes.Equal(configureISDP, []byte{0xBF, 0x24, 0x00}) {
+			t.Errorf("ConfigureISDPRequest = %X", configureISDP)
+		}
+		if want, _ := response.ProfileMetadata.MarshalBinary(); !bytes.Equal(stored, want) {
+			t.Errorf("StoreMetadataRequest = %X, want %X", stored, want)
+		}
+		if !bytes.Equal(elements, order.Profile.Elements) {
+			t.Errorf("profile elements (protection keys %t) differ", protectionKeys)
+		}
+		if got := bpp.BoundProfilePackage.First(bertlv.ContextSpecific.Constructed(2)) != nil; got != protectionKeys {
+			t.Errorf("secondSequenceOf87 present = %t, want %t", got, protectionKeys)
+		}
+		if smdp.CanceledSessions() != nil {
+			t.Errorf("CanceledSessions() = %v, want none", smdp.CanceledSessions())
+		}
+	}
+}
+
+func TestSMDPRejectsOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		order      func(*Order)
+		matchingID string
+		code       string
+		want       *sgp22.StatusCodeData
+	}{
+		{name: "unknown matching ID", matchingID: "UNKNOWN", want: StatusMatchingIDRefused},
+		{name: "other EID", order: func(order *Order) { order.EID = "89049032123451234512345678901234" }, want: StatusEIDMismatch},
+		{name: "confirmation code missing", order: func(order *Order) { order.ConfirmationCode = "1234" }, want: StatusConfirmationCodeMissing},
+		{name: "confirmation code refused", order: func(order *Order) { order.ConfirmationCode = "1234" }, code: "4321", want: StatusConfirmationCodeRefused},
+		{name: "confirmation code", order: func(order *Order) { order.ConfirmationCode = "1234" }, code: "1234"},
+		{name: "EID", order: func(order *Order) { order.MatchingID, order.EID = "", testEID }, matchingID: "-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := testOrder()
+			if tt.order != nil {
+				tt.order(order)
+			}
+			_, euicc := newTestSMDP(t, order)
+			matchingID := order.MatchingID
+			if tt.matchingID != "" {
+				matchingID = tt.matchingID
+			}
+			if matchingID == "-" {
+				matchingID = ""
+			}
+			response, _, err := euicc.authenticateClient(matchingID)
+			if err == nil {
+				_, err = euicc.getBoundProfilePackage(response, tt.code)
+			}
+			if tt.want == nil && err != nil {
+				t.Fatalf("download error = %v", err)
+			}
+			if tt.want != nil && (err == nil || err.Error() != tt.want.Error()) {
+				t.Errorf("download error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSMDPDefaultOrders(t *testing.T) {
+	order := testOrder()
+	order.MatchingID, order.EID = "", testEID
+	smdp, euicc := newTestSMDP(t, order)
+	other := testOrder()
+	other.MatchingID, other.EID = "", "89049032123451234512345678901234"
+	other.Profile.ProfileName = "Other Profile"
+	smdp.AddOrder(other)
+
+	response, _, err := euicc.authenticateClient("")
+	if err != nil {
+		t.Fatalf("authenticateClient() error = %v", err)
+	}
+	var metadata sgp22.ProfileInfo
+	if err := metadata.UnmarshalBERTLV(response.ProfileMetadata); err != nil {
+		t.Fatalf("ProfileInfo.UnmarshalBERTLV() error = %v", err)
+	}
+	if metadata.ProfileName != "Test Profile" {
+		t.Errorf("profile name = %q, want the order of the EID", metadata.ProfileName)
+	}
+}
+
+func TestSMDPRejectsUntrustedEUICC(t *testing.T) {
+	smdp, euicc := newTestSMDP(t, testOrder())
+	other, _ := NewCI()
+	smdp.Roots = other.Roots()
+	if _, _, err := euicc.authenticateClient("MATCHING-ID"); err == nil || err.Error() == StatusMatchingIDRefused.Error() {
+		t.Errorf("authenticateClient() error = %v, want invalid eUICC certificate", err)
+	}
+}
+
+func TestSMDPFail(t *testing.T) {
+	smdp, euicc := newTestSMDP(t, testOrder())
+	status := &sgp22.StatusCodeData{SubjectCode: "8.8.5", ReasonCode: "4.10"}
+	smdp.Fail(FunctionAuthenticateClient, status)
+	if _, _, err := euicc.authenticateClient("MATCHING-ID"); err == nil || err.Error() != status.Error() {
+		t.Errorf("authenticateClient() error = %v, want %v", err, status)
+	}
+	smdp.Fail(FunctionAuthenticateClient, nil)
+	if _, _, err := euicc.authenticateClient("MATCHING-ID"); err != nil {
+		t.Errorf("authenticateClient() error = %v", err)
+	}
+
+	metadata := bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(47),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{0x01}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{0x05, 0x40}),
+		bertlv.NewValue(bertlv.Universal.Primitive(12), []byte(euicc.address.Host)),
+	)
+	request := &sgp22.ES9HandleNotificationRequest{
+		PendingNotification: bertlv.NewChildren(bertlv.Universal.Constructed(16), metadata, euicc.sign(metadata), euicc.certificate, euicc.eum),
+	}
+	smdp.Fail(FunctionHandleNotification, StatusInvalidSignature)
+	var statusErr *http.StatusError
+	if _, err := sgp22.InvokeHTTP(t.Context(), euicc.client, euicc.address, request); !errors.As(err, &statusErr) || statusErr.StatusCode != nethttp.StatusInternalServerError {
+		t.Errorf("HandleNotification() error = %v, want status code 500", err)
+	}
+	smdp.Fail(FunctionHandleNotification, nil)
+	if _, err := sgp22.InvokeHTTP(t.Context(), euicc.client, euicc.address, request); err != nil {
+		t.Fatalf("HandleNotification() error = %v", err)
+	}
+	notifications := smdp.Notifications()
+	if len(notifications) != 1 || notifications[0].Notification.SequenceNumber != 1 {
+		t.Fatalf("Notifications() = %v, want the notification", notifications)
+	}
+	if err := notifications[0].Verify(nil, euicc.ci.Roots(), notifications[0].OtherSignedNotification.Certificates.EUICC.NotBefore); err != nil {
+		t.Errorf("PendingNotification.Verify() error = %v", err)
+	}
+}
+
+func TestSMDPCancelSession(t *testing.T) {
+	smdp, euicc := newTestSMDP(t, testOrder())
+	response, _, err := euicc.authenticateClient("MATCHING-ID")
+	if err != nil {
+		t.Fatalf("authenticateClient() error = %v", err)
+	}
+	cancel := func(oid []byte) error {
+		signed := bertlv.NewChildren(
+			bertlv.Universal.Constructed(16),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), response.TransactionID),
+			bertlv.NewValue(bertlv.Universal.Primitive(6), oid),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{byte(sgp22.CancelSessionReasonPostponed)}),
+		)
+		_, err := sgp22.InvokeHTTP(t.Context(), euicc.client, euicc.address, &sgp22.ES9CancelSessionRequest{
+			TransactionID: response.TransactionID,
+			Response: bertlv.NewChildren(
+				bertlv.ContextSpecific.Constructed(65),
+				bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0), signed, euicc.sign(signed)),
+			),
+		})
+		return err
+	}
+	if err := cancel([]byte{0x2A}); err == nil || err.Error() != StatusInvalidOID.Error() {
+		t.Errorf("CancelSession() error = %v, want %v", err, StatusInvalidOID)
+	}
+	if err := cancel(oidValue(OID)); err != nil {
+		t.Fatalf("CancelSession() error = %v", err)
+	}
+	canceled := smdp.CanceledSessions()
+	if len(canceled) != 1 || canceled[0].Reason != sgp22.CancelSessionReasonPostponed || !bytes.Equal(canceled[0].TransactionID, response.TransactionID) {
+		t.Errorf("CanceledSessions() = %+v, want the postponed session", canceled)
+	}
+	if _, err := euicc.getBoundProfilePackage(response, ""); err == nil || err.Error() != StatusUnknownTransaction.Error() {
+		t.Errorf("getBoundProfilePackage() error = %v, want %v", err, StatusUnknownTransaction)
+	}
+}
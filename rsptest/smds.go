@@ -0,0 +1,146 @@
+package rsptest
+
+import (
+	"crypto/x509"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// event is an event registered for an eUICC.
+type event struct {
+	eid   string
+	entry sgp22.EventEntry
+}
+
+// SMDS is an SM-DS serving ES11. It is an [http.Handler] and safe for concurrent use.
+//
+// The SM-DS authenticates the eUICC like the [SMDP] does, and returns the events registered for its EID.
+// Events are registered and deleted with [SMDS.RegisterEvent] and [SMDS.DeleteEvent],
+// which stand in for ES12.
+type SMDS struct {
+	// Address is the SM-DS address expected in initiateAuthentication. Any address is accepted if it is empty.
+	Address string
+	// Roots are the CI roots the eUICC certificates are verified against. NewSMDS sets it to the CI of the SM-DS.
+	Roots *x509.CertPool
+
+	*authenticator
+	mu       sync.Mutex
+	events   []event
+	failures map[Function]*sgp22.StatusCodeData
+}
+
+// NewSMDS creates an SM-DS whose CERT.DSauth.ECDSA is issued by ci.
+func NewSMDS(ci *CI) (*SMDS, error) {
+	authenticator, err := newAuthenticator(ci, "Test SM-DS Auth", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &SMDS{
+		Roots:         ci.Roots(),
+		authenticator: authenticator,
+		failures:      make(map[Function]*sgp22.StatusCodeData),
+	}, nil
+}
+
+// RegisterEvent registers an event for the eUICC with the EID, pointing it to the RSP server address
+// of the entry. It replaces the event with the same event ID.
+func (s *SMDS) RegisterEvent(eid string, entry sgp22.EventEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = slices.DeleteFunc(s.events, func(event event) bool { return event.entry.EventID == entry.EventID })
+	s.events = append(s.events, event{eid: eid, entry: entry})
+}
+
+// DeleteEvent deletes the event with the event ID, and reports whether it was registered.
+func (s *SMDS) DeleteEvent(eventID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.events)
+	s.events = slices.DeleteFunc(s.events, func(event event) bool { return event.entry.EventID == eventID })
+	return len(s.events) < n
+}
+
+// Events returns the events registered for the EID, oldest first.
+func (s *SMDS) Events(eid string) []*sgp22.EventEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookup(eid, "")
+}
+
+// lookup returns the events of the EID, or only the event with the event ID if it is not empty.
+func (s *SMDS) lookup(eid, eventID string) []*sgp22.EventEntry {
+	entries := make([]*sgp22.EventEntry, 0)
+	for _, event := range s.events {
+		if strings.EqualFold(event.eid, eid) && (eventID == "" || event.entry.EventID == eventID) {
+			entry := event.entry
+			entries = append(entries, &entry)
+		}
+	}
+	return entries
+}
+
+// Fail makes every call of function fail with status, until it is called again with a nil status.
+func (s *SMDS) Fail(function Function, status *sgp22.StatusCodeData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status == nil {
+		delete(s.failures, function)
+		return
+	}
+	s.failures[function] = status
+}
+
+// ServeHTTP serves the ES11 functions under /gsma/rsp2/es11/.
+// They are served under /gsma/rsp2/es9plus/ as well, where lpa.Client.Discovery calls them.
+func (s *SMDS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	function, ok := route(w, r, "/gsma/rsp2/es11/", "/gsma/rsp2/es9plus/")
+	if !ok {
+		return
+	}
+	var response any
+	var status *sgp22.StatusCodeData
+	var err error
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status = s.failures[function]; status == nil {
+		switch function {
+		case FunctionInitiateAuthentication:
+			response, status, err = handle(r, s.initiateAuthentication)
+		case FunctionAuthenticateClient:
+			response, status, err = handle(r, s.authenticateClient)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+	}
+	reply(w, r, response, status, err)
+}
+
+// initiateAuthentication starts a session and signs the eUICC challenge with CERT.DSauth.ECDSA.
+func (s *SMDS) initiateAuthentication(request *sgp22.ES9InitiateAuthenticationRequest) (*sgp22.ES9InitiateAuthenticationResponse, *sgp22.StatusCodeData) {
+	return s.begin(s.Address, request)
+}
+
+// authenticateClient verifies the eUICC and returns the events registered for its EID,
+// or only the event whose ID is the matching ID. The session ends with the response.
+//
+// See https://aka.pw/sgp22/v2.5#page=212 (Section 5.8.2, ES11.AuthenticateClient)
+func (s *SMDS) authenticateClient(request *sgp22.ES11AuthenticateClientRequest) (*sgp22.ES11AuthenticateClientResponse, *sgp22.StatusCodeData) {
+	if request.ES9AuthenticateClientRequest == nil {
+		return nil, StatusUnknownTransaction
+	}
+	session, signed1, _, status := s.authenticate(s.Roots, request.ES9AuthenticateClientRequest)
+	if status != nil {
+		return nil, status
+	}
+	s.end(session)
+	return &sgp22.ES11AuthenticateClientResponse{
+		Header:        executed(),
+		TransactionID: session.transactionID,
+		EventEntries:  s.lookup(session.certificates.EID(), matchingID(signed1)),
+	}, nil
+}
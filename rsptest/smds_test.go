@@ -0,0 +1,155 @@
+package rsptest
+
+import (
+	"bytes"
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+func newTestSMDS(t *testing.T, euicc *testEUICC) (*SMDS, *testEUICC) {
+	t.Helper()
+	smds, err := NewSMDS(euicc.ci)
+	if err != nil {
+		t.Fatalf("NewSMDS() error = %v", err)
+	}
+	server := httptest.NewTLSServer(smds)
+	t.Cleanup(server.Close)
+	return smds, euicc.at(server)
+}
+
+// discover returns the events of the eUICC, or only the event with the event ID if it is not empty.
+func (e *testEUICC) discover(eventID string) ([]*sgp22.EventEntry, error) {
+	e.t.Helper()
+	request, err := e.authenticateServer(eventID)
+	if err != nil {
+		return nil, err
+	}
+	response, err := sgp22.InvokeHTTP(e.t.Context(), e.client, e.address, &sgp22.ES11AuthenticateClientRequest{
+		ES9AuthenticateClientRequest: request,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response.EventEntries, nil
+}
+
+func eventIDs(entries []*sgp22.EventEntry) []string {
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.EventID)
+	}
+	return ids
+}
+
+func TestSMDSDiscoveryAndDownload(t *testing.T) {
+	order := testOrder()
+	order.MatchingID, order.EID = "EVENT-1", testEID
+	_, euicc := newTestSMDP(t, order)
+	smds, ds := newTestSMDS(t, euicc)
+	smds.RegisterEvent(testEID, sgp22.EventEntry{EventID: "EVENT-1", Address: euicc.address.Host})
+	smds.RegisterEvent("89049032123451234512345678901234", sgp22.EventEntry{EventID: "EVENT-2", Address: euicc.address.Host})
+
+	entries, err := ds.discover("")
+	if err != nil {
+		t.Fatalf("discover() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].EventID != "EVENT-1" {
+		t.Fatalf("discover() = %v, want EVENT-1", eventIDs(entries))
+	}
+	if got := entries[0].URL().Host; got != euicc.address.Host {
+		t.Fatalf("EventEntry.URL().Host = %q, want %q", got, euicc.address.Host)
+	}
+
+	response, _, err := euicc.authenticateClient(entries[0].EventID)
+	if err != nil {
+		t.Fatalf("authenticateClient() error = %v", err)
+	}
+	bpp, err := euicc.getBoundProfilePackage(response, "")
+	if err != nil {
+		t.Fatalf("getBoundProfilePackage() error = %v", err)
+	}
+	if _, _, elements := euicc.unwrap(bpp.BoundProfilePackage); !bytes.Equal(elements, order.Profile.Elements) {
+		t.Errorf("profile elements differ")
+	}
+}
+
+func TestSMDSEvents(t *testing.T) {
+	_, euicc := newTestSMDP(t, testOrder())
+	smds, ds := newTestSMDS(t, euicc)
+	for _, id := range []string{"EVENT-1", "EVENT-2", "EVENT-3"} {
+		smds.RegisterEvent(testEID, sgp22.EventEntry{EventID: id, Address: "smdp.example.com"})
+	}
+	smds.RegisterEvent(testEID, sgp22.EventEntry{EventID: "EVENT-1", Address: "other.example.com"})
+
+	if got, want := eventIDs(smds.Events(testEID)), []string{"EVENT-2", "EVENT-3", "EVENT-1"}; !slices.Equal(got, want) {
+		t.Errorf("Events() = %v, want %v", got, want)
+	}
+	entries, err := ds.discover("EVENT-1")
+	if err != nil {
+		t.Fatalf("discover() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Address != "other.example.com" {
+		t.Errorf("discover(EVENT-1) = %v, want the replaced event", entries)
+	}
+	if !smds.DeleteEvent("EVENT-2") {
+		t.Errorf("DeleteEvent(EVENT-2) = false, want true")
+	}
+	if smds.DeleteEvent("EVENT-2") {
+		t.Errorf("DeleteEvent(EVENT-2) = true after deletion, want false")
+	}
+	smds.DeleteEvent("EVENT-1")
+	smds.DeleteEvent("EVENT-3")
+	entries, err = ds.discover("")
+	if err != nil {
+		t.Fatalf("discover() error = %v", err)
+	}
+	if entries == nil || len(entries) != 0 {
+		t.Errorf("discover() = %v, want an empty list", entries)
+	}
+}
+
+func TestSMDSFail(t *testing.T) {
+	_, euicc := newTestSMDP(t, testOrder())
+	smds, ds := newTestSMDS(t, euicc)
+	smds.Fail(FunctionInitiateAuthentication, StatusUnsupportedCI)
+	if _, err := ds.discover(""); err == nil || err.Error() != StatusUnsupportedCI.Error() {
+		t.Errorf("discover() error = %v, want %v", err, StatusUnsupportedCI)
+	}
+	smds.Fail(FunctionInitiateAuthentication, nil)
+	other, _ := NewCI()
+	smds.Roots = other.Roots()
+	if _, err := ds.discover(""); err == nil {
+		t.Errorf("discover() error = nil, want invalid eUICC certificate")
+	}
+}
+
+func TestSMDSServeHTTP(t *testing.T) {
+	ci, _ := NewCI()
+	smds, _ := NewSMDS(ci)
+	body, _ := json.Marshal(&sgp22.ES9InitiateAuthenticationRequest{Challenge: make([]byte, 16), Address: "smds.example.com"})
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{nethttp.MethodPost, "/gsma/rsp2/es11/initiateAuthentication", nethttp.StatusOK},
+		{nethttp.MethodPost, "/gsma/rsp2/es9plus/initiateAuthentication", nethttp.StatusOK},
+		{nethttp.MethodGet, "/gsma/rsp2/es11/initiateAuthentication", nethttp.StatusMethodNotAllowed},
+		{nethttp.MethodPost, "/gsma/rsp2/es11/getBoundProfilePackage", nethttp.StatusNotFound},
+		{nethttp.MethodPost, "/gsma/rsp2/es12/registerEvent", nethttp.StatusNotFound},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, bytes.NewReader(body))
+		r.Header.Set("X-Admin-Protocol", "gsma/rsp/v2.5.0")
+		w := httptest.NewRecorder()
+		smds.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+		}
+	}
+}
@@ -6,9 +6,33 @@ import (
 	"slices"
 
 	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/bertlv/primitive"
 )
 
+// SegmentedBoundProfilePackage splits a bound profile package into the segments
+// sent to the eUICC with ES10b.LoadBoundProfilePackage.
 func SegmentedBoundProfilePackage(bpp *bertlv.TLV) ([][]byte, error) {
+	segments, err := BoundProfilePackageSegments(bpp)
+	if err != nil {
+		return nil, err
+	}
+	data := make([][]byte, len(segments))
+	for index, segment := range segments {
+		data[index] = segment.Data
+	}
+	return data, nil
+}
+
+// BoundProfilePackageSegment is a segment of a bound profile package,
+// together with the BPP command it belongs to.
+type BoundProfilePackageSegment struct {
+	Command BPPCommandID
+	Data    []byte
+}
+
+// BoundProfilePackageSegments splits a bound profile package like [SegmentedBoundProfilePackage],
+// tagging each segment with its BPP command.
+func BoundProfilePackageSegments(bpp *bertlv.TLV) ([]BoundProfilePackageSegment, error) {
 	if err := ValidBoundProfilePackage(bpp); err != nil {
 		return nil, err
 	}
@@ -36,7 +60,7 @@ func SegmentedBoundProfilePackage(bpp *bertlv.TLV) ([][]byte, error) {
 		}
 		return header, nil
 	}
-	appendSegmentedSequence := func(segments [][]byte, sequence *bertlv.TLV) ([][]byte, error) {
+	appendSegmentedSequence := func(segments []BoundProfilePackageSegment, command BPPCommandID, sequence *bertlv.TLV) ([]BoundProfilePackageSegment, error) {
 		header, err := marshalHeader(sequence)
 		if err != nil {
 			return nil, err
@@ -51,14 +75,14 @@ func SegmentedBoundProfilePackage(bpp *bertlv.TLV) ([][]byte, error) {
 				return nil, fmt.Errorf("marshal segmented TLV: %w", err)
 			}
 			if !headerWritten {
-				segments = append(segments, slices.Concat(header, encoded))
+				segments = append(segments, BoundProfilePackageSegment{command, slices.Concat(header, encoded)})
 				headerWritten = true
 				continue
 			}
-			segments = append(segments, encoded)
+			segments = append(segments, BoundProfilePackageSegment{command, encoded})
 		}
 		if !headerWritten {
-			segments = append(segments, header)
+			segments = append(segments, BoundProfilePackageSegment{command, header})
 		}
 		return segments, nil
 	}
@@ -69,7 +93,7 @@ func SegmentedBoundProfilePackage(bpp *bertlv.TLV) ([][]byte, error) {
 		secondSequenceOf87             = bpp.First(bertlv.Constructed.ContextSpecific(2))
 		sequenceOf86                   = bpp.First(bertlv.Constructed.ContextSpecific(3))
 	)
-	var segments [][]byte
+	var segments []BoundProfilePackageSegment
 	bppHeader, err := marshalHeader(bpp)
 	if err != nil {
 		return nil, err
@@ -79,15 +103,15 @@ func SegmentedBoundProfilePackage(bpp *bertlv.TLV) ([][]byte, error) {
 		return nil, fmt.Errorf("marshal initialise secure channel request: %w", err)
 	}
 	// Tag and length fields of the BoundProfilePackage TLV plus the initialiseSecureChannelRequest TLV
-	segments = append(segments, slices.Concat(
+	segments = append(segments, BoundProfilePackageSegment{BPPCommandIDInitialiseSecureChannel, slices.Concat(
 		// Tag and length fields of the BoundProfilePackage TLV
 		bppHeader,
 		// initialiseSecureChannelRequest TLV
 		initialiseSecureChannel,
-	))
+	)})
 	// Tag and length fields of the firstSequenceOf87 TLV plus the first '87' TLV,
 	// followed by the remaining '87' TLVs.
-	segments, err = appendSegmentedSequence(segments, firstSequenceOf87)
+	segments, err = appendSegmentedSequence(segments, BPPCommandIDConfigureISDP, firstSequenceOf87)
 	if err != nil {
 		return nil, err
 	}
@@ -96,7 +120,7 @@ func SegmentedBoundProfilePackage(bpp *bertlv.TLV) ([][]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	segments = append(segments, header)
+	segments = append(segments, BoundProfilePackageSegment{BPPCommandIDStoreMetadata, header})
 	// Each of the '88' TLVs
 	for _, child := range sequenceOf88.Children {
 		if child == nil {
@@ -106,12 +130,12 @@ func SegmentedBoundProfilePackage(bpp *bertlv.TLV) ([][]byte, error) {
 		if err != nil {
 			return nil, fmt.Errorf("marshal sequenceOf88 child: %w", err)
 		}
-		segments = append(segments, encoded)
+		segments = append(segments, BoundProfilePackageSegment{BPPCommandIDStoreMetadata, encoded})
 	}
 	// Tag and length fields of the secondSequenceOf87 TLV plus the first '87' TLV,
 	// followed by the remaining '87' TLVs.
 	if secondSequenceOf87 != nil {
-		segments, err = appendSegmentedSequence(segments, secondSequenceOf87)
+		segments, err = appendSegmentedSequence(segments, BPPCommandIDReplaceSessionKeys, secondSequenceOf87)
 		if err != nil {
 			return nil, err
 		}
@@ -121,7 +145,7 @@ func SegmentedBoundProfilePackage(bpp *bertlv.TLV) ([][]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	segments = append(segments, header)
+	segments = append(segments, BoundProfilePackageSegment{BPPCommandIDLoadProfileElements, header})
 	// Each of the '86' TLVs
 	for _, child := range sequenceOf86.Children {
 		if child == nil {
@@ -131,7 +155,7 @@ func SegmentedBoundProfilePackage(bpp *bertlv.TLV) ([][]byte, error) {
 		if err != nil {
 			return nil, fmt.Errorf("marshal sequenceOf86 child: %w", err)
 		}
-		segments = append(segments, encoded)
+		segments = append(segments, BoundProfilePackageSegment{BPPCommandIDLoadProfileElements, encoded})
 	}
 	return segments, nil
 }
@@ -164,3 +188,66 @@ func ValidBoundProfilePackage(bpp *bertlv.TLV) error {
 	}
 	return errors.Join(fields...)
 }
+
+// RemoteOperationID identifies the remote operation requested by initialiseSecureChannelRequest.
+type RemoteOperationID int8
+
+const RemoteOperationInstallBoundProfilePackage RemoteOperationID = 1
+
+// InitialiseSecureChannelRequest is the first TLV of a Bound Profile Package.
+type InitialiseSecureChannelRequest struct {
+	RemoteOperationID        RemoteOperationID
+	TransactionID            []byte
+	ControlReferenceTemplate *bertlv.TLV
+	SMDPOtpk                 []byte
+	SMDPSign                 *bertlv.TLV
+}
+
+func (r *InitialiseSecureChannelRequest) UnmarshalBERTLV(tlv *bertlv.TLV) error {
+	if tlv == nil || !tlv.Tag.If(bertlv.ContextSpecific, bertlv.Constructed, 35) {
+		return ErrUnexpectedTag
+	}
+	remoteOperationID := tlv.First(bertlv.ContextSpecific.Primitive(2))
+	transactionID := tlv.First(bertlv.ContextSpecific.Primitive(0))
+	controlReferenceTemplate := tlv.First(bertlv.ContextSpecific.Constructed(6))
+	smdpOtpk := tlv.First(bertlv.Application.Primitive(73))
+	smdpSign := tlv.First(bertlv.Application.Primitive(55))
+	if remoteOperationID == nil || transactionID == nil || controlReferenceTemplate == nil || smdpOtpk == nil || smdpSign == nil {
+		return ErrUnexpectedTag
+	}
+	*r = InitialiseSecureChannelRequest{
+		TransactionID:            transactionID.Value,
+		ControlReferenceTemplate: controlReferenceTemplate,
+		SMDPOtpk:                 smdpOtpk.Value,
+		SMDPSign:                 smdpSign,
+	}
+	return remoteOperationID.UnmarshalValue(primitive.UnmarshalInt(&r.RemoteOperationID))
+}
+
+// BoundProfilePackageMetadata decodes the StoreMetadataRequest carried by the sequenceOf88 of a Bound Profile Package.
+// The '88' TLVs are only MAC protected, so the metadata is readable before it is sent to the eUICC.
+func BoundProfilePackageMetadata(bpp *bertlv.TLV) (*ProfileInfo, error) {
+	if err := ValidBoundProfilePackage(bpp); err != nil {
+		return nil, err
+	}
+	var data []byte
+	for _, child := range bpp.First(bertlv.Constructed.ContextSpecific(1)).Children {
+		if child == nil {
+			continue
+		}
+		if len(child.Value) < 8 {
+			return nil, errors.New("'88' TLV is shorter than its MAC")
+		}
+		// Each '88' TLV carries a segment of the StoreMetadataRequest followed by an 8-byte C-MAC.
+		data = append(data, child.Value[:len(child.Value)-8]...)
+	}
+	var tlv bertlv.TLV
+	if err := tlv.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("decode StoreMetadataRequest: %w", err)
+	}
+	metadata := new(ProfileInfo)
+	if err := metadata.UnmarshalBERTLV(&tlv); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
@@ -92,6 +92,53 @@ func TestSegmentedBoundProfilePackageSplitsSequenceOf87(t *testing.T) {
 	}
 }
 
+func TestBoundProfilePackageSegments(t *testing.T) {
+	bpp := bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(54),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(35)),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(0),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(7), []byte{0x01}),
+		),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(1),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(8), []byte{0x02}),
+		),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(2),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(7), []byte{0x03}),
+		),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(3),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(6), []byte{0x04}),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(6), []byte{0x05}),
+		),
+	)
+
+	segments, err := BoundProfilePackageSegments(bpp)
+	if err != nil {
+		t.Fatalf("BoundProfilePackageSegments() error = %v", err)
+	}
+	want := []BPPCommandID{
+		BPPCommandIDInitialiseSecureChannel,
+		BPPCommandIDConfigureISDP,
+		BPPCommandIDStoreMetadata,
+		BPPCommandIDStoreMetadata,
+		BPPCommandIDReplaceSessionKeys,
+		BPPCommandIDLoadProfileElements,
+		BPPCommandIDLoadProfileElements,
+		BPPCommandIDLoadProfileElements,
+	}
+	if len(segments) != len(want) {
+		t.Fatalf("segment count = %d, want %d", len(segments), len(want))
+	}
+	for index, segment := range segments {
+		if segment.Command != want[index] {
+			t.Errorf("segment %d command = %v, want %v", index, segment.Command, want[index])
+		}
+	}
+}
+
 func loadBoundProfilePackage(name string) (bpp *bertlv.TLV, err error) {
 	fp, err := os.Open(filepath.Join("fixtures", name))
 	if err != nil {
@@ -134,3 +181,58 @@ func loadSegmentedBoundProfilePackage(name string) (sbpp [][]byte, err error) {
 	}
 	return sbpp, nil
 }
+
+func TestBoundProfilePackageHeader(t *testing.T) {
+	type Fixture struct {
+		BPP           string
+		TransactionID string
+		ICCID         string
+		ProfileName   string
+	}
+	fixtures := []Fixture{
+		{"bpp@1.txt", "9273A06E0F0089CF231FFCA68093AEF2", "89000000000000002000", "Leopard"},
+		{"bpp@2.txt", "00000000009C1FCA", "89852245280007179998", "RedteaGO"},
+		{"bpp@4.txt", "ADED09406FA6850CDFF5372438BFA853", "89997779000097261598", "Tele2_TK_eSIM_E01_2"},
+	}
+	for _, fixture := range fixtures {
+		t.Run(fixture.BPP, func(t *testing.T) {
+			bpp, err := loadBoundProfilePackage(fixture.BPP)
+			if err != nil {
+				t.Fatalf("loadBoundProfilePackage(%q) error = %v", fixture.BPP, err)
+			}
+			var request InitialiseSecureChannelRequest
+			if err := request.UnmarshalBERTLV(bpp.First(bertlv.ContextSpecific.Constructed(35))); err != nil {
+				t.Fatalf("InitialiseSecureChannelRequest.UnmarshalBERTLV() error = %v", err)
+			}
+			if request.RemoteOperationID != RemoteOperationInstallBoundProfilePackage {
+				t.Errorf("RemoteOperationID = %d, want %d", request.RemoteOperationID, RemoteOperationInstallBoundProfilePackage)
+			}
+			if transactionID := hex.EncodeToString(request.TransactionID); !strings.EqualFold(transactionID, fixture.TransactionID) {
+				t.Errorf("TransactionID = %s, want %s", transactionID, fixture.TransactionID)
+			}
+			metadata, err := BoundProfilePackageMetadata(bpp)
+			if err != nil {
+				t.Fatalf("BoundProfilePackageMetadata() error = %v", err)
+			}
+			if metadata.ICCID.String() != fixture.ICCID {
+				t.Errorf("ICCID = %s, want %s", metadata.ICCID, fixture.ICCID)
+			}
+			if metadata.ProfileName != fixture.ProfileName {
+				t.Errorf("ProfileName = %q, want %q", metadata.ProfileName, fixture.ProfileName)
+			}
+		})
+	}
+}
+
+func TestBoundProfilePackageMetadataRejectsShortSegment(t *testing.T) {
+	bpp := bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(54),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(35)),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0)),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(1), bertlv.NewValue(bertlv.ContextSpecific.Primitive(8), []byte{0x01})),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(3)),
+	)
+	if _, err := BoundProfilePackageMetadata(bpp); err == nil {
+		t.Error("BoundProfilePackageMetadata() error = nil, want error")
+	}
+}
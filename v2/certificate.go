@@ -0,0 +1,246 @@
+package sgp22
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/damonto/euicc-go/bertlv"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrEIDNotPermitted  = errors.New("EID not permitted by the EUM certificate")
+)
+
+var oidNameConstraints = asn1.ObjectIdentifier{2, 5, 29, 30}
+
+// ParseCertificate parses a DER encoded X.509 certificate, e.g. CERT.DPauth.ECDSA.
+func ParseCertificate(tlv *bertlv.TLV) (*x509.Certificate, error) {
+	if tlv == nil || !tlv.Tag.If(bertlv.Universal, bertlv.Constructed, 16) {
+		return nil, ErrUnexpectedTag
+	}
+	der, err := tlv.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// VerifyCertificate verifies the certificate chains up to one of the CI roots.
+// A zero currentTime uses the current time.
+func VerifyCertificate(certificate *x509.Certificate, roots *x509.CertPool, currentTime time.Time) error {
+	_, err := certificate.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: currentTime,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+// VerifySignature verifies an ECDSA signature computed over the concatenated encodings of the signed TLVs.
+// The signature value is the plain r || s format of BSI TR-03111, tagged [APPLICATION 55].
+func VerifySignature(certificate *x509.Certificate, signature *bertlv.TLV, signed ...*bertlv.TLV) error {
+	if signature == nil || !signature.Tag.If(bertlv.Application, bertlv.Primitive, 55) {
+		return ErrUnexpectedTag
+	}
+	publicKey, ok := certificate.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("unsupported public key type %T", certificate.PublicKey)
+	}
+	if len(signature.Value) == 0 || len(signature.Value)%2 != 0 {
+		return ErrInvalidSignature
+	}
+	digest := sha256.New()
+	for _, tlv := range signed {
+		if tlv == nil {
+			return errors.New("signed data is required")
+		}
+		data, err := tlv.Bytes()
+		if err != nil {
+			return err
+		}
+		digest.Write(data)
+	}
+	size := len(signature.Value) / 2
+	r := new(big.Int).SetBytes(signature.Value[:size])
+	s := new(big.Int).SetBytes(signature.Value[size:])
+	if !ecdsa.Verify(publicKey, digest.Sum(nil), r, s) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// EUICCCertificates are the certificates returned by the eUICC in AuthenticateServerResponse.
+type EUICCCertificates struct {
+	// EUICC is CERT.EUICC.ECDSA, its subject serialNumber is the EID.
+	EUICC *x509.Certificate
+	// EUM is CERT.EUM.ECDSA, which issued CERT.EUICC.ECDSA.
+	EUM *x509.Certificate
+}
+
+// ParseEUICCCertificates parses CERT.EUICC.ECDSA and CERT.EUM.ECDSA from an AuthenticateServerResponse.
+//
+// See https://aka.pw/sgp22/v2.5#page=195 (Section 5.7.13, ES10b.AuthenticateServer)
+func ParseEUICCCertificates(tlv *bertlv.TLV) (*EUICCCertificates, error) {
+	if tlv == nil || !tlv.Tag.If(bertlv.ContextSpecific, bertlv.Constructed, 56) {
+		return nil, ErrUnexpectedTag
+	}
+	ok := tlv.First(bertlv.ContextSpecific.Constructed(0))
+	if ok == nil {
+		return nil, errors.New("missing authenticateResponseOk")
+	}
+	// authenticateResponseOk holds euiccSigned1, euiccSignature1, euiccCertificate and eumCertificate,
+	// of which euiccSigned1 and both certificates are SEQUENCEs.
+	var sequences []*bertlv.TLV
+	for _, child := range ok.Children {
+		if child != nil && child.Tag.If(bertlv.Universal, bertlv.Constructed, 16) {
+			sequences = append(sequences, child)
+		}
+	}
+	if len(sequences) != 3 {
+		return nil, errors.New("missing eUICC or EUM certificate")
+	}
+	var certificates EUICCCertificates
+	var err error
+	if certificates.EUICC, err = ParseCertificate(sequences[1]); err != nil {
+		return nil, fmt.Errorf("parse eUICC certificate: %w", err)
+	}
+	if certificates.EUM, err = ParseCertificate(sequences[2]); err != nil {
+		return nil, fmt.Errorf("parse EUM certificate: %w", err)
+	}
+	return &certificates, nil
+}
+
+// EID returns the EID bound to CERT.EUICC.ECDSA.
+func (c *EUICCCertificates) EID() string {
+	return c.EUICC.Subject.SerialNumber
+}
+
+// PermittedEIDs returns the EID constraints of the EUM certificate.
+func (c *EUICCCertificates) PermittedEIDs() ([]EIDConstraint, error) {
+	return PermittedEIDs(c.EUM)
+}
+
+// VerifyEID checks that eid is the EID of CERT.EUICC.ECDSA, that the EUM certificate
+// issued it and that it falls inside the EUM name constraints.
+// eid is the value returned by ES10c.GetEID.
+func (c *EUICCCertificates) VerifyEID(eid EID) error {
+	value := eid.String()
+	if !strings.EqualFold(c.EID(), value) {
+		return fmt.Errorf("eUICC certificate is bound to EID %s, want %s", c.EID(), value)
+	}
+	if err := c.EUICC.CheckSignatureFrom(c.EUM); err != nil {
+		return fmt.Errorf("eUICC certificate is not issued by the EUM: %w", err)
+	}
+	return c.verifyConstraints()
+}
+
+// Verify checks that the EUM certificate issued CERT.EUICC.ECDSA and chains up to one of the CI roots,
+// and that the eUICC certificate falls inside the EUM name constraints. A zero currentTime uses the current time.
+func (c *EUICCCertificates) Verify(roots *x509.CertPool, currentTime time.Time) error {
+	if roots == nil {
+		return errors.New("no CI roots")
+	}
+	if err := c.EUICC.CheckSignatureFrom(c.EUM); err != nil {
+		return fmt.Errorf("eUICC certificate is not issued by the EUM: %w", err)
+	}
+	if currentTime.IsZero() {
+		currentTime = time.Now()
+	}
+	if currentTime.Before(c.EUICC.NotBefore) || currentTime.After(c.EUICC.NotAfter) {
+		return errors.New("eUICC certificate is expired or not yet valid")
+	}
+	// The name constraints are checked by verifyConstraints, as crypto/x509 rejects
+	// the directoryName constraints of the EUM certificate as an unhandled critical extension.
+	eum := *c.EUM
+	eum.UnhandledCriticalExtensions = slices.DeleteFunc(slices.Clone(eum.UnhandledCriticalExtensions), func(id asn1.ObjectIdentifier) bool {
+		return id.Equal(oidNameConstraints)
+	})
+	if err := VerifyCertificate(&eum, roots, currentTime); err != nil {
+		return fmt.Errorf("verify EUM certificate: %w", err)
+	}
+	return c.verifyConstraints()
+}
+
+func (c *EUICCCertificates) verifyConstraints() error {
+	constraints, err := c.PermittedEIDs()
+	if err != nil {
+		return err
+	}
+	if len(constraints) == 0 {
+		return nil
+	}
+	if !slices.ContainsFunc(constraints, func(constraint EIDConstraint) bool {
+		return constraint.Permits(&c.EUICC.Subject)
+	}) {
+		return fmt.Errorf("%w: %s", ErrEIDNotPermitted, c.EID())
+	}
+	return nil
+}
+
+// EIDConstraint is a permitted subtree of the EUM certificate name constraints.
+// It restricts the organization and the EID prefix of the eUICC certificates issued by the EUM.
+type EIDConstraint struct {
+	Organization string
+	EIDPrefix    string
+}
+
+// Permits reports whether subject, the subject of an eUICC certificate, satisfies the constraint.
+func (c EIDConstraint) Permits(subject *pkix.Name) bool {
+	if c.Organization != "" && !slices.Contains(subject.Organization, c.Organization) {
+		return false
+	}
+	return strings.HasPrefix(strings.ToUpper(subject.SerialNumber), strings.ToUpper(c.EIDPrefix))
+}
+
+// PermittedEIDs parses the directoryName permitted subtrees of the name constraints extension.
+// crypto/x509 does not decode directoryName constraints, so the extension is decoded here.
+func PermittedEIDs(certificate *x509.Certificate) ([]EIDConstraint, error) {
+	index := slices.IndexFunc(certificate.Extensions, func(extension pkix.Extension) bool {
+		return extension.Id.Equal(oidNameConstraints)
+	})
+	if index < 0 {
+		return nil, nil
+	}
+	var constraints bertlv.TLV
+	if err := constraints.UnmarshalBinary(certificate.Extensions[index].Value); err != nil {
+		return nil, fmt.Errorf("decode name constraints: %w", err)
+	}
+	permitted := constraints.First(bertlv.ContextSpecific.Constructed(0))
+	if permitted == nil {
+		return nil, nil
+	}
+	var eids []EIDConstraint
+	for _, subtree := range permitted.Children {
+		directoryName := subtree.First(bertlv.ContextSpecific.Constructed(4))
+		if directoryName == nil || len(directoryName.Children) == 0 {
+			continue
+		}
+		der, err := directoryName.Children[0].Bytes()
+		if err != nil {
+			return nil, err
+		}
+		var sequence pkix.RDNSequence
+		if _, err := asn1.Unmarshal(der, &sequence); err != nil {
+			return nil, fmt.Errorf("decode directoryName: %w", err)
+		}
+		var name pkix.Name
+		name.FillFromRDNSequence(&sequence)
+		var constraint EIDConstraint
+		if len(name.Organization) > 0 {
+			constraint.Organization = name.Organization[0]
+		}
+		constraint.EIDPrefix = name.SerialNumber
+		eids = append(eids, constraint)
+	}
+	return eids, nil
+}
@@ -0,0 +1,242 @@
+package sgp22
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/damonto/euicc-go/bertlv"
+)
+
+func newTestCertificate(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, *bertlv.TLV) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	var tlv bertlv.TLV
+	if err := tlv.UnmarshalBinary(der); err != nil {
+		t.Fatalf("TLV.UnmarshalBinary() error = %v", err)
+	}
+	return certificate, key, &tlv
+}
+
+func newTestCI(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	certificate, key, _ := newTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CI"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	return certificate, key
+}
+
+func signTestTLV(t *testing.T, key *ecdsa.PrivateKey, signed ...*bertlv.TLV) *bertlv.TLV {
+	t.Helper()
+	digest := sha256.New()
+	for _, tlv := range signed {
+		data, err := tlv.Bytes()
+		if err != nil {
+			t.Fatalf("TLV.Bytes() error = %v", err)
+		}
+		digest.Write(data)
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return bertlv.NewValue(bertlv.Application.Primitive(55), signature)
+}
+
+func TestVerifySignatureAndCertificate(t *testing.T) {
+	ci, ciKey := newTestCI(t)
+	certificate, key, tlv := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test SM-DP+"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, ci, ciKey)
+	signed := bertlv.NewChildren(
+		bertlv.Universal.Constructed(16),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{0x01}),
+	)
+	signature := signTestTLV(t, key, signed)
+
+	parsed, err := ParseCertificate(tlv)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	if !parsed.Equal(certificate) {
+		t.Error("ParseCertificate() returned a different certificate")
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ci)
+	if err := VerifyCertificate(parsed, roots, time.Time{}); err != nil {
+		t.Errorf("VerifyCertificate() error = %v", err)
+	}
+	if err := VerifyCertificate(parsed, x509.NewCertPool(), time.Time{}); err == nil {
+		t.Error("VerifyCertificate() error = nil for unknown CI")
+	}
+	if err := VerifySignature(parsed, signature, signed); err != nil {
+		t.Errorf("VerifySignature() error = %v", err)
+	}
+	signed.Children[0].Value = []byte{0x02}
+	if err := VerifySignature(parsed, signature, signed); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifySignature() error = %v, want invalid signature", err)
+	}
+}
+
+func TestParseCertificateRejectsUnexpectedTag(t *testing.T) {
+	if _, err := ParseCertificate(bertlv.NewValue(bertlv.Universal.Primitive(4), nil)); !errors.Is(err, ErrUnexpectedTag) {
+		t.Errorf("ParseCertificate() error = %v, want unexpected tag", err)
+	}
+	if _, err := ParseCertificate(nil); !errors.Is(err, ErrUnexpectedTag) {
+		t.Errorf("ParseCertificate(nil) error = %v, want unexpected tag", err)
+	}
+}
+
+// testEUICC is an eUICC certificate issued by a test EUM under a test CI, with the key of the eUICC.
+type testEUICC struct {
+	EUICCCertificates
+	CI               *x509.Certificate
+	Key              *ecdsa.PrivateKey
+	EUICCTLV, EUMTLV *bertlv.TLV
+}
+
+func newTestEUICCCertificates(t *testing.T, eid string) (*EUICCCertificates, *bertlv.TLV) {
+	t.Helper()
+	euicc := newTestEUICC(t, eid)
+	response := bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(56),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(0),
+			bertlv.NewChildren(bertlv.Universal.Constructed(16)),
+			bertlv.NewValue(bertlv.Application.Primitive(55), make([]byte, 64)),
+			euicc.EUICCTLV,
+			euicc.EUMTLV,
+		),
+	)
+	return &euicc.EUICCCertificates, response
+}
+
+func newTestEUICC(t *testing.T, eid string) *testEUICC {
+	t.Helper()
+	ci, ciKey := newTestCI(t)
+	name, err := asn1.Marshal(pkix.Name{Organization: []string{"Test EUM"}, SerialNumber: "89049032"}.ToRDNSequence())
+	if err != nil {
+		t.Fatalf("asn1.Marshal() error = %v", err)
+	}
+	var directoryName bertlv.TLV
+	if err := directoryName.UnmarshalBinary(name); err != nil {
+		t.Fatalf("TLV.UnmarshalBinary() error = %v", err)
+	}
+	nameConstraints, err := bertlv.NewChildren(
+		bertlv.Universal.Constructed(16),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(0),
+			bertlv.NewChildren(
+				bertlv.Universal.Constructed(16),
+				bertlv.NewChildren(bertlv.ContextSpecific.Constructed(4), &directoryName),
+			),
+		),
+	).Bytes()
+	if err != nil {
+		t.Fatalf("TLV.Bytes() error = %v", err)
+	}
+	eum, eumKey, eumTLV := newTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Test EUM", Organization: []string{"Test EUM"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		ExtraExtensions:       []pkix.Extension{{Id: oidNameConstraints, Critical: true, Value: nameConstraints}},
+	}, ci, ciKey)
+	euicc, euiccKey, euiccTLV := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "Test eUICC", Organization: []string{"Test EUM"}, SerialNumber: eid},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, eum, eumKey)
+	return &testEUICC{
+		EUICCCertificates: EUICCCertificates{EUICC: euicc, EUM: eum},
+		CI:                ci,
+		Key:               euiccKey,
+		EUICCTLV:          euiccTLV,
+		EUMTLV:            eumTLV,
+	}
+}
+
+func TestParseEUICCCertificates(t *testing.T) {
+	want, response := newTestEUICCCertificates(t, "89049032123451234512345678901235")
+	request := &ES9AuthenticateClientRequest{Response: response}
+	certificates, err := request.Certificates()
+	if err != nil {
+		t.Fatalf("Certificates() error = %v", err)
+	}
+	if !certificates.EUICC.Equal(want.EUICC) || !certificates.EUM.Equal(want.EUM) {
+		t.Error("Certificates() returned different certificates")
+	}
+	if eid := certificates.EID(); eid != "89049032123451234512345678901235" {
+		t.Errorf("EID() = %q, want %q", eid, "89049032123451234512345678901235")
+	}
+	constraints, err := certificates.PermittedEIDs()
+	if err != nil {
+		t.Fatalf("PermittedEIDs() error = %v", err)
+	}
+	if len(constraints) != 1 || constraints[0] != (EIDConstraint{Organization: "Test EUM", EIDPrefix: "89049032"}) {
+		t.Errorf("PermittedEIDs() = %+v", constraints)
+	}
+
+	response.Children[0].Children = response.Children[0].Children[:3]
+	if _, err := ParseEUICCCertificates(response); err == nil {
+		t.Error("ParseEUICCCertificates() error = nil for missing EUM certificate")
+	}
+}
+
+func TestEUICCCertificatesVerifyEID(t *testing.T) {
+	eid := []byte{0x89, 0x04, 0x90, 0x32, 0x12, 0x34, 0x51, 0x23, 0x45, 0x12, 0x34, 0x56, 0x78, 0x90, 0x12, 0x35}
+	certificates, _ := newTestEUICCCertificates(t, "89049032123451234512345678901235")
+	if err := certificates.VerifyEID(eid); err != nil {
+		t.Errorf("VerifyEID() error = %v", err)
+	}
+	other := []byte{0x89, 0x04, 0x90, 0x32, 0x12, 0x34, 0x51, 0x23, 0x45, 0x12, 0x34, 0x56, 0x78, 0x90, 0x12, 0x36}
+	if err := certificates.VerifyEID(other); err == nil {
+		t.Error("VerifyEID() error = nil for a different EID")
+	}
+
+	outside := []byte{0x89, 0x03, 0x30, 0x23, 0x12, 0x34, 0x51, 0x23, 0x45, 0x12, 0x34, 0x56, 0x78, 0x90, 0x12, 0x35}
+	certificates, _ = newTestEUICCCertificates(t, "89033023123451234512345678901235")
+	if err := certificates.VerifyEID(outside); !errors.Is(err, ErrEIDNotPermitted) {
+		t.Errorf("VerifyEID() error = %v, want EID not permitted", err)
+	}
+}
@@ -12,6 +12,8 @@ var (
 	ErrICCIDNotFound        = errors.New("iccid not found")
 	ErrCatBusy              = errors.New("cat busy")
 	ErrUndefined            = errors.New("undefined error")
+	ErrPPRNotAllowed        = errors.New("profile policy rules not allowed")
+	ErrInvalidCheckDigit    = errors.New("invalid check digit")
 )
 
 type BPPCommandID int8
@@ -136,20 +136,11 @@ func (r *LoadBoundProfilePackageResponse) Valid() error {
 	if result == nil {
 		return nil
 	}
-	var commandID BPPCommandID
-	if err := result.First(bertlv.ContextSpecific.Primitive(0)).
-		UnmarshalValue(primitive.UnmarshalInt(&commandID)); err != nil {
-		return err
-	}
-	var reason BPPErrorReason
-	if err := result.First(bertlv.ContextSpecific.Primitive(1)).
-		UnmarshalValue(primitive.UnmarshalInt(&reason)); err != nil {
-		return err
-	}
-	return &LoadBoundProfilePackageError{
-		BPPCommandID: commandID,
-		ErrorReason:  reason,
+	err, decodeErr := unmarshalErrorResult(result)
+	if decodeErr != nil {
+		return decodeErr
 	}
+	return err
 }
 
 // endregion
@@ -219,20 +210,36 @@ func (r *GetEuiccInfoRequest) CardResponse() *GetEuiccInfoResponse {
 	return &GetEuiccInfoResponse{Version: r.Version}
 }
 
+// GetEuiccInfoResponse keeps the raw response, which is forwarded to the SM-DP+ as-is,
+// next to the decoded EUICCInfo1 or EUICCInfo2. A response of the other version than
+// the one requested is an error.
 type GetEuiccInfoResponse struct {
 	Version  EuiccInfoVersion
 	Response *bertlv.TLV
+	Info1    *EUICCInfo1
+	Info2    *EUICCInfo2
 }
 
 func (r *GetEuiccInfoResponse) UnmarshalBERTLV(tlv *bertlv.TLV) error {
-	if !(tlv.Tag.ContextSpecific() && tlv.Tag.Constructed()) {
+	switch {
+	case r.Version == EuiccInfoVersion1 && !tlv.Tag.If(bertlv.ContextSpecific, bertlv.Constructed, 32),
+		r.Version == EuiccInfoVersion2 && !tlv.Tag.If(bertlv.ContextSpecific, bertlv.Constructed, 34):
+		return fmt.Errorf("EUICCInfo%d requested, got tag %X: %w", r.Version, []byte(tlv.Tag), ErrUnexpectedTag)
+	case tlv.Tag.If(bertlv.ContextSpecific, bertlv.Constructed, 32):
+		r.Info1 = new(EUICCInfo1)
+		if err := r.Info1.UnmarshalBERTLV(tlv); err != nil {
+			return err
+		}
+	case tlv.Tag.If(bertlv.ContextSpecific, bertlv.Constructed, 34):
+		r.Info2 = new(EUICCInfo2)
+		if err := r.Info2.UnmarshalBERTLV(tlv); err != nil {
+			return err
+		}
+	default:
 		return ErrUnexpectedTag
 	}
-	if value := tlv.Tag.Value(); value == 32 || value == 34 {
-		r.Response = tlv
-		return nil
-	}
-	return ErrUnexpectedTag
+	r.Response = tlv
+	return nil
 }
 
 func (r *GetEuiccInfoResponse) Valid() error {
@@ -510,3 +517,134 @@ func (r *CancelSessionRequest) MarshalBERTLV() (*bertlv.TLV, error) {
 }
 
 // endregion
+
+// region Section 5.7.22, ES10b.GetRAT
+
+// GetRATRequest is used to get the Rules Authorisation Table of the eUICC.
+//
+// See https://aka.pw/sgp22/v2.5#page=210 (Section 5.7.22, ES10b.GetRAT)
+type GetRATRequest struct{}
+
+func (r *GetRATRequest) MarshalBERTLV() (*bertlv.TLV, error) {
+	return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(67)), nil
+}
+
+func (r *GetRATRequest) CardResponse() *GetRATResponse {
+	return new(GetRATResponse)
+}
+
+type GetRATResponse struct {
+	RulesAuthorisationTable RulesAuthorisationTable
+}
+
+func (r *GetRATResponse) UnmarshalBERTLV(tlv *bertlv.TLV) error {
+	if !tlv.Tag.If(bertlv.ContextSpecific, bertlv.Constructed, 67) {
+		return ErrUnexpectedTag
+	}
+	r.RulesAuthorisationTable = nil
+	if rat := tlv.First(bertlv.ContextSpecific.Constructed(0)); rat != nil {
+		return r.RulesAuthorisationTable.UnmarshalBERTLV(rat)
+	}
+	return nil
+}
+
+func (r *GetRATResponse) Valid() error {
+	return nil
+}
+
+// RulesAuthorisationTable lists which operators may set which Profile Policy Rules.
+type RulesAuthorisationTable []*ProfilePolicyAuthorisationRule
+
+func (rat *RulesAuthorisationTable) UnmarshalBERTLV(tlv *bertlv.TLV) error {
+	rules := make(RulesAuthorisationTable, 0, len(tlv.Children))
+	for _, child := range tlv.Children {
+		rule := new(ProfilePolicyAuthorisationRule)
+		if err := rule.UnmarshalBERTLV(child); err != nil {
+			return err
+		}
+		rules = append(rules, rule)
+	}
+	*rat = rules
+	return nil
+}
+
+// Allows reports whether the profile owner is allowed to set the given Profile Policy Rules,
+// and whether the end user has to consent to them.
+// pprUpdateControl is not subject to the RAT and is ignored.
+func (rat RulesAuthorisationTable) Allows(rules ProfilePolicyRules, owner *OperatorId) (consentRequired bool, err error) {
+	pprs := []struct {
+		name string
+		set  func(ProfilePolicyRules) bool
+	}{
+		{"ppr1", func(r ProfilePolicyRules) bool { return r.DisablingNotAllowed }},
+		{"ppr2", func(r ProfilePolicyRules) bool { return r.DeletionNotAllowed }},
+	}
+	for _, ppr := range pprs {
+		if !ppr.set(rules) {
+			continue
+		}
+		rule := rat.match(ppr.set, owner)
+		if rule == nil {
+			return false, fmt.Errorf("%w: %s", ErrPPRNotAllowed, ppr.name)
+		}
+		consentRequired = consentRequired || rule.ConsentRequired
+	}
+	return consentRequired, nil
+}
+
+// match returns the first rule that authorises the PPR for the owner.
+func (rat RulesAuthorisationTable) match(set func(ProfilePolicyRules) bool, owner *OperatorId) *ProfilePolicyAuthorisationRule {
+	if owner == nil || len(owner.PLMN) == 0 {
+		return nil
+	}
+	for _, rule := range rat {
+		if !set(rule.ProfilePolicyRules) {
+			continue
+		}
+		for _, operator := range rule.AllowedOperators {
+			if owner.Match(operator) {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+type ProfilePolicyAuthorisationRule struct {
+	ProfilePolicyRules ProfilePolicyRules
+	AllowedOperators   []*OperatorId
+	ConsentRequired    bool
+}
+
+func (r *ProfilePolicyAuthorisationRule) UnmarshalBERTLV(tlv *bertlv.TLV) error {
+	if tlv == nil || !tlv.Tag.If(bertlv.Universal, bertlv.Constructed, 16) {
+		return ErrUnexpectedTag
+	}
+	rules := tlv.First(bertlv.ContextSpecific.Primitive(0))
+	operators := tlv.First(bertlv.ContextSpecific.Constructed(1))
+	flags := tlv.First(bertlv.ContextSpecific.Primitive(2))
+	if rules == nil || operators == nil || flags == nil {
+		return ErrUnexpectedTag
+	}
+	*r = ProfilePolicyAuthorisationRule{
+		AllowedOperators: make([]*OperatorId, 0, len(operators.Children)),
+	}
+	if err := rules.UnmarshalValue(&r.ProfilePolicyRules); err != nil {
+		return fmt.Errorf("decode pprIds: %w", err)
+	}
+	for _, child := range operators.Children {
+		operator := new(OperatorId)
+		if err := operator.UnmarshalBERTLV(child); err != nil {
+			return err
+		}
+		r.AllowedOperators = append(r.AllowedOperators, operator)
+	}
+	var pprFlags []bool
+	if err := flags.UnmarshalValue(primitive.UnmarshalBitString(&pprFlags)); err != nil {
+		return fmt.Errorf("decode pprFlags: %w", err)
+	}
+	r.ConsentRequired = len(pprFlags) > 0 && pprFlags[0]
+	return nil
+}
+
+// endregion
@@ -2,8 +2,11 @@ package sgp22
 
 import (
 	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/damonto/euicc-go/bertlv"
 )
@@ -273,3 +276,228 @@ func TestAuthenticateServerRequestRejectsInvalidIMEI(t *testing.T) {
 		t.Error("MarshalBERTLV() error = nil for invalid IMEI")
 	}
 }
+
+func TestGetEuiccInfoResponseDecodesInfo(t *testing.T) {
+	response := &GetEuiccInfoResponse{Version: EuiccInfoVersion1}
+	tlv := bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(32),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), []byte{0x02, 0x02, 0x00}),
+	)
+
+	if err := response.UnmarshalBERTLV(tlv); err != nil {
+		t.Fatalf("UnmarshalBERTLV() error = %v", err)
+	}
+	if response.Response != tlv || response.Info1 == nil || response.Info2 != nil {
+		t.Fatalf("response = %+v", response)
+	}
+	if got, want := response.Info1.SVN.String(), "2.2.0"; got != want {
+		t.Errorf("Info1.SVN = %q, want %q", got, want)
+	}
+}
+
+func TestGetEuiccInfoResponseRejectsOtherVersion(t *testing.T) {
+	info1 := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(32))
+	info2 := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(34))
+	for version, tlv := range map[EuiccInfoVersion]*bertlv.TLV{EuiccInfoVersion1: info2, EuiccInfoVersion2: info1} {
+		response := (&GetEuiccInfoRequest{Version: version}).CardResponse()
+		if err := response.UnmarshalBERTLV(tlv); !errors.Is(err, ErrUnexpectedTag) {
+			t.Errorf("version %d UnmarshalBERTLV(%X) error = %v, want %v", version, []byte(tlv.Tag), err, ErrUnexpectedTag)
+		}
+	}
+}
+
+func TestGetRATRequestUsesTagBF43(t *testing.T) {
+	request, err := new(GetRATRequest).MarshalBERTLV()
+	if err != nil {
+		t.Fatalf("MarshalBERTLV() error = %v", err)
+	}
+	data, err := request.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+	if want := []byte{0xBF, 0x43, 0x00}; !bytes.Equal(data, want) {
+		t.Errorf("MarshalBinary() = % X, want % X", data, want)
+	}
+}
+
+func TestGetRATResponseUnmarshal(t *testing.T) {
+	var tlv bertlv.TLV
+	// Two rules: PPR1 for 310/410 (GID1 0x01) with consent, PPR2 for any operator.
+	data := []byte{
+		0xBF, 0x43, 0x2B, 0xA0, 0x29,
+		0x30, 0x15,
+		0x80, 0x02, 0x06, 0x40,
+		0xA1, 0x0B, 0x30, 0x09, 0x80, 0x03, 0x13, 0x00, 0x14, 0x81, 0x02, 0x01, 0x00,
+		0x82, 0x02, 0x07, 0x80,
+		0x30, 0x10,
+		0x80, 0x02, 0x05, 0x20,
+		0xA1, 0x07, 0x30, 0x05, 0x80, 0x03, 0xEE, 0xEE, 0xEE,
+		0x82, 0x01, 0x00,
+	}
+	if err := tlv.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+	response := new(GetRATResponse)
+
+	if err := response.UnmarshalBERTLV(&tlv); err != nil {
+		t.Fatalf("UnmarshalBERTLV() error = %v", err)
+	}
+	rat := response.RulesAuthorisationTable
+	if len(rat) != 2 {
+		t.Fatalf("rule count = %d, want 2", len(rat))
+	}
+	if want := (ProfilePolicyRules{DisablingNotAllowed: true}); rat[0].ProfilePolicyRules != want || !rat[0].ConsentRequired {
+		t.Errorf("rule[0] = %+v", rat[0])
+	}
+	if len(rat[0].AllowedOperators) != 1 {
+		t.Fatalf("rule[0] operator count = %d, want 1", len(rat[0].AllowedOperators))
+	}
+	operator := rat[0].AllowedOperators[0]
+	if operator.MCC() != "310" || operator.MNC() != "410" || !bytes.Equal(operator.GID1, []byte{0x01, 0x00}) || operator.GID2 != nil {
+		t.Errorf("rule[0] operator = %s/%s %X %X", operator.MCC(), operator.MNC(), operator.GID1, operator.GID2)
+	}
+	if want := (ProfilePolicyRules{DeletionNotAllowed: true}); rat[1].ProfilePolicyRules != want || rat[1].ConsentRequired {
+		t.Errorf("rule[1] = %+v", rat[1])
+	}
+}
+
+func TestGetRATResponseRejectsIncompleteRule(t *testing.T) {
+	tlv := bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(67),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(0),
+			bertlv.NewChildren(
+				bertlv.Universal.Constructed(16),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{0x06, 0x40}),
+			),
+		),
+	)
+
+	if err := new(GetRATResponse).UnmarshalBERTLV(tlv); !errors.Is(err, ErrUnexpectedTag) {
+		t.Errorf("UnmarshalBERTLV() error = %v, want unexpected tag", err)
+	}
+}
+
+func TestRulesAuthorisationTableAllows(t *testing.T) {
+	owner := &OperatorId{PLMN: []byte{0x13, 0x00, 0x14}, GID1: []byte{0x01}}
+	rat := RulesAuthorisationTable{
+		{
+			ProfilePolicyRules: ProfilePolicyRules{DisablingNotAllowed: true},
+			AllowedOperators:   []*OperatorId{{PLMN: []byte{0x13, 0xE0, 0xEE}, GID1: []byte{0x01}}},
+			ConsentRequired:    true,
+		},
+		{
+			ProfilePolicyRules: ProfilePolicyRules{DeletionNotAllowed: true},
+			AllowedOperators:   []*OperatorId{{PLMN: []byte{0x21, 0xF3, 0x54}}},
+		},
+	}
+
+	consentRequired, err := rat.Allows(ProfilePolicyRules{UpdateControl: true, DisablingNotAllowed: true}, owner)
+	if err != nil {
+		t.Fatalf("Allows(ppr1) error = %v", err)
+	}
+	if !consentRequired {
+		t.Error("Allows(ppr1) consentRequired = false, want true")
+	}
+	if _, err := rat.Allows(ProfilePolicyRules{DeletionNotAllowed: true}, owner); !errors.Is(err, ErrPPRNotAllowed) {
+		t.Errorf("Allows(ppr2) error = %v, want PPR not allowed", err)
+	}
+	if _, err := rat.Allows(ProfilePolicyRules{DisablingNotAllowed: true}, &OperatorId{PLMN: []byte{0x13, 0x00, 0x14}, GID1: []byte{0x02}}); !errors.Is(err, ErrPPRNotAllowed) {
+		t.Errorf("Allows(ppr1) with other GID1 error = %v, want PPR not allowed", err)
+	}
+	if _, err := rat.Allows(ProfilePolicyRules{DisablingNotAllowed: true}, nil); !errors.Is(err, ErrPPRNotAllowed) {
+		t.Errorf("Allows(ppr1) without owner error = %v, want PPR not allowed", err)
+	}
+	if consentRequired, err := rat.Allows(ProfilePolicyRules{UpdateControl: true}, nil); err != nil || consentRequired {
+		t.Errorf("Allows(pprUpdateControl) = %t, %v, want false, nil", consentRequired, err)
+	}
+}
+
+func TestPendingNotificationVerify(t *testing.T) {
+	euicc := newTestEUICC(t, "89049032123451234512345678901235")
+	roots := x509.NewCertPool()
+	roots.AddCert(euicc.CI)
+
+	metadata := notificationMetadataTLV()
+	other := bertlv.NewChildren(
+		bertlv.Universal.Constructed(16),
+		metadata,
+		signTestTLV(t, euicc.Key, metadata),
+		euicc.EUICCTLV,
+		euicc.EUMTLV,
+	)
+	notification := new(PendingNotification)
+	if err := notification.UnmarshalBERTLV(other); err != nil {
+		t.Fatalf("UnmarshalBERTLV() error = %v", err)
+	}
+	if notification.OtherSignedNotification == nil || !notification.OtherSignedNotification.Certificates.EUICC.Equal(euicc.EUICC) {
+		t.Fatalf("OtherSignedNotification = %+v, want the eUICC certificate", notification.OtherSignedNotification)
+	}
+	if err := notification.Verify(nil, roots, time.Time{}); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if err := notification.Verify(nil, x509.NewCertPool(), time.Time{}); err == nil {
+		t.Error("Verify() error = nil for unknown CI")
+	}
+	metadata.Children[0].Value = []byte{0x02}
+	if err := notification.Verify(nil, roots, time.Time{}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() error = %v, want invalid signature", err)
+	}
+}
+
+func TestProfileInstallationResultDecodesFinalResult(t *testing.T) {
+	euicc := newTestEUICC(t, "89049032123451234512345678901235")
+	oid, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 31746})
+	var smdpOID bertlv.TLV
+	if err := smdpOID.UnmarshalBinary(oid); err != nil {
+		t.Fatalf("TLV.UnmarshalBinary() error = %v", err)
+	}
+	result := func(finalResult *bertlv.TLV) *bertlv.TLV {
+		data := bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(39),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{0x01, 0x02}),
+			notificationMetadataTLV(),
+			&smdpOID,
+			bertlv.NewChildren(bertlv.ContextSpecific.Constructed(2), finalResult),
+		)
+		return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(55), data, signTestTLV(t, euicc.Key, data))
+	}
+
+	var success PendingNotification
+	if err := success.UnmarshalBERTLV(result(bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(0),
+		bertlv.NewValue(bertlv.Application.Primitive(15), []byte{0xA0, 0x00, 0x00, 0x05, 0x59, 0x10, 0x10, 0x01}),
+		bertlv.NewValue(bertlv.Universal.Primitive(4), []byte{0x30, 0x00}),
+	))); err != nil {
+		t.Fatalf("UnmarshalBERTLV() error = %v", err)
+	}
+	pir := success.ProfileInstallationResult
+	if pir == nil || !pir.Succeeded() || len(pir.ISDPAID) != 8 || !bytes.Equal(pir.SIMAResponse, []byte{0x30, 0x00}) {
+		t.Fatalf("ProfileInstallationResult = %+v, want a successResult", pir)
+	}
+	if !pir.SMDPOID.Equal(asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 31746}) || !bytes.Equal(pir.TransactionID, []byte{0x01, 0x02}) {
+		t.Errorf("ProfileInstallationResult = %+v, want the SM-DP+ OID and transaction ID", pir)
+	}
+	if err := success.Verify(euicc.EUICC, nil, time.Time{}); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if err := success.Verify(nil, nil, time.Time{}); err == nil {
+		t.Error("Verify() error = nil without the eUICC certificate")
+	}
+
+	var failure PendingNotification
+	if err := failure.UnmarshalBERTLV(result(bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(1),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{byte(BPPCommandIDLoadProfileElements)}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{byte(BPPErrorReasonInstallFailedDueToPEProcessingError)}),
+	))); err != nil {
+		t.Fatalf("UnmarshalBERTLV() error = %v", err)
+	}
+	want := &LoadBoundProfilePackageError{
+		BPPCommandID: BPPCommandIDLoadProfileElements,
+		ErrorReason:  BPPErrorReasonInstallFailedDueToPEProcessingError,
+	}
+	if pir := failure.ProfileInstallationResult; pir.Succeeded() || pir.Error == nil || *pir.Error != *want {
+		t.Errorf("ProfileInstallationResult.Error = %v, want %v", pir.Error, want)
+	}
+}
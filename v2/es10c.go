@@ -280,7 +280,7 @@ func (r *GetEuiccDataRequest) MarshalBERTLV() (*bertlv.TLV, error) {
 }
 
 type GetEuiccDataResponse struct {
-	EID []byte
+	EID EID
 }
 
 func (r *GetEuiccDataResponse) UnmarshalBERTLV(tlv *bertlv.TLV) error {
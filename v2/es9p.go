@@ -46,6 +46,45 @@ func (r *ES9InitiateAuthenticationResponse) CardRequest() *AuthenticateServerReq
 	}
 }
 
+func (r *ES9InitiateAuthenticationResponse) ServerSigned1() (*ServerSigned1, error) {
+	if r.Signed1 == nil {
+		return nil, ErrUnexpectedTag
+	}
+	signed1 := new(ServerSigned1)
+	if err := signed1.UnmarshalBERTLV(r.Signed1); err != nil {
+		return nil, err
+	}
+	return signed1, nil
+}
+
+// ServerSigned1 is the data signed by the SM-DP+ with serverSignature1.
+type ServerSigned1 struct {
+	TransactionID   []byte
+	EUICCChallenge  []byte
+	ServerAddress   string
+	ServerChallenge []byte
+}
+
+func (s *ServerSigned1) UnmarshalBERTLV(tlv *bertlv.TLV) error {
+	if !tlv.Tag.If(bertlv.Universal, bertlv.Constructed, 16) {
+		return ErrUnexpectedTag
+	}
+	transactionID := tlv.First(bertlv.ContextSpecific.Primitive(0))
+	euiccChallenge := tlv.First(bertlv.ContextSpecific.Primitive(1))
+	serverAddress := tlv.First(bertlv.ContextSpecific.Primitive(3))
+	serverChallenge := tlv.First(bertlv.ContextSpecific.Primitive(4))
+	if transactionID == nil || euiccChallenge == nil || serverAddress == nil || serverChallenge == nil {
+		return ErrUnexpectedTag
+	}
+	*s = ServerSigned1{
+		TransactionID:   transactionID.Value,
+		EUICCChallenge:  euiccChallenge.Value,
+		ServerAddress:   string(serverAddress.Value),
+		ServerChallenge: serverChallenge.Value,
+	}
+	return nil
+}
+
 // endregion
 
 // region Section 5.6.2, ES9+.GetBoundProfilePackage
@@ -114,6 +153,23 @@ func (r *ES9AuthenticateClientRequest) UnmarshalBERTLV(tlv *bertlv.TLV) error {
 	return nil
 }
 
+// EUICCSignature1 returns the euiccSignature1 of a successful AuthenticateServerResponse, or nil.
+func (r *ES9AuthenticateClientRequest) EUICCSignature1() *bertlv.TLV {
+	if r.Response == nil {
+		return nil
+	}
+	ok := r.Response.First(bertlv.ContextSpecific.Constructed(0))
+	if ok == nil {
+		return nil
+	}
+	return ok.First(bertlv.Application.Primitive(55))
+}
+
+// Certificates returns CERT.EUICC.ECDSA and CERT.EUM.ECDSA of a successful AuthenticateServerResponse.
+func (r *ES9AuthenticateClientRequest) Certificates() (*EUICCCertificates, error) {
+	return ParseEUICCCertificates(r.Response)
+}
+
 func (r *ES9AuthenticateClientRequest) Valid() error {
 	if r.Response == nil {
 		return ErrUnexpectedTag
@@ -175,6 +231,40 @@ func (r *ES9AuthenticateClientResponse) CardRequest() *PrepareDownloadRequest {
 	}
 }
 
+func (r *ES9AuthenticateClientResponse) SMDPSigned2() (*SMDPSigned2, error) {
+	if r.Signed2 == nil {
+		return nil, ErrUnexpectedTag
+	}
+	signed2 := new(SMDPSigned2)
+	if err := signed2.UnmarshalBERTLV(r.Signed2); err != nil {
+		return nil, err
+	}
+	return signed2, nil
+}
+
+// SMDPSigned2 is the data signed by the SM-DP+ with smdpSignature2.
+type SMDPSigned2 struct {
+	TransactionID []byte
+	CCRequired    bool
+	BPPEUICCOtpk  []byte
+}
+
+func (s *SMDPSigned2) UnmarshalBERTLV(tlv *bertlv.TLV) error {
+	if !tlv.Tag.If(bertlv.Universal, bertlv.Constructed, 16) {
+		return ErrUnexpectedTag
+	}
+	transactionID := tlv.First(bertlv.ContextSpecific.Primitive(0))
+	ccRequired := tlv.First(bertlv.Universal.Primitive(1))
+	if transactionID == nil || ccRequired == nil {
+		return ErrUnexpectedTag
+	}
+	*s = SMDPSigned2{TransactionID: transactionID.Value}
+	if otpk := tlv.First(bertlv.Application.Primitive(73)); otpk != nil {
+		s.BPPEUICCOtpk = otpk.Value
+	}
+	return ccRequired.UnmarshalValue(primitive.UnmarshalBool(&s.CCRequired))
+}
+
 // endregion
 
 // region Section 5.6.4, ES9+.HandleNotification
@@ -85,3 +85,67 @@ func TestES9AuthenticateClientRequestUnmarshalUnexpectedTag(t *testing.T) {
 		t.Errorf("UnmarshalBERTLV() error = %v, want unexpected tag", err)
 	}
 }
+
+func TestES9InitiateAuthenticationResponseServerSigned1(t *testing.T) {
+	response := &ES9InitiateAuthenticationResponse{
+		Signed1: bertlv.NewChildren(
+			bertlv.Universal.Constructed(16),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{0x01, 0x02}),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{0x03}),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(3), []byte("smdp.example.com")),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(4), []byte{0x04}),
+		),
+	}
+
+	signed1, err := response.ServerSigned1()
+	if err != nil {
+		t.Fatalf("ServerSigned1() error = %v", err)
+	}
+	if !bytes.Equal(signed1.TransactionID, []byte{0x01, 0x02}) || !bytes.Equal(signed1.EUICCChallenge, []byte{0x03}) ||
+		signed1.ServerAddress != "smdp.example.com" || !bytes.Equal(signed1.ServerChallenge, []byte{0x04}) {
+		t.Errorf("ServerSigned1() = %+v", signed1)
+	}
+	response.Signed1.Children = response.Signed1.Children[:3]
+	if _, err := response.ServerSigned1(); !errors.Is(err, ErrUnexpectedTag) {
+		t.Errorf("ServerSigned1() error = %v, want unexpected tag", err)
+	}
+}
+
+func TestES9AuthenticateClientResponseSMDPSigned2(t *testing.T) {
+	response := &ES9AuthenticateClientResponse{
+		Signed2: bertlv.NewChildren(
+			bertlv.Universal.Constructed(16),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{0x01, 0x02}),
+			bertlv.NewValue(bertlv.Universal.Primitive(1), []byte{0xFF}),
+		),
+	}
+
+	signed2, err := response.SMDPSigned2()
+	if err != nil {
+		t.Fatalf("SMDPSigned2() error = %v", err)
+	}
+	if !bytes.Equal(signed2.TransactionID, []byte{0x01, 0x02}) || !signed2.CCRequired || signed2.BPPEUICCOtpk != nil {
+		t.Errorf("SMDPSigned2() = %+v", signed2)
+	}
+	response.Signed2.Children = response.Signed2.Children[:1]
+	if _, err := response.SMDPSigned2(); !errors.Is(err, ErrUnexpectedTag) {
+		t.Errorf("SMDPSigned2() error = %v, want unexpected tag", err)
+	}
+}
+
+func TestES9AuthenticateClientRequestEUICCSignature1(t *testing.T) {
+	signature := bertlv.NewValue(bertlv.Application.Primitive(55), []byte{0x01})
+	request := &ES9AuthenticateClientRequest{
+		Response: bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(56),
+			bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0), signature),
+		),
+	}
+	if got := request.EUICCSignature1(); got != signature {
+		t.Errorf("EUICCSignature1() = %v, want %v", got, signature)
+	}
+	request.Response = bertlv.NewChildren(bertlv.ContextSpecific.Constructed(56))
+	if got := request.EUICCSignature1(); got != nil {
+		t.Errorf("EUICCSignature1() = %v, want nil", got)
+	}
+}
@@ -21,10 +21,94 @@ func NewICCID(iccid string) (ICCID, error) {
 	return binaryCodedDecimalEncode[ICCID](iccid)
 }
 
+// ParseICCID parses an ICCID and validates it with Validate.
+func ParseICCID(iccid string) (ICCID, error) {
+	id, err := NewICCID(iccid)
+	if err != nil {
+		return nil, err
+	}
+	return id, id.Validate()
+}
+
 func (id ICCID) String() string {
 	return binaryCodedDecimalDecode(id)
 }
 
+// Validate checks the ICCID is an ITU-T E.118 number of 18 to 20 decimal digits,
+// starting with the telecommunication industry identifier 89 and ending with a Luhn check digit.
+// NewICCID accepts the non-standard ICCIDs issued by some operators, Validate does not.
+func (id ICCID) Validate() error {
+	digits := id.String()
+	if !decimal(digits) {
+		return fmt.Errorf("ICCID %s contains non-decimal digits", digits)
+	}
+	if len(digits) < 18 || len(digits) > 20 {
+		return fmt.Errorf("ICCID %s has %d digits, want 18 to 20", digits, len(digits))
+	}
+	if !strings.HasPrefix(digits, "89") {
+		return fmt.Errorf("ICCID %s does not start with 89", digits)
+	}
+	if !luhn(digits) {
+		return fmt.Errorf("%w: ICCID %s", ErrInvalidCheckDigit, digits)
+	}
+	return nil
+}
+
+// CountryCode returns the E.164 country code following the industry identifier.
+// Zone 1 is commonly written with a leading zero, so both 891 and 8901 return "1".
+func (id ICCID) CountryCode() string {
+	digits := id.String()
+	if len(digits) < 5 {
+		return ""
+	}
+	code, _ := countryCode(digits[2:])
+	return code
+}
+
+// IssuerIdentificationNumber returns the IIN, the industry identifier, country code and
+// issuer identifier, which are together at most 7 digits long.
+func (id ICCID) IssuerIdentificationNumber() string {
+	digits := id.String()
+	if len(digits) < 7 {
+		return ""
+	}
+	return digits[:7]
+}
+
+// IssuerIdentifier returns the issuer identifier part of the IIN.
+func (id ICCID) IssuerIdentifier() string {
+	digits := id.String()
+	if len(digits) < 7 {
+		return ""
+	}
+	code, n := countryCode(digits[2:])
+	if code == "" || 2+n > 7 {
+		return ""
+	}
+	return digits[2+n : 7]
+}
+
+// countryCode returns the E.164 country code at the beginning of digits and the number of digits it takes.
+func countryCode(digits string) (string, int) {
+	switch {
+	case strings.HasPrefix(digits, "01"):
+		return "1", 2
+	case digits[0] == '0':
+		return "", 0
+	case digits[0] == '1' || digits[0] == '7':
+		return digits[:1], 1
+	}
+	switch digits[:2] {
+	case "20", "27", "30", "31", "32", "33", "34", "36", "39",
+		"40", "41", "43", "44", "45", "46", "47", "48", "49",
+		"51", "52", "53", "54", "55", "56", "57", "58",
+		"60", "61", "62", "63", "64", "65", "66",
+		"81", "82", "84", "86", "90", "91", "92", "93", "94", "95", "98":
+		return digits[:2], 2
+	}
+	return digits[:3], 3
+}
+
 // endregion
 
 // region IMEI
@@ -39,12 +123,158 @@ func NewIMEI(imei string) (IMEI, error) {
 	return binaryCodedDecimalEncode[IMEI](imei)
 }
 
+// ParseIMEI parses an IMEI or IMEISV and validates it with Validate.
+func ParseIMEI(imei string) (IMEI, error) {
+	id, err := NewIMEI(imei)
+	if err != nil {
+		return nil, err
+	}
+	return id, id.Validate()
+}
+
 func (imei IMEI) String() string {
 	return binaryCodedDecimalDecode(imei)
 }
 
+// Validate checks the value is a 15 digit IMEI with a valid Luhn check digit,
+// or a 16 digit IMEISV, which carries a software version number instead of a check digit.
+//
+// See 3GPP TS 23.003 (Section 6.2, Composition of IMEI and IMEISV)
+func (imei IMEI) Validate() error {
+	digits := imei.String()
+	if !decimal(digits) {
+		return fmt.Errorf("IMEI %s contains non-decimal digits", digits)
+	}
+	switch len(digits) {
+	case 15:
+		if !luhn(digits) {
+			return fmt.Errorf("%w: IMEI %s", ErrInvalidCheckDigit, digits)
+		}
+		return nil
+	case 16:
+		return nil
+	}
+	return fmt.Errorf("IMEI %s has %d digits, want 15 (IMEI) or 16 (IMEISV)", digits, len(digits))
+}
+
+// TAC returns the Type Allocation Code, the first 8 digits.
+func (imei IMEI) TAC() string {
+	digits := imei.String()
+	if len(digits) < 8 {
+		return ""
+	}
+	return digits[:8]
+}
+
+// SerialNumber returns the 6 digit serial number following the TAC.
+func (imei IMEI) SerialNumber() string {
+	digits := imei.String()
+	if len(digits) < 14 {
+		return ""
+	}
+	return digits[8:14]
+}
+
+// SoftwareVersion returns the 2 digit software version number of an IMEISV, or an empty string for an IMEI.
+func (imei IMEI) SoftwareVersion() string {
+	digits := imei.String()
+	if len(digits) != 16 {
+		return ""
+	}
+	return digits[14:]
+}
+
+// endregion
+
+// region EID
+
+// EID represents the eUICC Identifier, 32 decimal digits stored as 16 bytes.
+//
+// See GSMA SGP.29 (EID Definition and Assignment Process)
+type EID []byte
+
+// ParseEID parses the 32 digit representation of an EID and validates it with Validate.
+func ParseEID(eid string) (EID, error) {
+	if len(eid) != 32 || !decimal(eid) {
+		return nil, fmt.Errorf("EID %s is not 32 decimal digits", eid)
+	}
+	id, err := hex.DecodeString(eid)
+	if err != nil {
+		return nil, err
+	}
+	return EID(id), EID(id).Validate()
+}
+
+func (eid EID) String() string {
+	return strings.ToUpper(hex.EncodeToString(eid))
+}
+
+// Validate checks the EID starts with the industry identifier 89 and that its
+// last two digits are ISO/IEC 7064 MOD 97-10 check digits.
+func (eid EID) Validate() error {
+	digits := eid.String()
+	if len(digits) != 32 || !decimal(digits) {
+		return fmt.Errorf("EID %s is not 32 decimal digits", digits)
+	}
+	if !strings.HasPrefix(digits, "89") {
+		return fmt.Errorf("EID %s does not start with 89", digits)
+	}
+	var remainder int
+	for _, digit := range digits {
+		remainder = (remainder*10 + int(digit-'0')) % 97
+	}
+	if remainder != 1 {
+		return fmt.Errorf("%w: EID %s", ErrInvalidCheckDigit, digits)
+	}
+	return nil
+}
+
+// CountryCode returns digits 3 to 5 of the EID, the country of the EUM.
+func (eid EID) CountryCode() string {
+	if len(eid) != 16 {
+		return ""
+	}
+	return eid.String()[2:5]
+}
+
+// IssuerIdentifier returns digits 6 to 8 of the EID, the issuer identifier of the EUM.
+func (eid EID) IssuerIdentifier() string {
+	if len(eid) != 16 {
+		return ""
+	}
+	return eid.String()[5:8]
+}
+
 // endregion
 
+// decimal reports whether value is a non-empty string of decimal digits.
+func decimal(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// luhn reports whether the last digit of digits is its Luhn check digit.
+func luhn(digits string) bool {
+	var sum int
+	for index := range len(digits) {
+		digit := int(digits[len(digits)-1-index] - '0')
+		if index%2 == 1 {
+			if digit *= 2; digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
+
 func binaryCodedDecimalEncode[T ~[]byte](value string) (T, error) {
 	for _, r := range value {
 		if (r < '0' || r > '9') && (r < 'A' || r > 'F') && (r < 'a' || r > 'f') {
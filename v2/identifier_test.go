@@ -2,6 +2,7 @@ package sgp22
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -37,3 +38,108 @@ func TestICCID_String(t *testing.T) {
 		t.Errorf("NewICCID() = % X, want % X", parsed, iccid)
 	}
 }
+
+func TestParseICCID(t *testing.T) {
+	tests := []struct {
+		iccid       string
+		countryCode string
+		iin         string
+		issuer      string
+	}{
+		{"8944478600004573128", "44", "8944478", "478"},
+		{"89852245280007179998", "852", "8985224", "24"},
+		{"89012604123456789014", "1", "8901260", "260"},
+		{"8970199123456789012", "7", "8970199", "0199"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.iccid, func(t *testing.T) {
+			iccid, err := ParseICCID(tt.iccid)
+			if err != nil {
+				t.Fatalf("ParseICCID(%q) error = %v", tt.iccid, err)
+			}
+			if got := iccid.CountryCode(); got != tt.countryCode {
+				t.Errorf("CountryCode() = %q, want %q", got, tt.countryCode)
+			}
+			if got := iccid.IssuerIdentificationNumber(); got != tt.iin {
+				t.Errorf("IssuerIdentificationNumber() = %q, want %q", got, tt.iin)
+			}
+			if got := iccid.IssuerIdentifier(); got != tt.issuer {
+				t.Errorf("IssuerIdentifier() = %q, want %q", got, tt.issuer)
+			}
+		})
+	}
+}
+
+func TestICCIDValidate(t *testing.T) {
+	tests := []struct {
+		iccid         string
+		wantCheckFail bool
+		wantErr       bool
+	}{
+		{"8944478600004573128", false, false},
+		// Transposed digits
+		{"8944478600004537128", true, true},
+		{"89860110f9900160570", false, true},
+		{"1944478600004573128", false, true},
+		{"894447860000457", false, true},
+	}
+	for _, tt := range tests {
+		iccid, err := NewICCID(tt.iccid)
+		if err != nil {
+			t.Fatalf("NewICCID(%q) error = %v", tt.iccid, err)
+		}
+		err = iccid.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ICCID(%s).Validate() error = %v, want error %v", tt.iccid, err, tt.wantErr)
+		}
+		if errors.Is(err, ErrInvalidCheckDigit) != tt.wantCheckFail {
+			t.Errorf("ICCID(%s).Validate() error = %v, want invalid check digit %v", tt.iccid, err, tt.wantCheckFail)
+		}
+	}
+}
+
+func TestParseIMEI(t *testing.T) {
+	imei, err := ParseIMEI("356938035643809")
+	if err != nil {
+		t.Fatalf("ParseIMEI() error = %v", err)
+	}
+	if imei.TAC() != "35693803" || imei.SerialNumber() != "564380" || imei.SoftwareVersion() != "" {
+		t.Errorf("ParseIMEI() TAC = %q, SerialNumber = %q, SoftwareVersion = %q", imei.TAC(), imei.SerialNumber(), imei.SoftwareVersion())
+	}
+	imeisv, err := ParseIMEI("3569380356438012")
+	if err != nil {
+		t.Fatalf("ParseIMEI() error = %v", err)
+	}
+	if imeisv.TAC() != "35693803" || imeisv.SoftwareVersion() != "12" {
+		t.Errorf("ParseIMEI() TAC = %q, SoftwareVersion = %q", imeisv.TAC(), imeisv.SoftwareVersion())
+	}
+	if _, err := ParseIMEI("356938035634809"); !errors.Is(err, ErrInvalidCheckDigit) {
+		t.Errorf("ParseIMEI() error = %v, want invalid check digit", err)
+	}
+	if _, err := ParseIMEI("35693803564380"); err == nil {
+		t.Error("ParseIMEI() error = nil for 14 digits")
+	}
+}
+
+func TestParseEID(t *testing.T) {
+	eid, err := ParseEID("89049032123451234512345678901235")
+	if err != nil {
+		t.Fatalf("ParseEID() error = %v", err)
+	}
+	if want := []byte{0x89, 0x04, 0x90, 0x32, 0x12, 0x34, 0x51, 0x23, 0x45, 0x12, 0x34, 0x56, 0x78, 0x90, 0x12, 0x35}; !bytes.Equal(eid, want) {
+		t.Errorf("ParseEID() = % X, want % X", []byte(eid), want)
+	}
+	if got := eid.String(); got != "89049032123451234512345678901235" {
+		t.Errorf("String() = %q", got)
+	}
+	if eid.CountryCode() != "049" || eid.IssuerIdentifier() != "032" {
+		t.Errorf("CountryCode() = %q, IssuerIdentifier() = %q, want 049, 032", eid.CountryCode(), eid.IssuerIdentifier())
+	}
+	// Transposed digits
+	if _, err := ParseEID("89049032123451234512345678910235"); !errors.Is(err, ErrInvalidCheckDigit) {
+		t.Errorf("ParseEID() error = %v, want invalid check digit", err)
+	}
+	if _, err := ParseEID("8904903212345123451234567890123A"); err == nil {
+		t.Error("ParseEID() error = nil for non-decimal digits")
+	}
+}
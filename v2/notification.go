@@ -1,7 +1,11 @@
 package sgp22
 
 import (
+	"crypto/x509"
+	"encoding/asn1"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/damonto/euicc-go/bertlv"
 	"github.com/damonto/euicc-go/bertlv/primitive"
@@ -96,28 +100,225 @@ func (n *NotificationMetadata) UnmarshalBERTLV(tlv *bertlv.TLV) error {
 	return nil
 }
 
+// PendingNotification is a notification retrieved from the eUICC, either a ProfileInstallationResult
+// or an OtherSignedNotification.
 type PendingNotification struct {
 	PendingNotification *bertlv.TLV
 	Notification        *NotificationMetadata
+	// ProfileInstallationResult is set if the notification is a ProfileInstallationResult.
+	ProfileInstallationResult *ProfileInstallationResult
+	// OtherSignedNotification is set if the notification is an OtherSignedNotification.
+	OtherSignedNotification *OtherSignedNotification
 }
 
 func (p *PendingNotification) UnmarshalBERTLV(tlv *bertlv.TLV) error {
 	if len(tlv.Children) == 0 {
 		return errors.New("notification does not exist")
 	}
-	var metadata *bertlv.TLV
+	*p = PendingNotification{PendingNotification: tlv}
 	switch {
 	case tlv.Tag.If(bertlv.ContextSpecific, bertlv.Constructed, 55):
-		metadata = tlv.Select(
-			bertlv.ContextSpecific.Constructed(39),
-			bertlv.ContextSpecific.Constructed(47),
-		)
+		p.ProfileInstallationResult = new(ProfileInstallationResult)
+		if err := p.ProfileInstallationResult.UnmarshalBERTLV(tlv); err != nil {
+			return err
+		}
+		p.Notification = p.ProfileInstallationResult.Notification
 	case tlv.Tag.If(bertlv.Universal, bertlv.Constructed, 16):
-		metadata = tlv.First(bertlv.ContextSpecific.Constructed(47))
+		p.OtherSignedNotification = new(OtherSignedNotification)
+		if err := p.OtherSignedNotification.UnmarshalBERTLV(tlv); err != nil {
+			return err
+		}
+		p.Notification = p.OtherSignedNotification.Notification
 	default:
 		return ErrUnexpectedTag
 	}
-	*p = PendingNotification{PendingNotification: tlv}
-	p.Notification = new(NotificationMetadata)
-	return p.Notification.UnmarshalBERTLV(metadata)
+	return nil
+}
+
+// Verify verifies the eUICC signature of the notification, e.g. of a notification forwarded by an LPA.
+//
+// A ProfileInstallationResult carries no certificate, so it is verified with certificate,
+// the CERT.EUICC.ECDSA the eUICC sent in its AuthenticateServerResponse.
+// An OtherSignedNotification is verified with the certificates it carries, see [OtherSignedNotification.Verify];
+// if certificate is not nil, the eUICC certificate of the notification must be the same.
+func (p *PendingNotification) Verify(certificate *x509.Certificate, roots *x509.CertPool, currentTime time.Time) error {
+	switch {
+	case p.ProfileInstallationResult != nil:
+		if certificate == nil {
+			return errors.New("eUICC certificate is required to verify a ProfileInstallationResult")
+		}
+		return p.ProfileInstallationResult.Verify(certificate)
+	case p.OtherSignedNotification != nil:
+		if certificate != nil && p.OtherSignedNotification.Certificates != nil &&
+			!certificate.Equal(p.OtherSignedNotification.Certificates.EUICC) {
+			return errors.New("notification is signed by a different eUICC certificate")
+		}
+		return p.OtherSignedNotification.Verify(roots, currentTime)
+	}
+	return errors.New("notification is not decoded")
+}
+
+// region ProfileInstallationResult
+
+// ProfileInstallationResult is the signed result of loading a bound profile package.
+//
+// See https://aka.pw/sgp22/v2.5#page=35 (Section 2.5.6, ProfileInstallationResult)
+type ProfileInstallationResult struct {
+	// Data is profileInstallationResultData, the data signed by the eUICC.
+	Data          *bertlv.TLV
+	TransactionID []byte
+	Notification  *NotificationMetadata
+	// SMDPOID is the OID of the SM-DP+, if the eUICC includes it.
+	SMDPOID asn1.ObjectIdentifier
+	// ISDPAID is the AID of the ISD-P of the installed profile, if the finalResult is a successResult.
+	ISDPAID ISDPAID
+	// Error is the failed BPP command and the reason, if the finalResult is an errorResult.
+	Error *LoadBoundProfilePackageError
+	// SIMAResponse is the response of the eUICC Profile Package processing, if any.
+	SIMAResponse []byte
+	// Signature is euiccSignPIR, computed over Data.
+	Signature *bertlv.TLV
+}
+
+func (r *ProfileInstallationResult) UnmarshalBERTLV(tlv *bertlv.TLV) error {
+	if !tlv.Tag.If(bertlv.ContextSpecific, bertlv.Constructed, 55) {
+		return ErrUnexpectedTag
+	}
+	data := tlv.First(bertlv.ContextSpecific.Constructed(39))
+	if data == nil {
+		return errors.New("missing profileInstallationResultData")
+	}
+	*r = ProfileInstallationResult{
+		Data:      data,
+		Signature: tlv.First(bertlv.Application.Primitive(55)),
+	}
+	if transactionID := data.First(bertlv.ContextSpecific.Primitive(0)); transactionID != nil {
+		r.TransactionID = transactionID.Value
+	}
+	r.Notification = new(NotificationMetadata)
+	if err := r.Notification.UnmarshalBERTLV(data.First(bertlv.ContextSpecific.Constructed(47))); err != nil {
+		return err
+	}
+	if oid := data.First(bertlv.Universal.Primitive(6)); oid != nil {
+		der, err := oid.Bytes()
+		if err != nil {
+			return err
+		}
+		if _, err := asn1.Unmarshal(der, &r.SMDPOID); err != nil {
+			return fmt.Errorf("decode SM-DP+ OID: %w", err)
+		}
+	}
+	finalResult := data.First(bertlv.ContextSpecific.Constructed(2))
+	if finalResult == nil {
+		return nil
+	}
+	if success := finalResult.First(bertlv.ContextSpecific.Constructed(0)); success != nil {
+		if aid := success.First(bertlv.Application.Primitive(15)); aid != nil {
+			r.ISDPAID = aid.Value
+		}
+		if response := success.First(bertlv.Universal.Primitive(4)); response != nil {
+			r.SIMAResponse = response.Value
+		}
+		return nil
+	}
+	result := finalResult.First(bertlv.ContextSpecific.Constructed(1))
+	if result == nil {
+		return nil
+	}
+	var err error
+	if r.Error, err = unmarshalErrorResult(result); err != nil {
+		return err
+	}
+	if response := result.First(bertlv.ContextSpecific.Primitive(2)); response != nil {
+		r.SIMAResponse = response.Value
+	}
+	return nil
 }
+
+// Succeeded reports whether the finalResult is a successResult.
+func (r *ProfileInstallationResult) Succeeded() bool {
+	return r.Error == nil && r.ISDPAID != nil
+}
+
+// Verify verifies euiccSignPIR with CERT.EUICC.ECDSA.
+func (r *ProfileInstallationResult) Verify(certificate *x509.Certificate) error {
+	return VerifySignature(certificate, r.Signature, r.Data)
+}
+
+func unmarshalErrorResult(tlv *bertlv.TLV) (*LoadBoundProfilePackageError, error) {
+	var commandID BPPCommandID
+	if err := tlv.First(bertlv.ContextSpecific.Primitive(0)).
+		UnmarshalValue(primitive.UnmarshalInt(&commandID)); err != nil {
+		return nil, err
+	}
+	var reason BPPErrorReason
+	if err := tlv.First(bertlv.ContextSpecific.Primitive(1)).
+		UnmarshalValue(primitive.UnmarshalInt(&reason)); err != nil {
+		return nil, err
+	}
+	return &LoadBoundProfilePackageError{
+		BPPCommandID: commandID,
+		ErrorReason:  reason,
+	}, nil
+}
+
+// endregion
+
+// region OtherSignedNotification
+
+// OtherSignedNotification is the signed notification of an enable, disable or delete operation.
+type OtherSignedNotification struct {
+	// Notification is tbsOtherNotification, the data signed by the eUICC.
+	Notification *NotificationMetadata
+	Data         *bertlv.TLV
+	// Signature is euiccNotificationSignature, computed over Data.
+	Signature *bertlv.TLV
+	// Certificates are CERT.EUICC.ECDSA and CERT.EUM.ECDSA, nil if the notification does not carry both.
+	Certificates *EUICCCertificates
+}
+
+func (n *OtherSignedNotification) UnmarshalBERTLV(tlv *bertlv.TLV) error {
+	if !tlv.Tag.If(bertlv.Universal, bertlv.Constructed, 16) {
+		return ErrUnexpectedTag
+	}
+	*n = OtherSignedNotification{
+		Data:      tlv.First(bertlv.ContextSpecific.Constructed(47)),
+		Signature: tlv.First(bertlv.Application.Primitive(55)),
+	}
+	n.Notification = new(NotificationMetadata)
+	if err := n.Notification.UnmarshalBERTLV(n.Data); err != nil {
+		return err
+	}
+	var certificates []*bertlv.TLV
+	for _, child := range tlv.Children {
+		if child.Tag.If(bertlv.Universal, bertlv.Constructed, 16) {
+			certificates = append(certificates, child)
+		}
+	}
+	if len(certificates) != 2 {
+		return nil
+	}
+	n.Certificates = new(EUICCCertificates)
+	var err error
+	if n.Certificates.EUICC, err = ParseCertificate(certificates[0]); err != nil {
+		return fmt.Errorf("parse eUICC certificate: %w", err)
+	}
+	if n.Certificates.EUM, err = ParseCertificate(certificates[1]); err != nil {
+		return fmt.Errorf("parse EUM certificate: %w", err)
+	}
+	return nil
+}
+
+// Verify verifies euiccNotificationSignature with the eUICC certificate and the certificate chain,
+// see [EUICCCertificates.Verify]. A zero currentTime uses the current time.
+func (n *OtherSignedNotification) Verify(roots *x509.CertPool, currentTime time.Time) error {
+	if n.Certificates == nil {
+		return errors.New("missing eUICC or EUM certificate")
+	}
+	if err := n.Certificates.Verify(roots, currentTime); err != nil {
+		return err
+	}
+	return VerifySignature(n.Certificates.EUICC, n.Signature, n.Data)
+}
+
+// endregion
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/base64"
 	"errors"
+	"fmt"
 
 	"github.com/damonto/euicc-go/bertlv"
 	"github.com/damonto/euicc-go/bertlv/primitive"
@@ -78,6 +79,97 @@ func (p *ProfileInfo) UnmarshalBERTLV(tlv *bertlv.TLV) error {
 	return nil
 }
 
+// EUICCInfo1 is the eUICC information exchanged with the SM-DP+ during mutual authentication.
+//
+// See https://aka.pw/sgp22/v2.5#page=187 (Section 5.7.8, ES10b.GetEUICCInfo)
+type EUICCInfo1 struct {
+	SVN                       VersionType
+	CIPKIDListForVerification [][]byte
+	CIPKIDListForSigning      [][]byte
+}
+
+func (info *EUICCInfo1) UnmarshalBERTLV(tlv *bertlv.TLV) error {
+	if !tlv.Tag.If(bertlv.ContextSpecific, bertlv.Constructed, 32) {
+		return ErrUnexpectedTag
+	}
+	*info = EUICCInfo1{
+		CIPKIDListForVerification: subjectKeyIdentifiers(tlv.First(bertlv.ContextSpecific.Constructed(9))),
+		CIPKIDListForSigning:      subjectKeyIdentifiers(tlv.First(bertlv.ContextSpecific.Constructed(10))),
+	}
+	return optional(tlv, bertlv.ContextSpecific.Primitive(2), &info.SVN, VersionType(nil))
+}
+
+// EUICCInfo2 is the extended eUICC information.
+//
+// See https://aka.pw/sgp22/v2.5#page=187 (Section 5.7.8, ES10b.GetEUICCInfo)
+type EUICCInfo2 struct {
+	ProfileVersion              VersionType
+	SVN                         VersionType
+	EUICCFirmwareVersion        VersionType
+	ExtCardResource             ExtCardResource
+	UICCCapability              UICCCapability
+	TS102241Version             VersionType
+	GlobalPlatformVersion       VersionType
+	RSPCapability               RSPCapability
+	CIPKIDListForVerification   [][]byte
+	CIPKIDListForSigning        [][]byte
+	EUICCCategory               EUICCCategory
+	ForbiddenProfilePolicyRules ProfilePolicyRules
+	PPVersion                   VersionType
+	SASAccreditationNumber      string
+	CertificationDataObject     *CertificationDataObject
+}
+
+func (info *EUICCInfo2) UnmarshalBERTLV(tlv *bertlv.TLV) error {
+	if !tlv.Tag.If(bertlv.ContextSpecific, bertlv.Constructed, 34) {
+		return ErrUnexpectedTag
+	}
+	*info = EUICCInfo2{
+		CIPKIDListForVerification: subjectKeyIdentifiers(tlv.First(bertlv.ContextSpecific.Constructed(9))),
+		CIPKIDListForSigning:      subjectKeyIdentifiers(tlv.First(bertlv.ContextSpecific.Constructed(10))),
+	}
+	versions := map[uint64]*VersionType{
+		1: &info.ProfileVersion,
+		2: &info.SVN,
+		3: &info.EUICCFirmwareVersion,
+		6: &info.TS102241Version,
+		7: &info.GlobalPlatformVersion,
+	}
+	for tag, version := range versions {
+		if err := optional(tlv, bertlv.ContextSpecific.Primitive(tag), version, VersionType(nil)); err != nil {
+			return err
+		}
+	}
+	if err := optional(tlv, bertlv.ContextSpecific.Primitive(4), &info.ExtCardResource, ExtCardResource{}); err != nil {
+		return fmt.Errorf("decode extCardResource: %w", err)
+	}
+	if err := optional(tlv, bertlv.ContextSpecific.Primitive(5), &info.UICCCapability, UICCCapability{}); err != nil {
+		return fmt.Errorf("decode uiccCapability: %w", err)
+	}
+	if err := optional(tlv, bertlv.ContextSpecific.Primitive(8), &info.RSPCapability, RSPCapability{}); err != nil {
+		return fmt.Errorf("decode rspCapability: %w", err)
+	}
+	if err := optional(tlv, bertlv.ContextSpecific.Primitive(11), &info.EUICCCategory, EUICCCategoryOther); err != nil {
+		return fmt.Errorf("decode euiccCategory: %w", err)
+	}
+	if err := optional(tlv, TagProfilePolicyRules, &info.ForbiddenProfilePolicyRules, ProfilePolicyRules{}); err != nil {
+		return fmt.Errorf("decode forbiddenProfilePolicyRules: %w", err)
+	}
+	if err := optional(tlv, bertlv.Universal.Primitive(4), &info.PPVersion, VersionType(nil)); err != nil {
+		return err
+	}
+	if err := optional(tlv, bertlv.Universal.Primitive(12), &info.SASAccreditationNumber, ""); err != nil {
+		return err
+	}
+	if certification := tlv.First(bertlv.ContextSpecific.Constructed(12)); certification != nil {
+		info.CertificationDataObject = new(CertificationDataObject)
+		if err := info.CertificationDataObject.UnmarshalBERTLV(certification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func optional[T any](tlv *bertlv.TLV, tag bertlv.Tag, dst *T, def T) error {
 	*dst = def
 	field := tlv.First(tag)
@@ -105,6 +197,16 @@ func optional[T any](tlv *bertlv.TLV, tag bertlv.Tag, dst *T, def T) error {
 		return field.UnmarshalValue(v)
 	case *ProfilePolicyRules:
 		return field.UnmarshalValue(v)
+	case *VersionType:
+		*v = VersionType(field.Value)
+	case *ExtCardResource:
+		return field.UnmarshalValue(v)
+	case *UICCCapability:
+		return field.UnmarshalValue(v)
+	case *RSPCapability:
+		return field.UnmarshalValue(v)
+	case *EUICCCategory:
+		return field.UnmarshalValue(primitive.UnmarshalInt(v))
 	case *[]bool:
 		return field.UnmarshalValue(primitive.UnmarshalBitString(v))
 	default:
@@ -178,12 +280,37 @@ func (id *OperatorId) MNC() string {
 	return string(mnc)
 }
 
+// Match reports whether the operator matches an allowed operator of the RAT.
+// A nibble 'E' in the allowed PLMN matches any digit, absent GID1 and GID2 match any value.
+func (id *OperatorId) Match(allowed *OperatorId) bool {
+	if len(id.PLMN) != len(allowed.PLMN) {
+		return false
+	}
+	for index, b := range allowed.PLMN {
+		if b&0x0f != 0x0e && b&0x0f != id.PLMN[index]&0x0f {
+			return false
+		}
+		if b>>4 != 0x0e && b>>4 != id.PLMN[index]>>4 {
+			return false
+		}
+	}
+	if allowed.GID1 != nil && !bytes.Equal(id.GID1, allowed.GID1) {
+		return false
+	}
+	return allowed.GID2 == nil || bytes.Equal(id.GID2, allowed.GID2)
+}
+
 func (id *OperatorId) UnmarshalBERTLV(tlv *bertlv.TLV) error {
-	if !tlv.Tag.If(bertlv.ContextSpecific, bertlv.Constructed, 23) {
+	// The profile owner is tagged [23], the allowed operators of the RAT are plain sequences.
+	if tlv == nil || !tlv.Tag.If(bertlv.ContextSpecific, bertlv.Constructed, 23) && !tlv.Tag.If(bertlv.Universal, bertlv.Constructed, 16) {
+		return ErrUnexpectedTag
+	}
+	plmn := tlv.First(bertlv.ContextSpecific.Primitive(0))
+	if plmn == nil {
 		return ErrUnexpectedTag
 	}
 	*id = OperatorId{
-		PLMN: tlv.First(bertlv.ContextSpecific.Primitive(0)).Value,
+		PLMN: plmn.Value,
 	}
 	if gid1 := tlv.First(bertlv.ContextSpecific.Primitive(1)); gid1 != nil {
 		id.GID1 = gid1.Value
@@ -275,3 +402,185 @@ func (p *ProfilePolicyRules) UnmarshalBinary(data []byte) error {
 	}
 	return nil
 }
+
+// VersionType is a three byte version number (major, minor, revision).
+type VersionType []byte
+
+func (v VersionType) String() string {
+	if len(v) != 3 {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// ExtCardResource describes the installed applications and free memory of the eUICC.
+//
+// See https://aka.pw/sgp22/v2.5#page=189 (Section 5.7.8, ES10b.GetEUICCInfo)
+type ExtCardResource struct {
+	InstalledApplications int
+	FreeNonVolatileMemory uint64
+	FreeVolatileMemory    uint64
+}
+
+func (r *ExtCardResource) UnmarshalBinary(data []byte) error {
+	*r = ExtCardResource{}
+	reader := bytes.NewReader(data)
+	for reader.Len() > 0 {
+		var tlv bertlv.TLV
+		if _, err := tlv.ReadFrom(reader); err != nil {
+			return err
+		}
+		if len(tlv.Value) > 8 {
+			return errors.New("resource value is too large")
+		}
+		var value uint64
+		for _, b := range tlv.Value {
+			value = value<<8 | uint64(b)
+		}
+		switch {
+		case tlv.Tag.If(bertlv.ContextSpecific, bertlv.Primitive, 1):
+			r.InstalledApplications = int(value)
+		case tlv.Tag.If(bertlv.ContextSpecific, bertlv.Primitive, 2):
+			r.FreeNonVolatileMemory = value
+		case tlv.Tag.If(bertlv.ContextSpecific, bertlv.Primitive, 3):
+			r.FreeVolatileMemory = value
+		}
+	}
+	return nil
+}
+
+type UICCCapability struct {
+	ContactlessSupport           bool
+	USIMSupport                  bool
+	ISIMSupport                  bool
+	CSIMSupport                  bool
+	AKAMilenage                  bool
+	AKACave                      bool
+	AKATuak128                   bool
+	AKATuak256                   bool
+	GBAAuthenUSIM                bool
+	GBAAuthenISIM                bool
+	MBMSAuthenUSIM               bool
+	EAPClient                    bool
+	JavaCard                     bool
+	Multos                       bool
+	MultipleUSIMSupport          bool
+	MultipleISIMSupport          bool
+	MultipleCSIMSupport          bool
+	BERTLVFileSupport            bool
+	DFLinkSupport                bool
+	CATTP                        bool
+	GetIdentity                  bool
+	ProfileAX25519               bool
+	ProfileBP256                 bool
+	SUCICalculatorAPI            bool
+	DNSResolution                bool
+	SCP11ac                      bool
+	SCP11cAuthorizationMechanism bool
+	S16Mode                      bool
+	EAKA                         bool
+	IoTMinimal                   bool
+}
+
+func (c *UICCCapability) UnmarshalBinary(data []byte) error {
+	*c = UICCCapability{}
+	// Bits 8 and 9 are reserved for future use.
+	return unmarshalFlags(data, []*bool{
+		&c.ContactlessSupport, &c.USIMSupport, &c.ISIMSupport, &c.CSIMSupport,
+		&c.AKAMilenage, &c.AKACave, &c.AKATuak128, &c.AKATuak256,
+		nil, nil, &c.GBAAuthenUSIM, &c.GBAAuthenISIM,
+		&c.MBMSAuthenUSIM, &c.EAPClient, &c.JavaCard, &c.Multos,
+		&c.MultipleUSIMSupport, &c.MultipleISIMSupport, &c.MultipleCSIMSupport, &c.BERTLVFileSupport,
+		&c.DFLinkSupport, &c.CATTP, &c.GetIdentity, &c.ProfileAX25519,
+		&c.ProfileBP256, &c.SUCICalculatorAPI, &c.DNSResolution, &c.SCP11ac,
+		&c.SCP11cAuthorizationMechanism, &c.S16Mode, &c.EAKA, &c.IoTMinimal,
+	})
+}
+
+type RSPCapability struct {
+	AdditionalProfile              bool
+	CRLSupport                     bool
+	RPMSupport                     bool
+	TestProfileSupport             bool
+	DeviceInfoExtensibilitySupport bool
+	ServiceSpecificDataSupport     bool
+}
+
+func (c *RSPCapability) UnmarshalBinary(data []byte) error {
+	*c = RSPCapability{}
+	return unmarshalFlags(data, []*bool{
+		&c.AdditionalProfile,
+		&c.CRLSupport,
+		&c.RPMSupport,
+		&c.TestProfileSupport,
+		&c.DeviceInfoExtensibilitySupport,
+		&c.ServiceSpecificDataSupport,
+	})
+}
+
+func unmarshalFlags(data []byte, flags []*bool) error {
+	var bits []bool
+	if err := primitive.UnmarshalBitString(&bits).UnmarshalBinary(data); err != nil {
+		return err
+	}
+	for index, bit := range bits {
+		if index < len(flags) && flags[index] != nil {
+			*flags[index] = bit
+		}
+	}
+	return nil
+}
+
+type EUICCCategory int8
+
+const (
+	EUICCCategoryOther       EUICCCategory = 0
+	EUICCCategoryBasic       EUICCCategory = 1
+	EUICCCategoryMedium      EUICCCategory = 2
+	EUICCCategoryContactless EUICCCategory = 3
+)
+
+func (c EUICCCategory) String() string {
+	switch c {
+	case EUICCCategoryOther:
+		return "other"
+	case EUICCCategoryBasic:
+		return "basic"
+	case EUICCCategoryMedium:
+		return "medium"
+	case EUICCCategoryContactless:
+		return "contactless"
+	}
+	return "unknown"
+}
+
+type CertificationDataObject struct {
+	PlatformLabel    string
+	DiscoveryBaseURL string
+}
+
+func (c *CertificationDataObject) UnmarshalBERTLV(tlv *bertlv.TLV) error {
+	if !tlv.Tag.If(bertlv.ContextSpecific, bertlv.Constructed, 12) {
+		return ErrUnexpectedTag
+	}
+	values := tlv.Find(bertlv.Universal.Primitive(12))
+	if len(values) != 2 {
+		return ErrUnexpectedTag
+	}
+	*c = CertificationDataObject{
+		PlatformLabel:    string(values[0].Value),
+		DiscoveryBaseURL: string(values[1].Value),
+	}
+	return nil
+}
+
+func subjectKeyIdentifiers(tlv *bertlv.TLV) [][]byte {
+	if tlv == nil {
+		return nil
+	}
+	identifiers := make([][]byte, 0, len(tlv.Children))
+	for _, child := range tlv.Find(bertlv.Universal.Primitive(4)) {
+		identifiers = append(identifiers, child.Value)
+	}
+	return identifiers
+}
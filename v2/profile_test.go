@@ -151,3 +151,107 @@ func TestNotificationConfigurationInfoUnmarshalMissingFieldDoesNotPanic(t *testi
 		t.Errorf("UnmarshalBERTLV() error = %v, want unexpected tag", err)
 	}
 }
+
+func TestEUICCInfo1Unmarshal(t *testing.T) {
+	pkid := []byte{0x81, 0x37, 0x0F, 0x51, 0x25, 0xD0, 0xB1, 0xD4, 0x08, 0xD4, 0xC3, 0xB2, 0x32, 0xE6, 0xD2, 0x5E, 0x79, 0x5B, 0xEB, 0xFB}
+	tlv := bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(32),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), []byte{0x02, 0x02, 0x00}),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(9), bertlv.NewValue(bertlv.Universal.Primitive(4), pkid)),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(10), bertlv.NewValue(bertlv.Universal.Primitive(4), pkid)),
+	)
+	info := new(EUICCInfo1)
+
+	if err := info.UnmarshalBERTLV(tlv); err != nil {
+		t.Fatalf("UnmarshalBERTLV() error = %v", err)
+	}
+	if got, want := info.SVN.String(), "2.2.0"; got != want {
+		t.Errorf("SVN = %q, want %q", got, want)
+	}
+	if want := [][]byte{pkid}; !reflect.DeepEqual(info.CIPKIDListForVerification, want) || !reflect.DeepEqual(info.CIPKIDListForSigning, want) {
+		t.Errorf("PKID lists = %X, %X", info.CIPKIDListForVerification, info.CIPKIDListForSigning)
+	}
+}
+
+func TestEUICCInfo2Unmarshal(t *testing.T) {
+	pkid := []byte{0x81, 0x37, 0x0F, 0x51, 0x25, 0xD0, 0xB1, 0xD4, 0x08, 0xD4, 0xC3, 0xB2, 0x32, 0xE6, 0xD2, 0x5E, 0x79, 0x5B, 0xEB, 0xFB}
+	tlv := bertlv.NewChildren(
+		bertlv.ContextSpecific.Constructed(34),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{0x02, 0x01, 0x00}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), []byte{0x02, 0x02, 0x00}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(3), []byte{0x04, 0x01, 0x00}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(4), []byte{0x81, 0x01, 0x06, 0x82, 0x04, 0x00, 0x03, 0x8B, 0x18, 0x83, 0x02, 0x21, 0x10}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(5), []byte{0x00, 0x40, 0x02}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(6), []byte{0x09, 0x02, 0x00}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(7), []byte{0x02, 0x03, 0x00}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(8), []byte{0x02, 0x84}),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(9), bertlv.NewValue(bertlv.Universal.Primitive(4), pkid)),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(10), bertlv.NewValue(bertlv.Universal.Primitive(4), pkid)),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(11), []byte{0x02}),
+		bertlv.NewValue(TagProfilePolicyRules, []byte{0x06, 0x40}),
+		bertlv.NewValue(bertlv.Universal.Primitive(4), []byte{0x00, 0x02, 0x01}),
+		bertlv.NewValue(bertlv.Universal.Primitive(12), []byte("GI-BA-UP-0419")),
+		bertlv.NewChildren(
+			bertlv.ContextSpecific.Constructed(12),
+			bertlv.NewValue(bertlv.Universal.Primitive(12), []byte("example-platform")),
+			bertlv.NewValue(bertlv.Universal.Primitive(12), []byte("https://ds.example.com")),
+		),
+	)
+	info := new(EUICCInfo2)
+
+	if err := info.UnmarshalBERTLV(tlv); err != nil {
+		t.Fatalf("UnmarshalBERTLV() error = %v", err)
+	}
+	versions := map[string]VersionType{
+		"2.1.0": info.ProfileVersion,
+		"2.2.0": info.SVN,
+		"4.1.0": info.EUICCFirmwareVersion,
+		"9.2.0": info.TS102241Version,
+		"2.3.0": info.GlobalPlatformVersion,
+		"0.2.1": info.PPVersion,
+	}
+	for want, version := range versions {
+		if got := version.String(); got != want {
+			t.Errorf("version = %q, want %q", got, want)
+		}
+	}
+	if want := (ExtCardResource{InstalledApplications: 6, FreeNonVolatileMemory: 232216, FreeVolatileMemory: 8464}); info.ExtCardResource != want {
+		t.Errorf("ExtCardResource = %+v, want %+v", info.ExtCardResource, want)
+	}
+	if want := (UICCCapability{USIMSupport: true, JavaCard: true}); info.UICCCapability != want {
+		t.Errorf("UICCCapability = %+v, want %+v", info.UICCCapability, want)
+	}
+	if want := (RSPCapability{AdditionalProfile: true, ServiceSpecificDataSupport: true}); info.RSPCapability != want {
+		t.Errorf("RSPCapability = %+v, want %+v", info.RSPCapability, want)
+	}
+	if want := [][]byte{pkid}; !reflect.DeepEqual(info.CIPKIDListForVerification, want) || !reflect.DeepEqual(info.CIPKIDListForSigning, want) {
+		t.Errorf("PKID lists = %X, %X", info.CIPKIDListForVerification, info.CIPKIDListForSigning)
+	}
+	if info.EUICCCategory != EUICCCategoryMedium {
+		t.Errorf("EUICCCategory = %v, want %v", info.EUICCCategory, EUICCCategoryMedium)
+	}
+	if want := (ProfilePolicyRules{DisablingNotAllowed: true}); info.ForbiddenProfilePolicyRules != want {
+		t.Errorf("ForbiddenProfilePolicyRules = %+v, want %+v", info.ForbiddenProfilePolicyRules, want)
+	}
+	if got, want := info.SASAccreditationNumber, "GI-BA-UP-0419"; got != want {
+		t.Errorf("SASAccreditationNumber = %q, want %q", got, want)
+	}
+	wantCertification := &CertificationDataObject{PlatformLabel: "example-platform", DiscoveryBaseURL: "https://ds.example.com"}
+	if !reflect.DeepEqual(info.CertificationDataObject, wantCertification) {
+		t.Errorf("CertificationDataObject = %+v, want %+v", info.CertificationDataObject, wantCertification)
+	}
+}
+
+func TestEUICCInfo2UnmarshalAllowsMissingOptionalFields(t *testing.T) {
+	info := new(EUICCInfo2)
+
+	if err := info.UnmarshalBERTLV(bertlv.NewChildren(bertlv.ContextSpecific.Constructed(34))); err != nil {
+		t.Fatalf("UnmarshalBERTLV() error = %v", err)
+	}
+	if info.TS102241Version != nil || info.CertificationDataObject != nil || info.EUICCCategory != EUICCCategoryOther {
+		t.Errorf("optional fields were populated: %+v", info)
+	}
+	if err := info.UnmarshalBERTLV(bertlv.NewChildren(bertlv.ContextSpecific.Constructed(32))); !errors.Is(err, ErrUnexpectedTag) {
+		t.Errorf("UnmarshalBERTLV() error = %v, want unexpected tag", err)
+	}
+}
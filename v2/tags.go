@@ -34,10 +34,19 @@ func (*SetDefaultDPAddressRequest) Tag() bertlv.Tag       { return []byte{0xBF,
 func (*SetDefaultDPAddressResponse) Tag() bertlv.Tag      { return []byte{0xBF, 0x3F} }
 func (*CancelSessionRequest) Tag() bertlv.Tag             { return []byte{0xBF, 0x41} }
 func (*ES9CancelSessionRequest) Tag() bertlv.Tag          { return []byte{0xBF, 0x41} }
+func (*GetRATRequest) Tag() bertlv.Tag                    { return []byte{0xBF, 0x43} }
+func (*GetRATResponse) Tag() bertlv.Tag                   { return []byte{0xBF, 0x43} }
 func (*ProfileInfo) Tag() bertlv.Tag                      { return []byte{0xE3} }
 
 // endregion
 
+// region Response Tags
+
+func (*EUICCInfo1) Tag() bertlv.Tag { return []byte{0xBF, 0x20} }
+func (*EUICCInfo2) Tag() bertlv.Tag { return []byte{0xBF, 0x22} }
+
+// endregion
+
 // region GetProfilesInfo Tags
 
 var (
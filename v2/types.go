@@ -1,6 +1,7 @@
 package sgp22
 
 import (
+	"context"
 	"errors"
 	"net/url"
 
@@ -8,8 +9,8 @@ import (
 )
 
 type Transmitter interface {
-	Transmit(bertlv.Marshaler, bertlv.Unmarshaler) error
-	TransmitRaw([]byte) ([]byte, error)
+	Transmit(context.Context, bertlv.Marshaler, bertlv.Unmarshaler) error
+	TransmitRaw(context.Context, []byte) ([]byte, error)
 }
 
 type CardRequest[R CardResponse] interface {
@@ -22,21 +23,21 @@ type CardResponse interface {
 	Valid() error
 }
 
-func InvokeAPDU[I CardRequest[O], O CardResponse](transmitter Transmitter, request I) (O, error) {
+func InvokeAPDU[I CardRequest[O], O CardResponse](ctx context.Context, transmitter Transmitter, request I) (O, error) {
 	response := request.CardResponse()
-	err := transmitter.Transmit(request, response)
+	err := transmitter.Transmit(ctx, request, response)
 	if err == nil {
 		err = response.Valid()
 	}
 	return response, err
 }
 
-func InvokeRawAPDU(transmitter Transmitter, command []byte) ([]byte, error) {
-	return transmitter.TransmitRaw(command)
+func InvokeRawAPDU(ctx context.Context, transmitter Transmitter, command []byte) ([]byte, error) {
+	return transmitter.TransmitRaw(ctx, command)
 }
 
 type HTTPClient interface {
-	SendRequest(url *url.URL, request, response any) error
+	SendRequest(ctx context.Context, url *url.URL, request, response any) error
 }
 
 type HTTPRequest[R HTTPResponse] interface {
@@ -48,9 +49,9 @@ type HTTPResponse interface {
 	FunctionExecutionStatus() *ExecutionStatus
 }
 
-func InvokeHTTP[I HTTPRequest[O], O HTTPResponse](client HTTPClient, address *url.URL, request I) (O, error) {
+func InvokeHTTP[I HTTPRequest[O], O HTTPResponse](ctx context.Context, client HTTPClient, address *url.URL, request I) (O, error) {
 	response := request.RemoteResponse()
-	if err := client.SendRequest(request.URL(address), request, response); err != nil {
+	if err := client.SendRequest(ctx, request.URL(address), request, response); err != nil {
 		return response, err
 	}
 	status := response.FunctionExecutionStatus()